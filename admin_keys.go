@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Admin Key Failover
+
+var activeKeyIndex = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "openai_exporter_active_key_index",
+		Help: "Position of the admin key the organization is collected with in its list of keys, starting at 0.",
+	},
+	[]string{"org_id"},
+)
+
+// keyRotation tracks which of the admin keys of an organization is active. Requests use the active key;
+// when the API rejects it with 401, as after it was rotated or revoked, the next key takes over.
+type keyRotation struct {
+	mu     sync.Mutex
+	active int
+}
+
+// splitKeys splits a list of admin keys separated by commas or newlines.
+func splitKeys(s string) []string {
+	return splitList(strings.ReplaceAll(s, "\n", ","))
+}
+
+// adminKeys returns the admin keys of the organization in failover order: from the key file, which is
+// re-read when it changes, or else from apiKey.
+func (e *Exporter) adminKeys() ([]string, error) {
+	raw := e.apiKey
+	if e.keyFile != nil {
+		var err error
+		if raw, err = e.keyFile.value(); err != nil {
+			return nil, err
+		}
+	}
+	keys := splitKeys(raw)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no admin key configured for organization %s", e.orgID)
+	}
+	return keys, nil
+}
+
+// activeKey returns the admin key requests are sent with.
+func (e *Exporter) activeKey() (string, error) {
+	keys, err := e.adminKeys()
+	if err != nil {
+		return "", err
+	}
+	e.keys.mu.Lock()
+	defer e.keys.mu.Unlock()
+	// The list may have shrunk since the key became active.
+	if e.keys.active >= len(keys) {
+		e.keys.active = 0
+	}
+	return keys[e.keys.active], nil
+}
+
+// failsOver reports whether a 401 from path means the admin key was revoked. The usage and cost endpoints
+// are readable by every admin key, while other endpoints may answer 401 only because the key lacks their
+// scope, which endpoint permissions handle instead.
+func failsOver(path string) bool {
+	endpoint := endpointLabel(path)
+	return endpoint == "costs" || strings.HasPrefix(endpoint, "usage/")
+}
+
+// rejectKey handles the rejection of key by the API. With rotate set, it fails over to the next admin key.
+// It reports whether the request should be sent again: another key is active that the request has not been
+// sent with yet, given that it was already rejected with tried keys.
+func (e *Exporter) rejectKey(key string, tried int, rotate bool) bool {
+	keys, err := e.adminKeys()
+	if err != nil || tried >= len(keys) {
+		return false
+	}
+	e.keys.mu.Lock()
+	defer e.keys.mu.Unlock()
+	if i := slices.Index(keys, key); rotate && i >= 0 && i == e.keys.active {
+		e.keys.active = (i + 1) % len(keys)
+		logrus.WithField("org_id", e.orgID).Warnf("Admin key %d of %d was rejected, failing over to key %d", i+1, len(keys), e.keys.active+1)
+		activeKeyIndex.WithLabelValues(e.orgID).Set(float64(e.keys.active))
+	}
+	// Another request may have failed over already; either way the active key is a different one.
+	return keys[e.keys.active] != key
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{name: "single key", in: "sk-admin-a", want: []string{"sk-admin-a"}},
+		{name: "comma-separated", in: "sk-admin-a, sk-admin-b", want: []string{"sk-admin-a", "sk-admin-b"}},
+		{name: "one per line", in: "sk-admin-a\nsk-admin-b\n", want: []string{"sk-admin-a", "sk-admin-b"}},
+		{name: "empty", in: " \n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, splitKeys(tt.in))
+		})
+	}
+}
+
+func TestAdminKeyFailover(t *testing.T) {
+	var keys []string
+	valid := "Bearer sk-admin-new"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") != valid {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error": {"message": "Incorrect API key provided", "type": "invalid_request_error"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"object": "list", "data": [], "has_more": false}`))
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "sk-admin-old,sk-admin-new", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+
+	resp, err := e.get("/v1/organization/usage/completions")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, []string{"Bearer sk-admin-old", "Bearer sk-admin-new"}, keys, "a rejected key fails over within the request")
+	assert.Equal(t, 1.0, testutil.ToFloat64(activeKeyIndex.WithLabelValues("org-1")))
+
+	keys = nil
+	resp, err = e.get("/v1/organization/usage/completions")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, []string{"Bearer sk-admin-new"}, keys, "later requests use the new key")
+
+	keys, valid = nil, "Bearer sk-admin-newer"
+	_, err = e.get("/v1/organization/usage/completions")
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	assert.Equal(t, []string{"Bearer sk-admin-new", "Bearer sk-admin-old"}, keys, "every key is tried once")
+}
+
+func TestAdminKeyFailover_OptionalEndpoints(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Authorization"))
+		if r.URL.Path == "/v1/organization/audit_logs" || r.Header.Get("Authorization") != "Bearer sk-admin-new" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error": {"message": "Missing scopes: api.audit_logs.read", "type": "invalid_request_error"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"object": "list", "data": [], "has_more": false}`))
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "sk-admin-new,sk-admin-next", orgID: "org-2", orgName: "prod", targets: newAPITargets("org-2", server.URL, 3)}
+	activeKeyIndex.WithLabelValues("org-2").Set(0)
+
+	_, err := e.get("/v1/organization/audit_logs")
+	require.Error(t, err)
+	assert.Equal(t, []string{"Bearer sk-admin-new"}, keys, "a key lacking the scope of an optional endpoint is not rejected")
+	assert.Equal(t, 0.0, testutil.ToFloat64(activeKeyIndex.WithLabelValues("org-2")))
+
+	keys = nil
+	resp, err := e.get("/v1/organization/costs")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, []string{"Bearer sk-admin-new"}, keys)
+}
+
+func TestAdminKeysFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	require.NoError(t, os.WriteFile(path, []byte("sk-admin-a\nsk-admin-b\n"), 0o600))
+	keyFile, err := newSecretFile(path)
+	require.NoError(t, err)
+	e := &Exporter{keyFile: keyFile, orgID: "org-1"}
+
+	e.keys.active = 1
+	key, err := e.activeKey()
+	require.NoError(t, err)
+	assert.Equal(t, "sk-admin-b", key)
+
+	require.NoError(t, os.WriteFile(path, []byte("sk-admin-c\n"), 0o600))
+	key, err = e.activeKey()
+	require.NoError(t, err)
+	assert.Equal(t, "sk-admin-c", key, "the first key becomes active when the list shrinks")
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Usage Anomaly Detection
+
+var (
+	anomalyEnabled  = flag.Bool("anomaly.enabled", false, "Keep a rolling baseline of the token rate per project and model and export openai_usage_anomaly_score and openai_usage_anomaly")
+	anomalyFactor   = flag.Float64("anomaly.factor", 3, "How many times its baseline the token rate of a project and model must reach to be flagged as an anomaly")
+	anomalyBaseline = flag.Duration("anomaly.baseline", 24*time.Hour, "Time constant of the rolling baseline; longer baselines adapt more slowly to lasting changes in usage")
+	anomalyMinRate  = flag.Float64("anomaly.min-rate", 1000, "Tokens per minute below which usage is never flagged, so idle projects do not alert on their first requests")
+
+	anomalyScore = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_usage_anomaly_score",
+			Help: "Token rate of the last collection cycle divided by its rolling baseline (or anomaly.min-rate, if higher)",
+		},
+		costLabelNames,
+	)
+	anomalyDetected = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_usage_anomaly",
+			Help: "Whether the token rate of the last collection cycle reached anomaly.factor times its baseline (1) or not (0)",
+		},
+		costLabelNames,
+	)
+
+	anomalyMu sync.Mutex
+	// anomalySeries holds the rolling baseline per project and model, keyed like cacheTokens.
+	anomalySeries = make(map[string]*anomalyTracker)
+	// anomalyElapsed is the time collected since the last evaluation, guarded by anomalyMu.
+	anomalyElapsed time.Duration
+)
+
+// anomalyWarmupCycles is the number of cycles a series must have been observed before it can be flagged,
+// so a baseline that has seen a single cycle does not raise alerts.
+const anomalyWarmupCycles = 10
+
+// anomalyTracker tracks the tokens counted for one project and model.
+type anomalyTracker struct {
+	labels prometheus.Labels
+	// pending are the tokens counted since the last evaluation.
+	pending float64
+	// rate is the exponentially weighted moving average of the tokens per minute.
+	rate   float64
+	cycles int
+	// detected is whether the last cycle was flagged, so only the start of an anomaly is notified.
+	detected bool
+}
+
+// validateAnomaly checks the anomaly detection flags.
+func validateAnomaly() error {
+	if *anomalyFactor <= 1 {
+		return fmt.Errorf("anomaly.factor must be greater than 1, got %g", *anomalyFactor)
+	}
+	if *anomalyBaseline <= 0 {
+		return fmt.Errorf("anomaly.baseline must be positive, got %s", *anomalyBaseline)
+	}
+	if *anomalyMinRate <= 0 {
+		return fmt.Errorf("anomaly.min-rate must be positive, got %g", *anomalyMinRate)
+	}
+	return nil
+}
+
+// recordAnomalyUsage adds the input and output tokens just counted for a usage result to the tokens of
+// the current cycle.
+func recordAnomalyUsage(labels prometheus.Labels, added map[string]float64) {
+	tokens := added["input"] + added["output"]
+	if !*anomalyEnabled || tokens <= 0 {
+		return
+	}
+	series := costLabels(labels)
+	parts := make([]string, len(costLabelNames))
+	for i, name := range costLabelNames {
+		parts[i] = series[name]
+	}
+	key := strings.Join(parts, "|")
+
+	anomalyMu.Lock()
+	defer anomalyMu.Unlock()
+	b, ok := anomalySeries[key]
+	if !ok {
+		b = &anomalyTracker{labels: series}
+		anomalySeries[key] = b
+	}
+	b.pending += tokens
+}
+
+// evaluateAnomalies scores the token rate of every series over the cycle that just collected window,
+// then folds it into the baseline. Series without usage in the cycle score 0 and let their baseline decay.
+// With buckets wider than the cycles, usage only arrives once a bucket is complete, so cycles are evaluated
+// together once they span a bucket.
+func evaluateAnomalies(window time.Duration) {
+	if !*anomalyEnabled || window <= 0 {
+		return
+	}
+
+	var notifications []notification
+	anomalyMu.Lock()
+	anomalyElapsed += window
+	if anomalyElapsed < bucketDuration() {
+		anomalyMu.Unlock()
+		return
+	}
+	window, anomalyElapsed = anomalyElapsed, 0
+	alpha := min(float64(window)/float64(*anomalyBaseline), 1)
+	for _, b := range anomalySeries {
+		rate := b.pending / window.Minutes()
+		b.pending = 0
+
+		if b.cycles == 0 {
+			b.rate = rate
+		}
+		score := rate / max(b.rate, *anomalyMinRate)
+		anomalyScore.With(b.labels).Set(score)
+		detected := b.cycles >= anomalyWarmupCycles && score >= *anomalyFactor
+		if detected {
+			anomalyDetected.With(b.labels).Set(1)
+		} else {
+			anomalyDetected.With(b.labels).Set(0)
+		}
+		if detected && !b.detected {
+			notifications = append(notifications, anomalyNotification(b.labels, score))
+		}
+		b.detected = detected
+
+		b.rate += alpha * (rate - b.rate)
+		b.cycles++
+	}
+	anomalyMu.Unlock()
+
+	sendNotifications(notifications)
+}
+
+// discardAnomalyUsage drops the tokens counted since the last evaluation, e.g. by a backfill, which
+// reports past usage rather than the current rate.
+func discardAnomalyUsage() {
+	anomalyMu.Lock()
+	defer anomalyMu.Unlock()
+	for _, b := range anomalySeries {
+		b.pending = 0
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAnomaly(t *testing.T) {
+	tests := []struct {
+		name    string
+		factor  float64
+		minRate float64
+		wantErr string
+	}{
+		{name: "defaults", factor: 3, minRate: 1000},
+		{name: "factor of one", factor: 1, minRate: 1000, wantErr: "anomaly.factor must be greater than 1"},
+		{name: "zero minimum rate", factor: 3, minRate: 0, wantErr: "anomaly.min-rate must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origFactor, origMinRate := *anomalyFactor, *anomalyMinRate
+			defer func() { *anomalyFactor, *anomalyMinRate = origFactor, origMinRate }()
+			*anomalyFactor, *anomalyMinRate = tt.factor, tt.minRate
+
+			err := validateAnomaly()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestEvaluateAnomalies(t *testing.T) {
+	anomalySeries = make(map[string]*anomalyTracker)
+	anomalyScore.Reset()
+	anomalyDetected.Reset()
+	*anomalyEnabled = true
+	defer func() { *anomalyEnabled = false }()
+
+	labels := prometheus.Labels{"org_id": "org-1", "org_name": "prod", "project_id": "proj-1", "project_name": "one", "model": "gpt-4o", "user_id": "user-1"}
+	score := func() float64 {
+		return testutil.ToFloat64(anomalyScore.WithLabelValues("org-1", "prod", "proj-1", "one", "gpt-4o"))
+	}
+	detected := func() float64 {
+		return testutil.ToFloat64(anomalyDetected.WithLabelValues("org-1", "prod", "proj-1", "one", "gpt-4o"))
+	}
+
+	for range anomalyWarmupCycles {
+		recordAnomalyUsage(labels, map[string]float64{"input": 8000, "output": 2000, "input_cached": 5000})
+		evaluateAnomalies(time.Minute)
+	}
+	assert.InDelta(t, 1, score(), 1e-9, "input and output tokens at the baseline rate")
+	assert.Equal(t, 0.0, detected())
+
+	recordAnomalyUsage(labels, map[string]float64{"input": 40000})
+	evaluateAnomalies(time.Minute)
+	assert.InDelta(t, 4, score(), 1e-9)
+	assert.Equal(t, 1.0, detected(), "four times the baseline reaches the factor")
+
+	evaluateAnomalies(time.Minute)
+	assert.Equal(t, 0.0, score(), "cycles without usage score 0")
+	assert.Equal(t, 0.0, detected())
+
+	recordAnomalyUsage(labels, map[string]float64{"input": 1e6})
+	discardAnomalyUsage()
+	evaluateAnomalies(time.Minute)
+	assert.Equal(t, 0.0, score(), "discarded usage is not scored")
+}
+
+func TestEvaluateAnomalies_MinRate(t *testing.T) {
+	anomalySeries = make(map[string]*anomalyTracker)
+	anomalyDetected.Reset()
+	*anomalyEnabled = true
+	defer func() { *anomalyEnabled = false }()
+
+	labels := prometheus.Labels{"org_id": "org-1", "org_name": "prod", "project_id": "proj-2", "project_name": "two", "model": "gpt-4o"}
+	for range anomalyWarmupCycles {
+		recordAnomalyUsage(labels, map[string]float64{"input": 10})
+		evaluateAnomalies(time.Minute)
+	}
+	recordAnomalyUsage(labels, map[string]float64{"input": 2000})
+	evaluateAnomalies(time.Minute)
+	assert.Equal(t, 0.0, testutil.ToFloat64(anomalyDetected.WithLabelValues("org-1", "prod", "proj-2", "two", "gpt-4o")),
+		"rates are compared to anomaly.min-rate while the baseline is below it")
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// API Key Inventory
+
+var (
+	apiKeysEnabled  = flag.Bool("collector.api-keys", false, "Export the owner and last use of every API key of every active project")
+	apiKeysInterval = flag.Duration("collector.api-keys.interval", 15*time.Minute, "Interval at which the API keys are re-read")
+
+	apiKeyInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_api_key_info",
+			Help: "API keys of the organization's projects with their owner; always 1.",
+		},
+		[]string{"org_id", "org_name", "project_id", "project_name", "api_key_id", "name", "owner", "owner_type"},
+	)
+	apiKeyLastUsed = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_api_key_last_used_timestamp_seconds",
+			Help: "Unix time at which an API key was last used; missing for keys that were never used.",
+		},
+		[]string{"org_id", "org_name", "project_id", "project_name", "api_key_id"},
+	)
+)
+
+type APIKeyList struct {
+	Object  string   `json:"object"`
+	Data    []APIKey `json:"data"`
+	HasMore bool     `json:"has_more"`
+	LastID  string   `json:"last_id"`
+}
+
+// APIKeyOwner is the user or service account that owns a project API key.
+type APIKeyOwner struct {
+	Type           string            `json:"type"`
+	User           *OrganizationUser `json:"user"`
+	ServiceAccount *ServiceAccount   `json:"service_account"`
+}
+
+// name returns the email of owning users, falling back to their name, or the name of owning service accounts.
+func (o *APIKeyOwner) name() string {
+	switch {
+	case o == nil:
+		return ""
+	case o.User != nil && o.User.Email != "":
+		return o.User.Email
+	case o.User != nil:
+		return o.User.Name
+	case o.ServiceAccount != nil:
+		return o.ServiceAccount.Name
+	}
+	return ""
+}
+
+// privateKeyLabels returns the ID, name and owner of a key as they are exported. The key and its owning user
+// are passed through the user filter and the privacy settings like the labels of usage, so a hashed, dropped
+// or filtered-out user is not exported as the owner of a key.
+func privateKeyLabels(k APIKey) (id, name, owner string) {
+	if k.Owner == nil || k.Owner.User == nil {
+		labels := privateLabels(prometheus.Labels{"api_key_id": k.ID, "api_key_name": k.displayName()})
+		return labels["api_key_id"], labels["api_key_name"], k.Owner.name()
+	}
+	email := ""
+	if *resolveUsers {
+		email = k.Owner.name()
+	}
+	labels := privateLabels(filterLabels(prometheus.Labels{
+		"api_key_id": k.ID, "api_key_name": k.displayName(), "user_id": k.Owner.User.ID, "user_email": email,
+	}))
+	switch {
+	case *dropUserIDs:
+		owner = ""
+	case labels["user_email"] != "":
+		owner = labels["user_email"]
+	default:
+		owner = labels["user_id"]
+	}
+	return labels["api_key_id"], labels["api_key_name"], owner
+}
+
+// collectAPIKeys lists the API keys of all active projects and replaces the exported ones of the organization.
+// The previous ones stay exported if any project cannot be read. The key names refresh the API key name cache.
+func (e *Exporter) collectAPIKeys() error {
+	projects, err := e.fetchProjects()
+	if err != nil {
+		return err
+	}
+
+	type projectKey struct {
+		project ProjectInfo
+		key     APIKey
+	}
+	var keys []projectKey
+	for _, p := range projects {
+		if p.Status == "archived" || !exportsProject(p.ID, func() string { return p.Name }) {
+			continue
+		}
+		path := fmt.Sprintf("projects/%s/api_keys", url.PathEscape(p.ID))
+		err := e.listPages(path, "api_keys", func(d *json.Decoder) (string, bool, error) {
+			var out APIKeyList
+			err := d.Decode(&out)
+			for _, k := range out.Data {
+				keys = append(keys, projectKey{p, k})
+			}
+			return out.LastID, out.HasMore, err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	e.rememberProjectNames(projects, time.Now())
+	apiKeyInfo.DeletePartialMatch(prometheus.Labels{"org_id": e.orgID})
+	apiKeyLastUsed.DeletePartialMatch(prometheus.Labels{"org_id": e.orgID})
+	for _, pk := range keys {
+		ownerType := ""
+		if pk.key.Owner != nil {
+			ownerType = pk.key.Owner.Type
+		}
+		id, name, owner := privateKeyLabels(pk.key)
+		apiKeyInfo.WithLabelValues(e.orgID, e.orgName, pk.project.ID, pk.project.Name, id, name, owner, ownerType).Set(1)
+		if pk.key.LastUsedAt != nil {
+			apiKeyLastUsed.WithLabelValues(e.orgID, e.orgName, pk.project.ID, pk.project.Name, id).Set(float64(*pk.key.LastUsedAt))
+		}
+		if pk.key.displayName() != "" {
+			apiKeyNames[pk.key.ID] = pk.key.displayName()
+		}
+	}
+	return nil
+}
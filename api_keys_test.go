@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectAPIKeys(t *testing.T) {
+	setResolveUsers(t)
+	projectNames = make(map[string]string)
+	apiKeyNames = make(map[string]string)
+	apiKeyInfo.Reset()
+	apiKeyLastUsed.Reset()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/organization/projects":
+			_, _ = w.Write([]byte(`{"object": "list", "data": [{"id": "proj-1", "name": "one", "status": "active"}], "has_more": false}`))
+		case "/v1/organization/projects/proj-1/api_keys":
+			_, _ = w.Write([]byte(`{"object": "list", "data": [
+				{"id": "key-1", "name": "backend", "redacted_value": "sk-...abc", "last_used_at": 1720000000,
+				 "owner": {"type": "user", "user": {"id": "user-1", "name": "Ada", "email": "ada@example.com"}}},
+				{"id": "key-2", "name": "", "redacted_value": "sk-...xyz", "last_used_at": null,
+				 "owner": {"type": "service_account", "service_account": {"id": "svc_acct_1", "name": "ci"}}}
+			], "has_more": false}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+
+	require.NoError(t, e.collectAPIKeys())
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(apiKeyInfo.WithLabelValues("org-1", "prod", "proj-1", "one", "key-1", "backend", "ada@example.com", "user")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(apiKeyInfo.WithLabelValues("org-1", "prod", "proj-1", "one", "key-2", "sk-...xyz", "ci", "service_account")))
+	assert.Equal(t, 1720000000.0, testutil.ToFloat64(apiKeyLastUsed.WithLabelValues("org-1", "prod", "proj-1", "one", "key-1")))
+	assert.Equal(t, 1, testutil.CollectAndCount(apiKeyLastUsed), "keys that were never used have no last use")
+	assert.Equal(t, map[string]string{"key-1": "backend", "key-2": "sk-...xyz"}, apiKeyNames)
+}
+
+func TestCollectAPIKeys_Privacy(t *testing.T) {
+	setResolveUsers(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/organization/projects":
+			_, _ = w.Write([]byte(`{"object": "list", "data": [{"id": "proj-1", "name": "one", "status": "active"}], "has_more": false}`))
+		case "/v1/organization/projects/proj-1/api_keys":
+			_, _ = w.Write([]byte(`{"object": "list", "data": [
+				{"id": "key-1", "name": "backend", "last_used_at": 1720000000,
+				 "owner": {"type": "user", "user": {"id": "user-1", "name": "Ada", "email": "ada@example.com"}}},
+				{"id": "key-2", "name": "deploy", "last_used_at": 1720000000,
+				 "owner": {"type": "service_account", "service_account": {"id": "svc_acct_1", "name": "ci"}}}
+			], "has_more": false}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+
+	type key struct{ id, name, owner, ownerType string }
+	tests := []struct {
+		name                           string
+		hashUsers, hashKeys, dropUsers bool
+		want                           []key // IDs and owners that are hashed are listed unhashed
+	}{
+		{name: "hash user IDs", hashUsers: true, want: []key{{"key-1", "backend", "user-1", "user"}, {"key-2", "deploy", "ci", "service_account"}}},
+		{name: "hash API key IDs", hashKeys: true, want: []key{{"key-1", "", "ada@example.com", "user"}, {"key-2", "", "ci", "service_account"}}},
+		{name: "drop user IDs", dropUsers: true, want: []key{{"key-1", "backend", "", "user"}, {"key-2", "deploy", "ci", "service_account"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiKeyInfo.Reset()
+			apiKeyLastUsed.Reset()
+			setPrivacy(t, tt.hashUsers, tt.hashKeys, tt.dropUsers)
+			require.NoError(t, configurePrivacy())
+
+			require.NoError(t, e.collectAPIKeys())
+			for _, k := range tt.want {
+				if tt.hashKeys {
+					k.id = pseudonym(k.id)
+				}
+				if tt.hashUsers && k.ownerType == "user" {
+					k.owner = pseudonym(k.owner)
+				}
+				assert.Equal(t, 1.0, testutil.ToFloat64(apiKeyInfo.WithLabelValues("org-1", "prod", "proj-1", "one", k.id, k.name, k.owner, k.ownerType)))
+				assert.Equal(t, 1720000000.0, testutil.ToFloat64(apiKeyLastUsed.WithLabelValues("org-1", "prod", "proj-1", "one", k.id)))
+			}
+			assert.Equal(t, 2, testutil.CollectAndCount(apiKeyInfo), "no series carry the raw identifiers")
+		})
+	}
+}
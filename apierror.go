@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// API Errors and Request Accounting
+
+var httpRequestsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "openai_api_http_requests_total",
+		Help: "Total number of requests made to the OpenAI API by endpoint and status code class (2xx, 4xx, 5xx, or error for transport failures).",
+	},
+	[]string{"endpoint", "code"},
+)
+
+// APIError is a response of the OpenAI API with a non-2xx status code.
+type APIError struct {
+	StatusCode int
+	Endpoint   string
+	// RequestID is the x-request-id of the response, which OpenAI support needs to look into a failure.
+	RequestID string
+	Type      string `json:"type"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("OpenAI API returned status %d for %s", e.StatusCode, e.Endpoint)
+	if e.Type != "" {
+		msg += " (" + e.Type + ")"
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.RequestID != "" {
+		msg += " (request ID " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// newAPIError reads and closes the body of a failed response and returns the error it describes.
+func newAPIError(path string, resp *http.Response) *APIError {
+	defer func() { _ = resp.Body.Close() }()
+
+	apiErr := &APIError{StatusCode: resp.StatusCode, Endpoint: endpointLabel(path), RequestID: resp.Header.Get("X-Request-Id")}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	var payload struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err == nil && len(payload.Error) > 0 {
+		// The error is usually an object, but some gateways return a plain string.
+		if err := json.Unmarshal(payload.Error, apiErr); err != nil {
+			_ = json.Unmarshal(payload.Error, &apiErr.Message)
+		}
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+
+	entry := logrus.WithFields(logrus.Fields{
+		"status":     resp.StatusCode,
+		"type":       apiErr.Type,
+		"code":       apiErr.Code,
+		"request_id": apiErr.RequestID,
+	})
+	// Missing objects are expected while resolving names, so they are not worth a warning.
+	if resp.StatusCode == http.StatusNotFound {
+		entry.Debugf("OpenAI API error for %s: %s", path, apiErr.Message)
+	} else {
+		entry.Warnf("OpenAI API error for %s: %s", path, apiErr.Message)
+	}
+	return apiErr
+}
+
+// idCollections are the path segments that are followed by the ID of one of their objects.
+var idCollections = map[string]bool{
+	"projects":         true,
+	"api_keys":         true,
+	"admin_api_keys":   true,
+	"users":            true,
+	"invites":          true,
+	"service_accounts": true,
+	"files":            true,
+	"models":           true,
+	"assistants":       true,
+	"vector_stores":    true,
+	"jobs":             true,
+}
+
+// endpointLabel turns a request path into a low-cardinality endpoint name by dropping the query,
+// the /v1/organization or /v1 prefix and object IDs, e.g. projects/:id/api_keys/:id or fine_tuning/jobs.
+func endpointLabel(path string) string {
+	path, _, _ = strings.Cut(path, "?")
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "/v1/"), "organization/")
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 1; i < len(segments); i++ {
+		if idCollections[segments[i-1]] {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// statusClass returns the code label of a request outcome.
+func statusClass(resp *http.Response, err error) string {
+	if err != nil {
+		return "error"
+	}
+	return fmt.Sprintf("%dxx", resp.StatusCode/100)
+}
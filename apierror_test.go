@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantType    string
+		wantCode    string
+		wantMessage string
+	}{
+		{
+			name:        "OpenAI error object",
+			status:      http.StatusUnauthorized,
+			body:        `{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","code":"invalid_api_key"}}`,
+			wantType:    "invalid_request_error",
+			wantCode:    "invalid_api_key",
+			wantMessage: "Incorrect API key provided",
+		},
+		{
+			name:        "error as string",
+			status:      http.StatusForbidden,
+			body:        `{"error":"insufficient permissions"}`,
+			wantMessage: "insufficient permissions",
+		},
+		{
+			name:        "plain text body",
+			status:      http.StatusBadGateway,
+			body:        "upstream unavailable\n",
+			wantMessage: "upstream unavailable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Body: io.NopCloser(strings.NewReader(tt.body))}
+			err := newAPIError("/v1/organization/costs?start_time=1", resp)
+
+			assert.Equal(t, tt.status, err.StatusCode)
+			assert.Equal(t, "costs", err.Endpoint)
+			assert.Equal(t, tt.wantType, err.Type)
+			assert.Equal(t, tt.wantCode, err.Code)
+			assert.Equal(t, tt.wantMessage, err.Message)
+			assert.Contains(t, err.Error(), tt.wantMessage)
+		})
+	}
+}
+
+func TestEndpointLabel(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/v1/organization/usage/completions?start_time=1&page=abc", want: "usage/completions"},
+		{path: "/v1/organization/costs?start_time=1", want: "costs"},
+		{path: "/v1/organization/projects/proj_abc", want: "projects/:id"},
+		{path: "/v1/organization/projects/proj_abc/api_keys/key_xyz", want: "projects/:id/api_keys/:id"},
+		{path: "/v1/organization/api_keys/key_xyz", want: "api_keys/:id"},
+		{path: "/v1/organization/audit_logs?limit=100", want: "audit_logs"},
+		{path: "/v1/organization/admin_api_keys/key_xyz", want: "admin_api_keys/:id"},
+		{path: "/v1/organization/users/user-abc", want: "users/:id"},
+		{path: "/v1/organization/projects/proj_abc/rate_limits?limit=100", want: "projects/:id/rate_limits"},
+		{path: "/v1/organization/projects/proj_abc/service_accounts", want: "projects/:id/service_accounts"},
+		{path: "/v1/files?limit=10000", want: "files"},
+		{path: "/v1/models", want: "models"},
+		{path: "/v1/assistants?limit=100", want: "assistants"},
+		{path: "/v1/vector_stores?limit=100", want: "vector_stores"},
+		{path: "/v1/fine_tuning/jobs?limit=100", want: "fine_tuning/jobs"},
+		{path: "/v1/fine_tuning/jobs/ftjob-abc", want: "fine_tuning/jobs/:id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, tt.want, endpointLabel(tt.path))
+		})
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	assert.Equal(t, "error", statusClass(nil, errors.New("timeout")))
+	assert.Equal(t, "2xx", statusClass(&http.Response{StatusCode: http.StatusOK}, nil))
+	assert.Equal(t, "4xx", statusClass(&http.Response{StatusCode: http.StatusTooManyRequests}, nil))
+}
+
+func TestExporterGet_StatusCodes(t *testing.T) {
+	httpRequestsTotal.Reset()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","code":"invalid_api_key"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"object":"page","data":[],"has_more":false}`))
+	}))
+	defer server.Close()
+
+	e := &Exporter{client: server.Client(), apiKey: "bad", targets: newAPITargets("", server.URL, 3)}
+	err := e.fetchCostData(1000, 2000)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Incorrect API key provided")
+	assert.Equal(t, 1.0, testutil.ToFloat64(httpRequestsTotal.WithLabelValues("costs", "4xx")))
+
+	e.apiKey = "good"
+	require.NoError(t, e.fetchCostData(1000, 2000))
+	assert.Equal(t, 1.0, testutil.ToFloat64(httpRequestsTotal.WithLabelValues("costs", "2xx")))
+}
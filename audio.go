@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Audio Usage
+
+var (
+	audioSecondsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "openai_api_audio_seconds_total",
+			Help: "Total seconds of audio transcribed per organization, project and model",
+		},
+		[]string{"org_id", "org_name", "project_id", "project_name", "model"},
+	)
+	ttsCharactersTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "openai_api_tts_characters_total",
+			Help: "Total number of characters converted to speech per organization, project and model",
+		},
+		[]string{"org_id", "org_name", "project_id", "project_name", "model"},
+	)
+)
+
+// audioUnit is the natural unit an audio endpoint is billed in.
+type audioUnit struct {
+	counter *prometheus.CounterVec
+	// name prefixes the usageState keys of the unit.
+	name  string
+	value func(UsageResult) int64
+}
+
+// audioUnits maps the audio endpoints to their unit.
+var audioUnits = map[string]audioUnit{
+	"audio_transcriptions": {audioSecondsTotal, "audio_seconds_total", func(r UsageResult) int64 { return r.Seconds }},
+	"audio_speeches":       {ttsCharactersTotal, "tts_characters_total", func(r UsageResult) int64 { return r.Characters }},
+}
+
+// countAudio counts the seconds or characters of the buckets of an audio endpoint. Results split by
+// dimensions the counters do not have, like users, are summed per bucket first.
+func (e *Exporter) countAudio(endpoint UsageEndpoint, buckets []Bucket) {
+	unit, ok := audioUnits[endpoint.Name]
+	if !ok {
+		return
+	}
+	for _, bucket := range buckets {
+		totals := make(map[string]int64)
+		series := make(map[string]prometheus.Labels)
+		for _, result := range bucket.Results {
+			key := strings.Join([]string{
+				unit.name,
+				fmt.Sprintf("%d", bucket.StartTime),
+				deref(result.ProjectID),
+				deref(result.Model),
+				e.orgID,
+			}, "|")
+			if _, ok := series[key]; !ok {
+				series[key] = prometheus.Labels{
+					"org_id":       e.orgID,
+					"org_name":     e.orgName,
+					"project_id":   deref(result.ProjectID),
+					"project_name": e.ensureProjectName(deref(result.ProjectID)),
+					"model":        deref(result.Model),
+				}
+			}
+			totals[key] += unit.value(result)
+		}
+		for _, key := range sortedKeys(totals) {
+			updateCounter(unit.counter, key, series[key], bucket.EndTime, float64(totals[key]))
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchUsageData_Audio(t *testing.T) {
+	usageState = make(map[string]float64)
+	projectNames = map[string]string{"proj-1": "one"}
+	audioSecondsTotal.Reset()
+	ttsCharactersTotal.Reset()
+
+	end := time.Now().Add(-time.Minute).Truncate(time.Minute).Unix()
+	bucket := `"start_time": ` + strconv.FormatInt(end-60, 10) + `, "end_time": ` + strconv.FormatInt(end, 10)
+	responses := map[string]string{
+		"/v1/organization/usage/audio_transcriptions": `[
+			{"seconds": 30, "project_id": "proj-1", "user_id": "user-1", "model": "whisper-1"},
+			{"seconds": 12, "project_id": "proj-1", "user_id": "user-2", "model": "whisper-1"}
+		]`,
+		"/v1/organization/usage/audio_speeches": `[
+			{"characters": 1500, "project_id": "proj-1", "model": "tts-1"}
+		]`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"object": "page", "data": [{` + bucket + `, "results": ` + responses[r.URL.Path] + `}], "has_more": false}`))
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+
+	for range 2 {
+		require.NoError(t, e.fetchUsageData(UsageEndpoint{Path: "audio_transcriptions", Name: "audio_transcriptions"}, end-60, end))
+		require.NoError(t, e.fetchUsageData(UsageEndpoint{Path: "audio_speeches", Name: "audio_speeches"}, end-60, end))
+	}
+
+	assert.Equal(t, 42.0, testutil.ToFloat64(audioSecondsTotal.WithLabelValues("org-1", "prod", "proj-1", "one", "whisper-1")),
+		"results of different users are summed and counted once")
+	assert.Equal(t, 1500.0, testutil.ToFloat64(ttsCharactersTotal.WithLabelValues("org-1", "prod", "proj-1", "one", "tts-1")))
+	assert.Equal(t, 1, testutil.CollectAndCount(audioSecondsTotal))
+	assert.Equal(t, 1, testutil.CollectAndCount(ttsCharactersTotal))
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Audit Logs and SIEM Forwarding
+
+var (
+	auditEnabled       = flag.Bool("collector.audit-logs", false, "Fetch the organization's audit log every cycle and count events by type")
+	auditForwardAddr   = flag.String("audit.forward.address", "", "Forward fetched audit log events as syslog messages to this address (udp://host:514 or tcp://host:601)")
+	auditForwardFormat = flag.String("audit.forward.format", "cef", "Message format of forwarded audit log events: cef or json")
+
+	auditEventsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "openai_audit_log_events_total",
+			Help: "Total number of audit log events fetched, by organization and event type.",
+		},
+		[]string{"org_id", "org_name", "type"},
+	)
+	auditForwardErrors = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "openai_exporter_audit_forward_errors_total",
+			Help: "Total number of audit log events that could not be forwarded to the SIEM.",
+		},
+	)
+
+	// auditCollected maps org_id -> end of the last window whose audit log events were collected, guarded by stateMu.
+	auditCollected = make(map[string]int64)
+)
+
+type AuditLogList struct {
+	Object  string            `json:"object"`
+	Data    []json.RawMessage `json:"data"`
+	HasMore bool              `json:"has_more"`
+	LastID  string            `json:"last_id"`
+}
+
+type AuditLogUser struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+}
+
+type AuditLogEvent struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	EffectiveAt int64  `json:"effective_at"`
+	Project     *struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"project"`
+	Actor struct {
+		Type    string `json:"type"`
+		Session *struct {
+			User      AuditLogUser `json:"user"`
+			IPAddress string       `json:"ip_address"`
+		} `json:"session"`
+		APIKey *struct {
+			ID   string        `json:"id"`
+			User *AuditLogUser `json:"user"`
+		} `json:"api_key"`
+	} `json:"actor"`
+
+	// Raw is the event exactly as returned by the API.
+	Raw json.RawMessage `json:"-"`
+}
+
+// actorUser returns the user behind the event, whether it acted through a session or an API key.
+func (ev AuditLogEvent) actorUser() AuditLogUser {
+	if ev.Actor.Session != nil {
+		return ev.Actor.Session.User
+	}
+	if ev.Actor.APIKey != nil && ev.Actor.APIKey.User != nil {
+		return *ev.Actor.APIKey.User
+	}
+	return AuditLogUser{}
+}
+
+// fetchAuditLogs returns the audit log events that took effect within [startTime, endTime).
+func (e *Exporter) fetchAuditLogs(startTime, endTime int64) ([]AuditLogEvent, error) {
+	var events []AuditLogEvent
+	after := ""
+	guard := newPageGuard(e.orgID, "audit_logs")
+
+	for {
+		path := fmt.Sprintf("/v1/organization/audit_logs?effective_at[gte]=%d&effective_at[lt]=%d&limit=100", startTime, endTime)
+		if after != "" {
+			path += "&after=" + url.QueryEscape(after)
+		}
+
+		logrus.Debugf("Fetching audit logs: %s", path)
+
+		resp, err := e.get(path)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching audit logs: %w", err)
+		}
+
+		var out AuditLogList
+		decodeErr := json.NewDecoder(resp.Body).Decode(&out)
+		closeErr := resp.Body.Close()
+
+		if decodeErr != nil {
+			return nil, fmt.Errorf("error decoding response: %w", decodeErr)
+		}
+		if closeErr != nil {
+			logrus.WithError(closeErr).Warn("failed to close response body")
+		}
+		pagesFetchedTotal.WithLabelValues(e.orgID, "audit_logs").Inc()
+
+		for _, raw := range out.Data {
+			var ev AuditLogEvent
+			if err := json.Unmarshal(raw, &ev); err != nil {
+				return nil, fmt.Errorf("error decoding audit log event: %w", err)
+			}
+			ev.Raw = raw
+			events = append(events, ev)
+		}
+
+		if !out.HasMore || out.LastID == "" || !guard.next(out.LastID) {
+			return events, nil
+		}
+		after = out.LastID
+	}
+}
+
+// collectAuditLogs counts the audit log events of one window and forwards them when a forwarder is configured.
+// Unlike usage buckets, events are never revised, so the window starts no earlier than the previous one ended:
+// the overlap of scrape.lookback would count and forward them again.
+func (e *Exporter) collectAuditLogs(startTime, endTime int64) error {
+	stateMu.RLock()
+	startTime = max(startTime, auditCollected[e.orgID])
+	stateMu.RUnlock()
+	if startTime >= endTime {
+		return nil
+	}
+	events, err := e.fetchAuditLogs(startTime, endTime)
+	if err != nil {
+		return err
+	}
+	stateMu.Lock()
+	auditCollected[e.orgID] = endTime
+	stateMu.Unlock()
+	for _, ev := range events {
+		auditEventsTotal.WithLabelValues(e.orgID, e.orgName, ev.Type).Inc()
+		if e.auditForwarder == nil {
+			continue
+		}
+		if err := e.auditForwarder.send(ev); err != nil {
+			logrus.WithError(err).Warnf("Error forwarding audit log event %s", ev.ID)
+			auditForwardErrors.Inc()
+		}
+	}
+	logrus.Infof("Total audit log events fetched: %d", len(events))
+	return nil
+}
+
+// syslogForwarder sends audit log events as RFC 5424 syslog messages carrying CEF or JSON.
+type syslogForwarder struct {
+	mu       sync.Mutex
+	network  string
+	address  string
+	format   string
+	hostname string
+	conn     net.Conn
+}
+
+func newSyslogForwarder(rawURL, format string) (*syslogForwarder, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid audit forward address %q: %w", rawURL, err)
+	}
+	if u.Scheme != "udp" && u.Scheme != "tcp" {
+		return nil, fmt.Errorf("unsupported audit forward scheme %q, use udp or tcp", u.Scheme)
+	}
+	if format != "cef" && format != "json" {
+		return nil, fmt.Errorf("unsupported audit forward format %q, use cef or json", format)
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "-"
+	}
+	return &syslogForwarder{network: u.Scheme, address: u.Host, format: format, hostname: hostname}, nil
+}
+
+// send writes one event, dialing (or redialing after a failure) as needed.
+func (f *syslogForwarder) send(ev AuditLogEvent) error {
+	msg := f.message(ev)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.conn == nil {
+		conn, err := net.DialTimeout(f.network, f.address, 5*time.Second)
+		if err != nil {
+			return fmt.Errorf("error connecting to %s: %w", f.address, err)
+		}
+		f.conn = conn
+	}
+	// TCP uses newline framing (RFC 6587 non-transparent framing); UDP sends one message per datagram.
+	if f.network == "tcp" {
+		msg += "\n"
+	}
+	if _, err := f.conn.Write([]byte(msg)); err != nil {
+		_ = f.conn.Close()
+		f.conn = nil
+		return fmt.Errorf("error writing to %s: %w", f.address, err)
+	}
+	return nil
+}
+
+// message formats ev as an RFC 5424 syslog message with facility local0.
+func (f *syslogForwarder) message(ev AuditLogEvent) string {
+	severity := cefSeverity(ev.Type)
+	// CEF severities 0-10 map onto syslog severities: 7+ is error, 5+ is warning, anything else notice.
+	syslogSeverity := 5
+	switch {
+	case severity >= 7:
+		syslogSeverity = 3
+	case severity >= 5:
+		syslogSeverity = 4
+	}
+	pri := 16*8 + syslogSeverity
+
+	var body string
+	if f.format == "cef" {
+		body = formatCEF(ev, severity)
+	} else {
+		// Syslog messages must be a single line.
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, ev.Raw); err != nil {
+			buf.Reset()
+			buf.Write(ev.Raw)
+		}
+		body = buf.String()
+	}
+	timestamp := time.Unix(ev.EffectiveAt, 0).UTC().Format(time.RFC3339)
+	return fmt.Sprintf("<%d>1 %s %s openai-exporter - %s - %s", pri, timestamp, f.hostname, ev.Type, body)
+}
+
+// cefSeverity rates an audit event type on the CEF 0-10 scale.
+func cefSeverity(eventType string) int {
+	switch {
+	case strings.HasSuffix(eventType, ".failed"), strings.HasSuffix(eventType, ".deleted"):
+		return 7
+	case strings.HasSuffix(eventType, ".created"), strings.HasSuffix(eventType, ".updated"):
+		return 5
+	default:
+		return 3
+	}
+}
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+// formatCEF renders ev in ArcSight Common Event Format.
+func formatCEF(ev AuditLogEvent, severity int) string {
+	ext := []string{
+		"rt=" + fmt.Sprint(ev.EffectiveAt*1000),
+		"externalId=" + cefExtensionEscaper.Replace(ev.ID),
+	}
+	if user := ev.actorUser(); user.ID != "" {
+		ext = append(ext, "suid="+cefExtensionEscaper.Replace(user.ID))
+		if user.Email != "" {
+			ext = append(ext, "suser="+cefExtensionEscaper.Replace(user.Email))
+		}
+	}
+	if ev.Actor.Session != nil && ev.Actor.Session.IPAddress != "" {
+		ext = append(ext, "src="+cefExtensionEscaper.Replace(ev.Actor.Session.IPAddress))
+	}
+	if ev.Actor.APIKey != nil {
+		ext = append(ext, "cs1Label=api_key_id", "cs1="+cefExtensionEscaper.Replace(ev.Actor.APIKey.ID))
+	}
+	if ev.Project != nil {
+		ext = append(ext, "cs2Label=project_id", "cs2="+cefExtensionEscaper.Replace(ev.Project.ID))
+	}
+	return fmt.Sprintf("CEF:0|OpenAI|Platform|v1|%s|%s|%d|%s",
+		cefHeaderEscaper.Replace(ev.Type), cefHeaderEscaper.Replace(ev.Type), severity, strings.Join(ext, " "))
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAuditEvent = `{"id": "audit_log-1", "type": "api_key.deleted", "effective_at": 1717200000,
+	"project": {"id": "proj-1", "name": "production"},
+	"actor": {"type": "session", "session": {"user": {"id": "user-1", "email": "a=b@example.com"}, "ip_address": "10.0.0.1"}}}`
+
+func TestFetchAuditLogs(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		if r.URL.Query().Get("after") == "" {
+			_, _ = w.Write([]byte(`{"object": "list", "data": [` + testAuditEvent + `], "has_more": true, "last_id": "audit_log-1"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"object": "list", "data": [{"id": "audit_log-2", "type": "login.succeeded", "effective_at": 1717200001}], "has_more": false}`))
+	}))
+	defer server.Close()
+
+	e := &Exporter{client: server.Client(), apiKey: "test", targets: newAPITargets("", server.URL, 3)}
+	events, err := e.fetchAuditLogs(1717200000, 1717200060)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "api_key.deleted", events[0].Type)
+	assert.Equal(t, "a=b@example.com", events[0].actorUser().Email)
+	assert.JSONEq(t, testAuditEvent, string(events[0].Raw))
+	assert.Contains(t, queries[0], "effective_at[gte]=1717200000&effective_at[lt]=1717200060")
+	assert.Contains(t, queries[1], "after=audit_log-1")
+}
+
+func TestFormatCEF(t *testing.T) {
+	ev := mustDecodeAuditEvent(t, testAuditEvent)
+	cef := formatCEF(ev, cefSeverity(ev.Type))
+	assert.Equal(t, `CEF:0|OpenAI|Platform|v1|api_key.deleted|api_key.deleted|7|rt=1717200000000 externalId=audit_log-1 suid=user-1 suser=a\=b@example.com src=10.0.0.1 cs2Label=project_id cs2=proj-1`, cef)
+}
+
+func TestCEFSeverity(t *testing.T) {
+	assert.Equal(t, 7, cefSeverity("login.failed"))
+	assert.Equal(t, 7, cefSeverity("project.deleted"))
+	assert.Equal(t, 5, cefSeverity("invite.created"))
+	assert.Equal(t, 3, cefSeverity("login.succeeded"))
+}
+
+func TestNewSyslogForwarder(t *testing.T) {
+	_, err := newSyslogForwarder("http://siem:514", "cef")
+	assert.Error(t, err)
+	_, err = newSyslogForwarder("udp://siem:514", "leef")
+	assert.Error(t, err)
+	f, err := newSyslogForwarder("tcp://siem:601", "json")
+	require.NoError(t, err)
+	assert.Equal(t, "siem:601", f.address)
+}
+
+func TestSyslogForwarder_TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+
+	lines := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	ev := mustDecodeAuditEvent(t, testAuditEvent)
+
+	f, err := newSyslogForwarder("tcp://"+ln.Addr().String(), "json")
+	require.NoError(t, err)
+	f.hostname = "host"
+	require.NoError(t, f.send(ev))
+
+	line := <-lines
+	assert.True(t, strings.HasPrefix(line, "<131>1 2024-06-01T00:00:00Z host openai-exporter - api_key.deleted - {"), line)
+	assert.Contains(t, line, `"ip_address":"10.0.0.1"`)
+}
+
+func TestCollectAuditLogs_CountsAndForwards(t *testing.T) {
+	auditEventsTotal.Reset()
+	auditCollected = make(map[string]int64)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"object": "list", "data": [` + testAuditEvent + `], "has_more": false}`))
+	}))
+	defer server.Close()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = pc.Close() }()
+
+	f, err := newSyslogForwarder("udp://"+pc.LocalAddr().String(), "cef")
+	require.NoError(t, err)
+
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("", server.URL, 3), auditForwarder: f}
+	require.NoError(t, e.collectAuditLogs(1717200000, 1717200060))
+	assert.Equal(t, 1.0, testutil.ToFloat64(auditEventsTotal.WithLabelValues("org-1", "prod", "api_key.deleted")))
+
+	buf := make([]byte, 2048)
+	n, _, err := pc.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Contains(t, string(buf[:n]), "CEF:0|OpenAI|Platform|v1|api_key.deleted")
+}
+
+func TestCollectAuditLogs_Lookback(t *testing.T) {
+	origEnabled, origLookback, origEndpoints, origLast := *auditEnabled, *scrapeLookback, activeEndpoints, lastScrape
+	*auditEnabled, *scrapeLookback, activeEndpoints = true, 10*time.Minute, nil
+	defer func() {
+		*auditEnabled, *scrapeLookback, activeEndpoints, lastScrape = origEnabled, origLookback, origEndpoints, origLast
+	}()
+	auditEventsTotal.Reset()
+	auditCollected = make(map[string]int64)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gte, _ := strconv.ParseInt(r.URL.Query().Get("effective_at[gte]"), 10, 64)
+		lt, _ := strconv.ParseInt(r.URL.Query().Get("effective_at[lt]"), 10, 64)
+		if r.URL.Path != "/v1/organization/audit_logs" || gte > 1717200000 || lt <= 1717200000 {
+			_, _ = w.Write([]byte(`{"object": "list", "data": [], "has_more": false}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"object": "list", "data": [` + testAuditEvent + `], "has_more": false}`))
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+
+	require.NoError(t, runCycle(e, 1717200000, 1717200060))
+	require.NoError(t, runCycle(e, 1717200060, 1717200120))
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(auditEventsTotal.WithLabelValues("org-1", "prod", "api_key.deleted")),
+		"events are not counted again when the lookback reaches back to them")
+}
+
+func mustDecodeAuditEvent(t *testing.T, raw string) AuditLogEvent {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"object": "list", "data": [` + raw + `], "has_more": false}`))
+	}))
+	defer server.Close()
+
+	e := &Exporter{client: server.Client(), apiKey: "test", targets: newAPITargets("", server.URL, 3)}
+	events, err := e.fetchAuditLogs(0, 1)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	return events[0]
+}
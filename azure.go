@@ -0,0 +1,514 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Azure OpenAI Provider
+
+const (
+	azureManagementURL = "https://management.azure.com"
+	azureLoginURL      = "https://login.microsoftonline.com"
+	azureScope         = "https://management.azure.com/.default"
+)
+
+var (
+	azureResources    = flag.String("azure.resources", "", "Comma-separated resource IDs of the Azure OpenAI accounts to collect when -provider=azure")
+	azureCostInterval = flag.Duration("azure.cost-interval", time.Hour, "Minimum time between two Cost Management queries, which Azure rate limits strictly (0 disables cost collection)")
+
+	azureDeploymentInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_azure_deployment_info",
+			Help: "Azure OpenAI deployments seen in Azure Monitor, with the resource group and region of their account.",
+		},
+		[]string{"org_id", "project_id", "api_key_id", "resource_group", "region", "model"},
+	)
+)
+
+// azureTokenMetrics maps the Azure Monitor metrics of Azure OpenAI accounts to token types.
+var azureTokenMetrics = map[string]string{
+	"ProcessedPromptTokens": "input",
+	"GeneratedTokens":       "output",
+}
+
+// azureResource is an Azure OpenAI (Cognitive Services) account.
+type azureResource struct {
+	ID             string
+	SubscriptionID string
+	ResourceGroup  string
+	Name           string
+}
+
+// parseAzureResourceID splits /subscriptions/<id>/resourceGroups/<group>/providers/Microsoft.CognitiveServices/accounts/<name>.
+func parseAzureResourceID(id string) (azureResource, error) {
+	parts := strings.Split(strings.Trim(id, "/"), "/")
+	if len(parts) != 8 || !strings.EqualFold(parts[0], "subscriptions") || !strings.EqualFold(parts[2], "resourceGroups") ||
+		!strings.EqualFold(parts[4], "providers") || !strings.EqualFold(parts[5], "Microsoft.CognitiveServices") ||
+		!strings.EqualFold(parts[6], "accounts") {
+		return azureResource{}, fmt.Errorf("invalid Azure OpenAI resource ID %q", id)
+	}
+	return azureResource{
+		ID:             "/" + strings.Join(parts, "/"),
+		SubscriptionID: parts[1],
+		ResourceGroup:  parts[3],
+		Name:           parts[7],
+	}, nil
+}
+
+// AzureExporter collects token usage from Azure Monitor and spend from Cost Management into the same metrics
+// as the OpenAI exporter. Subscriptions take the place of organizations, accounts the place of projects and
+// deployments the place of API keys.
+type AzureExporter struct {
+	client        *http.Client
+	managementURL string
+	loginURL      string
+	tenantID      string
+	clientID      string
+	clientSecret  string
+	resources     []azureResource
+	costInterval  time.Duration
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+	regions     map[string]string // resource ID -> region
+	lastCost    time.Time
+}
+
+type AzureToken struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+type AzureMetricsResponse struct {
+	Value []AzureMetric `json:"value"`
+}
+
+type AzureMetric struct {
+	Name       AzureName         `json:"name"`
+	Timeseries []AzureTimeseries `json:"timeseries"`
+}
+
+type AzureName struct {
+	Value string `json:"value"`
+}
+
+type AzureTimeseries struct {
+	Metadata []AzureMetadataValue `json:"metadatavalues"`
+	Data     []AzureDataPoint     `json:"data"`
+}
+
+type AzureMetadataValue struct {
+	Name  AzureName `json:"name"`
+	Value string    `json:"value"`
+}
+
+type AzureDataPoint struct {
+	TimeStamp time.Time `json:"timeStamp"`
+	Total     *float64  `json:"total"`
+}
+
+type AzureCostQueryResult struct {
+	Properties struct {
+		NextLink string `json:"nextLink"`
+		Columns  []struct {
+			Name string `json:"name"`
+		} `json:"columns"`
+		Rows [][]json.RawMessage `json:"rows"`
+	} `json:"properties"`
+}
+
+func NewAzureExporter() (*AzureExporter, error) {
+	client, err := newAPIClient(30 * time.Second)
+	if err != nil {
+		return nil, err
+	}
+	a := &AzureExporter{
+		client:        client,
+		managementURL: azureManagementURL,
+		loginURL:      azureLoginURL,
+		tenantID:      os.Getenv("AZURE_TENANT_ID"),
+		clientID:      os.Getenv("AZURE_CLIENT_ID"),
+		clientSecret:  os.Getenv("AZURE_CLIENT_SECRET"),
+		costInterval:  *azureCostInterval,
+		regions:       make(map[string]string),
+	}
+	for _, name := range []string{"AZURE_TENANT_ID", "AZURE_CLIENT_ID", "AZURE_CLIENT_SECRET"} {
+		if os.Getenv(name) == "" {
+			return nil, fmt.Errorf("%s environment variable is not set", name)
+		}
+	}
+	for _, id := range splitList(*azureResources) {
+		r, err := parseAzureResourceID(id)
+		if err != nil {
+			return nil, err
+		}
+		a.resources = append(a.resources, r)
+	}
+	if len(a.resources) == 0 {
+		return nil, fmt.Errorf("-azure.resources must list at least one Azure OpenAI resource")
+	}
+	return a, nil
+}
+
+// accessToken returns a Microsoft Entra ID token for Azure Resource Manager, fetching a new one shortly before
+// the current one expires.
+func (a *AzureExporter) accessToken() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token != "" && time.Now().Before(a.tokenExpiry) {
+		return a.token, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", a.clientID)
+	form.Set("client_secret", a.clientSecret)
+	form.Set("scope", azureScope)
+	req, err := http.NewRequestWithContext(requestCtx, http.MethodPost,
+		fmt.Sprintf("%s/%s/oauth2/v2.0/token", a.loginURL, url.PathEscape(a.tenantID)), strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error fetching Azure access token: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error fetching Azure access token: status %d", resp.StatusCode)
+	}
+	var tok AzureToken
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", fmt.Errorf("error decoding Azure access token: %w", err)
+	}
+	a.token = tok.AccessToken
+	a.tokenExpiry = time.Now().Add(time.Duration(tok.ExpiresIn)*time.Second - time.Minute)
+	return a.token, nil
+}
+
+// do sends an authenticated request to Azure Resource Manager and decodes the JSON response into out.
+func (a *AzureExporter) do(method, path string, body any, out any) error {
+	token, err := a.accessToken()
+	if err != nil {
+		return err
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("error encoding request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	u := path
+	if !strings.HasPrefix(u, "http") {
+		u = a.managementURL + path
+	}
+	req, err := http.NewRequestWithContext(requestCtx, method, u, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	logrus.Debugf("Fetching Azure data: %s %s", method, u)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Azure returned status %d for %s", resp.StatusCode, path)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
+
+// region returns the location of r, looking it up once.
+func (a *AzureExporter) region(r azureResource) string {
+	a.mu.Lock()
+	region, ok := a.regions[r.ID]
+	a.mu.Unlock()
+	if ok {
+		return region
+	}
+
+	var out struct {
+		Location string `json:"location"`
+	}
+	if err := a.do("GET", r.ID+"?api-version=2023-05-01", nil, &out); err != nil || out.Location == "" {
+		logrus.WithError(err).Debugf("Error looking up the region of %s", r.ID)
+		return "unknown"
+	}
+	a.mu.Lock()
+	a.regions[r.ID] = out.Location
+	a.mu.Unlock()
+	return out.Location
+}
+
+// collectWindow fetches the token metrics of all resources for one time window and, when due, the costs.
+func (a *AzureExporter) collectWindow(startTime, endTime int64) error {
+	var failed bool
+	for _, r := range a.resources {
+		err := a.fetchResourceUsage(r, startTime, endTime)
+		recordFetch(r.SubscriptionID, "azure_metrics", err)
+		if err != nil {
+			logrus.WithError(err).WithFields(windowFields(r.SubscriptionID, "azure_metrics", startTime, endTime)).WithField("resource", r.ID).Error("Error fetching Azure Monitor metrics")
+			failed = true
+		}
+	}
+
+	if a.costInterval > 0 && time.Since(a.lastCost) >= a.costInterval {
+		costFailed := false
+		for _, sub := range a.subscriptions() {
+			err := a.fetchCosts(sub, time.Unix(endTime, 0))
+			recordFetch(sub, "azure_costs", err)
+			if err != nil {
+				logrus.WithError(err).WithFields(logrus.Fields{"org_id": sub, "endpoint": "azure_costs"}).Warn("Error fetching Azure costs")
+				costFailed = true
+			}
+		}
+		if !costFailed {
+			a.lastCost = time.Now()
+		}
+		failed = failed || costFailed
+	}
+
+	if failed {
+		return fmt.Errorf("collection of window %d-%d was incomplete", startTime, endTime)
+	}
+	return nil
+}
+
+// subscriptions returns the distinct subscriptions of the configured resources.
+func (a *AzureExporter) subscriptions() []string {
+	var subs []string
+	seen := make(map[string]bool)
+	for _, r := range a.resources {
+		if !seen[r.SubscriptionID] {
+			seen[r.SubscriptionID] = true
+			subs = append(subs, r.SubscriptionID)
+		}
+	}
+	return subs
+}
+
+// fetchResourceUsage reads the per-minute token metrics of r between startTime and endTime, split by
+// deployment, model and API.
+func (a *AzureExporter) fetchResourceUsage(r azureResource, startTime, endTime int64) error {
+	query := url.Values{}
+	query.Set("api-version", "2018-01-01")
+	query.Set("metricnames", strings.Join(sortedKeys(azureTokenMetrics), ","))
+	query.Set("timespan", time.Unix(startTime, 0).UTC().Format(time.RFC3339)+"/"+time.Unix(endTime, 0).UTC().Format(time.RFC3339))
+	query.Set("interval", "PT1M")
+	query.Set("aggregation", "Total")
+	query.Set("top", "1000")
+	query.Set("$filter", "ModelDeploymentName eq '*' and ModelName eq '*' and ApiName eq '*'")
+
+	var out AzureMetricsResponse
+	if err := a.do("GET", r.ID+"/providers/microsoft.insights/metrics?"+query.Encode(), nil, &out); err != nil {
+		return fmt.Errorf("error fetching Azure Monitor metrics: %w", err)
+	}
+	pagesFetchedTotal.WithLabelValues(r.SubscriptionID, "azure_metrics").Inc()
+	region := a.region(r)
+
+	// Several APIs map to the same operation, so values are summed per label set and minute before counting.
+	type bucket struct {
+		labels    prometheus.Labels
+		tokenType string
+		start     int64
+		value     float64
+	}
+	buckets := make(map[string]*bucket)
+	for _, metric := range out.Value {
+		tokenType, ok := azureTokenMetrics[metric.Name.Value]
+		if !ok {
+			continue
+		}
+		for _, series := range metric.Timeseries {
+			dims := make(map[string]string, len(series.Metadata))
+			for _, m := range series.Metadata {
+				dims[strings.ToLower(m.Name.Value)] = m.Value
+			}
+			labels := prometheus.Labels{
+				"org_id":       r.SubscriptionID,
+				"org_name":     r.SubscriptionID,
+				"model":        orUnknown(dims["modelname"]),
+				"operation":    azureOperation(dims["apiname"]),
+				"project_id":   r.Name,
+				"project_name": r.Name,
+				"user_id":      "unknown",
+				"user_email":   "",
+				"api_key_id":   orUnknown(dims["modeldeploymentname"]),
+				"api_key_name": orUnknown(dims["modeldeploymentname"]),
+				"batch":        "false",
+			}
+			azureDeploymentInfo.WithLabelValues(r.SubscriptionID, r.Name, labels["api_key_id"], r.ResourceGroup, region, labels["model"]).Set(1)
+
+			for _, point := range series.Data {
+				if point.Total == nil {
+					continue
+				}
+				start := point.TimeStamp.Unix()
+				key := fmt.Sprint(labels, tokenType, start)
+				b, ok := buckets[key]
+				if !ok {
+					b = &bucket{labels: labels, tokenType: tokenType, start: start}
+					buckets[key] = b
+				}
+				b.value += *point.Total
+			}
+		}
+	}
+
+	for _, b := range buckets {
+		updateMetric(b.labels, b.tokenType, b.start, b.start+60, b.value)
+	}
+	logrus.WithFields(windowFields(r.SubscriptionID, "azure_metrics", startTime, endTime)).WithFields(logrus.Fields{"resource": r.Name, "records": len(buckets)}).Info("Fetched Azure Monitor buckets")
+	return nil
+}
+
+// azureOperation maps the ApiName dimension of Azure OpenAI metrics to the operation label of usage endpoints.
+func azureOperation(apiName string) string {
+	name := strings.ToLower(apiName)
+	switch {
+	case name == "":
+		return "unknown"
+	case strings.Contains(name, "embedding"):
+		return "embeddings"
+	case strings.Contains(name, "image"):
+		return "images"
+	case strings.Contains(name, "speech"):
+		return "audio_speeches"
+	case strings.Contains(name, "transcription"), strings.Contains(name, "translation"):
+		return "audio_transcriptions"
+	case strings.Contains(name, "completion"), strings.Contains(name, "response"):
+		return "completions"
+	default:
+		return name
+	}
+}
+
+// fetchCosts queries the daily actual cost of the configured resources of subscription sub for the day of now
+// and the day before, so late revisions of yesterday are picked up.
+func (a *AzureExporter) fetchCosts(sub string, now time.Time) error {
+	day := now.UTC().Truncate(24 * time.Hour)
+	var ids []string
+	resources := make(map[string]azureResource)
+	for _, r := range a.resources {
+		if r.SubscriptionID == sub {
+			ids = append(ids, r.ID)
+			resources[strings.ToLower(r.ID)] = r
+		}
+	}
+	body := map[string]any{
+		"type":      "ActualCost",
+		"timeframe": "Custom",
+		"timePeriod": map[string]string{
+			"from": day.Add(-24 * time.Hour).Format(time.RFC3339),
+			"to":   day.Add(24*time.Hour - time.Second).Format(time.RFC3339),
+		},
+		"dataset": map[string]any{
+			"granularity": "Daily",
+			"aggregation": map[string]any{"totalCost": map[string]string{"name": "Cost", "function": "Sum"}},
+			"grouping": []map[string]string{
+				{"type": "Dimension", "name": "ResourceId"},
+				{"type": "Dimension", "name": "Meter"},
+			},
+			"filter": map[string]any{
+				"dimensions": map[string]any{"name": "ResourceId", "operator": "In", "values": ids},
+			},
+		},
+	}
+
+	path := fmt.Sprintf("/subscriptions/%s/providers/Microsoft.CostManagement/query?api-version=2023-03-01", url.PathEscape(sub))
+	guard := newPageGuard(sub, "azure_costs")
+	for path != "" {
+		var out AzureCostQueryResult
+		if err := a.do("POST", path, body, &out); err != nil {
+			return fmt.Errorf("error fetching Azure costs: %w", err)
+		}
+		pagesFetchedTotal.WithLabelValues(sub, "azure_costs").Inc()
+
+		columns := make(map[string]int, len(out.Properties.Columns))
+		for i, c := range out.Properties.Columns {
+			columns[c.Name] = i
+		}
+		for _, row := range out.Properties.Rows {
+			var cost float64
+			var date int64
+			var resourceID, meter, currency string
+			if err := decodeAzureRow(row, columns, &cost, &date, &resourceID, &meter, &currency); err != nil {
+				return err
+			}
+			r, ok := resources[strings.ToLower(resourceID)]
+			if !ok {
+				continue
+			}
+			parsed, err := time.Parse("20060102", strconv.FormatInt(date, 10))
+			if err != nil {
+				return fmt.Errorf("invalid usage date %d: %w", date, err)
+			}
+			labels := prometheus.Labels{
+				"date":            parsed.Format("2006-01-02"),
+				"project_id":      r.Name,
+				"project_name":    r.Name,
+				"line_item":       orUnknown(meter),
+				"organization_id": sub,
+				"org_name":        sub,
+				"currency":        strings.ToLower(currency),
+			}
+			dailyCostUSD.With(labels).Set(cost)
+			updateCost(labels, cost)
+		}
+		path = out.Properties.NextLink
+		if path != "" && !guard.next(path) {
+			break
+		}
+	}
+	return nil
+}
+
+// decodeAzureRow decodes the Cost, UsageDate, ResourceId, Meter and Currency columns of a Cost Management row.
+func decodeAzureRow(row []json.RawMessage, columns map[string]int, cost *float64, date *int64, resourceID, meter, currency *string) error {
+	fields := []struct {
+		column string
+		target any
+	}{
+		{"Cost", cost},
+		{"UsageDate", date},
+		{"ResourceId", resourceID},
+		{"Meter", meter},
+		{"Currency", currency},
+	}
+	for _, f := range fields {
+		i, ok := columns[f.column]
+		if !ok || i >= len(row) {
+			return fmt.Errorf("cost query result has no %s column", f.column)
+		}
+		if err := json.Unmarshal(row[i], f.target); err != nil {
+			return fmt.Errorf("error decoding %s column: %w", f.column, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAzureResource = "/subscriptions/sub-1/resourceGroups/rg-ml/providers/Microsoft.CognitiveServices/accounts/aoai-east"
+
+func TestParseAzureResourceID(t *testing.T) {
+	r, err := parseAzureResourceID(testAzureResource + "/")
+	require.NoError(t, err)
+	assert.Equal(t, azureResource{ID: testAzureResource, SubscriptionID: "sub-1", ResourceGroup: "rg-ml", Name: "aoai-east"}, r)
+
+	for _, id := range []string{
+		"",
+		"/subscriptions/sub-1/resourceGroups/rg-ml",
+		"/subscriptions/sub-1/resourceGroups/rg-ml/providers/Microsoft.Storage/storageAccounts/data",
+	} {
+		_, err := parseAzureResourceID(id)
+		assert.Error(t, err, id)
+	}
+}
+
+func TestNewAzureExporter(t *testing.T) {
+	orig := *azureResources
+	defer func() { *azureResources = orig }()
+	t.Setenv("AZURE_TENANT_ID", "tenant")
+	t.Setenv("AZURE_CLIENT_ID", "client")
+	t.Setenv("AZURE_CLIENT_SECRET", "secret")
+
+	*azureResources = ""
+	_, err := NewAzureExporter()
+	assert.ErrorContains(t, err, "-azure.resources")
+
+	*azureResources = testAzureResource
+	a, err := NewAzureExporter()
+	require.NoError(t, err)
+	assert.Len(t, a.resources, 1)
+
+	t.Setenv("AZURE_CLIENT_SECRET", "")
+	_, err = NewAzureExporter()
+	assert.ErrorContains(t, err, "AZURE_CLIENT_SECRET")
+}
+
+func TestAzureOperation(t *testing.T) {
+	tests := map[string]string{
+		"ChatCompletions_Create": "completions",
+		"Completions_Create":     "completions",
+		"Embeddings_Create":      "embeddings",
+		"ImageGenerations":       "images",
+		"AudioTranscriptions":    "audio_transcriptions",
+		"":                       "unknown",
+		"Assistants_Run":         "assistants_run",
+	}
+	for apiName, want := range tests {
+		assert.Equal(t, want, azureOperation(apiName), apiName)
+	}
+}
+
+func TestAzureExporter_CollectWindow(t *testing.T) {
+	usageState = make(map[string]float64)
+	costState = make(map[string]float64)
+	tokensTotal.Reset()
+	dailyCostUSD.Reset()
+	costsTotal.Reset()
+	azureDeploymentInfo.Reset()
+
+	end := time.Now().Add(-time.Minute).Truncate(time.Minute)
+	start := end.Add(-time.Minute)
+	today := end.UTC().Format("20060102")
+
+	var tokenRequests atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tenant/oauth2/v2.0/token", func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests.Add(1)
+		assert.Equal(t, "client_credentials", r.FormValue("grant_type"))
+		assert.Equal(t, azureScope, r.FormValue("scope"))
+		_, _ = w.Write([]byte(`{"access_token": "token-1", "expires_in": 3600}`))
+	})
+	mux.HandleFunc(testAzureResource, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token-1", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"location": "eastus"}`))
+	})
+	mux.HandleFunc(testAzureResource+"/providers/microsoft.insights/metrics", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GeneratedTokens,ProcessedPromptTokens", r.URL.Query().Get("metricnames"))
+		assert.Equal(t, "PT1M", r.URL.Query().Get("interval"))
+		ts := start.UTC().Format(time.RFC3339)
+		series := func(api string, total int) string {
+			return `{"metadatavalues": [
+				{"name": {"value": "ModelDeploymentName"}, "value": "chat"},
+				{"name": {"value": "ModelName"}, "value": "gpt-4o"},
+				{"name": {"value": "ApiName"}, "value": "` + api + `"}
+			], "data": [{"timeStamp": "` + ts + `", "total": ` + strconv.Itoa(total) + `}]}`
+		}
+		_, _ = w.Write([]byte(`{"value": [
+			{"name": {"value": "ProcessedPromptTokens"}, "timeseries": [` + series("ChatCompletions_Create", 11) + `, ` + series("Completions_Create", 1) + `]},
+			{"name": {"value": "GeneratedTokens"}, "timeseries": [` + series("ChatCompletions_Create", 1) + `]}
+		]}`))
+	})
+	mux.HandleFunc("/subscriptions/sub-1/providers/Microsoft.CostManagement/query", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "ActualCost", body["type"])
+		_, _ = w.Write([]byte(`{"properties": {"columns": [
+			{"name": "Cost"}, {"name": "UsageDate"}, {"name": "ResourceId"}, {"name": "Meter"}, {"name": "Currency"}
+		], "rows": [
+			[1.5, ` + today + `, "` + strings.ToLower(testAzureResource) + `", "gpt-4o Input Tokens", "USD"],
+			[9, ` + today + `, "/subscriptions/sub-1/resourcegroups/other/providers/microsoft.storage/storageaccounts/x", "Storage", "USD"]
+		]}}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	r, err := parseAzureResourceID(testAzureResource)
+	require.NoError(t, err)
+	a := &AzureExporter{
+		client:        server.Client(),
+		managementURL: server.URL,
+		loginURL:      server.URL,
+		tenantID:      "tenant",
+		clientID:      "client",
+		clientSecret:  "secret",
+		resources:     []azureResource{r},
+		costInterval:  time.Hour,
+		regions:       make(map[string]string),
+	}
+
+	require.NoError(t, a.collectWindow(start.Unix(), end.Unix()))
+
+	tokens := func(tokenType string) float64 {
+		return testutil.ToFloat64(tokensTotal.WithLabelValues("sub-1", "sub-1", "gpt-4o", "completions", "aoai-east", "aoai-east", "unknown", "", "chat", "chat", "false", tokenType))
+	}
+	assert.Equal(t, 12.0, tokens("input"), "APIs of the same operation are summed")
+	assert.Equal(t, 1.0, tokens("output"))
+	assert.Equal(t, 1.0, testutil.ToFloat64(azureDeploymentInfo.WithLabelValues("sub-1", "aoai-east", "chat", "rg-ml", "eastus", "gpt-4o")))
+
+	date := end.UTC().Format("2006-01-02")
+	assert.Equal(t, 1.5, testutil.ToFloat64(dailyCostUSD.WithLabelValues(date, "aoai-east", "aoai-east", "gpt-4o Input Tokens", "sub-1", "sub-1", "usd")))
+	assert.Equal(t, 1.5, testutil.ToFloat64(costsTotal.WithLabelValues("sub-1", "sub-1", "aoai-east", "aoai-east", "gpt-4o Input Tokens")))
+	assert.Equal(t, 1, testutil.CollectAndCount(dailyCostUSD), "costs of other resources are ignored")
+
+	t.Run("token and costs are reused within their lifetime", func(t *testing.T) {
+		require.NoError(t, a.collectWindow(end.Unix(), end.Add(time.Minute).Unix()))
+		assert.Equal(t, int32(1), tokenRequests.Load())
+		assert.Equal(t, 12.0, tokens("input"), "buckets are not counted twice")
+	})
+}
+
+func TestAzureExporter_CollectWindow_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	r, err := parseAzureResourceID(testAzureResource)
+	require.NoError(t, err)
+	a := &AzureExporter{client: server.Client(), managementURL: server.URL, loginURL: server.URL, tenantID: "tenant",
+		resources: []azureResource{r}, regions: make(map[string]string)}
+
+	err = a.collectWindow(0, 60)
+	require.Error(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(scrapeErrorsTotal.WithLabelValues("sub-1", "azure_metrics")))
+}
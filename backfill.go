@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Historical Backfill
+
+var (
+	backfillDuration = flag.Duration("backfill.duration", 0, "At startup, initialize the counters with the usage and costs of this period before the first window, read in hourly buckets (0 disables the backfill)")
+	backfillToday    = flag.Bool("backfill.today", false, "At startup, initialize the counters with the usage and costs since midnight UTC, or since the start of backfill.duration if that is earlier")
+)
+
+// backfillEnd is the end of the backfill, guarded by stateMu. Windows never reach back before it, not even
+// with scrape.lookback: the backfill counted that time in hourly buckets, which the minute buckets of the
+// windows are not deduplicated against.
+var backfillEnd int64
+
+// windowStart returns where the window starting at startTime is queried from: scrape.lookback before it,
+// but not before the end of the backfill.
+func windowStart(startTime int64) int64 {
+	stateMu.RLock()
+	defer stateMu.RUnlock()
+	return max(startTime-int64(*scrapeLookback/time.Second), backfillEnd)
+}
+
+// backfiller initializes the counters with the totals of a past period.
+type backfiller interface {
+	backfill(startTime, endTime int64) error
+}
+
+// backfill counts the usage of [startTime, endTime) from hourly buckets and the costs of the days it spans.
+// The period must end on a full hour, so the minute buckets collected afterwards don't overlap it.
+func (e *Exporter) backfill(startTime, endTime int64) error {
+	var errs []error
+	for _, endpoint := range currentEndpoints() {
+		err := e.countUsage(endpoint, startTime, endTime, "1h")
+		recordFetch(e.orgID, endpoint.Name, err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error backfilling %s: %w", endpoint.Name, err))
+		}
+	}
+	err := e.fetchCostData(startTime, endTime)
+	recordFetch(e.orgID, "costs", err)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("error backfilling costs: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+func (o orgExporters) backfill(startTime, endTime int64) error {
+	var errs []error
+	for _, e := range o {
+		if err := e.backfill(startTime, endTime); err != nil {
+			errs = append(errs, fmt.Errorf("organization %s: %w", e.orgID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// backfillStart returns the start of a backfill ending at end: duration before it, or midnight UTC of its day
+// if today is set and that is earlier. Both are full hours, like end.
+func backfillStart(end time.Time, duration time.Duration, today bool) time.Time {
+	start := end.Add(-duration).Truncate(time.Hour)
+	if midnight := end.UTC().Truncate(24 * time.Hour); today && midnight.Before(start) {
+		start = midnight
+	}
+	return start
+}
+
+// runBackfill backfills the period of backfill.duration, or since midnight with backfill.today, before the
+// first window of c and moves the start of the first window back to the full hour (or day, with daily buckets)
+// the backfill ends on. Exporters restored from a state snapshot already have their history and are not backfilled.
+func runBackfill(c windowCollector, duration time.Duration) {
+	b, ok := c.(backfiller)
+	if !ok {
+		logrus.Warnf("Backfilling is not supported by provider %s, skipping", *providerName)
+		return
+	}
+
+	stateMu.Lock()
+	if len(usageState) > 0 {
+		stateMu.Unlock()
+		logrus.Info("State was restored, skipping backfill")
+		return
+	}
+	// Regular collection resumes at the end, so it must not split one of its buckets with the backfill.
+	end := time.Unix(lastScrape, 0).Truncate(max(time.Hour, bucketDuration()))
+	lastScrape, backfillEnd = end.Unix(), end.Unix()
+	stateMu.Unlock()
+
+	start := backfillStart(end, duration, *backfillToday)
+	if !start.Before(end) {
+		logrus.Info("Nothing to backfill yet today")
+		return
+	}
+	logrus.Infof("Backfilling usage and costs from %s to %s", start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339))
+	err := b.backfill(start.Unix(), end.Unix())
+	discardAnomalyUsage()
+	if err := flushHistory(time.Now()); err != nil {
+		logrus.WithError(err).Error("Error writing usage history")
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Backfill was incomplete")
+		return
+	}
+	logrus.Info("Backfill finished")
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunBackfill(t *testing.T) {
+	setResolveUsers(t)
+	origEndpoints, origLast, origLookback := activeEndpoints, lastScrape, *scrapeLookback
+	activeEndpoints = []UsageEndpoint{{Path: "completions", Name: "completions"}}
+	defer func() {
+		activeEndpoints, lastScrape, *scrapeLookback, backfillEnd = origEndpoints, origLast, origLookback, 0
+	}()
+
+	now := time.Now().Truncate(time.Minute)
+	hour := now.Truncate(time.Hour).Add(-time.Hour).Unix()
+	bucket := hour
+
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Path+"?"+r.URL.Query().Get("start_time")+"-"+r.URL.Query().Get("end_time")+" "+r.URL.Query().Get("bucket_width"))
+		if strings.HasSuffix(r.URL.Path, "/costs") {
+			_, _ = w.Write([]byte(`{"object": "page", "data": [], "has_more": false}`))
+			return
+		}
+		if r.URL.Query().Get("bucket_width") == "1m" {
+			// A minute within the backfilled hour, returned when the window reaches back to it.
+			minute := hour + 3600 - 300
+			if start, _ := strconv.ParseInt(r.URL.Query().Get("start_time"), 10, 64); start > minute {
+				_, _ = w.Write([]byte(`{"object": "page", "data": [], "has_more": false}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"object": "page", "data": [{"start_time": ` + strconv.FormatInt(minute, 10) + `, "end_time": ` + strconv.FormatInt(minute+60, 10) + `, "results": [
+				{"input_tokens": 100, "project_id": "proj-1", "user_id": "user-1", "api_key_id": "key-1", "model": "gpt-4", "batch": false}
+			]}], "has_more": false}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"object": "page", "data": [{"start_time": ` + strconv.FormatInt(bucket, 10) + `, "end_time": ` + strconv.FormatInt(bucket+3600, 10) + `, "results": [
+			{"input_tokens": 5000, "project_id": "proj-1", "user_id": "user-1", "api_key_id": "key-1", "model": "gpt-4", "batch": false}
+		]}], "has_more": false}`))
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+
+	t.Run("counts hourly buckets before the first window", func(t *testing.T) {
+		usageState = make(map[string]float64)
+		tokensTotal.Reset()
+		projectNames = map[string]string{"proj-1": "one"}
+		apiKeyNames = map[string]string{"key-1": "ci"}
+		userEmails = map[string]string{"user-1": "ana@example.com"}
+		queries = nil
+		lastScrape = now.Unix()
+
+		runBackfill(e, 3*time.Hour)
+
+		end := now.Truncate(time.Hour).Unix()
+		start := end - 3*3600
+		window := strconv.FormatInt(start, 10) + "-" + strconv.FormatInt(end, 10)
+		assert.Equal(t, []string{
+			"/v1/organization/usage/completions?" + window + " 1h",
+			"/v1/organization/costs?" + window + " ",
+		}, queries)
+		assert.Equal(t, end, lastScrape, "collection continues from the end of the backfill")
+		assert.Equal(t, 5000.0, testutil.ToFloat64(tokensTotal.WithLabelValues("org-1", "prod", "gpt-4", "completions", "proj-1", "one", "user-1", "ana@example.com", "key-1", "ci", "false", "input")))
+
+		// The lookback of the first window reaches into the backfilled hour, which must not be counted again.
+		*scrapeLookback = 10 * time.Minute
+		_ = runCycle(e, end, end+60)
+		assert.Equal(t, 5000.0, testutil.ToFloat64(tokensTotal.WithLabelValues("org-1", "prod", "gpt-4", "completions", "proj-1", "one", "user-1", "ana@example.com", "key-1", "ci", "false", "input")),
+			"the backfilled hour is not counted twice")
+		*scrapeLookback = origLookback
+	})
+
+	t.Run("backfilled series are kept for the retention after they were counted", func(t *testing.T) {
+		usageState = make(map[string]float64)
+		tokensTotal.Reset()
+		tokenSeries = make(map[string]int64)
+		end := now.Truncate(time.Hour).Unix()
+		bucket = end - 47*3600
+		defer func() { bucket = hour }()
+		lastScrape = now.Unix()
+
+		runBackfill(e, 47*time.Hour)
+		require.NoError(t, validateRetention(), "the default retention covers the backfill")
+		_ = runCycle(e, end, end+60)
+		pruneState(time.Now().Add(2 * time.Hour))
+
+		assert.Len(t, tokenSeries, 1, "the series counts towards the limit until it is idle for the retention")
+		assert.Equal(t, 5000.0, testutil.ToFloat64(tokensTotal.WithLabelValues("org-1", "prod", "gpt-4", "completions", "proj-1", "one", "user-1", "ana@example.com", "key-1", "ci", "false", "input")))
+	})
+
+	t.Run("skipped after restoring state", func(t *testing.T) {
+		usageState = map[string]float64{"restored": 1}
+		queries = nil
+		lastScrape = now.Unix()
+
+		runBackfill(e, 3*time.Hour)
+		assert.Empty(t, queries)
+		assert.Equal(t, now.Unix(), lastScrape)
+	})
+
+	t.Run("unsupported provider", func(t *testing.T) {
+		usageState = make(map[string]float64)
+		lastScrape = now.Unix()
+
+		runBackfill(&fakeCollector{}, 3*time.Hour)
+		assert.Equal(t, now.Unix(), lastScrape)
+	})
+}
+
+func TestBackfillStart(t *testing.T) {
+	end := time.Date(2024, 6, 1, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		duration time.Duration
+		today    bool
+		want     time.Time
+	}{
+		{name: "duration", duration: 3 * time.Hour, want: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)},
+		{name: "since midnight", today: true, want: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "duration shorter than today", duration: 3 * time.Hour, today: true, want: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "duration longer than today", duration: 24 * time.Hour, today: true, want: time.Date(2024, 5, 31, 15, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, backfillStart(end, tt.duration, tt.today).UTC())
+		})
+	}
+
+	midnight := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, midnight, backfillStart(midnight, 0, true).UTC(), "nothing to backfill right after midnight")
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+// Usage Bucket Width
+
+var usageBucketWidth = flag.String("usage.bucket-width", "1m", "Width of the usage buckets fetched every cycle: 1m, 1h or 1d; wider buckets need far fewer API calls but only count usage once a bucket is complete")
+
+// bucketDurations are the bucket widths the Usage API supports.
+var bucketDurations = map[string]time.Duration{"1m": time.Minute, "1h": time.Hour, "1d": 24 * time.Hour}
+
+// validateBucketWidth checks usage.bucket-width. Buckets are remembered by their start, so the state must
+// outlive a whole bucket.
+func validateBucketWidth() error {
+	width, ok := bucketDurations[*usageBucketWidth]
+	if !ok {
+		return fmt.Errorf("unknown usage.bucket-width %q, use 1m, 1h or 1d", *usageBucketWidth)
+	}
+	if *stateRetention > 0 && *stateRetention <= width+*scrapeLookback {
+		return fmt.Errorf("state.retention (%s) must exceed usage.bucket-width (%s) plus scrape.lookback (%s)", *stateRetention, width, *scrapeLookback)
+	}
+	return nil
+}
+
+// bucketDuration returns the configured usage bucket width.
+func bucketDuration() time.Duration {
+	return bucketDurations[*usageBucketWidth]
+}
+
+// bucketWindow sizes a Usage API request for the buckets of width between startTime and endTime: the start
+// moves back to the beginning of its bucket, so a bucket still in progress is fetched again until it is
+// complete, and the page size is the number of buckets in the window, at most the API maximum.
+func bucketWindow(startTime, endTime int64, width string) (int64, int) {
+	seconds := int64(bucketDurations[width] / time.Second)
+	if seconds == 0 {
+		return startTime, bucketLimits[width]
+	}
+	start := startTime - startTime%seconds
+	buckets := (endTime - start + seconds - 1) / seconds
+	return start, int(min(max(buckets, 1), int64(bucketLimits[width])))
+}
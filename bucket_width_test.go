@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateBucketWidth(t *testing.T) {
+	tests := []struct {
+		name      string
+		width     string
+		retention time.Duration
+		wantErr   string
+	}{
+		{name: "minutes", width: "1m", retention: 48 * time.Hour},
+		{name: "days", width: "1d", retention: 48 * time.Hour},
+		{name: "days without retention", width: "1d", retention: 0},
+		{name: "unknown width", width: "5m", retention: 48 * time.Hour, wantErr: `unknown usage.bucket-width "5m"`},
+		{name: "retention shorter than a bucket", width: "1d", retention: 12 * time.Hour, wantErr: "state.retention (12h0m0s) must exceed usage.bucket-width"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origWidth, origRetention := *usageBucketWidth, *stateRetention
+			defer func() { *usageBucketWidth, *stateRetention = origWidth, origRetention }()
+			*usageBucketWidth, *stateRetention = tt.width, tt.retention
+
+			err := validateBucketWidth()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestBucketWindow(t *testing.T) {
+	day := int64(1717804800) // 2024-06-08T00:00:00Z
+
+	tests := []struct {
+		name       string
+		start, end int64
+		width      string
+		wantStart  int64
+		wantLimit  int
+	}{
+		{name: "one minute", start: day + 600, end: day + 660, width: "1m", wantStart: day + 600, wantLimit: 1},
+		{name: "five minutes", start: day + 600, end: day + 900, width: "1m", wantStart: day + 600, wantLimit: 5},
+		{name: "minute within the hour", start: day + 3660, end: day + 3720, width: "1h", wantStart: day + 3600, wantLimit: 1},
+		{name: "across an hour", start: day + 3540, end: day + 3660, width: "1h", wantStart: day, wantLimit: 2},
+		{name: "day", start: day + 3600, end: day + 3660, width: "1d", wantStart: day, wantLimit: 1},
+		{name: "more buckets than a page", start: day - 10*86400, end: day, width: "1m", wantStart: day - 10*86400, wantLimit: 1440},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, limit := bucketWindow(tt.start, tt.end, tt.width)
+			assert.Equal(t, tt.wantStart, start)
+			assert.Equal(t, tt.wantLimit, limit)
+		})
+	}
+}
+
+func TestFetchUsageBucketsHourly(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		_, _ = w.Write([]byte(`{"object": "page", "data": [], "has_more": false}`))
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+
+	_, err := e.fetchUsageBuckets(UsageEndpoint{Name: "completions", Path: "completions"}, 1717808340, 1717808460, "1h", "model")
+	require.NoError(t, err)
+	assert.Equal(t, "start_time=1717804800&end_time=1717808460&bucket_width=1h&limit=2&group_by=model", query)
+}
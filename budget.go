@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Self API-Call Budget
+
+var (
+	apiHourlyBudget  = flag.Int("api.hourly-budget", 0, "Maximum number of OpenAI API calls per hour the exporter aims to stay under; optional lookups pause when approaching it (0 disables)")
+	apiOptionalRatio = flag.Float64("api.optional-ratio", 0.8, "Fraction of api.hourly-budget above which optional calls (name resolution, inventories) are skipped")
+
+	apiCallsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "openai_exporter_api_calls_total",
+			Help: "Total number of OpenAI API calls made by the exporter.",
+		},
+	)
+	apiCallsLastHour = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "openai_exporter_api_calls_last_hour",
+			Help: "Number of OpenAI API calls made by the exporter during the last hour.",
+		},
+		func() float64 { return float64(apiBudget.lastHour(time.Now())) },
+	)
+	optionalCallsSkipped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "openai_exporter_optional_calls_skipped_total",
+			Help: "Total number of optional OpenAI API calls skipped to stay within the hourly budget.",
+		},
+	)
+)
+
+// apiBudget counts the API calls of the whole process; it is shared by all exporters.
+var apiBudget = &callBudget{}
+
+// callBudget counts calls in one-minute slots over a sliding hour.
+type callBudget struct {
+	mu            sync.Mutex
+	limit         int
+	optionalRatio float64
+	slots         [60]struct {
+		minute int64
+		count  int
+	}
+}
+
+// configure sets the hourly limit (0 for unlimited) and the share of it available to optional calls.
+func (b *callBudget) configure(limit int, optionalRatio float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limit = limit
+	b.optionalRatio = optionalRatio
+}
+
+// record counts one call made at now.
+func (b *callBudget) record(now time.Time) {
+	apiCallsTotal.Inc()
+
+	minute := now.Unix() / 60
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	slot := &b.slots[minute%60]
+	if slot.minute != minute {
+		slot.minute, slot.count = minute, 0
+	}
+	slot.count++
+}
+
+// lastHour returns the number of calls recorded in the hour before now.
+func (b *callBudget) lastHour(now time.Time) int {
+	minute := now.Unix() / 60
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	total := 0
+	for _, slot := range b.slots {
+		if slot.minute > minute-60 {
+			total += slot.count
+		}
+	}
+	return total
+}
+
+// allowOptional reports whether an optional call may be made at now without approaching the limit.
+// Skipped calls are counted.
+func (b *callBudget) allowOptional(now time.Time) bool {
+	b.mu.Lock()
+	limit, ratio := b.limit, b.optionalRatio
+	b.mu.Unlock()
+
+	if limit <= 0 || float64(b.lastHour(now)) < float64(limit)*ratio {
+		return true
+	}
+	optionalCallsSkipped.Inc()
+	return false
+}
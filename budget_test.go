@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallBudget_LastHour(t *testing.T) {
+	b := &callBudget{}
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	b.record(start)
+	b.record(start.Add(30 * time.Second))
+	b.record(start.Add(30 * time.Minute))
+	assert.Equal(t, 3, b.lastHour(start.Add(30*time.Minute)))
+
+	assert.Equal(t, 1, b.lastHour(start.Add(61*time.Minute)), "calls older than an hour drop out")
+	assert.Equal(t, 0, b.lastHour(start.Add(3*time.Hour)))
+
+	b.record(start.Add(2 * time.Hour))
+	assert.Equal(t, 1, b.lastHour(start.Add(2*time.Hour)), "reused slots start from zero")
+}
+
+func TestCallBudget_AllowOptional(t *testing.T) {
+	now := time.Now()
+	skipped := testutil.ToFloat64(optionalCallsSkipped)
+
+	unlimited := &callBudget{}
+	for i := 0; i < 100; i++ {
+		unlimited.record(now)
+	}
+	assert.True(t, unlimited.allowOptional(now))
+
+	b := &callBudget{}
+	b.configure(10, 0.5)
+	for i := 0; i < 4; i++ {
+		b.record(now)
+	}
+	assert.True(t, b.allowOptional(now))
+
+	b.record(now)
+	assert.False(t, b.allowOptional(now))
+	assert.Equal(t, skipped+1, testutil.ToFloat64(optionalCallsSkipped))
+}
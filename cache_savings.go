@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Prompt Cache Savings
+
+var (
+	costLabelNames = []string{"org_id", "org_name", "project_id", "project_name", "model"}
+
+	cachedTokenRatio = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_api_cached_token_ratio",
+			Help: "Share of the input tokens counted since the exporter started that were served from the prompt cache; series nothing was counted in for state.retention are removed",
+		},
+		costLabelNames,
+	)
+	cacheSavingsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "openai_api_cache_savings_usd_total",
+			Help: "Estimated USD saved by charging cached input tokens at the cached price instead of the input price",
+		},
+		costLabelNames,
+	)
+
+	cacheMu sync.Mutex
+	// cacheTokens holds the tokens counted per cost series.
+	cacheTokens = make(map[string]*cacheCount)
+)
+
+// cacheCount is the input and cached input tokens counted in a cost series, and the Unix time they were
+// last counted.
+type cacheCount struct {
+	series        prometheus.Labels
+	input, cached float64
+	last          int64
+}
+
+// pruneCacheTokens forgets the cost series nothing was counted in since cutoff and removes their ratio.
+// It returns the number of series removed.
+func pruneCacheTokens(cutoff int64) int {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	var pruned int
+	for key, tokens := range cacheTokens {
+		if tokens.last >= cutoff {
+			continue
+		}
+		cachedTokenRatio.Delete(tokens.series)
+		delete(cacheTokens, key)
+		pruned++
+	}
+	return pruned
+}
+
+// costLabels returns the labels of the cost metrics for the usage labels of a result.
+func costLabels(labels prometheus.Labels) prometheus.Labels {
+	out := make(prometheus.Labels, len(costLabelNames))
+	for _, name := range costLabelNames {
+		out[name] = labels[name]
+	}
+	return out
+}
+
+// recordCacheUsage updates the cached token ratio and the estimated savings with the tokens just counted
+// for a usage result.
+func recordCacheUsage(labels prometheus.Labels, added map[string]float64) {
+	if added["input"] == 0 && added["input_cached"] == 0 {
+		return
+	}
+	series := costLabels(labels)
+	parts := make([]string, len(costLabelNames))
+	for i, name := range costLabelNames {
+		parts[i] = series[name]
+	}
+	key := strings.Join(parts, "|")
+
+	cacheMu.Lock()
+	tokens, ok := cacheTokens[key]
+	if !ok {
+		tokens = &cacheCount{series: series}
+		cacheTokens[key] = tokens
+	}
+	tokens.input += added["input"]
+	tokens.cached += added["input_cached"]
+	tokens.last = time.Now().Unix()
+	if tokens.input > 0 {
+		cachedTokenRatio.With(series).Set(tokens.cached / tokens.input)
+	}
+	cacheMu.Unlock()
+
+	if !*pricingEnabled || added["input_cached"] <= 0 {
+		return
+	}
+	price, ok := priceOf(currentPrices(), labels["model"])
+	if !ok || price.CachedInput == 0 || price.CachedInput >= price.Input {
+		return
+	}
+	savings := added["input_cached"] * (price.Input - price.CachedInput) / 1e6
+	if labels["batch"] == "true" {
+		savings /= 2
+	}
+	cacheSavingsTotal.With(series).Add(savings)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordCacheUsage(t *testing.T) {
+	cacheTokens = make(map[string]*cacheCount)
+	cachedTokenRatio.Reset()
+	cacheSavingsTotal.Reset()
+
+	labels := func(model, batch string) prometheus.Labels {
+		return prometheus.Labels{"org_id": "org-1", "org_name": "prod", "project_id": "proj-1", "project_name": "one", "model": model, "batch": batch, "user_id": "user-1"}
+	}
+	ratio := func(model string) float64 {
+		return testutil.ToFloat64(cachedTokenRatio.WithLabelValues("org-1", "prod", "proj-1", "one", model))
+	}
+	savings := func(model string) float64 {
+		return testutil.ToFloat64(cacheSavingsTotal.WithLabelValues("org-1", "prod", "proj-1", "one", model))
+	}
+
+	recordCacheUsage(labels("gpt-4o-2024-08-06", "false"), map[string]float64{"input": 1e6, "input_cached": 2e5})
+	recordCacheUsage(labels("gpt-4o-2024-08-06", "true"), map[string]float64{"input": 1e6, "input_cached": 6e5})
+	assert.InDelta(t, 0.4, ratio("gpt-4o-2024-08-06"), 1e-9, "ratio of all tokens counted so far")
+	assert.InDelta(t, 0.25+0.375, savings("gpt-4o-2024-08-06"), 1e-9, "batch savings are halved")
+
+	recordCacheUsage(labels("gpt-4", "false"), map[string]float64{"input": 1e6, "input_cached": 5e5})
+	assert.InDelta(t, 0.5, ratio("gpt-4"), 1e-9)
+	recordCacheUsage(labels("whisper-1", "false"), map[string]float64{"input": 0})
+	assert.Equal(t, 1, testutil.CollectAndCount(cacheSavingsTotal), "models without a cached price save nothing")
+	assert.Equal(t, 2, testutil.CollectAndCount(cachedTokenRatio), "results without input tokens are skipped")
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Cardinality Limit
+
+var (
+	maxSeries = flag.Int("metrics.max-series", 0, "Maximum number of openai_api_tokens_total series; usage of further label combinations is aggregated with project, user, API key and model set to \"other\" (0 means unlimited)")
+
+	seriesLimitHits = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "openai_exporter_series_limit_hits_total",
+			Help: "Number of token counts aggregated into an overflow series because -metrics.max-series was reached.",
+		},
+	)
+)
+
+// overflowLabels are the labels set to "other" in the overflow series.
+var overflowLabels = []string{"model", "project_id", "project_name", "user_id", "user_email", "api_key_id", "api_key_name"}
+
+// overflowValue replaces the values of overflowLabels in overflow series.
+const overflowValue = "other"
+
+var (
+	// tokenSeries maps the label values of every openai_api_tokens_total series counted in within
+	// state.retention to the Unix time it was last counted in, guarded by stateMu.
+	tokenSeries = make(map[string]int64)
+)
+
+func seriesKey(labels prometheus.Labels) string {
+	values := make([]string, len(tokenLabels))
+	for i, name := range tokenLabels {
+		values[i] = labels[name]
+	}
+	return strings.Join(values, "\xff")
+}
+
+// limitSeries returns the series labels are counted in: labels itself while the series limit allows it,
+// and otherwise the overflow series of their organization, operation and token type. now is when the series
+// is counted in. Callers hold stateMu.
+func limitSeries(labels prometheus.Labels, now int64) prometheus.Labels {
+	key := seriesKey(labels)
+	if _, ok := tokenSeries[key]; ok || *maxSeries <= 0 || len(tokenSeries) < *maxSeries {
+		tokenSeries[key] = now
+		return labels
+	}
+
+	overflow := make(prometheus.Labels, len(labels))
+	for k, v := range labels {
+		overflow[k] = v
+	}
+	for _, name := range overflowLabels {
+		if _, ok := overflow[name]; ok {
+			overflow[name] = overflowValue
+		}
+	}
+	seriesLimitHits.Inc()
+	key = seriesKey(overflow)
+	if _, ok := tokenSeries[key]; !ok {
+		logrus.Warnf("Series limit of %d reached, aggregating new label combinations as %q", *maxSeries, overflowValue)
+	}
+	tokenSeries[key] = now
+	return overflow
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateMetric_SeriesLimit(t *testing.T) {
+	usageState = make(map[string]float64)
+	tokenSeries = make(map[string]int64)
+	tokensTotal.Reset()
+	orig := *maxSeries
+	*maxSeries = 2
+	defer func() { *maxSeries = orig }()
+
+	now := time.Now().Unix()
+	labels := func(apiKey string) prometheus.Labels {
+		return prometheus.Labels{
+			"org_id": "org-1", "org_name": "prod", "model": "gpt-4", "operation": "completions", "project_id": "proj-1", "project_name": "p",
+			"user_id": "user-1", "user_email": "", "api_key_id": apiKey, "api_key_name": apiKey, "batch": "false",
+		}
+	}
+	hits := testutil.ToFloat64(seriesLimitHits)
+
+	updateMetric(labels("key-1"), "input", now-120, now-60, 1)
+	updateMetric(labels("key-2"), "input", now-120, now-60, 2)
+	updateMetric(labels("key-3"), "input", now-120, now-60, 3)
+	updateMetric(labels("key-4"), "input", now-120, now-60, 4)
+	updateMetric(labels("key-1"), "input", now-180, now-120, 5)
+
+	assert.Equal(t, 3, testutil.CollectAndCount(tokensTotal), "two series plus the overflow series")
+	assert.Equal(t, 6.0, testutil.ToFloat64(tokensTotal.WithLabelValues("org-1", "prod", "gpt-4", "completions", "proj-1", "p", "user-1", "", "key-1", "key-1", "false", "input")),
+		"existing series keep counting")
+	assert.Equal(t, 7.0, testutil.ToFloat64(tokensTotal.WithLabelValues("org-1", "prod", "other", "completions", "other", "other", "other", "other", "other", "other", "false", "input")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(seriesLimitHits)-hits)
+}
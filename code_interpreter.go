@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Code Interpreter Sessions
+
+// codeInterpreterEndpoint reports sessions instead of tokens and can only be grouped by project.
+const codeInterpreterEndpoint = "code_interpreter_sessions"
+
+var codeInterpreterSessionsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "openai_api_code_interpreter_sessions_total",
+		Help: "Total number of code interpreter sessions per organization and project",
+	},
+	[]string{"org_id", "org_name", "project_id", "project_name"},
+)
+
+// countCodeInterpreterSessions fetches the code interpreter buckets of the given width between startTime
+// and endTime and counts their sessions.
+func (e *Exporter) countCodeInterpreterSessions(endpoint UsageEndpoint, startTime, endTime int64, bucketWidth string) error {
+	buckets, err := e.fetchUsageBuckets(endpoint, startTime, endTime, bucketWidth, "project_id")
+	if err != nil {
+		return err
+	}
+
+	var records int
+	for _, bucket := range buckets {
+		for _, result := range bucket.Results {
+			records++
+			labels := prometheus.Labels{
+				"org_id":       e.orgID,
+				"org_name":     e.orgName,
+				"project_id":   deref(result.ProjectID),
+				"project_name": e.ensureProjectName(deref(result.ProjectID)),
+			}
+			key := strings.Join([]string{
+				"code_interpreter_sessions_total",
+				fmt.Sprintf("%d", bucket.StartTime),
+				labels["project_id"],
+				e.orgID,
+			}, "|")
+			updateCounter(codeInterpreterSessionsTotal, key, labels, bucket.EndTime, float64(result.NumSessions))
+		}
+	}
+
+	logrus.WithFields(windowFields(e.orgID, endpoint.Name, startTime, endTime)).WithField("records", records).Info("Fetched usage records")
+	return nil
+}
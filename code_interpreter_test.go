@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchUsageData_CodeInterpreterSessions(t *testing.T) {
+	usageState = make(map[string]float64)
+	projectNames = map[string]string{"proj-1": "one"}
+	codeInterpreterSessionsTotal.Reset()
+
+	end := time.Now().Add(-time.Minute).Truncate(time.Minute).Unix()
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path+"?group_by="+r.URL.Query().Get("group_by"))
+		_, _ = w.Write([]byte(`{"object": "page", "data": [
+			{"start_time": ` + strconv.FormatInt(end-120, 10) + `, "end_time": ` + strconv.FormatInt(end-60, 10) + `, "results": [{"num_sessions": 2, "project_id": "proj-1"}]},
+			{"start_time": ` + strconv.FormatInt(end-60, 10) + `, "end_time": ` + strconv.FormatInt(end, 10) + `, "results": [{"num_sessions": 1, "project_id": "proj-1"}]}
+		], "has_more": false}`))
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+	endpoint, ok := findEndpoint(codeInterpreterEndpoint)
+	require.True(t, ok)
+
+	require.NoError(t, e.fetchUsageData(endpoint, end-120, end))
+	require.NoError(t, e.fetchUsageData(endpoint, end-120, end))
+
+	assert.Equal(t, []string{
+		"/v1/organization/usage/code_interpreter_sessions?group_by=project_id",
+		"/v1/organization/usage/code_interpreter_sessions?group_by=project_id",
+	}, paths)
+	assert.Equal(t, 3.0, testutil.ToFloat64(codeInterpreterSessionsTotal.WithLabelValues("org-1", "prod", "proj-1", "one")))
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Subcommands
+
+const commandsHelp = `Usage: openai-exporter [command] [flags]
+
+Commands:
+  serve     Run the exporter (default)
+  validate  Check the configuration and API credentials, then exit
+  query     Print a usage or cost report for a period (see query -h)
+  rules     Print recommended Prometheus recording and alerting rules for the configuration
+  health    Check that the exporter running on this host is ready, for container health checks
+  version   Print version and build information
+
+Flags:
+`
+
+var printVersion = flag.Bool("version", false, "Print version and build information and exit, like the version command")
+
+// subcommand splits the command line into the subcommand and its flags. Without a subcommand the exporter
+// serves, so existing command lines keep working.
+func subcommand(args []string) (string, []string) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		return args[0], args[1:]
+	}
+	return "serve", args
+}
+
+// usage prints the commands and flags to stderr.
+func usage() {
+	fmt.Fprint(flag.CommandLine.Output(), commandsHelp)
+	flag.PrintDefaults()
+}
+
+func init() {
+	flag.Usage = usage
+}
+
+// credentialChecker is implemented by collectors that can verify their API credentials with a single request.
+type credentialChecker interface {
+	checkCredentials() error
+}
+
+// runValidate checks the configuration and the API credentials and returns the exit code:
+// 0 if both are valid and 1 otherwise.
+func runValidate(args []string) int {
+	cfg, err := configure(args)
+	if err != nil {
+		logrus.WithError(err).Error("Invalid configuration")
+		return 1
+	}
+	collector, err := newCollector(cfg.organizations)
+	if err != nil {
+		logrus.WithError(err).Error("Invalid configuration")
+		return 1
+	}
+	if c, ok := collector.(credentialChecker); ok {
+		if err := c.checkCredentials(); err != nil {
+			logrus.WithError(err).Error("API credentials were rejected")
+			return 1
+		}
+	}
+	logrus.Info("Configuration and API credentials are valid")
+	return 0
+}
+
+// checkCredentials lists a single project, which requires a valid admin key of the organization.
+func (e *Exporter) checkCredentials() error {
+	resp, err := e.get("/v1/organization/projects?limit=1")
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+func (o orgExporters) checkCredentials() error {
+	var errs []error
+	for _, e := range o {
+		if err := e.checkCredentials(); err != nil {
+			errs = append(errs, fmt.Errorf("organization %s: %w", e.orgID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkCredentials reads the spend logs of the last minute, which requires the master key.
+func (l *LiteLLMExporter) checkCredentials() error {
+	now := time.Now().Unix()
+	_, err := l.fetchSpendLogs(now-60, now)
+	return err
+}
+
+// checkCredentials fetches an access token with the client credentials.
+func (a *AzureExporter) checkCredentials() error {
+	_, err := a.accessToken()
+	return err
+}
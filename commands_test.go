@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubcommand(t *testing.T) {
+	tests := []struct {
+		args     []string
+		wantName string
+		wantArgs []string
+	}{
+		{args: nil, wantName: "serve", wantArgs: nil},
+		{args: []string{"-scrape.interval=5m"}, wantName: "serve", wantArgs: []string{"-scrape.interval=5m"}},
+		{args: []string{"serve", "-scrape.interval=5m"}, wantName: "serve", wantArgs: []string{"-scrape.interval=5m"}},
+		{args: []string{"validate", "-config.file=config.yml"}, wantName: "validate", wantArgs: []string{"-config.file=config.yml"}},
+		{args: []string{"version"}, wantName: "version", wantArgs: []string{}},
+	}
+
+	for _, tt := range tests {
+		name, args := subcommand(tt.args)
+		assert.Equal(t, tt.wantName, name)
+		assert.Equal(t, tt.wantArgs, args)
+	}
+}
+
+func TestCheckCredentials(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.String()
+		if r.Header.Get("Authorization") != "Bearer valid" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error": {"message": "Incorrect API key provided", "type": "invalid_request_error"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": []}`))
+	}))
+	defer server.Close()
+
+	valid := &Exporter{client: server.Client(), apiKey: "valid", orgID: "org-1", targets: newAPITargets("org-1", server.URL, 3)}
+	invalid := &Exporter{client: server.Client(), apiKey: "revoked", orgID: "org-2", targets: newAPITargets("org-2", server.URL, 3)}
+
+	require.NoError(t, valid.checkCredentials())
+	assert.Equal(t, "/v1/organization/projects?limit=1", gotPath)
+
+	var apiErr *APIError
+	assert.ErrorAs(t, invalid.checkCredentials(), &apiErr)
+
+	err := orgExporters{valid, invalid}.checkCredentials()
+	assert.ErrorContains(t, err, "organization org-2")
+	assert.NotContains(t, err.Error(), "organization org-1")
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Configuration File
+
+var configFile = flag.String("config.file", "", "YAML configuration file; flags given on the command line override its settings")
+
+// FileConfig holds the settings of the configuration file that have no flag equivalent.
+// Every other key of the file names a flag, with nested mappings joined by dots.
+type FileConfig struct {
+	Endpoints     []string            `yaml:"endpoints"`
+	GroupBy       map[string][]string `yaml:"group_by"`
+	Relabel       []RelabelRule       `yaml:"relabel_configs"`
+	Budgets       []Budget            `yaml:"budgets"`
+	Organizations []Organization      `yaml:"-"`
+
+	// Flags holds the flag settings of the file by flag name.
+	Flags map[string]fileSetting `yaml:"-"`
+}
+
+// fileSetting is the value of one flag in the configuration file, normalized to the flag's syntax.
+type fileSetting struct {
+	Value string
+	Line  int
+}
+
+// fileOnlyKeys are the top-level keys decoded into FileConfig rather than set as flags.
+var fileOnlyKeys = map[string]bool{
+	"budgets":         true,
+	"endpoints":       true,
+	"group_by":        true,
+	"organizations":   true,
+	"relabel_configs": true,
+}
+
+// readConfigFile parses and validates the configuration file at path without applying it.
+// Errors carry the file name and line number.
+func readConfigFile(path string) (*FileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading configuration file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	cfg := &FileConfig{Flags: make(map[string]fileSetting)}
+	if len(doc.Content) == 0 {
+		return cfg, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s:%d: configuration must be a mapping", path, root.Line)
+	}
+
+	nodes := make(map[string]*yaml.Node)
+	for i := 0; i < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if fileOnlyKeys[key.Value] {
+			continue
+		}
+		flattenSettings(key.Value, value, nodes)
+	}
+	if err := root.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, name := range cfg.Endpoints {
+		if _, ok := findEndpoint(name); !ok {
+			return nil, fmt.Errorf("%s:%d: unknown endpoint %q", path, fileKeyLine(root, "endpoints"), name)
+		}
+	}
+	for _, name := range sortedKeys(cfg.GroupBy) {
+		if _, ok := findEndpoint(name); !ok {
+			return nil, fmt.Errorf("%s:%d: group_by: unknown endpoint %q", path, fileKeyLine(root, "group_by"), name)
+		}
+		if err := validateGroupBy(cfg.GroupBy[name]); err != nil {
+			return nil, fmt.Errorf("%s:%d: group_by of %s: %w", path, fileKeyLine(root, "group_by"), name, err)
+		}
+	}
+	for i := range cfg.Relabel {
+		if err := cfg.Relabel[i].compile(); err != nil {
+			return nil, fmt.Errorf("%s:%d: relabel_configs[%d]: %w", path, fileKeyLine(root, "relabel_configs"), i, err)
+		}
+	}
+	if err := validateBudgets(cfg.Budgets); err != nil {
+		return nil, fmt.Errorf("%s:%d: %w", path, fileKeyLine(root, "budgets"), err)
+	}
+	for i := 0; i < len(root.Content); i += 2 {
+		if root.Content[i].Value == "organizations" {
+			if cfg.Organizations, err = readOrganizations(path, root.Content[i+1]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(nodes) {
+		node := nodes[name]
+		f := flag.Lookup(name)
+		if f == nil || name == "config.file" {
+			return nil, fmt.Errorf("%s:%d: unknown setting %q", path, node.Line, name)
+		}
+		value, err := scalarValue(node)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s: %w", path, node.Line, name, err)
+		}
+		normalized, err := normalizeFlagValue(f, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid value %q for %s: %w", path, node.Line, value, name, err)
+		}
+		cfg.Flags[name] = fileSetting{Value: normalized, Line: node.Line}
+	}
+	return cfg, nil
+}
+
+// loadConfigFile reads the configuration file at path, sets every flag it mentions that is not in explicit,
+// and returns its settings.
+func loadConfigFile(path string, explicit map[string]bool) (*FileConfig, error) {
+	cfg, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for name, setting := range cfg.Flags {
+		if explicit[name] {
+			continue
+		}
+		// Value.Set rather than flag.Set, so file settings don't count as given on the command line.
+		if err := flag.Lookup(name).Value.Set(setting.Value); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid value %q for %s: %w", path, setting.Line, setting.Value, name, err)
+		}
+	}
+	return cfg, nil
+}
+
+// flattenSettings collects the leaves below node under dot-joined names.
+func flattenSettings(prefix string, node *yaml.Node, out map[string]*yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		out[prefix] = node
+		return
+	}
+	for i := 0; i < len(node.Content); i += 2 {
+		flattenSettings(prefix+"."+node.Content[i].Value, node.Content[i+1], out)
+	}
+}
+
+// scalarValue returns the flag value of a scalar, or of a sequence of scalars as a comma-separated list.
+func scalarValue(node *yaml.Node) (string, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return node.Value, nil
+	case yaml.SequenceNode:
+		items := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return "", fmt.Errorf("lists may only contain plain values")
+			}
+			items = append(items, item.Value)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value")
+	}
+}
+
+// normalizeFlagValue checks that value parses as the type of f and returns it the way f prints its values,
+// so settings can be compared with the current flag values.
+func normalizeFlagValue(f *flag.Flag, value string) (string, error) {
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return value, nil
+	}
+	switch getter.Get().(type) {
+	case bool:
+		b, err := strconv.ParseBool(value)
+		return strconv.FormatBool(b), err
+	case int:
+		i, err := strconv.Atoi(value)
+		return strconv.Itoa(i), err
+	case float64:
+		v, err := strconv.ParseFloat(value, 64)
+		return strconv.FormatFloat(v, 'g', -1, 64), err
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		return d.String(), err
+	default:
+		return value, nil
+	}
+}
+
+func fileKeyLine(root *yaml.Node, key string) int {
+	for i := 0; i < len(root.Content); i += 2 {
+		if root.Content[i].Value == key {
+			return root.Content[i].Line
+		}
+	}
+	return root.Line
+}
+
+// explicitFlags returns the names of the flags set on the command line.
+func explicitFlags() map[string]bool {
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	return explicit
+}
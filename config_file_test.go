@@ -0,0 +1,148 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	origURL, origAttempts, origBackoff, origDeny := *heartbeatURL, *retryMaxAttempts, *retryBackoff, *userDenyFlag
+	defer func() {
+		*heartbeatURL, *retryMaxAttempts, *retryBackoff, *userDenyFlag = origURL, origAttempts, origBackoff, origDeny
+	}()
+
+	path := writeConfigFile(t, `
+heartbeat.url: https://hc.example/ping
+api:
+  retry:
+    max-attempts: 5
+    backoff: 2s
+filter:
+  users:
+    deny: [bot-*, "ci-*"]
+endpoints: [completions, embeddings]
+group_by:
+  completions: [project_id, model, service_tier]
+`)
+
+	cfg, err := loadConfigFile(path, map[string]bool{"api.retry.backoff": true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"completions", "embeddings"}, cfg.Endpoints)
+	assert.Equal(t, map[string][]string{"completions": {"project_id", "model", "service_tier"}}, cfg.GroupBy)
+	assert.Equal(t, "https://hc.example/ping", *heartbeatURL)
+	assert.Equal(t, 5, *retryMaxAttempts)
+	assert.Equal(t, origBackoff, *retryBackoff, "flags given on the command line win")
+	assert.Equal(t, "bot-*,ci-*", *userDenyFlag)
+}
+
+func TestLoadConfigFile_Errors(t *testing.T) {
+	origAttempts := *retryMaxAttempts
+	defer func() { *retryMaxAttempts = origAttempts }()
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "invalid yaml",
+			content: "api:\n  retry: [\n",
+			wantErr: "yaml: line 2",
+		},
+		{
+			name:    "not a mapping",
+			content: "- a\n- b\n",
+			wantErr: ":1: configuration must be a mapping",
+		},
+		{
+			name:    "unknown setting",
+			content: "web:\n  listen-adress: :9185\n",
+			wantErr: `:2: unknown setting "web.listen-adress"`,
+		},
+		{
+			name:    "invalid value",
+			content: "scrape.interval: 1m\napi.retry.max-attempts: many\n",
+			wantErr: `:2: invalid value "many" for api.retry.max-attempts`,
+		},
+		{
+			name:    "nested list",
+			content: "filter.users.allow:\n  - [a]\n",
+			wantErr: ":2: filter.users.allow: lists may only contain plain values",
+		},
+		{
+			name:    "unknown endpoint",
+			content: "\nendpoints: [chat]\n",
+			wantErr: `:2: unknown endpoint "chat"`,
+		},
+		{
+			name:    "group_by of an unknown endpoint",
+			content: "\ngroup_by:\n  chat: [model]\n",
+			wantErr: `:2: group_by: unknown endpoint "chat"`,
+		},
+		{
+			name:    "unknown group_by dimension",
+			content: "\ngroup_by:\n  embeddings: [model, region]\n",
+			wantErr: `:2: group_by of embeddings: unknown group_by dimension "region"`,
+		},
+		{
+			name:    "invalid relabel rule",
+			content: "\nrelabel_configs:\n  - action: replace\n    target_label: region\n",
+			wantErr: `:2: relabel_configs[0]: replace needs a target_label`,
+		},
+		{
+			name:    "budget without a limit",
+			content: "\nbudgets:\n  - project_id: proj-1\n",
+			wantErr: `:2: budgets[0]: monthly_usd must be positive, got 0`,
+		},
+		{
+			name:    "duplicate budget",
+			content: "\nbudgets:\n  - {project_id: proj-1, monthly_usd: 10}\n  - {project_id: proj-1, monthly_usd: 20}\n",
+			wantErr: `:2: budgets[1]: duplicate budget "proj-1"`,
+		},
+		{
+			name:    "config file cannot include itself",
+			content: "config.file: other.yaml\n",
+			wantErr: `unknown setting "config.file"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadConfigFile(writeConfigFile(t, tt.content), nil)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := loadConfigFile(filepath.Join(t.TempDir(), "missing.yaml"), nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("empty file", func(t *testing.T) {
+		cfg, err := loadConfigFile(writeConfigFile(t, ""), nil)
+		require.NoError(t, err)
+		assert.Empty(t, cfg.Endpoints)
+	})
+}
+
+func TestLoadConfigFile_Durations(t *testing.T) {
+	orig := *scrapeInterval
+	defer func() { *scrapeInterval = orig }()
+
+	_, err := loadConfigFile(writeConfigFile(t, "scrape:\n  interval: 5m\n"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, *scrapeInterval)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Effective Configuration Info
+
+var configInfo = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "openai_exporter_config_info",
+		Help: "Effective exporter configuration: a hash over all settings plus key non-secret settings as labels. Always 1.",
+	},
+	[]string{"config_hash", "provider", "scrape_interval", "group_by", "endpoints"},
+)
+
+// configHash returns a short, stable hash of every flag value and the live settings that may differ from them.
+// Secrets are read from the environment and are therefore never part of it.
+func configHash() string {
+	h := sha256.New()
+	flag.VisitAll(func(f *flag.Flag) {
+		_, _ = fmt.Fprintf(h, "%s=%s\n", f.Name, f.Value.String())
+	})
+	_, _ = fmt.Fprintf(h, "endpoints=%s\n", strings.Join(endpointNames(activeEndpoints), ","))
+	_, _ = fmt.Fprintf(h, "users=%v\n", userFilter)
+	_, _ = fmt.Fprintf(h, "projects=%v models=%v\n", projectFilter, modelFilter)
+	_, _ = fmt.Fprintf(h, "group_by=%v\n", endpointGroupBy)
+	rules, _ := json.Marshal(relabelRules)
+	_, _ = fmt.Fprintf(h, "relabel=%s\n", rules)
+	_, _ = fmt.Fprintf(h, "budgets=%v\n", budgets)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// updateConfigInfo replaces the config info series with one describing the current configuration.
+func updateConfigInfo() {
+	configMu.RLock()
+	defer configMu.RUnlock()
+
+	configInfo.Reset()
+	configInfo.WithLabelValues(
+		configHash(),
+		*providerName,
+		scrapeInterval.String(),
+		groupBySummary(),
+		strings.Join(endpointNames(activeEndpoints), ","),
+	).Set(1)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateConfigInfo(t *testing.T) {
+	origInterval, origEndpoints := *scrapeInterval, activeEndpoints
+	defer func() { *scrapeInterval, activeEndpoints = origInterval, origEndpoints }()
+
+	updateConfigInfo()
+	hash := configHash()
+	assert.Len(t, hash, 16)
+	assert.Equal(t, 1, testutil.CollectAndCount(configInfo))
+	assert.Equal(t, 1.0, testutil.ToFloat64(configInfo.WithLabelValues(
+		hash, "openai", "1m0s", usageGroupBy,
+		"completions,embeddings,moderations,images,audio_speeches,audio_transcriptions,vector_stores,code_interpreter_sessions")))
+
+	applyConfig(&Config{ScrapeInterval: "5m", Endpoints: []string{"completions"}})
+	assert.NotEqual(t, hash, configHash(), "hash must change with the effective configuration")
+	assert.Equal(t, 1, testutil.CollectAndCount(configInfo), "stale series must be removed")
+	assert.Equal(t, 1.0, testutil.ToFloat64(configInfo.WithLabelValues(
+		configHash(), "openai", (5*time.Minute).String(), usageGroupBy, "completions")))
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Debug Endpoints
+
+var enablePprof = flag.Bool("web.enable-pprof", false, "Serve Go runtime profiles under /debug/pprof/ for profiling memory and CPU in production")
+
+// endpointStatus is the outcome of the fetches of one endpoint of an organization.
+type endpointStatus struct {
+	OrgID               string    `json:"org_id"`
+	Endpoint            string    `json:"endpoint"`
+	LastAttempt         time.Time `json:"last_attempt"`
+	LastSuccess         time.Time `json:"last_success,omitzero"`
+	LastError           string    `json:"last_error,omitempty"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+}
+
+var (
+	statusMu sync.Mutex
+	// endpointStatuses holds the status of every fetched endpoint by organization and endpoint.
+	endpointStatuses = make(map[string]*endpointStatus)
+)
+
+// recordEndpointStatus records the outcome of a fetch of endpoint for the organization orgID.
+func recordEndpointStatus(orgID, endpoint string, err error, now time.Time) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	key := orgID + "|" + endpoint
+	s, ok := endpointStatuses[key]
+	if !ok {
+		s = &endpointStatus{OrgID: orgID, Endpoint: endpoint}
+		endpointStatuses[key] = s
+	}
+	s.LastAttempt = now
+	if err != nil {
+		s.LastError = err.Error()
+		s.ConsecutiveFailures++
+		return
+	}
+	s.LastSuccess = now
+	s.LastError = ""
+	s.ConsecutiveFailures = 0
+}
+
+// debugState is the response of /debug/state.
+type debugState struct {
+	LastScrape   int64              `json:"last_scrape"`
+	UsageState   map[string]float64 `json:"usage_state"`
+	ProjectNames map[string]string  `json:"project_names"`
+	APIKeyNames  map[string]string  `json:"api_key_names"`
+	Endpoints    []endpointStatus   `json:"endpoints"`
+}
+
+// snapshotDebugState copies the deduplication state, keeping only usage state keys that contain match.
+func snapshotDebugState(match string) debugState {
+	state := debugState{
+		UsageState:   make(map[string]float64),
+		ProjectNames: make(map[string]string),
+		APIKeyNames:  make(map[string]string),
+		Endpoints:    []endpointStatus{},
+	}
+
+	stateMu.RLock()
+	state.LastScrape = lastScrape
+	for key, value := range usageState {
+		if strings.Contains(key, match) {
+			state.UsageState[key] = value
+		}
+	}
+	for id, name := range projectNames {
+		state.ProjectNames[id] = name
+	}
+	for id, name := range apiKeyNames {
+		state.APIKeyNames[id] = name
+	}
+	stateMu.RUnlock()
+
+	statusMu.Lock()
+	for _, key := range sortedKeys(endpointStatuses) {
+		state.Endpoints = append(state.Endpoints, *endpointStatuses[key])
+	}
+	statusMu.Unlock()
+	return state
+}
+
+// stateHandler serves GET /debug/state. Requests must carry the token as a bearer token;
+// without a token the endpoint is disabled. The match query parameter narrows the usage state
+// to keys containing it, e.g. an operation, bucket start or project ID.
+func stateHandler(token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "only GET is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if token == "" {
+			http.Error(w, "the state endpoint is disabled, set OPENAI_EXPORTER_DEBUG_TOKEN to enable it", http.StatusForbidden)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(snapshotDebugState(r.URL.Query().Get("match"))); err != nil {
+			logrus.WithError(err).Error("Failed to write response")
+		}
+	}
+}
+
+// registerDebugHandlers adds the enabled debug endpoints to mux.
+func registerDebugHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/state", stateHandler(os.Getenv("OPENAI_EXPORTER_DEBUG_TOKEN")))
+	if *enablePprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterDebugHandlers(t *testing.T) {
+	orig := *enablePprof
+	defer func() { *enablePprof = orig }()
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		wantStatus int
+	}{
+		{name: "disabled", enabled: false, wantStatus: http.StatusNotFound},
+		{name: "enabled", enabled: true, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*enablePprof = tt.enabled
+			mux := http.NewServeMux()
+			registerDebugHandlers(mux)
+
+			for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap", "/debug/pprof/cmdline"} {
+				rec := httptest.NewRecorder()
+				mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+				assert.Equal(t, tt.wantStatus, rec.Code, path)
+			}
+		})
+	}
+}
+
+func TestStateHandler(t *testing.T) {
+	origLast := lastScrape
+	defer func() { lastScrape = origLast }()
+	usageState = map[string]float64{
+		"completions|1000|proj-1|user-1|key-1|gpt-4|false|input|org-1":  42,
+		"embeddings|1000|proj-1|user-1|key-1|ada-002|false|input|org-1": 7,
+	}
+	projectNames = map[string]string{"proj-1": "production"}
+	apiKeyNames = map[string]string{"key-1": "ci"}
+	lastScrape = 1060
+	endpointStatuses = make(map[string]*endpointStatus)
+	now := time.Unix(1100, 0).UTC()
+	recordEndpointStatus("org-1", "completions", nil, now)
+	recordEndpointStatus("org-1", "costs", errors.New("status 500"), now)
+	recordEndpointStatus("org-1", "costs", errors.New("status 502"), now)
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		method     string
+		auth       string
+		wantStatus int
+	}{
+		{name: "disabled without token", handler: stateHandler(""), method: http.MethodGet, wantStatus: http.StatusForbidden},
+		{name: "wrong token", handler: stateHandler("secret"), method: http.MethodGet, auth: "Bearer wrong", wantStatus: http.StatusUnauthorized},
+		{name: "POST is not allowed", handler: stateHandler("secret"), method: http.MethodPost, auth: "Bearer secret", wantStatus: http.StatusMethodNotAllowed},
+		{name: "authorized", handler: stateHandler("secret"), method: http.MethodGet, auth: "Bearer secret", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/debug/state?match=completions", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			tt.handler(rec, req)
+			require.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var state debugState
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &state))
+			assert.Equal(t, int64(1060), state.LastScrape)
+			assert.Equal(t, map[string]float64{"completions|1000|proj-1|user-1|key-1|gpt-4|false|input|org-1": 42}, state.UsageState)
+			assert.Equal(t, map[string]string{"proj-1": "production"}, state.ProjectNames)
+			assert.Equal(t, map[string]string{"key-1": "ci"}, state.APIKeyNames)
+			assert.Equal(t, []endpointStatus{
+				{OrgID: "org-1", Endpoint: "completions", LastAttempt: now, LastSuccess: now},
+				{OrgID: "org-1", Endpoint: "costs", LastAttempt: now, LastError: "status 502", ConsecutiveFailures: 2},
+			}, state.Endpoints)
+		})
+	}
+}
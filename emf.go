@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+)
+
+// CloudWatch Embedded Metric Format
+
+var (
+	emfOutput     = flag.String("emf.output", "", "Write the OpenAI metrics as CloudWatch Embedded Metric Format log lines to this file, or - for stdout (disabled when empty)")
+	emfInterval   = flag.Duration("emf.interval", time.Minute, "Interval at which metrics are written to -emf.output")
+	emfNamespace  = flag.String("emf.namespace", "OpenAI", "CloudWatch namespace of the metrics written to -emf.output")
+	emfDimensions = flag.String("emf.dimensions", "org_name,project_name,model,token_type,line_item", "Comma-separated labels that become CloudWatch dimensions; other labels are only logged as properties")
+)
+
+// validateEMF checks the EMF flags.
+func validateEMF() error {
+	if *emfOutput == "" {
+		return nil
+	}
+	if *emfInterval <= 0 {
+		return fmt.Errorf("emf.interval must be positive, got %s", *emfInterval)
+	}
+	if *emfNamespace == "" {
+		return fmt.Errorf("emf.namespace must not be empty")
+	}
+	if dims := splitList(*emfDimensions); len(dims) > 30 {
+		return fmt.Errorf("emf.dimensions lists %d labels, CloudWatch allows at most 30", len(dims))
+	}
+	return nil
+}
+
+// emfWriter writes metrics as CloudWatch Embedded Metric Format, one JSON log line per series.
+type emfWriter struct {
+	mu         sync.Mutex
+	out        io.Writer
+	namespace  string
+	dimensions []string
+	// previous holds the value of every counter when it was last written, so only its growth is written.
+	previous map[string]float64
+}
+
+func newEMFWriter(out io.Writer, namespace string, dimensions []string) *emfWriter {
+	return &emfWriter{out: out, namespace: namespace, dimensions: dimensions, previous: make(map[string]float64)}
+}
+
+// emfUnit returns the CloudWatch unit of a metric, derived from the suffix of its name.
+func emfUnit(name string) string {
+	switch {
+	case strings.HasSuffix(name, "_seconds"), strings.HasSuffix(name, "_seconds_total"):
+		return "Seconds"
+	case strings.HasSuffix(name, "_bytes"), strings.HasSuffix(name, "_bytes_total"):
+		return "Bytes"
+	case strings.HasSuffix(name, "_usd"), strings.HasSuffix(name, "_usd_total"), strings.HasSuffix(name, "_ratio"):
+		return "None"
+	case strings.HasSuffix(name, "_total"):
+		return "Count"
+	}
+	return "None"
+}
+
+// write writes the OpenAI metrics of g with the timestamp now. Gauges are written as they are, counters as
+// their growth since the previous write, so CloudWatch sums per period add up; unchanged counters are
+// left out. Labels with empty values are dropped, since CloudWatch rejects empty dimension values.
+func (e *emfWriter) write(g prometheus.Gatherer, now time.Time) error {
+	families, err := openaiGatherer{gatherer: g}.Gather()
+	if err != nil {
+		return fmt.Errorf("error gathering metrics: %w", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	enc := json.NewEncoder(e.out)
+	for _, mf := range families {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			var value float64
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				key := name
+				for _, lp := range m.GetLabel() {
+					key += "\xff" + lp.GetName() + "=" + lp.GetValue()
+				}
+				value = m.GetCounter().GetValue() - e.previous[key]
+				e.previous[key] = m.GetCounter().GetValue()
+				if value == 0 {
+					continue
+				}
+			case dto.MetricType_GAUGE:
+				value = m.GetGauge().GetValue()
+			case dto.MetricType_UNTYPED:
+				value = m.GetUntyped().GetValue()
+			default:
+				continue
+			}
+			if err := enc.Encode(e.record(name, metricLabels(m), value, now)); err != nil {
+				return fmt.Errorf("error writing EMF record: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// record returns the EMF log record of one sample.
+func (e *emfWriter) record(name string, labels map[string]string, value float64, now time.Time) map[string]any {
+	rec := map[string]any{name: value}
+	dims := []string{}
+	for k, v := range labels {
+		if v == "" {
+			continue
+		}
+		rec[k] = v
+		if slices.Contains(e.dimensions, k) {
+			dims = append(dims, k)
+		}
+	}
+	sort.Strings(dims)
+	rec["_aws"] = map[string]any{
+		"Timestamp": now.UnixMilli(),
+		"CloudWatchMetrics": []map[string]any{{
+			"Namespace":  e.namespace,
+			"Dimensions": [][]string{dims},
+			"Metrics":    []map[string]string{{"Name": name, "Unit": emfUnit(name)}},
+		}},
+	}
+	return rec
+}
+
+func metricLabels(m *dto.Metric) map[string]string {
+	labels := make(map[string]string, len(m.GetLabel()))
+	for _, lp := range m.GetLabel() {
+		labels[lp.GetName()] = lp.GetValue()
+	}
+	return labels
+}
+
+// runEMF starts writing the metrics of g to -emf.output every -emf.interval when it is set and returns the
+// function that writes them a last time and stops on shutdown.
+func runEMF(g prometheus.Gatherer) func() {
+	if *emfOutput == "" {
+		return func() {}
+	}
+	var out io.Writer = os.Stdout
+	closeOut := func() {}
+	if *emfOutput != "-" {
+		f, err := os.OpenFile(*emfOutput, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			logrus.Fatalf("Error opening EMF output: %s", err)
+		}
+		out, closeOut = f, func() { _ = f.Close() }
+	}
+	w := newEMFWriter(out, *emfNamespace, splitList(*emfDimensions))
+	logrus.Infof("Writing CloudWatch EMF metrics to %s every %s", *emfOutput, *emfInterval)
+
+	stop := runEvery(*emfInterval, func(now time.Time) {
+		if err := w.write(g, now); err != nil {
+			logrus.WithError(err).Warn("Error writing EMF metrics")
+		}
+	})
+	return func() {
+		stop()
+		closeOut()
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEMFUnit(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "openai_api_tokens_total", want: "Count"},
+		{name: "openai_api_audio_seconds_total", want: "Seconds"},
+		{name: "openai_files_bytes", want: "Bytes"},
+		{name: "openai_api_costs_usd_total", want: "None"},
+		{name: "openai_budget_utilization_ratio", want: "None"},
+		{name: "openai_api_daily_cost", want: "None"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, emfUnit(tt.name))
+		})
+	}
+}
+
+func TestEMFWriter(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	tokens := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "openai_api_tokens_total", Help: "Tokens."}, []string{"org_name", "model", "user_id", "batch"})
+	cost := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "openai_api_daily_cost", Help: "Cost."}, []string{"org_name", "line_item"})
+	goInfo := prometheus.NewGauge(prometheus.GaugeOpts{Name: "go_info", Help: "Not an OpenAI metric."})
+	reg.MustRegister(tokens, cost, goInfo)
+
+	var out bytes.Buffer
+	w := newEMFWriter(&out, "OpenAI", []string{"org_name", "model", "line_item"})
+	now := time.Unix(1717842660, 0)
+	lines := func() []map[string]any {
+		var records []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var rec map[string]any
+			require.NoError(t, json.Unmarshal([]byte(line), &rec))
+			records = append(records, rec)
+		}
+		out.Reset()
+		return records
+	}
+
+	tokens.WithLabelValues("prod", "gpt-4o", "user-1", "").Add(100)
+	cost.WithLabelValues("prod", "completions").Set(1.5)
+	require.NoError(t, w.write(reg, now))
+	records := lines()
+	require.Len(t, records, 2)
+	assert.Equal(t, map[string]any{
+		"openai_api_daily_cost": 1.5,
+		"org_name":              "prod",
+		"line_item":             "completions",
+		"_aws": map[string]any{
+			"Timestamp": 1717842660000.0,
+			"CloudWatchMetrics": []any{map[string]any{
+				"Namespace":  "OpenAI",
+				"Dimensions": []any{[]any{"line_item", "org_name"}},
+				"Metrics":    []any{map[string]any{"Name": "openai_api_daily_cost", "Unit": "None"}},
+			}},
+		},
+	}, records[0])
+	assert.Equal(t, 100.0, records[1]["openai_api_tokens_total"])
+	assert.Equal(t, "user-1", records[1]["user_id"], "other labels are properties")
+	assert.NotContains(t, records[1], "batch", "empty labels are dropped")
+	assert.Equal(t, []any{[]any{"model", "org_name"}}, records[1]["_aws"].(map[string]any)["CloudWatchMetrics"].([]any)[0].(map[string]any)["Dimensions"])
+
+	tokens.WithLabelValues("prod", "gpt-4o", "user-1", "").Add(20)
+	require.NoError(t, w.write(reg, now.Add(time.Minute)))
+	records = lines()
+	require.Len(t, records, 2)
+	assert.Equal(t, 20.0, records[1]["openai_api_tokens_total"], "counters are written as their growth")
+
+	require.NoError(t, w.write(reg, now.Add(2*time.Minute)))
+	assert.Len(t, lines(), 1, "unchanged counters are left out")
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// API Targets and Failover
+
+const defaultBaseURL = "https://api.openai.com"
+
+var (
+	baseURLs          = flag.String("openai.base-url", defaultBaseURL, "Comma-separated, ordered list of OpenAI API base URLs; requests fail over to the next one on sustained errors (overridden per organization by base_url in the configuration file)")
+	failoverThreshold = flag.Int("openai.failover-threshold", 3, "Number of consecutive failed requests after which the next API base URL becomes active")
+
+	apiTargetActive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_exporter_api_target_active",
+			Help: "Whether the API base URL is the one currently used for requests of the organization (1) or a standby (0).",
+		},
+		[]string{"org_id", "base_url"},
+	)
+)
+
+// apiTargets is the ordered list of API base URLs of one organization, of which exactly one is active at a time.
+// A nil *apiTargets always resolves to defaultBaseURL.
+type apiTargets struct {
+	mu        sync.Mutex
+	orgID     string
+	urls      []string
+	active    int
+	failures  int
+	threshold int
+}
+
+func newAPITargets(orgID, list string, threshold int) *apiTargets {
+	var urls []string
+	for _, u := range strings.Split(list, ",") {
+		if u = strings.TrimSuffix(strings.TrimSpace(u), "/"); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	if len(urls) == 0 {
+		urls = []string{defaultBaseURL}
+	}
+	if threshold < 1 {
+		threshold = 1
+	}
+	t := &apiTargets{orgID: orgID, urls: urls, threshold: threshold}
+	t.updateMetric()
+	return t
+}
+
+// current returns the active base URL.
+func (t *apiTargets) current() string {
+	if t == nil {
+		return defaultBaseURL
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.urls[t.active]
+}
+
+// report records the outcome of a request sent to baseURL. After threshold consecutive failures
+// of the active target the next one in the list becomes active, wrapping around at the end.
+func (t *apiTargets) report(baseURL string, ok bool) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Outcomes of requests that were sent before a failover say nothing about the new target.
+	if t.urls[t.active] != baseURL {
+		return
+	}
+	if ok {
+		t.failures = 0
+		return
+	}
+	t.failures++
+	if t.failures < t.threshold || len(t.urls) == 1 {
+		return
+	}
+
+	t.active = (t.active + 1) % len(t.urls)
+	t.failures = 0
+	logrus.Warnf("API base URL %s failed %d times in a row, failing over to %s", baseURL, t.threshold, t.urls[t.active])
+	t.updateMetric()
+}
+
+func (t *apiTargets) updateMetric() {
+	for i, u := range t.urls {
+		if i == t.active {
+			apiTargetActive.WithLabelValues(t.orgID, u).Set(1)
+		} else {
+			apiTargetActive.WithLabelValues(t.orgID, u).Set(0)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAPITargets(t *testing.T) {
+	targets := newAPITargets("", " https://a.example/ ,,https://b.example", 0)
+	assert.Equal(t, []string{"https://a.example", "https://b.example"}, targets.urls)
+	assert.Equal(t, 1, targets.threshold)
+
+	targets = newAPITargets("", "", 3)
+	assert.Equal(t, []string{defaultBaseURL}, targets.urls)
+}
+
+func TestAPITargets_Failover(t *testing.T) {
+	targets := newAPITargets("org-1", "https://primary.example,https://secondary.example", 2)
+	assert.Equal(t, "https://primary.example", targets.current())
+	assert.Equal(t, 1.0, testutil.ToFloat64(apiTargetActive.WithLabelValues("org-1", "https://primary.example")))
+
+	t.Run("success resets the failure streak", func(t *testing.T) {
+		targets.report("https://primary.example", false)
+		targets.report("https://primary.example", true)
+		targets.report("https://primary.example", false)
+		assert.Equal(t, "https://primary.example", targets.current())
+	})
+
+	t.Run("sustained errors fail over", func(t *testing.T) {
+		targets.report("https://primary.example", false)
+		assert.Equal(t, "https://secondary.example", targets.current())
+		assert.Equal(t, 0.0, testutil.ToFloat64(apiTargetActive.WithLabelValues("org-1", "https://primary.example")))
+		assert.Equal(t, 1.0, testutil.ToFloat64(apiTargetActive.WithLabelValues("org-1", "https://secondary.example")))
+	})
+
+	t.Run("stale reports for the previous target are ignored", func(t *testing.T) {
+		targets.report("https://primary.example", false)
+		targets.report("https://primary.example", false)
+		assert.Equal(t, "https://secondary.example", targets.current())
+	})
+
+	t.Run("wraps around to the first target", func(t *testing.T) {
+		targets.report("https://secondary.example", false)
+		targets.report("https://secondary.example", false)
+		assert.Equal(t, "https://primary.example", targets.current())
+	})
+}
+
+func TestAPITargets_Nil(t *testing.T) {
+	var targets *apiTargets
+	assert.Equal(t, defaultBaseURL, targets.current())
+	targets.report(defaultBaseURL, false)
+}
+
+func TestExporterGet_FailsOver(t *testing.T) {
+	var gotAuth, gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotPath = r.URL.RequestURI()
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	e := &Exporter{
+		client:  server.Client(),
+		apiKey:  "test-key",
+		targets: newAPITargets("", "http://127.0.0.1:0,"+server.URL, 1),
+	}
+
+	_, err := e.get("/v1/organization/costs?limit=1")
+	assert.Error(t, err)
+	assert.Equal(t, server.URL, e.targets.current())
+
+	resp, err := e.get("/v1/organization/costs?limit=1")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, "Bearer test-key", gotAuth)
+	assert.Equal(t, "/v1/organization/costs?limit=1", gotPath)
+}
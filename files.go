@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// File Storage
+
+var (
+	filesEnabled  = flag.Bool("collector.files", false, "Export the number and size of the files stored in each project by purpose")
+	filesInterval = flag.Duration("collector.files.interval", 15*time.Minute, "Interval at which the files of all projects are re-read")
+
+	filesCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_files_count",
+			Help: "Number of files stored per project and purpose.",
+		},
+		[]string{"org_id", "org_name", "project_id", "project_name", "purpose"},
+	)
+	filesBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_files_bytes",
+			Help: "Bytes of the files stored per project and purpose.",
+		},
+		[]string{"org_id", "org_name", "project_id", "project_name", "purpose"},
+	)
+)
+
+type FileList struct {
+	Object  string `json:"object"`
+	Data    []File `json:"data"`
+	HasMore bool   `json:"has_more"`
+	LastID  string `json:"last_id"`
+}
+
+type File struct {
+	ID      string `json:"id"`
+	Bytes   int64  `json:"bytes"`
+	Purpose string `json:"purpose"`
+}
+
+// fetchFiles lists the files stored in a project.
+func (e *Exporter) fetchFiles(projectID string) ([]File, error) {
+	var files []File
+	after := ""
+	guard := newPageGuard(e.orgID, "files")
+
+	for {
+		path := "/v1/files?limit=10000"
+		if after != "" {
+			path += "&after=" + url.QueryEscape(after)
+		}
+
+		resp, err := e.getInProject(path, projectID)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching files of project %s: %w", projectID, err)
+		}
+
+		var out FileList
+		decodeErr := json.NewDecoder(resp.Body).Decode(&out)
+		closeErr := resp.Body.Close()
+
+		if decodeErr != nil {
+			return nil, fmt.Errorf("error decoding response: %w", decodeErr)
+		}
+		if closeErr != nil {
+			logrus.WithError(closeErr).Warn("failed to close response body")
+		}
+
+		pagesFetchedTotal.WithLabelValues(e.orgID, "files").Inc()
+
+		files = append(files, out.Data...)
+		if !out.HasMore || out.LastID == "" || !guard.next(out.LastID) {
+			return files, nil
+		}
+		after = out.LastID
+	}
+}
+
+// collectFiles sums the files of all active projects by purpose and replaces the exported ones of the organization.
+// The previous values stay exported if any project cannot be read.
+func (e *Exporter) collectFiles() error {
+	projects, err := e.fetchProjects()
+	if err != nil {
+		return err
+	}
+
+	type storage struct {
+		labels prometheus.Labels
+		count  int
+		bytes  int64
+	}
+	current := make(map[string]*storage)
+	for _, p := range projects {
+		if p.Status == "archived" || !exportsProject(p.ID, func() string { return p.Name }) {
+			continue
+		}
+		files, err := e.fetchFiles(p.ID)
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			key := p.ID + "|" + f.Purpose
+			s, ok := current[key]
+			if !ok {
+				s = &storage{labels: prometheus.Labels{
+					"org_id":       e.orgID,
+					"org_name":     e.orgName,
+					"project_id":   p.ID,
+					"project_name": p.Name,
+					"purpose":      f.Purpose,
+				}}
+				current[key] = s
+			}
+			s.count++
+			s.bytes += f.Bytes
+		}
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	e.rememberProjectNames(projects, time.Now())
+	filesCount.DeletePartialMatch(prometheus.Labels{"org_id": e.orgID})
+	filesBytes.DeletePartialMatch(prometheus.Labels{"org_id": e.orgID})
+	for _, s := range current {
+		filesCount.With(s.labels).Set(float64(s.count))
+		filesBytes.With(s.labels).Set(float64(s.bytes))
+	}
+	return nil
+}
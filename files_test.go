@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectFiles(t *testing.T) {
+	projectNames = make(map[string]string)
+	filesCount.Reset()
+	filesBytes.Reset()
+
+	files := map[string]string{
+		"proj-1": `[
+			{"id": "file-1", "bytes": 1000, "purpose": "fine-tune"},
+			{"id": "file-2", "bytes": 500, "purpose": "fine-tune"},
+			{"id": "file-3", "bytes": 20, "purpose": "assistants"}
+		]`,
+		"proj-2": `[]`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/organization/projects":
+			_, _ = w.Write([]byte(`{"object": "list", "data": [
+				{"id": "proj-1", "name": "one", "status": "active"},
+				{"id": "proj-2", "name": "two", "status": "active"}
+			], "has_more": false}`))
+		case "/v1/files":
+			_, _ = w.Write([]byte(`{"object": "list", "data": ` + files[r.Header.Get("OpenAI-Project")] + `, "has_more": false}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+
+	require.NoError(t, e.collectFiles())
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(filesCount.WithLabelValues("org-1", "prod", "proj-1", "one", "fine-tune")))
+	assert.Equal(t, 1500.0, testutil.ToFloat64(filesBytes.WithLabelValues("org-1", "prod", "proj-1", "one", "fine-tune")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(filesCount.WithLabelValues("org-1", "prod", "proj-1", "one", "assistants")))
+	assert.Equal(t, 20.0, testutil.ToFloat64(filesBytes.WithLabelValues("org-1", "prod", "proj-1", "one", "assistants")))
+
+	files["proj-1"] = `[{"id": "file-3", "bytes": 20, "purpose": "assistants"}]`
+	require.NoError(t, e.collectFiles())
+	assert.Equal(t, 1, testutil.CollectAndCount(filesBytes), "purposes without files are no longer exported")
+}
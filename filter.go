@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Usage Filters
+
+var (
+	userAllowFlag    = flag.String("filter.users.allow", "", "Comma-separated user IDs (glob patterns allowed) whose usage is exported individually; everyone else is aggregated as user_id=\"other\"")
+	userDenyFlag     = flag.String("filter.users.deny", "", "Comma-separated user IDs (glob patterns allowed) whose usage is aggregated as user_id=\"other\"")
+	projectAllowFlag = flag.String("filter.projects.allow", "", "Comma-separated project IDs or names (glob patterns allowed) whose usage and costs are exported; all others are dropped")
+	projectDenyFlag  = flag.String("filter.projects.deny", "", "Comma-separated project IDs or names (glob patterns allowed) whose usage and costs are dropped")
+	modelAllowFlag   = flag.String("filter.models.allow", "", "Comma-separated models (glob patterns allowed) whose usage is exported; all others are dropped")
+	modelDenyFlag    = flag.String("filter.models.deny", "", "Comma-separated models (glob patterns allowed) whose usage is dropped")
+)
+
+// otherUser is the user_id under which the usage of filtered-out users is aggregated.
+const otherUser = "other"
+
+// Filter restricts which users are exported individually, and which projects and models are exported at all.
+// An empty Allow list allows everyone; Deny wins over Allow.
+type Filter struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+// userFilter, projectFilter and modelFilter are the filters currently in effect, guarded by configMu.
+var userFilter, projectFilter, modelFilter Filter
+
+// validate checks that all patterns are well-formed; kind names what they match in errors.
+func (f Filter) validate(kind string) error {
+	for _, pattern := range append(append([]string{}, f.Allow...), f.Deny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid %s pattern %q: %w", kind, pattern, err)
+		}
+	}
+	return nil
+}
+
+// allowed reports whether usage of userID is exported under its own ID.
+func (f Filter) allowed(userID string) bool {
+	if matchAny(f.Deny, userID) {
+		return false
+	}
+	return len(f.Allow) == 0 || matchAny(f.Allow, userID)
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// filterLabels returns the labels under which usage is exported, replacing filtered-out user IDs with "other"
+// and dropping their email.
+// The input is never modified.
+func filterLabels(labels prometheus.Labels) prometheus.Labels {
+	configMu.RLock()
+	f := userFilter
+	configMu.RUnlock()
+
+	if f.allowed(labels["user_id"]) {
+		return labels
+	}
+	return mergeLabels(mergeLabels(labels, "user_id", otherUser), "user_email", "")
+}
+
+// exportsResult reports whether the usage or costs of a project and model are exported. Results without
+// a model, like costs, are only filtered by project.
+func (e *Exporter) exportsResult(projectID, model *string) bool {
+	configMu.RLock()
+	mf := modelFilter
+	configMu.RUnlock()
+
+	if model != nil && !mf.allowed(*model) {
+		return false
+	}
+	return exportsProject(deref(projectID), func() string { return e.ensureProjectName(deref(projectID)) })
+}
+
+// exportsProject reports whether the usage, costs and resources of a project are exported by this replica.
+func exportsProject(id string, name func() string) bool {
+	if !ownsProject(id) {
+		return false
+	}
+	configMu.RLock()
+	pf := projectFilter
+	configMu.RUnlock()
+	return pf.matchesProject(id, name)
+}
+
+// matchesProject reports whether a project passes the filter by its ID or its name, which is looked up
+// only if there are patterns to match.
+func (f Filter) matchesProject(id string, name func() string) bool {
+	if len(f.Allow) == 0 && len(f.Deny) == 0 {
+		return true
+	}
+	if matchAny(f.Deny, id) {
+		return false
+	}
+	projectName := name()
+	if matchAny(f.Deny, projectName) {
+		return false
+	}
+	return len(f.Allow) == 0 || matchAny(f.Allow, id) || matchAny(f.Allow, projectName)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserFilter_Allowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   Filter
+		userID   string
+		expected bool
+	}{
+		{name: "no filter", filter: Filter{}, userID: "user-1", expected: true},
+		{name: "allowlisted", filter: Filter{Allow: []string{"user-1"}}, userID: "user-1", expected: true},
+		{name: "not allowlisted", filter: Filter{Allow: []string{"user-1"}}, userID: "user-2", expected: false},
+		{name: "denylisted", filter: Filter{Deny: []string{"user-bot-*"}}, userID: "user-bot-ci", expected: false},
+		{name: "deny wins over allow", filter: Filter{Allow: []string{"user-*"}, Deny: []string{"user-bot-*"}}, userID: "user-bot-ci", expected: false},
+		{name: "glob allow", filter: Filter{Allow: []string{"user-*"}}, userID: "user-3", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.filter.allowed(tt.userID))
+		})
+	}
+}
+
+func TestUserFilter_Validate(t *testing.T) {
+	assert.NoError(t, Filter{Allow: []string{"user-*"}}.validate("user"))
+	assert.Error(t, Filter{Deny: []string{"user-["}}.validate("user"))
+}
+
+func TestFilter_MatchesProject(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   Filter
+		id       string
+		expected bool
+	}{
+		{name: "no filter", filter: Filter{}, id: "proj_1", expected: true},
+		{name: "allowed by ID", filter: Filter{Allow: []string{"proj_1"}}, id: "proj_1", expected: true},
+		{name: "allowed by name", filter: Filter{Allow: []string{"team-a-*"}}, id: "proj_1", expected: true},
+		{name: "not allowed", filter: Filter{Allow: []string{"team-b-*"}}, id: "proj_1", expected: false},
+		{name: "denied by ID", filter: Filter{Deny: []string{"proj_*"}}, id: "proj_1", expected: false},
+		{name: "denied by name", filter: Filter{Deny: []string{"*-staging"}}, id: "proj_1", expected: false},
+		{name: "deny wins over allow", filter: Filter{Allow: []string{"proj_1"}, Deny: []string{"*-staging"}}, id: "proj_1", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.filter.matchesProject(tt.id, func() string { return "team-a-staging" }))
+		})
+	}
+
+	lookedUp := false
+	assert.True(t, Filter{}.matchesProject("proj_1", func() string { lookedUp = true; return "" }))
+	assert.False(t, lookedUp, "the name is not looked up without patterns")
+}
+
+func TestFetchUsageData_FiltersProjectsAndModels(t *testing.T) {
+	usageState = make(map[string]float64)
+	projectNames = map[string]string{"proj_1": "team-a", "proj_2": "team-b"}
+	projectFilter = Filter{Allow: []string{"team-a"}}
+	modelFilter = Filter{Deny: []string{"gpt-4o-mini*"}}
+	defer func() { projectFilter, modelFilter = Filter{}, Filter{} }()
+
+	end := time.Now().Add(-time.Minute).Truncate(time.Minute).Unix()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"object": "page", "data": [{"start_time": ` + strconv.FormatInt(end-60, 10) + `, "end_time": ` + strconv.FormatInt(end, 10) + `, "results": [
+			{"input_tokens": 10, "project_id": "proj_1", "model": "gpt-4o"},
+			{"input_tokens": 20, "project_id": "proj_1", "model": "gpt-4o-mini-2024-07-18"},
+			{"input_tokens": 30, "project_id": "proj_2", "model": "gpt-4o"}
+		]}], "has_more": false}`))
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+
+	require.NoError(t, e.fetchUsageData(UsageEndpoint{Path: "completions", Name: "completions"}, end-60, end))
+
+	series := make(map[string]float64)
+	for key, value := range usageState {
+		parts := strings.Split(key, "|")
+		series[parts[2]+"/"+parts[5]] += value
+	}
+	assert.Equal(t, map[string]float64{"proj_1/gpt-4o": 10}, series)
+}
+
+func TestSplitList(t *testing.T) {
+	assert.Equal(t, []string{"a", "b"}, splitList(" a, ,b,"))
+	assert.Nil(t, splitList(""))
+}
+
+func TestUpdateMetric_AggregatesFilteredUsers(t *testing.T) {
+	usageState = make(map[string]float64)
+	tokensTotal.Reset()
+	userFilter = Filter{Deny: []string{"user-bot-*"}}
+	defer func() { userFilter = Filter{} }()
+
+	now := time.Now().Unix()
+	labels := func(user string) prometheus.Labels {
+		return prometheus.Labels{
+			"org_id": "org-1", "org_name": "prod", "model": "gpt-4", "operation": "completions", "project_id": "proj-1", "project_name": "p",
+			"user_id": user, "user_email": user + "@example.com", "api_key_id": "key-1", "api_key_name": "k", "batch": "false",
+		}
+	}
+
+	updateMetric(labels("user-bot-a"), "input", now-120, now-60, 10)
+	updateMetric(labels("user-bot-b"), "input", now-120, now-60, 5)
+	updateMetric(labels("user-human"), "input", now-120, now-60, 7)
+
+	require.Len(t, usageState, 3)
+	assert.Equal(t, 15.0, testutil.ToFloat64(tokensTotal.WithLabelValues("org-1", "prod", "gpt-4", "completions", "proj-1", "p", otherUser, "", "key-1", "k", "false", "input")))
+	assert.Equal(t, 7.0, testutil.ToFloat64(tokensTotal.WithLabelValues("org-1", "prod", "gpt-4", "completions", "proj-1", "p", "user-human", "user-human@example.com", "key-1", "k", "false", "input")))
+}
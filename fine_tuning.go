@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Fine-Tuning Jobs
+
+var (
+	fineTuningEnabled  = flag.Bool("collector.fine-tuning", false, "Export the status, trained tokens and durations of the organization's fine-tuning jobs")
+	fineTuningInterval = flag.Duration("collector.fine-tuning.interval", 5*time.Minute, "Interval at which the fine-tuning jobs are re-read")
+
+	fineTuningJobs = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_fine_tuning_jobs",
+			Help: "Number of fine-tuning jobs by status.",
+		},
+		[]string{"org_id", "org_name", "status"},
+	)
+	fineTuningTrainedTokens = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "openai_fine_tuning_trained_tokens_total",
+			Help: "Total number of tokens trained by finished fine-tuning jobs, by base model.",
+		},
+		[]string{"org_id", "org_name", "model"},
+	)
+	fineTuningJobDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "openai_fine_tuning_job_duration_seconds",
+			Help:    "Time from creation to completion of finished fine-tuning jobs, by final status.",
+			Buckets: prometheus.ExponentialBuckets(60, 2, 12),
+		},
+		[]string{"org_id", "org_name", "status"},
+	)
+)
+
+// fineTuningStatuses are the statuses of fine-tuning jobs, exported even when no job has them.
+var fineTuningStatuses = []string{"validating_files", "queued", "running", "succeeded", "failed", "cancelled"}
+
+var (
+	// fineTuningFinished maps the IDs of the jobs whose tokens and duration have been recorded to their finished_at.
+	fineTuningFinished = make(map[string]int64)
+	// fineTuningSince is when the exporter started; jobs that finished earlier are not recorded.
+	fineTuningSince = time.Now().Unix()
+)
+
+type FineTuningJobList struct {
+	Object  string          `json:"object"`
+	Data    []FineTuningJob `json:"data"`
+	HasMore bool            `json:"has_more"`
+}
+
+type FineTuningJob struct {
+	ID            string `json:"id"`
+	Model         string `json:"model"`
+	Status        string `json:"status"`
+	CreatedAt     int64  `json:"created_at"`
+	FinishedAt    *int64 `json:"finished_at"`
+	TrainedTokens *int64 `json:"trained_tokens"`
+}
+
+// finished reports whether the job has reached a final status.
+func (j FineTuningJob) finished() bool {
+	return j.Status == "succeeded" || j.Status == "failed" || j.Status == "cancelled"
+}
+
+// fetchFineTuningJobs lists all fine-tuning jobs of the organization.
+func (e *Exporter) fetchFineTuningJobs() ([]FineTuningJob, error) {
+	var jobs []FineTuningJob
+	after := ""
+	guard := newPageGuard(e.orgID, "fine_tuning_jobs")
+
+	for {
+		path := "/v1/fine_tuning/jobs?limit=100"
+		if after != "" {
+			path += "&after=" + url.QueryEscape(after)
+		}
+
+		resp, err := e.get(path)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching fine-tuning jobs: %w", err)
+		}
+
+		var out FineTuningJobList
+		decodeErr := json.NewDecoder(resp.Body).Decode(&out)
+		closeErr := resp.Body.Close()
+
+		if decodeErr != nil {
+			return nil, fmt.Errorf("error decoding response: %w", decodeErr)
+		}
+		if closeErr != nil {
+			logrus.WithError(closeErr).Warn("failed to close response body")
+		}
+
+		pagesFetchedTotal.WithLabelValues(e.orgID, "fine_tuning_jobs").Inc()
+
+		jobs = append(jobs, out.Data...)
+		if !out.HasMore || len(out.Data) == 0 || !guard.next(out.Data[len(out.Data)-1].ID) {
+			return jobs, nil
+		}
+		after = out.Data[len(out.Data)-1].ID
+	}
+}
+
+// collectFineTuningJobs counts the jobs by status and records the trained tokens and duration of each job
+// the first time it is seen finished. Jobs that finished before the exporter started are not recorded, so a
+// restart does not count them again. Jobs are forgotten once they finished state.retention ago; jobs that
+// finished before then are not recorded either.
+func (e *Exporter) collectFineTuningJobs() error {
+	jobs, err := e.fetchFineTuningJobs()
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[string]int)
+	for _, status := range fineTuningStatuses {
+		counts[status] = 0
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	since := fineTuningSince
+	if *stateRetention > 0 {
+		cutoff := time.Now().Add(-*stateRetention).Unix()
+		for id, finishedAt := range fineTuningFinished {
+			if finishedAt < cutoff {
+				delete(fineTuningFinished, id)
+			}
+		}
+		since = max(since, cutoff)
+	}
+
+	for _, job := range jobs {
+		counts[job.Status]++
+		if _, seen := fineTuningFinished[job.ID]; seen || !job.finished() || job.FinishedAt == nil || *job.FinishedAt < since {
+			continue
+		}
+		fineTuningFinished[job.ID] = *job.FinishedAt
+		if job.TrainedTokens != nil {
+			fineTuningTrainedTokens.WithLabelValues(e.orgID, e.orgName, job.Model).Add(float64(*job.TrainedTokens))
+		}
+		if *job.FinishedAt >= job.CreatedAt {
+			fineTuningJobDuration.WithLabelValues(e.orgID, e.orgName, job.Status).Observe(float64(*job.FinishedAt - job.CreatedAt))
+		}
+	}
+
+	fineTuningJobs.DeletePartialMatch(prometheus.Labels{"org_id": e.orgID})
+	for status, count := range counts {
+		fineTuningJobs.WithLabelValues(e.orgID, e.orgName, status).Set(float64(count))
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectFineTuningJobs(t *testing.T) {
+	origSince, origRetention := fineTuningSince, *stateRetention
+	fineTuningSince, *stateRetention = 0, 0
+	defer func() { fineTuningSince, *stateRetention = origSince, origRetention }()
+	fineTuningFinished = make(map[string]int64)
+	fineTuningJobs.Reset()
+	fineTuningTrainedTokens.Reset()
+	fineTuningJobDuration.Reset()
+
+	pages := map[string]string{
+		"": `{"object": "list", "data": [
+			{"id": "ftjob-3", "model": "gpt-4o-mini", "status": "running", "created_at": 3000},
+			{"id": "ftjob-2", "model": "gpt-4o-mini", "status": "succeeded", "created_at": 1000, "finished_at": 1600, "trained_tokens": 5000}
+		], "has_more": true}`,
+		"ftjob-2": `{"object": "list", "data": [
+			{"id": "ftjob-1", "model": "gpt-4o", "status": "failed", "created_at": 100, "finished_at": 220, "trained_tokens": null}
+		], "has_more": false}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/fine_tuning/jobs", r.URL.Path)
+		_, _ = w.Write([]byte(pages[r.URL.Query().Get("after")]))
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+	jobs := func(status string) float64 {
+		return testutil.ToFloat64(fineTuningJobs.WithLabelValues("org-1", "prod", status))
+	}
+
+	require.NoError(t, e.collectFineTuningJobs())
+	require.NoError(t, e.collectFineTuningJobs())
+
+	assert.Equal(t, 1.0, jobs("running"))
+	assert.Equal(t, 1.0, jobs("succeeded"))
+	assert.Equal(t, 1.0, jobs("failed"))
+	assert.Equal(t, 0.0, jobs("queued"))
+	assert.Equal(t, 5000.0, testutil.ToFloat64(fineTuningTrainedTokens.WithLabelValues("org-1", "prod", "gpt-4o-mini")),
+		"finished jobs are only counted once")
+	assert.NoError(t, testutil.CollectAndCompare(fineTuningJobDuration, strings.NewReader(`
+# HELP openai_fine_tuning_job_duration_seconds Time from creation to completion of finished fine-tuning jobs, by final status.
+# TYPE openai_fine_tuning_job_duration_seconds histogram
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="failed",le="60"} 0
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="failed",le="120"} 1
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="failed",le="240"} 1
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="failed",le="480"} 1
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="failed",le="960"} 1
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="failed",le="1920"} 1
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="failed",le="3840"} 1
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="failed",le="7680"} 1
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="failed",le="15360"} 1
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="failed",le="30720"} 1
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="failed",le="61440"} 1
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="failed",le="122880"} 1
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="failed",le="+Inf"} 1
+openai_fine_tuning_job_duration_seconds_sum{org_id="org-1",org_name="prod",status="failed"} 120
+openai_fine_tuning_job_duration_seconds_count{org_id="org-1",org_name="prod",status="failed"} 1
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="succeeded",le="60"} 0
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="succeeded",le="120"} 0
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="succeeded",le="240"} 0
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="succeeded",le="480"} 0
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="succeeded",le="960"} 1
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="succeeded",le="1920"} 1
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="succeeded",le="3840"} 1
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="succeeded",le="7680"} 1
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="succeeded",le="15360"} 1
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="succeeded",le="30720"} 1
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="succeeded",le="61440"} 1
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="succeeded",le="122880"} 1
+openai_fine_tuning_job_duration_seconds_bucket{org_id="org-1",org_name="prod",status="succeeded",le="+Inf"} 1
+openai_fine_tuning_job_duration_seconds_sum{org_id="org-1",org_name="prod",status="succeeded"} 600
+openai_fine_tuning_job_duration_seconds_count{org_id="org-1",org_name="prod",status="succeeded"} 1
+`)))
+}
+
+func TestCollectFineTuningJobs_Restart(t *testing.T) {
+	now := time.Now().Unix()
+	origSince := fineTuningSince
+	fineTuningSince = now - 3600
+	defer func() { fineTuningSince = origSince }()
+	fineTuningFinished = map[string]int64{"ftjob-gone": now - 72*3600}
+	fineTuningTrainedTokens.Reset()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"object": "list", "data": [
+			{"id": "ftjob-new", "model": "gpt-4o", "status": "succeeded", "created_at": %d, "finished_at": %d, "trained_tokens": 100},
+			{"id": "ftjob-old", "model": "gpt-4o", "status": "succeeded", "created_at": %d, "finished_at": %d, "trained_tokens": 5000}
+		], "has_more": false}`, now-600, now-60, now-7200, now-7000)
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+
+	require.NoError(t, e.collectFineTuningJobs())
+
+	assert.Equal(t, 100.0, testutil.ToFloat64(fineTuningTrainedTokens.WithLabelValues("org-1", "prod", "gpt-4o")),
+		"jobs that finished before the exporter started are not counted")
+	assert.Equal(t, map[string]int64{"ftjob-new": now - 60}, fineTuningFinished,
+		"jobs that finished more than state.retention ago are forgotten")
+}
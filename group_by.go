@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Usage Grouping
+
+var usageGroupByFlag = flag.String("usage.group-by", usageGroupBy, "Comma-separated dimensions usage is grouped by: project_id, user_id, api_key_id, model, batch and service_tier. The configuration file can override them per endpoint under group_by")
+
+// groupByLabels maps each dimension the Usage API can group by to the labels of openai_api_tokens_total it provides.
+var groupByLabels = map[string][]string{
+	"project_id":   {"project_id", "project_name"},
+	"user_id":      {"user_id", "user_email"},
+	"api_key_id":   {"api_key_id", "api_key_name"},
+	"model":        {"model"},
+	"batch":        {"batch"},
+	"service_tier": {"service_tier"},
+}
+
+// allTokenLabels is the order of the labels of openai_api_tokens_total; labels of dimensions no endpoint groups by are left out.
+var allTokenLabels = []string{"org_id", "org_name", "model", "operation", "project_id", "project_name", "user_id", "user_email",
+	"api_key_id", "api_key_name", "batch", "service_tier", "token_type"}
+
+var (
+	// defaultGroupBy and endpointGroupBy hold the group_by dimensions of all endpoints and of those
+	// that override them. Set at startup.
+	defaultGroupBy  = splitList(usageGroupBy)
+	endpointGroupBy = map[string][]string{}
+	// tokenLabels are the labels of openai_api_tokens_total.
+	tokenLabels = tokenLabelNames(defaultGroupBy, nil)
+)
+
+func newTokensTotal(labels []string) *prometheus.CounterVec {
+	return prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "openai_api_tokens_total",
+			Help: "Total number of tokens used per organization, model, operation, project, user, API key, batch and token type",
+		},
+		labels,
+	)
+}
+
+// validateGroupBy checks that every dimension can be grouped by.
+func validateGroupBy(dims []string) error {
+	if len(dims) == 0 {
+		return fmt.Errorf("group_by needs at least one dimension")
+	}
+	for _, dim := range dims {
+		if _, ok := groupByLabels[dim]; !ok {
+			return fmt.Errorf("unknown group_by dimension %q", dim)
+		}
+	}
+	return nil
+}
+
+// tokenLabelNames returns the labels of openai_api_tokens_total when endpoints are grouped by defaults
+// unless perEndpoint lists their dimensions.
+func tokenLabelNames(defaults []string, perEndpoint map[string][]string) []string {
+	grouped := make(map[string]bool)
+	add := func(dims []string) {
+		for _, dim := range dims {
+			for _, label := range groupByLabels[dim] {
+				grouped[label] = true
+			}
+		}
+	}
+	if len(perEndpoint) < len(usageEndpoints) {
+		add(defaults)
+	}
+	for _, dims := range perEndpoint {
+		add(dims)
+	}
+
+	var labels []string
+	for _, label := range allTokenLabels {
+		if _, dimension := groupedLabel(label); !dimension || grouped[label] {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// groupedLabel returns the dimension that provides label, and whether it depends on one at all.
+func groupedLabel(label string) (string, bool) {
+	for dim, labels := range groupByLabels {
+		for _, l := range labels {
+			if l == label {
+				return dim, true
+			}
+		}
+	}
+	return "", false
+}
+
+// configureGrouping validates the group_by dimensions and rebuilds openai_api_tokens_total with the labels they provide.
+// It must run before the metrics are registered.
+func configureGrouping(defaults string, perEndpoint map[string][]string) error {
+	dims := splitList(defaults)
+	if err := validateGroupBy(dims); err != nil {
+		return fmt.Errorf("-usage.group-by: %w", err)
+	}
+	for _, name := range sortedKeys(perEndpoint) {
+		if _, ok := findEndpoint(name); !ok {
+			return fmt.Errorf("group_by: unknown endpoint %q", name)
+		}
+		if err := validateGroupBy(perEndpoint[name]); err != nil {
+			return fmt.Errorf("group_by of %s: %w", name, err)
+		}
+	}
+
+	configMu.Lock()
+	defaultGroupBy, endpointGroupBy = dims, perEndpoint
+	configMu.Unlock()
+	tokenLabels = tokenLabelNames(dims, perEndpoint)
+	tokensTotal = newTokensTotal(tokenLabels)
+	return nil
+}
+
+// groupByFor returns the comma-separated group_by dimensions of the endpoint.
+func groupByFor(endpoint string) string {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	if dims, ok := endpointGroupBy[endpoint]; ok {
+		return strings.Join(dims, ",")
+	}
+	return strings.Join(defaultGroupBy, ",")
+}
+
+// groupBySummary describes the effective grouping, e.g. "project_id,model" or "project_id,model;embeddings=project_id".
+func groupBySummary() string {
+	parts := []string{strings.Join(defaultGroupBy, ",")}
+	for _, name := range sortedKeys(endpointGroupBy) {
+		parts = append(parts, name+"="+strings.Join(endpointGroupBy[name], ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// tokenSeriesLabels returns the labels of the openai_api_tokens_total series for labels,
+// dropping labels of dimensions that are not grouped by and leaving missing ones empty.
+func tokenSeriesLabels(labels prometheus.Labels) prometheus.Labels {
+	out := make(prometheus.Labels, len(tokenLabels))
+	for _, name := range tokenLabels {
+		out[name] = labels[name]
+	}
+	return out
+}
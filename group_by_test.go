@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenLabelNames(t *testing.T) {
+	tests := []struct {
+		name        string
+		defaults    []string
+		perEndpoint map[string][]string
+		want        []string
+	}{
+		{
+			name:     "default grouping",
+			defaults: []string{"project_id", "user_id", "api_key_id", "model", "batch"},
+			want: []string{"org_id", "org_name", "model", "operation", "project_id", "project_name", "user_id", "user_email",
+				"api_key_id", "api_key_name", "batch", "token_type"},
+		},
+		{
+			name:     "without users",
+			defaults: []string{"project_id", "model"},
+			want:     []string{"org_id", "org_name", "model", "operation", "project_id", "project_name", "token_type"},
+		},
+		{
+			name:        "service tier for one endpoint",
+			defaults:    []string{"project_id", "model"},
+			perEndpoint: map[string][]string{"completions": {"project_id", "model", "service_tier"}},
+			want:        []string{"org_id", "org_name", "model", "operation", "project_id", "project_name", "service_tier", "token_type"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tokenLabelNames(tt.defaults, tt.perEndpoint))
+		})
+	}
+}
+
+func TestConfigureGrouping_Errors(t *testing.T) {
+	defer func() { require.NoError(t, configureGrouping(usageGroupBy, nil)) }()
+
+	assert.ErrorContains(t, configureGrouping("project_id,region", nil), `-usage.group-by: unknown group_by dimension "region"`)
+	assert.ErrorContains(t, configureGrouping("", nil), "at least one dimension")
+	assert.ErrorContains(t, configureGrouping(usageGroupBy, map[string][]string{"chat": {"model"}}), `unknown endpoint "chat"`)
+}
+
+func TestFetchUsageData_GroupBy(t *testing.T) {
+	require.NoError(t, configureGrouping("project_id,model", map[string][]string{"completions": {"project_id", "model", "service_tier"}}))
+	defer func() { require.NoError(t, configureGrouping(usageGroupBy, nil)) }()
+	usageState = make(map[string]float64)
+	projectNames = map[string]string{"proj-1": "one"}
+
+	end := time.Now().Add(-time.Minute).Truncate(time.Minute).Unix()
+	var groupBy = make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		groupBy[r.URL.Path] = r.URL.Query().Get("group_by")
+		_, _ = w.Write([]byte(`{"object": "page", "data": [{"start_time": ` + strconv.FormatInt(end-60, 10) + `, "end_time": ` + strconv.FormatInt(end, 10) + `, "results": [
+			{"input_tokens": 10, "project_id": "proj-1", "model": "gpt-4o", "service_tier": "default"},
+			{"input_tokens": 4, "project_id": "proj-1", "model": "gpt-4o", "service_tier": "flex"}
+		]}], "has_more": false}`))
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+
+	require.NoError(t, e.fetchUsageData(UsageEndpoint{Path: "completions", Name: "completions"}, end-60, end))
+	require.NoError(t, e.fetchUsageData(UsageEndpoint{Path: "embeddings", Name: "embeddings"}, end-60, end))
+
+	assert.Equal(t, map[string]string{
+		"/v1/organization/usage/completions": "project_id,model,service_tier",
+		"/v1/organization/usage/embeddings":  "project_id,model",
+	}, groupBy)
+	series := func(operation, tier string) float64 {
+		return testutil.ToFloat64(tokensTotal.WithLabelValues("org-1", "prod", "gpt-4o", operation, "proj-1", "one", tier, "input"))
+	}
+	assert.Equal(t, 10.0, series("completions", "default"))
+	assert.Equal(t, 4.0, series("completions", "flex"), "service tiers are counted separately")
+	assert.Equal(t, "project_id,model;completions=project_id,model,service_tier", groupBySummary())
+}
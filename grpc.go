@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/foxdalas/openai-exporter/usagepb"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/exporter-toolkit/web"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// gRPC Usage Query Service
+
+var grpcListenAddress = flag.String("grpc.listen-address", "", "Serve the gRPC usage query service on this address (disabled when empty)")
+
+// usageDimensions maps the dimensions of QueryUsage to their position in the usageState keys built by updateMetric.
+var usageDimensions = map[string]int{
+	"operation":  0,
+	"project_id": 2,
+	"user_id":    3,
+	"api_key_id": 4,
+	"model":      5,
+	"batch":      6,
+	"token_type": 7,
+	"org_id":     8,
+}
+
+// costDimensions lists the labels of openai_api_daily_cost that QueryCosts can group by.
+var costDimensions = map[string]bool{
+	"date":            true,
+	"project_id":      true,
+	"project_name":    true,
+	"line_item":       true,
+	"organization_id": true,
+	"org_name":        true,
+}
+
+// usageServer implements usagepb.UsageServiceServer over the exporter's in-memory state.
+type usageServer struct {
+	usagepb.UnimplementedUsageServiceServer
+}
+
+// serveGRPC starts the usage query service on addr and returns once it is listening.
+// TLS and basic authentication are set up by web.config.file, like for the HTTP server.
+func serveGRPC(addr string) (*grpc.Server, error) {
+	opts, err := grpcServerOptions(*webConfigFile)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring the gRPC server: %w", err)
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error listening on %s: %w", addr, err)
+	}
+	srv := grpc.NewServer(opts...)
+	usagepb.RegisterUsageServiceServer(srv, &usageServer{})
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			logrus.WithError(err).Error("gRPC server stopped")
+		}
+	}()
+	return srv, nil
+}
+
+// grpcServerOptions applies the TLS settings and basic_auth_users of the web configuration file at path.
+func grpcServerOptions(path string) ([]grpc.ServerOption, error) {
+	if path == "" {
+		return nil, nil
+	}
+	cfg, err := loadWebConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	var opts []grpc.ServerOption
+	if tlsEnabled(&cfg.TLSConfig) {
+		tlsConfig, err := web.ConfigToTLSConfig(&cfg.TLSConfig)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	if len(cfg.Users) > 0 {
+		users := make(map[string]string, len(cfg.Users))
+		for user, hash := range cfg.Users {
+			users[user] = string(hash)
+		}
+		opts = append(opts, grpc.UnaryInterceptor(basicAuthInterceptor(users)))
+	}
+	return opts, nil
+}
+
+// basicAuthInterceptor rejects calls whose authorization metadata does not carry the basic auth
+// credentials of one of users, which maps user names to bcrypt hashes.
+func basicAuthInterceptor(users map[string]string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		r := http.Request{Header: http.Header{"Authorization": md.Get("authorization")}}
+		user, pass, ok := r.BasicAuth()
+		hash, known := users[user]
+		if !ok || !known || bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass)) != nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// stopGRPC stops srv, letting queries in flight finish until ctx is done.
+func stopGRPC(ctx context.Context, srv *grpc.Server) {
+	if srv == nil {
+		return
+	}
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		srv.Stop()
+	}
+}
+
+// QueryUsage sums the processed usage buckets starting within the requested range.
+func (s *usageServer) QueryUsage(_ context.Context, req *usagepb.QueryUsageRequest) (*usagepb.QueryUsageResponse, error) {
+	for _, dim := range req.GetGroupBy() {
+		if _, ok := usageDimensions[dim]; !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown usage dimension %q", dim)
+		}
+	}
+	if req.GetEndTime() != 0 && req.GetEndTime() <= req.GetStartTime() {
+		return nil, status.Error(codes.InvalidArgument, "end_time must be after start_time")
+	}
+
+	f := req.GetFilter()
+	filters := map[int][]string{
+		0: f.GetOperations(),
+		2: f.GetProjectIds(),
+		3: f.GetUserIds(),
+		4: f.GetApiKeyIds(),
+		5: f.GetModels(),
+		7: f.GetTokenTypes(),
+	}
+
+	configMu.RLock()
+	uf := userFilter
+	configMu.RUnlock()
+
+	aggregates := make(map[string]*usagepb.UsageAggregate)
+
+	stateMu.RLock()
+	for key, value := range usageState {
+		parts := strings.Split(key, "|")
+		if len(parts) < 9 {
+			continue
+		}
+		start, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || start < req.GetStartTime() || (req.GetEndTime() != 0 && start >= req.GetEndTime()) {
+			continue
+		}
+		// Report users the same way they are exported.
+		if !uf.allowed(parts[3]) {
+			parts[3] = otherUser
+		}
+		parts[3], parts[4] = privateUserID(parts[3]), privateAPIKeyID(parts[4])
+		if !matchesFilters(parts, filters) {
+			continue
+		}
+
+		values := make([]string, len(req.GetGroupBy()))
+		for i, dim := range req.GetGroupBy() {
+			values[i] = parts[usageDimensions[dim]]
+		}
+		groupKey := strings.Join(values, "|")
+		agg, ok := aggregates[groupKey]
+		if !ok {
+			agg = &usagepb.UsageAggregate{Dimensions: zipDimensions(req.GetGroupBy(), values)}
+			aggregates[groupKey] = agg
+		}
+		agg.Tokens += value
+		agg.Buckets++
+	}
+	stateMu.RUnlock()
+
+	resp := &usagepb.QueryUsageResponse{}
+	for _, key := range sortedKeys(aggregates) {
+		resp.Aggregates = append(resp.Aggregates, aggregates[key])
+	}
+	return resp, nil
+}
+
+// QueryCosts sums the daily costs within the requested date range.
+func (s *usageServer) QueryCosts(_ context.Context, req *usagepb.QueryCostsRequest) (*usagepb.QueryCostsResponse, error) {
+	for _, dim := range req.GetGroupBy() {
+		if !costDimensions[dim] {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown cost dimension %q", dim)
+		}
+	}
+	for _, date := range []string{req.GetStartDate(), req.GetEndDate()} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid date %q, use YYYY-MM-DD", date)
+		}
+	}
+
+	samples, err := collectSamples(dailyCostUSD, func(m *dto.Metric) float64 { return m.GetGauge().GetValue() })
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "error reading costs: %v", err)
+	}
+
+	aggregates := make(map[string]*usagepb.CostAggregate)
+	for _, sample := range samples {
+		date := sample.Labels["date"]
+		// Dates are zero-padded, so they compare in calendar order.
+		if (req.GetStartDate() != "" && date < req.GetStartDate()) || (req.GetEndDate() != "" && date > req.GetEndDate()) {
+			continue
+		}
+		if len(req.GetProjectIds()) > 0 && !slices.Contains(req.GetProjectIds(), sample.Labels["project_id"]) {
+			continue
+		}
+
+		currency := sample.Labels["currency"]
+		values := make([]string, len(req.GetGroupBy()))
+		for i, dim := range req.GetGroupBy() {
+			values[i] = sample.Labels[dim]
+		}
+		groupKey := strings.Join(append(values, currency), "|")
+		agg, ok := aggregates[groupKey]
+		if !ok {
+			agg = &usagepb.CostAggregate{Dimensions: zipDimensions(req.GetGroupBy(), values), Currency: currency}
+			aggregates[groupKey] = agg
+		}
+		agg.Amount += sample.Value
+	}
+
+	resp := &usagepb.QueryCostsResponse{}
+	for _, key := range sortedKeys(aggregates) {
+		resp.Aggregates = append(resp.Aggregates, aggregates[key])
+	}
+	return resp, nil
+}
+
+// matchesFilters reports whether every non-empty filter contains the key part at its position.
+func matchesFilters(parts []string, filters map[int][]string) bool {
+	for i, values := range filters {
+		if len(values) > 0 && !slices.Contains(values, parts[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func zipDimensions(names, values []string) map[string]string {
+	if len(names) == 0 {
+		return nil
+	}
+	dims := make(map[string]string, len(names))
+	for i, name := range names {
+		dims[name] = values[i]
+	}
+	return dims
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
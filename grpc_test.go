@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/foxdalas/openai-exporter/usagepb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newUsageClient(t *testing.T) usagepb.UsageServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	usagepb.RegisterUsageServiceServer(srv, &usageServer{})
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return usagepb.NewUsageServiceClient(conn)
+}
+
+func TestUsageServer_QueryUsage(t *testing.T) {
+	origState, origFilter := usageState, userFilter
+	defer func() { usageState, userFilter = origState, origFilter }()
+
+	usageState = map[string]float64{
+		"completions|1000|proj-1|user-1|key-1|gpt-4|false|input|org-1":  10,
+		"completions|1000|proj-1|user-1|key-1|gpt-4|false|output|org-1": 5,
+		"completions|1060|proj-2|user-2|key-2|gpt-4|false|input|org-1":  7,
+		"embeddings|1060|proj-1|bot-1|key-1|ada|false|input|org-1":      3,
+		"completions|2000|proj-1|user-1|key-1|gpt-4|false|input|org-1":  100,
+		"completions|1000|proj-9|user-9|key-9|gpt-4|false|input|org-2":  1,
+	}
+	userFilter = Filter{Deny: []string{"bot-*"}}
+
+	client := newUsageClient(t)
+
+	tests := []struct {
+		name string
+		req  *usagepb.QueryUsageRequest
+		want []*usagepb.UsageAggregate
+	}{
+		{
+			name: "grand total within range",
+			req:  &usagepb.QueryUsageRequest{StartTime: 1000, EndTime: 2000},
+			want: []*usagepb.UsageAggregate{{Tokens: 26, Buckets: 5}},
+		},
+		{
+			name: "open-ended range",
+			req:  &usagepb.QueryUsageRequest{StartTime: 1060},
+			want: []*usagepb.UsageAggregate{{Tokens: 110, Buckets: 3}},
+		},
+		{
+			name: "grouped by project and token type",
+			req:  &usagepb.QueryUsageRequest{StartTime: 1000, EndTime: 2000, GroupBy: []string{"project_id", "token_type"}},
+			want: []*usagepb.UsageAggregate{
+				{Dimensions: map[string]string{"project_id": "proj-1", "token_type": "input"}, Tokens: 13, Buckets: 2},
+				{Dimensions: map[string]string{"project_id": "proj-1", "token_type": "output"}, Tokens: 5, Buckets: 1},
+				{Dimensions: map[string]string{"project_id": "proj-2", "token_type": "input"}, Tokens: 7, Buckets: 1},
+				{Dimensions: map[string]string{"project_id": "proj-9", "token_type": "input"}, Tokens: 1, Buckets: 1},
+			},
+		},
+		{
+			name: "grouped by organization",
+			req:  &usagepb.QueryUsageRequest{StartTime: 1000, EndTime: 2000, GroupBy: []string{"org_id"}},
+			want: []*usagepb.UsageAggregate{
+				{Dimensions: map[string]string{"org_id": "org-1"}, Tokens: 25, Buckets: 4},
+				{Dimensions: map[string]string{"org_id": "org-2"}, Tokens: 1, Buckets: 1},
+			},
+		},
+		{
+			name: "filtered users are reported as other",
+			req:  &usagepb.QueryUsageRequest{StartTime: 1000, EndTime: 2000, GroupBy: []string{"user_id"}, Filter: &usagepb.UsageFilter{Operations: []string{"embeddings"}}},
+			want: []*usagepb.UsageAggregate{{Dimensions: map[string]string{"user_id": "other"}, Tokens: 3, Buckets: 1}},
+		},
+		{
+			name: "filter by model",
+			req:  &usagepb.QueryUsageRequest{Filter: &usagepb.UsageFilter{Models: []string{"gpt-4"}, TokenTypes: []string{"input"}}},
+			want: []*usagepb.UsageAggregate{{Tokens: 118, Buckets: 4}},
+		},
+		{
+			name: "nothing matches",
+			req:  &usagepb.QueryUsageRequest{StartTime: 5000},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.QueryUsage(context.Background(), tt.req)
+			require.NoError(t, err)
+			require.Len(t, resp.GetAggregates(), len(tt.want))
+			for i, want := range tt.want {
+				got := resp.GetAggregates()[i]
+				assert.Equal(t, len(want.GetDimensions()), len(got.GetDimensions()))
+				for k, v := range want.GetDimensions() {
+					assert.Equal(t, v, got.GetDimensions()[k])
+				}
+				assert.Equal(t, want.GetTokens(), got.GetTokens())
+				assert.Equal(t, want.GetBuckets(), got.GetBuckets())
+			}
+		})
+	}
+}
+
+func TestUsageServer_QueryCosts(t *testing.T) {
+	dailyCostUSD.Reset()
+	defer dailyCostUSD.Reset()
+
+	set := func(date, project, item, currency string, v float64) {
+		dailyCostUSD.With(prometheus.Labels{
+			"date": date, "project_id": project, "project_name": project + "-name",
+			"line_item": item, "organization_id": "org-1", "org_name": "prod", "currency": currency,
+		}).Set(v)
+	}
+	set("2024-01-01", "proj-1", "gpt-4", "usd", 1.5)
+	set("2024-01-02", "proj-1", "gpt-4", "usd", 2)
+	set("2024-01-02", "proj-2", "ada", "usd", 0.25)
+	set("2024-01-02", "proj-2", "ada", "eur", 4)
+	set("2024-01-03", "proj-1", "gpt-4", "usd", 8)
+
+	client := newUsageClient(t)
+
+	tests := []struct {
+		name string
+		req  *usagepb.QueryCostsRequest
+		want []*usagepb.CostAggregate
+	}{
+		{
+			name: "totals per currency",
+			req:  &usagepb.QueryCostsRequest{StartDate: "2024-01-01", EndDate: "2024-01-02"},
+			want: []*usagepb.CostAggregate{{Currency: "eur", Amount: 4}, {Currency: "usd", Amount: 3.75}},
+		},
+		{
+			name: "grouped by project",
+			req:  &usagepb.QueryCostsRequest{StartDate: "2024-01-02", GroupBy: []string{"project_id"}},
+			want: []*usagepb.CostAggregate{
+				{Dimensions: map[string]string{"project_id": "proj-1"}, Currency: "usd", Amount: 10},
+				{Dimensions: map[string]string{"project_id": "proj-2"}, Currency: "eur", Amount: 4},
+				{Dimensions: map[string]string{"project_id": "proj-2"}, Currency: "usd", Amount: 0.25},
+			},
+		},
+		{
+			name: "filtered by project",
+			req:  &usagepb.QueryCostsRequest{ProjectIds: []string{"proj-1"}, GroupBy: []string{"date"}},
+			want: []*usagepb.CostAggregate{
+				{Dimensions: map[string]string{"date": "2024-01-01"}, Currency: "usd", Amount: 1.5},
+				{Dimensions: map[string]string{"date": "2024-01-02"}, Currency: "usd", Amount: 2},
+				{Dimensions: map[string]string{"date": "2024-01-03"}, Currency: "usd", Amount: 8},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.QueryCosts(context.Background(), tt.req)
+			require.NoError(t, err)
+			require.Len(t, resp.GetAggregates(), len(tt.want))
+			for i, want := range tt.want {
+				got := resp.GetAggregates()[i]
+				assert.Equal(t, want.GetDimensions(), got.GetDimensions())
+				assert.Equal(t, want.GetCurrency(), got.GetCurrency())
+				assert.InDelta(t, want.GetAmount(), got.GetAmount(), 1e-9)
+			}
+		})
+	}
+}
+
+func TestUsageServer_InvalidArgument(t *testing.T) {
+	client := newUsageClient(t)
+	ctx := context.Background()
+
+	_, err := client.QueryUsage(ctx, &usagepb.QueryUsageRequest{GroupBy: []string{"organization"}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.QueryUsage(ctx, &usagepb.QueryUsageRequest{StartTime: 2000, EndTime: 1000})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.QueryCosts(ctx, &usagepb.QueryCostsRequest{GroupBy: []string{"currency"}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.QueryCosts(ctx, &usagepb.QueryCostsRequest{StartDate: "01/02/2024"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCServerOptions(t *testing.T) {
+	opts, err := grpcServerOptions("")
+	require.NoError(t, err)
+	assert.Empty(t, opts, "plaintext without a web configuration file")
+
+	dir := t.TempDir()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	path := filepath.Join(dir, "web.yml")
+	require.NoError(t, os.WriteFile(path, []byte("basic_auth_users:\n  prometheus: "+string(hash)+"\n"), 0600))
+	opts, err = grpcServerOptions(path)
+	require.NoError(t, err)
+	assert.Len(t, opts, 1, "basic authentication without TLS")
+
+	require.NoError(t, os.WriteFile(path, []byte("tls_server_config:\n  cert_file: missing.crt\n  key_file: missing.key\n"), 0600))
+	_, err = grpcServerOptions(path)
+	assert.Error(t, err, "a certificate that cannot be loaded stops the server from starting")
+}
+
+func TestBasicAuthInterceptor(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	interceptor := basicAuthInterceptor(map[string]string{"prometheus": string(hash)})
+	handler := func(context.Context, any) (any, error) { return "ok", nil }
+	basic := func(user, pass string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+	}
+
+	tests := []struct {
+		name string
+		auth string
+		want codes.Code
+	}{
+		{name: "valid credentials", auth: basic("prometheus", "secret"), want: codes.OK},
+		{name: "wrong password", auth: basic("prometheus", "wrong"), want: codes.Unauthenticated},
+		{name: "unknown user", auth: basic("admin", "secret"), want: codes.Unauthenticated},
+		{name: "no credentials", want: codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.auth != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", tt.auth))
+			}
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+			assert.Equal(t, tt.want, status.Code(err))
+		})
+	}
+}
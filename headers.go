@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/common/version"
+	"golang.org/x/net/http/httpguts"
+)
+
+// Request Headers
+
+var (
+	userAgent    = flag.String("openai.user-agent", "", "User-Agent of API requests (default: openai-exporter/<version>)")
+	extraHeaders = flag.String("openai.headers", "", "Comma-separated Name=value headers added to every API request, e.g. X-Tenant-ID=analytics for an API gateway")
+)
+
+// apiHeaders are the headers added to every API request, set by configureHeaders.
+var apiHeaders http.Header
+
+// configureHeaders parses the User-Agent and extra header flags into apiHeaders.
+func configureHeaders() error {
+	header := http.Header{}
+	for _, pair := range splitList(*extraHeaders) {
+		name, value, ok := strings.Cut(pair, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		switch {
+		case !ok:
+			return fmt.Errorf("invalid header %q in -openai.headers, use Name=value", pair)
+		case !httpguts.ValidHeaderFieldName(name):
+			return fmt.Errorf("invalid header name %q in -openai.headers", name)
+		case !httpguts.ValidHeaderFieldValue(value):
+			return fmt.Errorf("invalid value of header %s in -openai.headers", name)
+		}
+		header.Add(name, value)
+	}
+	ua := *userAgent
+	if ua == "" {
+		ua = "openai-exporter/" + version.Version
+	}
+	if !httpguts.ValidHeaderFieldValue(ua) {
+		return fmt.Errorf("invalid -openai.user-agent %q", ua)
+	}
+	header.Set("User-Agent", ua)
+	apiHeaders = header
+	return nil
+}
+
+// headerTransport adds header to every request sent through next. Headers the request already sets, such as
+// Authorization, take precedence.
+type headerTransport struct {
+	next   http.RoundTripper
+	header http.Header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, values := range t.header {
+		if _, ok := req.Header[name]; !ok {
+			req.Header[name] = values
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+// withHeaders returns next, adding the configured headers to its requests.
+func withHeaders(next http.RoundTripper) http.RoundTripper {
+	if len(apiHeaders) == 0 {
+		return next
+	}
+	return &headerTransport{next: next, header: apiHeaders}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigureHeaders(t *testing.T) {
+	defer func(ua, headers string, h http.Header) { *userAgent, *extraHeaders, apiHeaders = ua, headers, h }(*userAgent, *extraHeaders, apiHeaders)
+
+	tests := []struct {
+		name    string
+		ua      string
+		headers string
+		want    http.Header
+		wantErr string
+	}{
+		{name: "defaults", want: http.Header{"User-Agent": {"openai-exporter/" + version.Version}}},
+		{
+			name:    "custom user agent and headers",
+			ua:      "acme-billing/1.0",
+			headers: "X-Tenant-ID=analytics, x-cost-center = 4711",
+			want:    http.Header{"User-Agent": {"acme-billing/1.0"}, "X-Tenant-Id": {"analytics"}, "X-Cost-Center": {"4711"}},
+		},
+		{name: "missing value", headers: "X-Tenant-ID", wantErr: "use Name=value"},
+		{name: "invalid name", headers: "X Tenant=analytics", wantErr: "invalid header name"},
+		{name: "invalid user agent", ua: "acme\nbilling", wantErr: "invalid -openai.user-agent"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*userAgent, *extraHeaders = tt.ua, tt.headers
+			err := configureHeaders()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, apiHeaders)
+		})
+	}
+}
+
+func TestNewAPIClient_Headers(t *testing.T) {
+	defer func(ua, headers string, h http.Header) { *userAgent, *extraHeaders, apiHeaders = ua, headers, h }(*userAgent, *extraHeaders, apiHeaders)
+	*userAgent, *extraHeaders = "acme-billing/1.0", "X-Tenant-ID=analytics,Authorization=Bearer gateway"
+	require.NoError(t, configureHeaders())
+
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	client, err := newAPIClient(time.Second)
+	require.NoError(t, err)
+	e := &Exporter{client: client, apiKey: "sk-admin", orgID: "org-1", targets: newAPITargets("org-1", server.URL, 3)}
+
+	resp, err := e.get("/v1/organization/projects")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, "acme-billing/1.0", got.Get("User-Agent"))
+	assert.Equal(t, "analytics", got.Get("X-Tenant-ID"))
+	assert.Equal(t, "Bearer sk-admin", got.Get("Authorization"), "headers of the request take precedence")
+}
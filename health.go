@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Health Checks
+
+// exporterReady is whether the exporter is ready: a collection cycle succeeded or, in pull mode, the server
+// started.
+var exporterReady atomic.Bool
+
+// setReady marks the exporter ready and, the first time, tells systemd.
+func setReady() {
+	if exporterReady.CompareAndSwap(false, true) {
+		notifySystemdReady()
+	}
+}
+
+// healthyHandler answers 200 as long as the exporter is running.
+func healthyHandler(w http.ResponseWriter, _ *http.Request) {
+	_, _ = w.Write([]byte("OpenAI Exporter is Healthy.\n"))
+}
+
+// readyHandler answers 200 once the exporter is ready and 503 before.
+func readyHandler(w http.ResponseWriter, _ *http.Request) {
+	if !exporterReady.Load() {
+		http.Error(w, "OpenAI Exporter is not ready: no collection cycle has succeeded yet.", http.StatusServiceUnavailable)
+		return
+	}
+	_, _ = w.Write([]byte("OpenAI Exporter is Ready.\n"))
+}
+
+// healthOptions are the flags of the health command.
+type healthOptions struct {
+	url     string
+	timeout time.Duration
+}
+
+func parseHealthOptions(args []string) (healthOptions, error) {
+	fs := flag.NewFlagSet("health", flag.ContinueOnError)
+	url := fs.String("url", "", "Readiness URL to check (default: /-/ready on -web.listen-address)")
+	timeout := fs.Duration("timeout", 5*time.Second, "Time to wait for the answer")
+	flag.VisitAll(func(f *flag.Flag) { fs.Var(f.Value, f.Name, f.Usage) })
+	if err := fs.Parse(args); err != nil {
+		return healthOptions{}, err
+	}
+	return healthOptions{url: *url, timeout: *timeout}, nil
+}
+
+// readinessURL returns the URL of the readiness endpoint served on listen, and the Unix socket to dial
+// instead of its host, if any. Wildcard addresses are checked on the loopback interface.
+func readinessURL(listen string, tls bool) (url, socket string, err error) {
+	scheme := "http"
+	if tls {
+		scheme = "https"
+	}
+	if path, ok := strings.CutPrefix(listen, "unix://"); ok {
+		return scheme + "://localhost/-/ready", path, nil
+	}
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid web.listen-address %q: %w", listen, err)
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return scheme + "://" + net.JoinHostPort(host, port) + "/-/ready", "", nil
+}
+
+// webConfigTLS reports whether the web configuration file serves TLS.
+func webConfigTLS() (bool, error) {
+	if *webConfigFile == "" {
+		return false, nil
+	}
+	data, err := os.ReadFile(*webConfigFile)
+	if err != nil {
+		return false, fmt.Errorf("error reading web configuration file: %w", err)
+	}
+	var cfg struct {
+		TLSServerConfig map[string]any `yaml:"tls_server_config"`
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return false, fmt.Errorf("error parsing web configuration file: %w", err)
+	}
+	return len(cfg.TLSServerConfig) > 0, nil
+}
+
+// checkReady requests the readiness endpoint at url, through socket if set. The certificate of a TLS
+// endpoint is not verified, since the check only asks whether the exporter on this host is ready.
+func checkReady(url, socket string, timeout time.Duration) (string, error) {
+	transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	if socket != "" {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		}
+	}
+	client := &http.Client{Transport: transport, Timeout: timeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("error checking readiness: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s returned status %d: %s", url, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// runHealth checks the readiness of the exporter running on this host and returns the exit code: 0 if it is
+// ready and 1 otherwise.
+func runHealth(args []string, out io.Writer) int {
+	opts, err := parseHealthOptions(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		logrus.Error(err)
+		return 2
+	}
+	url, socket := opts.url, ""
+	if url == "" {
+		tls, err := webConfigTLS()
+		if err != nil {
+			logrus.Error(err)
+			return 1
+		}
+		if url, socket, err = readinessURL(*listenAddress, tls); err != nil {
+			logrus.Error(err)
+			return 1
+		}
+	}
+	status, err := checkReady(url, socket, opts.timeout)
+	if err != nil {
+		logrus.Error(err)
+		return 1
+	}
+	_, _ = fmt.Fprintln(out, status)
+	return 0
+}
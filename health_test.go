@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadinessURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		listen     string
+		tls        bool
+		wantURL    string
+		wantSocket string
+		wantErr    bool
+	}{
+		{name: "all interfaces", listen: ":9185", wantURL: "http://localhost:9185/-/ready"},
+		{name: "unspecified IPv4", listen: "0.0.0.0:9185", wantURL: "http://localhost:9185/-/ready"},
+		{name: "unspecified IPv6", listen: "[::]:9185", wantURL: "http://localhost:9185/-/ready"},
+		{name: "specific address", listen: "10.0.0.5:9185", wantURL: "http://10.0.0.5:9185/-/ready"},
+		{name: "TLS", listen: ":9185", tls: true, wantURL: "https://localhost:9185/-/ready"},
+		{name: "unix socket", listen: "unix:///run/openai-exporter.sock", wantURL: "http://localhost/-/ready", wantSocket: "/run/openai-exporter.sock"},
+		{name: "missing port", listen: "localhost", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, socket, err := readinessURL(tt.listen, tt.tls)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantURL, url)
+			assert.Equal(t, tt.wantSocket, socket)
+		})
+	}
+}
+
+func TestRunHealth(t *testing.T) {
+	defer exporterReady.Store(false)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/-/ready", readyHandler)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	orig := *listenAddress
+	defer func() { *listenAddress = orig }()
+
+	exporterReady.Store(false)
+	var out bytes.Buffer
+	assert.Equal(t, 1, runHealth([]string{"-web.listen-address=" + server.Listener.Addr().String()}, &out), "not ready before a cycle succeeded")
+
+	exporterReady.Store(true)
+	assert.Equal(t, 0, runHealth([]string{"-web.listen-address=" + server.Listener.Addr().String()}, &out))
+	assert.Equal(t, "OpenAI Exporter is Ready.\n", out.String())
+
+	assert.Equal(t, 0, runHealth([]string{"-url=" + server.URL + "/-/ready"}, &out))
+	assert.Equal(t, 1, runHealth([]string{"-url=" + server.URL + "/missing"}, &out))
+}
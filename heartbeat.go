@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Dead-Man's-Switch Heartbeat
+
+var heartbeatURL = flag.String("heartbeat.url", "", "URL to ping after each successful collection cycle (e.g. a healthchecks.io check URL)")
+
+var heartbeatClient = &http.Client{Timeout: 10 * time.Second}
+
+// sendHeartbeat pings the heartbeat URL so an external monitor notices when the exporter stops completing cycles.
+func sendHeartbeat(url string) error {
+	logrus.Debugf("Sending heartbeat: %s", url)
+	resp, err := heartbeatClient.Get(url)
+	if err != nil {
+		return fmt.Errorf("error sending heartbeat: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("heartbeat returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendHeartbeat(t *testing.T) {
+	t.Run("successful ping", func(t *testing.T) {
+		pings := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pings++
+			_, _ = w.Write([]byte("OK"))
+		}))
+		defer server.Close()
+
+		assert.NoError(t, sendHeartbeat(server.URL))
+		assert.Equal(t, 1, pings)
+	})
+
+	t.Run("non-2xx status", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer server.Close()
+
+		err := sendHeartbeat(server.URL)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "404")
+	})
+
+	t.Run("unreachable URL", func(t *testing.T) {
+		assert.Error(t, sendHeartbeat("http://127.0.0.1:0"))
+	})
+}
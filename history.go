@@ -0,0 +1,284 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	_ "modernc.org/sqlite"
+)
+
+// Usage History
+
+var (
+	historyFile      = flag.String("history.file", "", "SQLite database every counted usage bucket is stored in, queryable at /api/v1/usage (disabled when empty)")
+	historyRetention = flag.Duration("history.retention", 0, "Time after which usage is deleted from the history database (0 keeps it forever)")
+)
+
+var (
+	// history is the database opened for -history.file, if set.
+	history *sql.DB
+
+	historyMu sync.Mutex
+	// historyRows are the usage rows counted since the history was last written, guarded by historyMu.
+	historyRows []historyRow
+)
+
+// historyRow is the growth of one token type of one usage bucket and series.
+type historyRow struct {
+	start, end int64
+	labels     prometheus.Labels
+	tokenType  string
+	tokens     float64
+}
+
+// historyColumns are the label columns of the usage table, in order.
+var historyColumns = []string{"org_id", "org_name", "operation", "project_id", "project_name", "user_id",
+	"api_key_id", "api_key_name", "model", "batch", "service_tier"}
+
+// historyGroups maps every group_by value of /api/v1/usage to its SQL expression.
+var historyGroups = map[string]string{
+	"day":  "strftime('%Y-%m-%d', bucket_start, 'unixepoch')",
+	"hour": "strftime('%Y-%m-%dT%H:00:00Z', bucket_start, 'unixepoch')",
+}
+
+func init() {
+	for _, c := range historyColumns {
+		historyGroups[c] = c
+	}
+}
+
+const historySchema = `
+CREATE TABLE IF NOT EXISTS usage (
+	bucket_start INTEGER NOT NULL,
+	bucket_end INTEGER NOT NULL,
+	org_id TEXT NOT NULL,
+	org_name TEXT NOT NULL,
+	operation TEXT NOT NULL,
+	project_id TEXT NOT NULL,
+	project_name TEXT NOT NULL,
+	user_id TEXT NOT NULL,
+	api_key_id TEXT NOT NULL,
+	api_key_name TEXT NOT NULL,
+	model TEXT NOT NULL,
+	batch TEXT NOT NULL,
+	service_tier TEXT NOT NULL,
+	token_type TEXT NOT NULL,
+	tokens REAL NOT NULL
+);
+CREATE INDEX IF NOT EXISTS usage_bucket_start ON usage (bucket_start);
+`
+
+// configureHistory opens the history database and creates its table.
+func configureHistory() error {
+	if history != nil {
+		_ = history.Close()
+		history = nil
+	}
+	if *historyFile == "" {
+		return nil
+	}
+	db, err := sql.Open("sqlite", *historyFile)
+	if err != nil {
+		return fmt.Errorf("error opening history database: %w", err)
+	}
+	// SQLite allows a single writer; one connection keeps writes and reads from contending for locks.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(historySchema); err != nil {
+		_ = db.Close()
+		return fmt.Errorf("error creating history table in %s: %w", *historyFile, err)
+	}
+	history = db
+	return nil
+}
+
+// recordHistory queues the tokens just counted for a usage result for the history database.
+func recordHistory(labels prometheus.Labels, bucketStart, bucketEnd int64, added map[string]float64) {
+	if history == nil {
+		return
+	}
+	labels = privateLabels(filterLabels(labels))
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	for tokenType, tokens := range added {
+		if tokens > 0 {
+			historyRows = append(historyRows, historyRow{start: bucketStart, end: bucketEnd, labels: labels, tokenType: tokenType, tokens: tokens})
+		}
+	}
+}
+
+// flushHistory writes the queued rows to the history database in one transaction and deletes usage older
+// than history.retention. Rows that cannot be written stay queued for the next attempt.
+func flushHistory(now time.Time) error {
+	if history == nil {
+		return nil
+	}
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	tx, err := history.Begin()
+	if err != nil {
+		return fmt.Errorf("error writing usage history: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.Prepare(fmt.Sprintf("INSERT INTO usage (bucket_start, bucket_end, %s, token_type, tokens) VALUES (?, ?%s, ?, ?)",
+		strings.Join(historyColumns, ", "), strings.Repeat(", ?", len(historyColumns))))
+	if err != nil {
+		return fmt.Errorf("error writing usage history: %w", err)
+	}
+	defer func() { _ = stmt.Close() }()
+	for _, row := range historyRows {
+		args := []any{row.start, row.end}
+		for _, c := range historyColumns {
+			args = append(args, row.labels[c])
+		}
+		if _, err := stmt.Exec(append(args, row.tokenType, row.tokens)...); err != nil {
+			return fmt.Errorf("error writing usage history: %w", err)
+		}
+	}
+	if *historyRetention > 0 {
+		if _, err := tx.Exec("DELETE FROM usage WHERE bucket_start < ?", now.Add(-*historyRetention).Unix()); err != nil {
+			return fmt.Errorf("error pruning usage history: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error writing usage history: %w", err)
+	}
+	logrus.Debugf("Wrote %d usage rows to the history", len(historyRows))
+	historyRows = nil
+	return nil
+}
+
+// usageAggregate is one group of the /api/v1/usage response.
+type usageAggregate struct {
+	Labels map[string]string  `json:"labels"`
+	Tokens map[string]float64 `json:"tokens"`
+}
+
+// usageReport is the /api/v1/usage response.
+type usageReport struct {
+	From    time.Time        `json:"from"`
+	To      time.Time        `json:"to"`
+	GroupBy []string         `json:"group_by"`
+	Data    []usageAggregate `json:"data"`
+}
+
+// queryHistory sums the tokens of the buckets starting in [from, to) per token type and group_by value.
+func queryHistory(db *sql.DB, from, to time.Time, groupBy []string) ([]usageAggregate, error) {
+	exprs := make([]string, len(groupBy))
+	for i, g := range groupBy {
+		expr, ok := historyGroups[g]
+		if !ok {
+			return nil, fmt.Errorf("unknown group_by %q", g)
+		}
+		exprs[i] = expr
+	}
+	query := "SELECT " + strings.Join(append(exprs, "token_type", "SUM(tokens)"), ", ") +
+		" FROM usage WHERE bucket_start >= ? AND bucket_start < ? GROUP BY " + strings.Join(append(exprs, "token_type"), ", ")
+	if len(exprs) > 0 {
+		query += " ORDER BY " + strings.Join(exprs, ", ")
+	}
+
+	rows, err := db.Query(query, from.Unix(), to.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("error querying usage history: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	data := []usageAggregate{}
+	values := make([]string, len(groupBy))
+	for rows.Next() {
+		var tokenType string
+		var tokens float64
+		dest := make([]any, 0, len(groupBy)+2)
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(append(dest, &tokenType, &tokens)...); err != nil {
+			return nil, fmt.Errorf("error reading usage history: %w", err)
+		}
+
+		labels := make(map[string]string, len(groupBy))
+		for i, g := range groupBy {
+			labels[g] = values[i]
+		}
+		// Rows are ordered by group, so the token types of a group follow each other.
+		if n := len(data); n == 0 || !maps.Equal(data[n-1].Labels, labels) {
+			data = append(data, usageAggregate{Labels: labels, Tokens: map[string]float64{}})
+		}
+		data[len(data)-1].Tokens[tokenType] = tokens
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading usage history: %w", err)
+	}
+	return data, nil
+}
+
+// parseHistoryTime parses a from or to query parameter, either RFC 3339 or Unix seconds.
+func parseHistoryTime(s string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, use RFC 3339 or Unix seconds", s)
+	}
+	return t.UTC(), nil
+}
+
+// usageHistoryHandler serves GET /api/v1/usage: the tokens of the buckets starting between from (default: 24
+// hours before to) and to (default: now), summed per token type and grouped by the comma-separated group_by.
+func usageHistoryHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "only GET is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		report := usageReport{To: time.Now().UTC(), GroupBy: splitList(q.Get("group_by"))}
+		if report.GroupBy == nil {
+			report.GroupBy = []string{}
+		}
+		var err error
+		if s := q.Get("to"); s != "" {
+			if report.To, err = parseHistoryTime(s); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		report.From = report.To.Add(-24 * time.Hour)
+		if s := q.Get("from"); s != "" {
+			if report.From, err = parseHistoryTime(s); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		for _, g := range report.GroupBy {
+			if _, ok := historyGroups[g]; !ok {
+				http.Error(w, fmt.Sprintf("unknown group_by %q", g), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if report.Data, err = queryHistory(db, report.From, report.To, report.GroupBy); err != nil {
+			logrus.WithError(err).Error("Failed to query usage history")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			logrus.WithError(err).Error("Failed to write response")
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageHistory(t *testing.T) {
+	orig := *historyFile
+	*historyFile = filepath.Join(t.TempDir(), "history.db")
+	defer func() {
+		*historyFile = orig
+		require.NoError(t, configureHistory())
+	}()
+	require.NoError(t, configureHistory())
+
+	day := int64(1717804800) // 2024-06-08T00:00:00Z
+	labels := func(project, model string) prometheus.Labels {
+		return prometheus.Labels{"org_id": "org-1", "org_name": "prod", "operation": "completions", "project_id": project,
+			"project_name": project, "user_id": "user-1", "api_key_id": "key-1", "api_key_name": "ci", "model": model, "batch": "false"}
+	}
+	recordHistory(labels("proj-1", "gpt-4o"), day, day+60, map[string]float64{"input": 100, "output": 10})
+	recordHistory(labels("proj-1", "gpt-4o"), day+60, day+120, map[string]float64{"input": 50, "output": 0})
+	recordHistory(labels("proj-2", "gpt-4o-mini"), day+86400, day+86460, map[string]float64{"input": 7})
+	require.NoError(t, flushHistory(time.Unix(day+86460, 0)))
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		want     []usageAggregate
+	}{
+		{
+			name:     "by project",
+			query:    "?from=2024-06-08T00:00:00Z&to=1717977600&group_by=project_id",
+			wantCode: http.StatusOK,
+			want: []usageAggregate{
+				{Labels: map[string]string{"project_id": "proj-1"}, Tokens: map[string]float64{"input": 150, "output": 10}},
+				{Labels: map[string]string{"project_id": "proj-2"}, Tokens: map[string]float64{"input": 7}},
+			},
+		},
+		{
+			name:     "by day and model",
+			query:    "?from=1717804800&to=1717977600&group_by=day,model",
+			wantCode: http.StatusOK,
+			want: []usageAggregate{
+				{Labels: map[string]string{"day": "2024-06-08", "model": "gpt-4o"}, Tokens: map[string]float64{"input": 150, "output": 10}},
+				{Labels: map[string]string{"day": "2024-06-09", "model": "gpt-4o-mini"}, Tokens: map[string]float64{"input": 7}},
+			},
+		},
+		{
+			name:     "total of the first day",
+			query:    "?from=1717804800&to=1717891200",
+			wantCode: http.StatusOK,
+			want:     []usageAggregate{{Labels: map[string]string{}, Tokens: map[string]float64{"input": 150, "output": 10}}},
+		},
+		{name: "no usage", query: "?from=1600000000&to=1600086400", wantCode: http.StatusOK, want: []usageAggregate{}},
+		{name: "unknown dimension", query: "?group_by=user_email", wantCode: http.StatusBadRequest},
+		{name: "invalid time", query: "?from=yesterday", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			usageHistoryHandler(history)(rec, httptest.NewRequest(http.MethodGet, "/api/v1/usage"+tt.query, nil))
+			require.Equal(t, tt.wantCode, rec.Code, rec.Body.String())
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var report usageReport
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+			assert.Equal(t, tt.want, report.Data)
+		})
+	}
+}
+
+func TestFlushHistoryRetention(t *testing.T) {
+	origFile, origRetention := *historyFile, *historyRetention
+	*historyFile, *historyRetention = filepath.Join(t.TempDir(), "history.db"), 24*time.Hour
+	defer func() {
+		*historyFile, *historyRetention = origFile, origRetention
+		require.NoError(t, configureHistory())
+	}()
+	require.NoError(t, configureHistory())
+
+	now := time.Unix(1717804800, 0)
+	recordHistory(prometheus.Labels{"org_id": "org-1"}, now.Add(-48*time.Hour).Unix(), now.Add(-48*time.Hour).Unix()+60, map[string]float64{"input": 1})
+	recordHistory(prometheus.Labels{"org_id": "org-1"}, now.Add(-time.Hour).Unix(), now.Add(-time.Hour).Unix()+60, map[string]float64{"input": 2})
+	require.NoError(t, flushHistory(now))
+
+	var count int
+	require.NoError(t, history.QueryRow("SELECT COUNT(*) FROM usage").Scan(&count))
+	assert.Equal(t, 1, count, "usage older than the retention is deleted")
+}
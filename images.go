@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Image Usage
+
+// imageDimensions are the dimensions image counts are grouped by.
+var imageDimensions = []string{"project_id", "model", "size", "source"}
+
+var imagesTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "openai_api_images_total",
+		Help: "Total number of generated images per organization, project, model, size and source",
+	},
+	[]string{"org_id", "org_name", "project_id", "project_name", "model", "size", "source"},
+)
+
+// imagesGroupBy returns the dimensions of groupBy together with those image counts are grouped by, so the
+// images endpoint is fetched once for both its tokens and its images.
+func imagesGroupBy(groupBy string) string {
+	dims := splitList(groupBy)
+	for _, dim := range imageDimensions {
+		if !slices.Contains(dims, dim) {
+			dims = append(dims, dim)
+		}
+	}
+	return strings.Join(dims, ",")
+}
+
+// mergeResults returns the buckets with the results that only differ in dimensions outside groupBy summed
+// into one, as if they had been fetched grouped by groupBy. Dimensions outside groupBy are left empty.
+func mergeResults(buckets []Bucket, groupBy string) []Bucket {
+	dims := splitList(groupBy)
+	merged := make([]Bucket, len(buckets))
+	for i, bucket := range buckets {
+		merged[i] = bucket
+		merged[i].Results = nil
+		index := make(map[string]int)
+		for _, r := range bucket.Results {
+			key := make([]string, len(dims))
+			for j, dim := range dims {
+				key[j] = r.dimension(dim)
+			}
+			if n, ok := index[strings.Join(key, "|")]; ok {
+				merged[i].Results[n].add(r)
+				continue
+			}
+			index[strings.Join(key, "|")] = len(merged[i].Results)
+			merged[i].Results = append(merged[i].Results, r.only(dims))
+		}
+	}
+	return merged
+}
+
+// dimension returns the value of the group_by dimension dim of the result.
+func (r UsageResult) dimension(dim string) string {
+	switch dim {
+	case "project_id":
+		return deref(r.ProjectID)
+	case "user_id":
+		return deref(r.UserID)
+	case "api_key_id":
+		return deref(r.APIKeyID)
+	case "model":
+		return deref(r.Model)
+	case "batch":
+		return string(r.Batch)
+	case "service_tier":
+		return deref(r.ServiceTier)
+	}
+	return ""
+}
+
+// only returns the result with the dimensions outside dims left empty.
+func (r UsageResult) only(dims []string) UsageResult {
+	keep := func(dim string, value *string) *string {
+		if slices.Contains(dims, dim) {
+			return value
+		}
+		return nil
+	}
+	r.ProjectID = keep("project_id", r.ProjectID)
+	r.UserID = keep("user_id", r.UserID)
+	r.APIKeyID = keep("api_key_id", r.APIKeyID)
+	r.Model = keep("model", r.Model)
+	r.ServiceTier = keep("service_tier", r.ServiceTier)
+	if !slices.Contains(dims, "batch") {
+		r.Batch = ""
+	}
+	r.Size, r.Source = nil, nil
+	return r
+}
+
+// add adds the counts of o to the result.
+func (r *UsageResult) add(o UsageResult) {
+	r.InputTokens += o.InputTokens
+	r.OutputTokens += o.OutputTokens
+	r.InputCachedTokens += o.InputCachedTokens
+	r.InputAudioTokens += o.InputAudioTokens
+	r.OutputAudioTokens += o.OutputAudioTokens
+	r.NumModelRequests += o.NumModelRequests
+	r.Images += o.Images
+	r.Seconds += o.Seconds
+	r.Characters += o.Characters
+	r.UsageBytes += o.UsageBytes
+	r.NumSessions += o.NumSessions
+	r.NumWebSearchCalls += o.NumWebSearchCalls
+	r.NumFileSearchCalls += o.NumFileSearchCalls
+	r.OutputTokensDetails.ReasoningTokens += o.OutputTokensDetails.ReasoningTokens
+}
+
+// countImages counts the images of buckets fetched grouped by imagesGroupBy.
+func (e *Exporter) countImages(endpoint UsageEndpoint, startTime, endTime int64, buckets []Bucket) {
+	var records int
+	for _, bucket := range buckets {
+		for _, result := range bucket.Results {
+			records++
+			labels := prometheus.Labels{
+				"org_id":       e.orgID,
+				"org_name":     e.orgName,
+				"project_id":   deref(result.ProjectID),
+				"project_name": e.ensureProjectName(deref(result.ProjectID)),
+				"model":        deref(result.Model),
+				"size":         deref(result.Size),
+				"source":       deref(result.Source),
+			}
+			key := strings.Join([]string{
+				"images_total",
+				fmt.Sprintf("%d", bucket.StartTime),
+				labels["project_id"],
+				labels["model"],
+				labels["size"],
+				labels["source"],
+				e.orgID,
+			}, "|")
+			updateCounter(imagesTotal, key, labels, bucket.EndTime, float64(result.Images))
+		}
+	}
+
+	logrus.WithFields(windowFields(e.orgID, endpoint.Name, startTime, endTime)).WithField("records", records).Debug("Fetched image counts")
+}
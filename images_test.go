@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchUsageData_Images(t *testing.T) {
+	usageState = make(map[string]float64)
+	projectNames = map[string]string{"proj-1": "one"}
+	apiKeyNames = map[string]string{"key-1": "ci"}
+	imagesTotal.Reset()
+	tokensTotal.Reset()
+
+	end := time.Now().Add(-time.Minute).Truncate(time.Minute).Unix()
+	bucket := `"start_time": ` + strconv.FormatInt(end-60, 10) + `, "end_time": ` + strconv.FormatInt(end, 10)
+	var groupBy []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		groupBy = append(groupBy, r.URL.Query().Get("group_by"))
+		_, _ = w.Write([]byte(`{"object": "page", "data": [{` + bucket + `, "results": [
+			{"images": 3, "input_tokens": 30, "project_id": "proj-1", "user_id": "user-1", "api_key_id": "key-1", "model": "dall-e-3", "size": "1024x1024", "source": "image.generation"},
+			{"images": 2, "input_tokens": 20, "project_id": "proj-1", "user_id": "user-1", "api_key_id": "key-1", "model": "dall-e-3", "size": "1792x1024", "source": "image.generation"},
+			{"images": 1, "input_tokens": 10, "project_id": "proj-1", "user_id": "user-1", "api_key_id": "key-1", "model": "dall-e-2", "size": "512x512", "source": "image.edit"}
+		]}], "has_more": false}`))
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+	images := UsageEndpoint{Path: "images", Name: "images"}
+
+	require.NoError(t, e.fetchUsageData(images, end-60, end))
+	require.NoError(t, e.fetchUsageData(images, end-60, end))
+
+	combined := usageGroupBy + ",size,source"
+	assert.Equal(t, []string{combined, combined}, groupBy, "tokens and images are fetched in one query")
+	series := func(model, size, source string) float64 {
+		return testutil.ToFloat64(imagesTotal.WithLabelValues("org-1", "prod", "proj-1", "one", model, size, source))
+	}
+	assert.Equal(t, 3.0, series("dall-e-3", "1024x1024", "image.generation"), "buckets seen again are not counted twice")
+	assert.Equal(t, 2.0, series("dall-e-3", "1792x1024", "image.generation"))
+	assert.Equal(t, 1.0, series("dall-e-2", "512x512", "image.edit"))
+	assert.Equal(t, 3, testutil.CollectAndCount(imagesTotal))
+
+	tokens := func(model string) float64 {
+		return testutil.ToFloat64(tokensTotal.WithLabelValues("org-1", "prod", model, "images", "proj-1", "one", "user-1", "", "key-1", "ci", "", "input"))
+	}
+	assert.Equal(t, 50.0, tokens("dall-e-3"), "tokens split by size and source are summed")
+	assert.Equal(t, 10.0, tokens("dall-e-2"))
+}
+
+func TestImagesGroupBy(t *testing.T) {
+	assert.Equal(t, "project_id,user_id,model,size,source", imagesGroupBy("project_id,user_id,model"))
+	assert.Equal(t, "user_id,project_id,model,size,source", imagesGroupBy("user_id"))
+}
+
+func TestMergeResults(t *testing.T) {
+	buckets := []Bucket{{StartTime: 60, EndTime: 120, Results: []UsageResult{
+		{InputTokens: 30, Images: 3, ProjectID: strPtr("proj-1"), Model: strPtr("dall-e-3"), Size: strPtr("1024x1024")},
+		{InputTokens: 20, Images: 2, ProjectID: strPtr("proj-1"), Model: strPtr("dall-e-3"), Size: strPtr("1792x1024")},
+		{InputTokens: 10, Images: 1, ProjectID: strPtr("proj-2"), Model: strPtr("dall-e-2"), Size: strPtr("512x512")},
+	}}}
+
+	merged := mergeResults(buckets, "project_id")
+	require.Len(t, merged, 1)
+	assert.Equal(t, []UsageResult{
+		{InputTokens: 50, Images: 5, ProjectID: strPtr("proj-1")},
+		{InputTokens: 10, Images: 1, ProjectID: strPtr("proj-2")},
+	}, merged[0].Results)
+	assert.Len(t, buckets[0].Results, 3, "the fetched buckets are not modified")
+}
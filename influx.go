@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+)
+
+// Influx Line Protocol Push
+
+var (
+	influxURL      = flag.String("influx.url", "", "URL the OpenAI metrics are POSTed to in Influx line protocol, e.g. http://victoriametrics:8428/write or http://influxdb:8086/api/v2/write?org=o&bucket=b; the token is taken from INFLUX_TOKEN")
+	influxInterval = flag.Duration("influx.interval", time.Minute, "Interval at which metrics are pushed to -influx.url")
+
+	influxPushesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "openai_exporter_influx_pushes_total",
+			Help: "Total number of pushes to -influx.url, by result (success or error).",
+		},
+		[]string{"result"},
+	)
+)
+
+var influxClient = &http.Client{Timeout: 30 * time.Second}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `)
+	influxTagEscaper         = strings.NewReplacer(`,`, `\,`, ` `, `\ `, `=`, `\=`)
+)
+
+// validateInflux checks the Influx push flags.
+func validateInflux() error {
+	if *influxURL == "" {
+		return nil
+	}
+	u, err := url.Parse(*influxURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid influx.url %q: must be an http or https URL", *influxURL)
+	}
+	if *influxInterval <= 0 {
+		return fmt.Errorf("influx.interval must be positive, got %s", *influxInterval)
+	}
+	return nil
+}
+
+// writeLineProtocol writes the OpenAI metrics of g in Influx line protocol with the timestamp now: one line
+// per series, measured as the metric name with its labels as tags and its value in the field value.
+// Counters are written as their cumulative value. Labels with empty values are left out, since Influx
+// rejects empty tags.
+func writeLineProtocol(w io.Writer, g prometheus.Gatherer, now time.Time) error {
+	families, err := openaiGatherer{gatherer: g}.Gather()
+	if err != nil {
+		return fmt.Errorf("error gathering metrics: %w", err)
+	}
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			var value float64
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				value = m.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				value = m.GetGauge().GetValue()
+			case dto.MetricType_UNTYPED:
+				value = m.GetUntyped().GetValue()
+			default:
+				continue
+			}
+			var line strings.Builder
+			line.WriteString(influxMeasurementEscaper.Replace(mf.GetName()))
+			// Labels come sorted by name, which is also the order Influx prefers for tags.
+			for _, lp := range m.GetLabel() {
+				if lp.GetValue() == "" {
+					continue
+				}
+				line.WriteString("," + influxTagEscaper.Replace(lp.GetName()) + "=" + influxTagEscaper.Replace(lp.GetValue()))
+			}
+			line.WriteString(" value=" + strconv.FormatFloat(value, 'g', -1, 64) + " " + strconv.FormatInt(now.UnixNano(), 10) + "\n")
+			if _, err := io.WriteString(w, line.String()); err != nil {
+				return fmt.Errorf("error writing line protocol: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// pushInflux POSTs the OpenAI metrics of g to rawURL in line protocol. token, if set, is sent as an InfluxDB
+// API token; credentials in the URL are sent as basic auth.
+func pushInflux(rawURL, token string, g prometheus.Gatherer, now time.Time) error {
+	var body bytes.Buffer
+	if err := writeLineProtocol(&body, g, now); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, rawURL, &body)
+	if err != nil {
+		return fmt.Errorf("invalid -influx.url: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if token != "" {
+		req.Header.Set("Authorization", "Token "+token)
+	}
+	resp, err := influxClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error pushing metrics: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("push returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// runInflux starts pushing the metrics of g to -influx.url every -influx.interval when it is set and returns
+// the function that pushes them a last time and stops on shutdown.
+func runInflux(g prometheus.Gatherer) func() {
+	if *influxURL == "" {
+		return func() {}
+	}
+	token := os.Getenv("INFLUX_TOKEN")
+	push := func(now time.Time) {
+		result := "success"
+		if err := pushInflux(*influxURL, token, g, now); err != nil {
+			logrus.WithError(err).Warn("Error pushing metrics in Influx line protocol")
+			result = "error"
+		}
+		influxPushesTotal.WithLabelValues(result).Inc()
+	}
+	logrus.Infof("Pushing metrics in Influx line protocol every %s", *influxInterval)
+	return runEvery(*influxInterval, push)
+}
+
+// runEvery calls fn every interval in the background and returns the function that stops it and calls
+// fn a last time.
+func runEvery(interval time.Duration, fn func(time.Time)) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				fn(now)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+		fn(time.Now())
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateInflux(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		interval time.Duration
+		wantErr  string
+	}{
+		{name: "disabled", interval: 0},
+		{name: "VictoriaMetrics", url: "http://victoriametrics:8428/write", interval: time.Minute},
+		{name: "no scheme", url: "victoriametrics:8428/write", interval: time.Minute, wantErr: "must be an http or https URL"},
+		{name: "zero interval", url: "http://victoriametrics:8428/write", interval: 0, wantErr: "influx.interval must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origURL, origInterval := *influxURL, *influxInterval
+			defer func() { *influxURL, *influxInterval = origURL, origInterval }()
+			*influxURL, *influxInterval = tt.url, tt.interval
+
+			err := validateInflux()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestWriteLineProtocol(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	tokens := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "openai_api_tokens_total", Help: "Tokens."}, []string{"project_name", "model", "batch"})
+	cost := prometheus.NewGauge(prometheus.GaugeOpts{Name: "openai_budget_utilization_ratio", Help: "Budget."})
+	goInfo := prometheus.NewGauge(prometheus.GaugeOpts{Name: "go_info", Help: "Not an OpenAI metric."})
+	reg.MustRegister(tokens, cost, goInfo)
+	tokens.WithLabelValues("team a,b", "gpt-4o", "").Add(1520)
+	cost.Set(0.25)
+
+	var out bytes.Buffer
+	require.NoError(t, writeLineProtocol(&out, reg, time.Unix(1717842660, 0)))
+	assert.Equal(t, `openai_api_tokens_total,model=gpt-4o,project_name=team\ a\,b value=1520 1717842660000000000
+openai_budget_utilization_ratio value=0.25 1717842660000000000
+`, out.String())
+}
+
+func TestPushInflux(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "openai_exporter_up", Help: "Up."})
+	reg.MustRegister(gauge)
+	gauge.Set(1)
+
+	status := http.StatusNoContent
+	var body, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body, auth = string(data), r.Header.Get("Authorization")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("partial write: field type conflict"))
+	}))
+	defer server.Close()
+
+	require.NoError(t, pushInflux(server.URL+"/write", "secret", reg, time.Unix(1717842660, 0)))
+	assert.Equal(t, "openai_exporter_up value=1 1717842660000000000\n", body)
+	assert.Equal(t, "Token secret", auth)
+
+	status = http.StatusBadRequest
+	assert.EqualError(t, pushInflux(server.URL+"/write", "", reg, time.Unix(1717842660, 0)), "push returned status 400: partial write: field type conflict")
+	assert.Empty(t, auth)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Assistants and Vector Store Inventory
+
+var (
+	inventoryEnabled  = flag.Bool("collector.inventory", false, "Export the number of assistants and vector stores of each project")
+	inventoryInterval = flag.Duration("collector.inventory.interval", 15*time.Minute, "Interval at which the assistants and vector stores of all projects are re-counted")
+
+	assistantsCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_assistants_count",
+			Help: "Number of assistants per project.",
+		},
+		[]string{"org_id", "org_name", "project_id", "project_name"},
+	)
+	vectorStoresCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_vector_stores_count",
+			Help: "Number of vector stores per project.",
+		},
+		[]string{"org_id", "org_name", "project_id", "project_name"},
+	)
+)
+
+// ObjectList is a page of any list endpoint of which only the IDs are needed.
+type ObjectList struct {
+	Object string `json:"object"`
+	Data   []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+	HasMore bool   `json:"has_more"`
+	LastID  string `json:"last_id"`
+}
+
+// countObjects pages through the list endpoint at path (e.g. "assistants") in a project and returns the number of objects.
+func (e *Exporter) countObjects(path, projectID string) (int, error) {
+	// Assistants and vector stores are only listed with the beta header.
+	header := http.Header{"OpenAI-Project": {projectID}, "OpenAI-Beta": {"assistants=v2"}}
+	var count int
+	after := ""
+	guard := newPageGuard(e.orgID, path)
+
+	for {
+		u := fmt.Sprintf("/v1/%s?limit=100", path)
+		if after != "" {
+			u += "&after=" + url.QueryEscape(after)
+		}
+
+		resp, err := e.getWithHeader(u, header)
+		if err != nil {
+			return 0, fmt.Errorf("error fetching %s of project %s: %w", path, projectID, err)
+		}
+
+		var out ObjectList
+		decodeErr := json.NewDecoder(resp.Body).Decode(&out)
+		closeErr := resp.Body.Close()
+
+		if decodeErr != nil {
+			return 0, fmt.Errorf("error decoding response: %w", decodeErr)
+		}
+		if closeErr != nil {
+			logrus.WithError(closeErr).Warn("failed to close response body")
+		}
+
+		pagesFetchedTotal.WithLabelValues(e.orgID, path).Inc()
+
+		count += len(out.Data)
+		if !out.HasMore || out.LastID == "" || !guard.next(out.LastID) {
+			return count, nil
+		}
+		after = out.LastID
+	}
+}
+
+// collectInventory counts the assistants and vector stores of all active projects and replaces the exported
+// counts of the organization. The previous counts stay exported if any project cannot be read.
+func (e *Exporter) collectInventory() error {
+	projects, err := e.fetchProjects()
+	if err != nil {
+		return err
+	}
+
+	type inventory struct {
+		labels       prometheus.Labels
+		assistants   int
+		vectorStores int
+	}
+	var current []inventory
+	for _, p := range projects {
+		if p.Status == "archived" || !exportsProject(p.ID, func() string { return p.Name }) {
+			continue
+		}
+		assistants, err := e.countObjects("assistants", p.ID)
+		if err != nil {
+			return err
+		}
+		vectorStores, err := e.countObjects("vector_stores", p.ID)
+		if err != nil {
+			return err
+		}
+		current = append(current, inventory{prometheus.Labels{
+			"org_id":       e.orgID,
+			"org_name":     e.orgName,
+			"project_id":   p.ID,
+			"project_name": p.Name,
+		}, assistants, vectorStores})
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	e.rememberProjectNames(projects, time.Now())
+	assistantsCount.DeletePartialMatch(prometheus.Labels{"org_id": e.orgID})
+	vectorStoresCount.DeletePartialMatch(prometheus.Labels{"org_id": e.orgID})
+	for _, inv := range current {
+		assistantsCount.With(inv.labels).Set(float64(inv.assistants))
+		vectorStoresCount.With(inv.labels).Set(float64(inv.vectorStores))
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectInventory(t *testing.T) {
+	projectNames = make(map[string]string)
+	assistantsCount.Reset()
+	vectorStoresCount.Reset()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/organization/projects" {
+			_, _ = w.Write([]byte(`{"object": "list", "data": [
+				{"id": "proj-1", "name": "one", "status": "active"},
+				{"id": "proj-2", "name": "two", "status": "archived"}
+			], "has_more": false}`))
+			return
+		}
+		assert.Equal(t, "assistants=v2", r.Header.Get("OpenAI-Beta"))
+		assert.Equal(t, "proj-1", r.Header.Get("OpenAI-Project"))
+		switch r.URL.Path {
+		case "/v1/assistants":
+			// Two pages of assistants.
+			if r.URL.Query().Get("after") == "" {
+				_, _ = w.Write([]byte(`{"object": "list", "data": [{"id": "asst-1"}, {"id": "asst-2"}], "has_more": true, "last_id": "asst-2"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"object": "list", "data": [{"id": "asst-3"}], "has_more": false, "last_id": "asst-3"}`))
+		case "/v1/vector_stores":
+			_, _ = w.Write([]byte(`{"object": "list", "data": [{"id": "vs-1"}], "has_more": false, "last_id": "vs-1"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+
+	require.NoError(t, e.collectInventory())
+
+	assert.Equal(t, 3.0, testutil.ToFloat64(assistantsCount.WithLabelValues("org-1", "prod", "proj-1", "one")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(vectorStoresCount.WithLabelValues("org-1", "prod", "proj-1", "one")))
+	assert.Equal(t, 1, testutil.CollectAndCount(assistantsCount), "archived projects are skipped")
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"flag"
+	"math/rand/v2"
+	"time"
+)
+
+// Scheduling Jitter
+
+var scrapeJitter = flag.Duration("scrape.jitter", 0, "Maximum random delay, drawn once, between the end of each window and the start of its collection cycle, and before each endpoint fetch within a cycle (0 disables)")
+
+// jitter returns a random duration in [0, max), or 0 if max is not positive.
+func jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return rand.N(max)
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJitter(t *testing.T) {
+	assert.Equal(t, time.Duration(0), jitter(0))
+	assert.Equal(t, time.Duration(0), jitter(-time.Second))
+
+	for i := 0; i < 100; i++ {
+		d := jitter(time.Second)
+		assert.GreaterOrEqual(t, d, time.Duration(0))
+		assert.Less(t, d, time.Second)
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// LiteLLM Gateway Provider
+
+var (
+	providerName = flag.String("provider", "openai", "Source of usage data: openai (Usage and Costs APIs), litellm (LiteLLM proxy spend logs) or azure (Azure Monitor and Cost Management)")
+	litellmURL   = flag.String("litellm.url", "http://localhost:4000", "Base URL of the LiteLLM proxy admin API")
+)
+
+// litellmOrg is the organization label of all LiteLLM usage and spend.
+const litellmOrg = "litellm"
+
+// litellmOperations maps LiteLLM call types to the operation label used for OpenAI usage endpoints.
+var litellmOperations = map[string]string{
+	"completion":          "completions",
+	"acompletion":         "completions",
+	"text_completion":     "completions",
+	"atext_completion":    "completions",
+	"embedding":           "embeddings",
+	"aembedding":          "embeddings",
+	"moderation":          "moderations",
+	"amoderation":         "moderations",
+	"image_generation":    "images",
+	"aimage_generation":   "images",
+	"speech":              "audio_speeches",
+	"aspeech":             "audio_speeches",
+	"transcription":       "audio_transcriptions",
+	"atranscription":      "audio_transcriptions",
+	"responses":           "completions",
+	"aresponses":          "completions",
+	"anthropic_messages":  "completions",
+	"aanthropic_messages": "completions",
+}
+
+// LiteLLMExporter collects usage and spend from a LiteLLM proxy into the same metrics as the OpenAI exporter.
+// Teams take the place of projects and virtual keys the place of API keys.
+type LiteLLMExporter struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+	// spend accumulates the spend per cost series, since LiteLLM reports it per request rather than per day.
+	spend map[string]float64
+}
+
+type LiteLLMSpendLogs struct {
+	Data       []LiteLLMSpendLog `json:"data"`
+	Page       int               `json:"page"`
+	TotalPages int               `json:"total_pages"`
+}
+
+type LiteLLMSpendLog struct {
+	RequestID        string  `json:"request_id"`
+	CallType         string  `json:"call_type"`
+	APIKey           string  `json:"api_key"`
+	Spend            float64 `json:"spend"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	StartTime        string  `json:"startTime"`
+	Model            string  `json:"model"`
+	User             string  `json:"user"`
+	TeamID           string  `json:"team_id"`
+	Metadata         struct {
+		UserAPIKeyAlias     string `json:"user_api_key_alias"`
+		UserAPIKeyTeamAlias string `json:"user_api_key_team_alias"`
+	} `json:"metadata"`
+}
+
+func NewLiteLLMExporter() (*LiteLLMExporter, error) {
+	apiKey := os.Getenv("LITELLM_MASTER_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("LITELLM_MASTER_KEY environment variable is not set")
+	}
+	client, err := newAPIClient(10 * time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return &LiteLLMExporter{
+		client:  client,
+		baseURL: strings.TrimSuffix(*litellmURL, "/"),
+		apiKey:  apiKey,
+		spend:   make(map[string]float64),
+	}, nil
+}
+
+// parseLiteLLMTime parses the timestamps of LiteLLM spend logs, which are UTC with or without a zone suffix.
+func parseLiteLLMTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999", "2006-01-02 15:04:05.999999"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported time format %q", s)
+}
+
+// collectWindow fetches the spend logs of one time window and records them as a single bucket per label set.
+func (l *LiteLLMExporter) collectWindow(startTime, endTime int64) error {
+	// Unlike the Usage API, spend logs are not bucketed upstream, so the window must be over before it is read.
+	if wait := time.Until(time.Unix(endTime, 0)); wait > 0 {
+		time.Sleep(wait)
+	}
+
+	logs, err := l.fetchSpendLogs(startTime, endTime)
+	recordFetch(litellmOrg, "spend_logs", err)
+	if err != nil {
+		logrus.WithError(err).WithFields(windowFields(litellmOrg, "spend_logs", startTime, endTime)).Error("Error fetching LiteLLM spend logs")
+		return err
+	}
+
+	type bucket struct {
+		labels       prometheus.Labels
+		input        int64
+		output       int64
+		spend        float64
+		costLabels   prometheus.Labels
+		costSeriesID string
+	}
+	buckets := make(map[string]*bucket)
+
+	for _, entry := range logs {
+		ts, err := parseLiteLLMTime(entry.StartTime)
+		if err != nil {
+			logrus.WithError(err).Warnf("Skipping LiteLLM spend log %s", entry.RequestID)
+			continue
+		}
+		// The API filters on whole seconds; keep the window half-open so no request is counted twice.
+		if ts.Unix() < startTime || ts.Unix() >= endTime {
+			continue
+		}
+
+		operation, ok := litellmOperations[entry.CallType]
+		if !ok {
+			operation = entry.CallType
+		}
+		labels := prometheus.Labels{
+			"org_id":       litellmOrg,
+			"org_name":     litellmOrg,
+			"model":        orUnknown(entry.Model),
+			"operation":    operation,
+			"project_id":   orUnknown(entry.TeamID),
+			"project_name": orUnknown(entry.Metadata.UserAPIKeyTeamAlias),
+			"user_id":      orUnknown(entry.User),
+			"user_email":   "",
+			"api_key_id":   orUnknown(entry.APIKey),
+			"api_key_name": orUnknown(entry.Metadata.UserAPIKeyAlias),
+			"batch":        "false",
+		}
+		costLabels := prometheus.Labels{
+			"date":            ts.Format("2006-01-02"),
+			"project_id":      labels["project_id"],
+			"project_name":    labels["project_name"],
+			"line_item":       labels["model"],
+			"organization_id": litellmOrg,
+			"org_name":        litellmOrg,
+			"currency":        "usd",
+		}
+
+		key := fmt.Sprint(labels)
+		b, ok := buckets[key]
+		if !ok {
+			b = &bucket{labels: labels, costLabels: costLabels, costSeriesID: fmt.Sprint(costLabels)}
+			buckets[key] = b
+		}
+		b.input += entry.PromptTokens
+		b.output += entry.CompletionTokens
+		b.spend += entry.Spend
+	}
+
+	for _, b := range buckets {
+		updateMetric(b.labels, "input", startTime, endTime, float64(b.input))
+		updateMetric(b.labels, "output", startTime, endTime, float64(b.output))
+
+		stateMu.Lock()
+		l.spend[b.costSeriesID] += b.spend
+		total := l.spend[b.costSeriesID]
+		dailyCostUSD.With(b.costLabels).Set(total)
+		stateMu.Unlock()
+		updateCost(b.costLabels, total)
+	}
+
+	logrus.WithFields(windowFields(litellmOrg, "spend_logs", startTime, endTime)).WithField("records", len(logs)).Info("Fetched LiteLLM spend logs")
+	return nil
+}
+
+// fetchSpendLogs pages through the LiteLLM spend logs between startTime and endTime.
+func (l *LiteLLMExporter) fetchSpendLogs(startTime, endTime int64) ([]LiteLLMSpendLog, error) {
+	const layout = "2006-01-02 15:04:05"
+	var logs []LiteLLMSpendLog
+	guard := newPageGuard(litellmOrg, "spend_logs")
+
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("start_date", time.Unix(startTime, 0).UTC().Format(layout))
+		query.Set("end_date", time.Unix(endTime, 0).UTC().Format(layout))
+		query.Set("page", fmt.Sprintf("%d", page))
+		query.Set("page_size", "100")
+		u := fmt.Sprintf("%s/spend/logs/ui?%s", l.baseURL, query.Encode())
+
+		logrus.Debugf("Fetching LiteLLM spend logs: %s", u)
+
+		req, err := http.NewRequestWithContext(requestCtx, "GET", u, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+l.apiKey)
+
+		resp, err := l.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching spend logs: %w", err)
+		}
+
+		var out LiteLLMSpendLogs
+		decodeErr := json.NewDecoder(resp.Body).Decode(&out)
+		closeErr := resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("LiteLLM returned status %d", resp.StatusCode)
+		}
+		if decodeErr != nil {
+			return nil, fmt.Errorf("error decoding response: %w", decodeErr)
+		}
+		if closeErr != nil {
+			logrus.WithError(closeErr).Warn("failed to close response body")
+		}
+
+		pagesFetchedTotal.WithLabelValues(litellmOrg, "spend_logs").Inc()
+
+		logs = append(logs, out.Data...)
+		if page >= out.TotalPages || !guard.next(fmt.Sprintf("%d", page+1)) {
+			return logs, nil
+		}
+	}
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
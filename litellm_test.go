@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLiteLLMExporter(t *testing.T) {
+	t.Setenv("LITELLM_MASTER_KEY", "")
+	_, err := NewLiteLLMExporter()
+	assert.Error(t, err)
+
+	t.Setenv("LITELLM_MASTER_KEY", "sk-master")
+	l, err := NewLiteLLMExporter()
+	require.NoError(t, err)
+	assert.Equal(t, "sk-master", l.apiKey)
+	assert.Equal(t, "http://localhost:4000", l.baseURL)
+}
+
+func TestParseLiteLLMTime(t *testing.T) {
+	want := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+	for _, input := range []string{"2024-06-01T12:30:00Z", "2024-06-01T12:30:00.000000", "2024-06-01 12:30:00"} {
+		got, err := parseLiteLLMTime(input)
+		require.NoError(t, err, input)
+		assert.True(t, want.Equal(got), input)
+	}
+
+	_, err := parseLiteLLMTime("yesterday")
+	assert.Error(t, err)
+}
+
+func TestLiteLLMExporter_CollectWindow(t *testing.T) {
+	usageState = make(map[string]float64)
+	tokensTotal.Reset()
+	dailyCostUSD.Reset()
+
+	start := time.Now().Add(-2 * time.Minute).Truncate(time.Minute)
+	inWindow := start.Add(30 * time.Second).UTC().Format("2006-01-02T15:04:05.000000")
+	outside := start.Add(-30 * time.Second).UTC().Format("2006-01-02T15:04:05.000000")
+
+	var gotAuth string
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		pages = append(pages, r.URL.Query().Get("page"))
+		if r.URL.Query().Get("page") == "1" {
+			_, _ = w.Write([]byte(`{"data": [
+				{"request_id": "a", "call_type": "acompletion", "api_key": "hashed", "spend": 0.5, "prompt_tokens": 10, "completion_tokens": 5,
+				 "startTime": "` + inWindow + `", "model": "gpt-4o", "user": "alice", "team_id": "team-1",
+				 "metadata": {"user_api_key_alias": "ci-key", "user_api_key_team_alias": "ml"}},
+				{"request_id": "b", "call_type": "acompletion", "api_key": "hashed", "spend": 0.25, "prompt_tokens": 20, "completion_tokens": 1,
+				 "startTime": "` + outside + `", "model": "gpt-4o", "user": "alice", "team_id": "team-1"}
+			], "page": 1, "total_pages": 2}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": [
+			{"request_id": "c", "call_type": "acompletion", "api_key": "hashed", "spend": 0.5, "prompt_tokens": 1, "completion_tokens": 2,
+			 "startTime": "` + inWindow + `", "model": "gpt-4o", "user": "alice", "team_id": "team-1",
+			 "metadata": {"user_api_key_alias": "ci-key", "user_api_key_team_alias": "ml"}}
+		], "page": 2, "total_pages": 2}`))
+	}))
+	defer server.Close()
+
+	l := &LiteLLMExporter{client: server.Client(), baseURL: server.URL, apiKey: "sk-master", spend: make(map[string]float64)}
+	require.NoError(t, l.collectWindow(start.Unix(), start.Add(time.Minute).Unix()))
+
+	assert.Equal(t, "Bearer sk-master", gotAuth)
+	assert.Equal(t, []string{"1", "2"}, pages)
+	assert.Equal(t, 11.0, testutil.ToFloat64(tokensTotal.WithLabelValues("litellm", "litellm", "gpt-4o", "completions", "team-1", "ml", "alice", "", "hashed", "ci-key", "false", "input")))
+	assert.Equal(t, 7.0, testutil.ToFloat64(tokensTotal.WithLabelValues("litellm", "litellm", "gpt-4o", "completions", "team-1", "ml", "alice", "", "hashed", "ci-key", "false", "output")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(dailyCostUSD.WithLabelValues(start.UTC().Format("2006-01-02"), "team-1", "ml", "gpt-4o", "litellm", "litellm", "usd")))
+}
+
+func TestLiteLLMExporter_CollectWindowError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error": "invalid key"}`))
+	}))
+	defer server.Close()
+
+	l := &LiteLLMExporter{client: server.Client(), baseURL: server.URL, apiKey: "bad", spend: make(map[string]float64)}
+	err := l.collectWindow(1000, 2000)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+}
@@ -1,20 +1,31 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	versioncollector "github.com/prometheus/client_golang/prometheus/collectors/version"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/version"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
 )
 
 // Custom Type for Batch Field
@@ -80,21 +91,30 @@ var (
 	lastScrape   = int64(0)
 	projectNames = make(map[string]string) // mapping project_id -> project_name
 	apiKeyNames  = make(map[string]string) // mapping api_key_id -> api_key_name
+	// costState stores the last amount seen for each daily cost bucket, so revisions are only counted once.
+	costState = make(map[string]float64)
 )
 
 // Prometheus Metric and CLI Flags
 
+// usageGroupBy lists the dimensions usage results are grouped by unless -usage.group-by says otherwise.
+const usageGroupBy = "project_id,user_id,api_key_id,model,batch"
+
+// bucketLimits is the maximum number of buckets the Usage API returns per page for each bucket width.
+var bucketLimits = map[string]int{"1m": 1440, "1h": 168, "1d": 31}
+
 type UsageEndpoint struct {
 	Path string // API endpoint path (e.g. "completions")
 	Name string // Name of the operation (e.g. "completions")
 }
 
 var (
-	listenAddress = flag.String("web.listen-address", ":9185", "Address to listen on for web interface and telemetry")
+	listenAddress = flag.String("web.listen-address", ":9185", "Address to listen on for web interface and telemetry, or unix:///path/to/socket for a Unix domain socket")
 	metricsPath   = flag.String("web.telemetry-path", "/metrics", "Path under which to expose metrics")
 	// API polling interval; also used to determine the time window (last minute).
 	scrapeInterval = flag.Duration("scrape.interval", 1*time.Minute, "Interval for API calls and data window")
 	logLevel       = flag.String("log.level", "info", "Log level")
+	logFormat      = flag.String("log.format", "text", "Log format: text, or json with one object per line for Loki or Elasticsearch pipelines")
 
 	usageEndpoints = []UsageEndpoint{
 		{Path: "completions", Name: "completions"},
@@ -103,28 +123,103 @@ var (
 		{Path: "images", Name: "images"},
 		{Path: "audio_speeches", Name: "audio_speeches"},
 		{Path: "audio_transcriptions", Name: "audio_transcriptions"},
-		{Path: "vector_stores", Name: "vector_stores"},
+		{Path: "vector_stores", Name: vectorStoresEndpoint},
+		{Path: "code_interpreter_sessions", Name: codeInterpreterEndpoint},
 	}
 
-	tokensTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "openai_api_tokens_total",
-			Help: "Total number of tokens used per model, operation, project, user, API key, batch and token type",
-		},
-		[]string{"model", "operation", "project_id", "project_name", "user_id", "api_key_id", "api_key_name", "batch", "token_type"},
-	)
+	tokensTotal  = newTokensTotal(tokenLabels)
 	dailyCostUSD = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "openai_api_daily_cost",
 			Help: "Daily spend by date/project/line_item/organization (currency indicated by label).",
 		},
-		[]string{"date", "project_id", "project_name", "line_item", "organization_id", "currency"},
+		[]string{"date", "project_id", "project_name", "line_item", "organization_id", "org_name", "currency"},
+	)
+	costsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "openai_api_costs_usd_total",
+			Help: "Total billed amount in USD per organization, project and line item",
+		},
+		[]string{"org_id", "org_name", "project_id", "project_name", "line_item"},
 	)
 )
 
-func init() {
-	prometheus.MustRegister(tokensTotal)
-	prometheus.MustRegister(dailyCostUSD)
+// RegisterMetrics registers all metrics of the exporter with reg. Nothing is registered
+// on the global default registry, so callers and tests can use a registry of their own.
+func RegisterMetrics(reg prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{
+		tokensTotal,
+		dailyCostUSD,
+		costsTotal,
+		imagesTotal,
+		audioSecondsTotal,
+		ttsCharactersTotal,
+		vectorStoreUsageBytes,
+		codeInterpreterSessionsTotal,
+		projectRateLimit,
+		fineTuningJobs,
+		fineTuningTrainedTokens,
+		fineTuningJobDuration,
+		filesCount,
+		filesBytes,
+		assistantsCount,
+		vectorStoresCount,
+		modelAvailable,
+		modelCreated,
+		organizationUsers,
+		organizationInvites,
+		oldestPendingInvite,
+		serviceAccountInfo,
+		apiKeyInfo,
+		apiKeyLastUsed,
+		budgetLimitUSD,
+		budgetSpendUSD,
+		budgetUtilization,
+		estimatedCostTotal,
+		pricingReloadSuccessful,
+		cachedTokenRatio,
+		cacheSavingsTotal,
+		toolCallsTotal,
+		anomalyScore,
+		anomalyDetected,
+		notificationsTotal,
+		endpointEnabled,
+		paginationTruncatedTotal,
+		influxPushesTotal,
+		activeKeyIndex,
+		keyReloadsTotal,
+		exporterUp,
+		scrapeDuration,
+		scrapeErrorsTotal,
+		httpRequestsTotal,
+		reloadSuccessful,
+		reloadSuccessTimestamp,
+		pagesFetchedTotal,
+		lastSuccessTimestamp,
+		lastErrorInfo,
+		apiTargetActive,
+		projectLifecycleEvents,
+		configInfo,
+		apiCallsTotal,
+		apiCallsLastHour,
+		optionalCallsSkipped,
+		rateLimitWaitSeconds,
+		workerPoolSize,
+		workerPoolBusy,
+		dailyTokens,
+		dailyTokensDrift,
+		auditEventsTotal,
+		auditForwardErrors,
+		azureDeploymentInfo,
+		seriesLimitHits,
+		stateEntries,
+		versioncollector.NewCollector("openai_exporter"),
+	} {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func setupLogging() {
@@ -133,16 +228,40 @@ func setupLogging() {
 		logrus.WithError(err).Fatal("Failed to parse log level")
 	}
 	logrus.SetLevel(level)
-	logrus.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	switch *logFormat {
+	case "text":
+		logrus.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	case "json":
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+		// The web server of the exporter toolkit logs through slog.
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
+	default:
+		logrus.Fatalf("Unknown log format %q, use text or json", *logFormat)
+	}
 	logrus.Infof("Log level set to %s", level)
 }
 
+// windowFields are the log fields identifying the fetch of one endpoint of an organization for a window,
+// named like the labels of the self-metrics so log lines and series can be matched.
+func windowFields(orgID, endpoint string, startTime, endTime int64) logrus.Fields {
+	return logrus.Fields{"org_id": orgID, "endpoint": endpoint, "window_start": startTime, "window_end": endTime}
+}
+
 // Exporter and API Structures
 
 type Exporter struct {
 	client *http.Client
+	// apiKey holds the admin keys, separated by commas, unless they are read from keyFile.
 	apiKey string
-	orgID  string
+	// projectKey is the project or service account key for project resources outside /v1/organization.
+	projectKey     string
+	keyFile        *secretFile
+	keys           keyRotation
+	orgID          string
+	orgName        string
+	targets        *apiTargets
+	retry          retryPolicy
+	auditForwarder *syslogForwarder
 }
 
 type APIResponse struct {
@@ -172,6 +291,43 @@ type UsageResult struct {
 	APIKeyID          *string      `json:"api_key_id"`
 	Model             *string      `json:"model"`
 	Batch             StringOrBool `json:"batch"`
+	ServiceTier       *string      `json:"service_tier"`
+	// Images, Size and Source are only reported by the images endpoint.
+	Images int64   `json:"images"`
+	Size   *string `json:"size"`
+	Source *string `json:"source"`
+	// Seconds and Characters are only reported by the audio_transcriptions and audio_speeches endpoints.
+	Seconds    int64 `json:"seconds"`
+	Characters int64 `json:"characters"`
+	// UsageBytes is only reported by the vector_stores endpoint.
+	UsageBytes int64 `json:"usage_bytes"`
+	// NumSessions is only reported by the code_interpreter_sessions endpoint.
+	NumSessions int64 `json:"num_sessions"`
+	// NumWebSearchCalls and NumFileSearchCalls count the calls of built-in tools, which are charged per call.
+	NumWebSearchCalls  int64 `json:"num_web_search_calls"`
+	NumFileSearchCalls int64 `json:"num_file_search_calls"`
+	// OutputTokensDetails breaks down the output tokens of reasoning models.
+	OutputTokensDetails struct {
+		ReasoningTokens int64 `json:"reasoning_tokens"`
+	} `json:"output_tokens_details"`
+}
+
+// tokenCount is the number of tokens of one token_type in a usage result.
+type tokenCount struct {
+	Type  string
+	Value int64
+}
+
+// tokenCounts returns the token counts of the result by token_type.
+func (r UsageResult) tokenCounts() []tokenCount {
+	return []tokenCount{
+		{"input", r.InputTokens},
+		{"output", r.OutputTokens},
+		{"input_cached", r.InputCachedTokens},
+		{"input_audio", r.InputAudioTokens},
+		{"output_audio", r.OutputAudioTokens},
+		{"reasoning", r.OutputTokensDetails.ReasoningTokens},
+	}
 }
 
 type Project struct {
@@ -179,7 +335,19 @@ type Project struct {
 }
 
 type APIKey struct {
-	Name string `json:"name"`
+	ID            string       `json:"id"`
+	Name          string       `json:"name"`
+	RedactedValue string       `json:"redacted_value"`
+	LastUsedAt    *int64       `json:"last_used_at"`
+	Owner         *APIKeyOwner `json:"owner"`
+}
+
+// displayName returns the name of the key, or its redacted value for keys created without a name.
+func (k APIKey) displayName() string {
+	if k.Name != "" {
+		return k.Name
+	}
+	return k.RedactedValue
 }
 
 type CostsList struct {
@@ -210,19 +378,158 @@ type CostResult struct {
 }
 
 func NewExporter() (*Exporter, error) {
+	keyFilePath := *apiKeyFile
+	if keyFilePath == "" {
+		keyFilePath = os.Getenv("OPENAI_SECRET_KEY_FILE")
+	}
+	var keyFile *secretFile
 	apiKey := os.Getenv("OPENAI_SECRET_KEY")
-	if apiKey == "" {
+	if keyFilePath != "" {
+		f, err := newSecretFile(keyFilePath)
+		if err != nil {
+			return nil, err
+		}
+		keyFile = f
+	} else if apiKey == "" {
 		return nil, fmt.Errorf("OPENAI_SECRET_KEY environment variable is not set")
 	}
-	orgID := os.Getenv("OPENAI_ORG_ID")
-	if orgID == "" {
+	org := singleOrganization()
+	if org.ID == "" {
 		return nil, fmt.Errorf("OPENAI_ORG_ID environment variable is not set")
 	}
-	return &Exporter{
-		client: &http.Client{Timeout: 10 * time.Second},
-		apiKey: apiKey,
-		orgID:  orgID,
-	}, nil
+	projectKey, err := projectKey(org)
+	if err != nil {
+		return nil, err
+	}
+	return newExporter(org, apiKey, projectKey, keyFile)
+}
+
+// newExporter creates the exporter of org, authenticating with keyFile if set and apiKey otherwise.
+// Project resources are read with projectKey.
+func newExporter(org Organization, apiKey, projectKey string, keyFile *secretFile) (*Exporter, error) {
+	baseURL := *baseURLs
+	if org.BaseURL != "" {
+		baseURL = org.BaseURL
+	}
+	client, err := newAPIClient(*openaiTimeout)
+	if err != nil {
+		return nil, err
+	}
+	e := &Exporter{
+		client:     client,
+		apiKey:     apiKey,
+		projectKey: projectKey,
+		keyFile:    keyFile,
+		orgID:      org.ID,
+		orgName:    org.Name,
+		targets:    newAPITargets(org.ID, baseURL, *failoverThreshold),
+		retry:      retryPolicyFromFlags(),
+	}
+	activeKeyIndex.WithLabelValues(org.ID).Set(0)
+	if *auditForwardAddr != "" {
+		forwarder, err := newSyslogForwarder(*auditForwardAddr, *auditForwardFormat)
+		if err != nil {
+			return nil, err
+		}
+		e.auditForwarder = forwarder
+	}
+	return e, nil
+}
+
+// get performs an authenticated GET request for path against the active API base URL.
+// Transport errors and 5xx responses count towards failing over to the next base URL.
+// Rate limited and failed attempts are retried according to the retry policy.
+func (e *Exporter) get(path string) (*http.Response, error) {
+	return e.getWithHeader(path, nil)
+}
+
+// getInProject performs the GET request of get on behalf of a project, for endpoints scoped to a single project.
+func (e *Exporter) getInProject(path, projectID string) (*http.Response, error) {
+	return e.getWithHeader(path, http.Header{"OpenAI-Project": {projectID}})
+}
+
+// getWithHeader performs the GET request of get with additional request headers.
+func (e *Exporter) getWithHeader(path string, header http.Header) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := e.attempt(path, header)
+		if !retryable(resp, err) {
+			return checkStatus(path, resp, err)
+		}
+		delay, ok := e.retry.next(attempt, resp, time.Now())
+		if !ok || requestCtx.Err() != nil {
+			return checkStatus(path, resp, err)
+		}
+		if err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{"org_id": e.orgID, "path": path}).Warnf("Request failed, retrying in %s", delay)
+		} else {
+			logrus.WithFields(logrus.Fields{"org_id": e.orgID, "path": path, "status": resp.StatusCode}).Warnf("Request failed, retrying in %s", delay)
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		if !sleep(requestCtx, delay) {
+			return nil, fmt.Errorf("request to %s cancelled while waiting to retry: %w", path, requestCtx.Err())
+		}
+	}
+}
+
+// attempt performs a single request for path. A response of 401 from a usage or cost endpoint fails over to
+// the next admin key and sends the request again with it, until every key has been tried.
+// Project resources outside /v1/organization are requested with the project key instead.
+func (e *Exporter) attempt(path string, header http.Header) (*http.Response, error) {
+	if !strings.HasPrefix(path, "/v1/organization/") {
+		return e.send(path, header, e.projectKey)
+	}
+	for tried := 1; ; tried++ {
+		key, err := e.activeKey()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := e.send(path, header, key)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized || !e.rejectKey(key, tried, failsOver(path)) {
+			return resp, err
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+}
+
+// send performs a single request for path with the admin key apiKey.
+func (e *Exporter) send(path string, header http.Header, apiKey string) (*http.Response, error) {
+	base := e.targets.current()
+	req, err := http.NewRequestWithContext(requestCtx, "GET", base+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	if err := waitForAPI(); err != nil {
+		return nil, err
+	}
+	apiBudget.record(time.Now())
+	resp, err := e.client.Do(req)
+	httpRequestsTotal.WithLabelValues(endpointLabel(path), statusClass(resp, err)).Inc()
+	if err != nil {
+		e.targets.report(base, false)
+		return nil, err
+	}
+	e.targets.report(base, resp.StatusCode < 500)
+	return resp, nil
+}
+
+// checkStatus turns responses with a non-2xx status into an *APIError.
+func checkStatus(path string, resp *http.Response, err error) (*http.Response, error) {
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(path, resp)
+	}
+	return resp, nil
 }
 
 // Helper Functions for State and Metrics
@@ -239,7 +546,11 @@ func mergeLabels(base prometheus.Labels, key, value string) prometheus.Labels {
 // updateMetric updates the metric for a given token type.
 // If the bucket is completed (bucketEnd <= current time) and has not been processed yet,
 // its value is added to the counter, and the bucket information is saved in usageState.
-func updateMetric(labels prometheus.Labels, tokenType string, bucketStart, bucketEnd int64, newValue float64) {
+// Buckets seen again with a larger value, e.g. within the lookback window, add the difference.
+// Deduplication uses the labels as reported by the API; filters and relabel rules only apply to the exported series,
+// so buckets of series dropped by a relabel rule are still marked as processed.
+// It returns the number of tokens added, which is 0 for buckets that were skipped.
+func updateMetric(labels prometheus.Labels, tokenType string, bucketStart, bucketEnd int64, newValue float64) float64 {
 	compositeKey := strings.Join([]string{
 		labels["operation"],
 		fmt.Sprintf("%d", bucketStart),
@@ -249,54 +560,226 @@ func updateMetric(labels prometheus.Labels, tokenType string, bucketStart, bucke
 		labels["model"],
 		labels["batch"],
 		tokenType,
+		labels["org_id"],
 	}, "|")
+	// Results split by service tier only differ in it, so it becomes part of the key when grouped by.
+	if tier, ok := labels["service_tier"]; ok {
+		compositeKey += "|" + tier
+	}
 
 	now := time.Now().Unix()
 	// Update the metric only if the bucket is completed.
 	if bucketEnd > now {
 		logrus.Debugf("Bucket %s is not yet completed (bucketEnd: %d, now: %d), skipping", compositeKey, bucketEnd, now)
+		return 0
+	}
+
+	// If the bucket has already been processed, only its growth is counted.
+	stateMu.RLock()
+	previous, exists := usageState[compositeKey]
+	stateMu.RUnlock()
+	if exists && newValue <= previous {
+		logrus.Debugf("Bucket %s has already been processed, skipping", compositeKey)
+		return 0
+	}
+
+	if !exists && !claimBucket(compositeKey, newValue) {
+		return 0
+	}
+
+	series, keep := relabel(currentRelabelRules(), mergeLabels(privateLabels(filterLabels(labels)), "token_type", tokenType))
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	delta := newValue - usageState[compositeKey]
+	if delta <= 0 {
+		return 0
+	}
+	if exists {
+		logrus.Debugf("Bucket %s grew from %g to %g", compositeKey, newValue-delta, newValue)
+	}
+	if keep {
+		tokensTotal.With(limitSeries(tokenSeriesLabels(series), time.Now().Unix())).Add(delta)
+	}
+	usageState[compositeKey] = newValue
+	return delta
+}
+
+// claimBucket reports whether this replica counts a bucket it has not seen before. With a shared backend,
+// only the replica that claims the bucket counts it and its later growth.
+func claimBucket(key string, value float64) bool {
+	if dedupBackend == nil {
+		return true
+	}
+	claimed, err := dedupBackend.claim(key, value)
+	if err != nil {
+		logrus.WithError(err).Warnf("Error claiming bucket %s, deduplicating locally", key)
+		return true
+	}
+	if !claimed {
+		logrus.Debugf("Bucket %s has already been counted by another replica, skipping", key)
+	}
+	return claimed
+}
+
+// updateCounter adds the growth of a completed usage bucket to counter, deduplicating it like updateMetric.
+// It is used for usage that is not measured in tokens, such as generated images. Their keys have fewer parts
+// than those of updateMetric, so the token queries and the reconciliation skip them.
+func updateCounter(counter *prometheus.CounterVec, key string, labels prometheus.Labels, bucketEnd int64, newValue float64) {
+	if bucketEnd > time.Now().Unix() {
+		return
+	}
+	stateMu.RLock()
+	previous, exists := usageState[key]
+	stateMu.RUnlock()
+	if exists && newValue <= previous {
+		return
+	}
+	if !exists && !claimBucket(key, newValue) {
 		return
 	}
 
 	stateMu.Lock()
 	defer stateMu.Unlock()
 
-	// If the bucket has already been processed, it is not updated again.
-	if _, exists := usageState[compositeKey]; exists {
-		logrus.Debugf("Bucket %s has already been processed, skipping", compositeKey)
+	delta := newValue - usageState[key]
+	if delta <= 0 {
 		return
 	}
+	counter.With(labels).Add(delta)
+	usageState[key] = newValue
+}
 
-	tokensTotal.With(mergeLabels(labels, "token_type", tokenType)).Add(newValue)
-	usageState[compositeKey] = newValue
+// updateCost adds the growth of a daily cost bucket since it was last seen to costsTotal.
+// Buckets keep changing until the day is over, and revisions downwards are ignored to keep the counter monotonic.
+func updateCost(labels prometheus.Labels, amount float64) {
+	if !strings.EqualFold(labels["currency"], "usd") {
+		return
+	}
+	key := strings.Join([]string{
+		labels["date"],
+		labels["organization_id"],
+		labels["project_id"],
+		labels["line_item"],
+	}, "|")
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	delta := amount - costState[key]
+	if delta <= 0 {
+		return
+	}
+	costState[key] = amount
+	costsTotal.With(prometheus.Labels{
+		"org_id":       labels["organization_id"],
+		"org_name":     labels["org_name"],
+		"project_id":   labels["project_id"],
+		"project_name": labels["project_name"],
+		"line_item":    labels["line_item"],
+	}).Add(delta)
 }
 
 // Data Collection
 
 func (e *Exporter) fetchUsageData(endpoint UsageEndpoint, startTime, endTime int64) error {
-	baseURL := fmt.Sprintf("https://api.openai.com/v1/organization/usage/%s", endpoint.Path)
-	nextPage := ""
+	return e.countUsage(endpoint, startTime, endTime, *usageBucketWidth)
+}
+
+// countUsage fetches the usage buckets of the given width between startTime and endTime and counts their tokens.
+func (e *Exporter) countUsage(endpoint UsageEndpoint, startTime, endTime int64, bucketWidth string) error {
+	switch endpoint.Name {
+	case codeInterpreterEndpoint:
+		return e.countCodeInterpreterSessions(endpoint, startTime, endTime, bucketWidth)
+	case vectorStoresEndpoint:
+		return e.countVectorStoreUsage(endpoint, startTime, endTime, bucketWidth)
+	}
+	groupBy := groupByFor(endpoint.Name)
+	fetchGroupBy := groupBy
+	if endpoint.Name == "images" {
+		fetchGroupBy = imagesGroupBy(groupBy)
+	}
+	buckets, err := e.fetchUsageBuckets(endpoint, startTime, endTime, bucketWidth, fetchGroupBy)
+	if err != nil {
+		return err
+	}
+	if endpoint.Name == "images" {
+		e.countImages(endpoint, startTime, endTime, buckets)
+		buckets = mergeResults(buckets, groupBy)
+	}
+	byTier := slices.Contains(strings.Split(groupBy, ","), "service_tier")
 
 	allResults := []UsageResult{}
+	for _, bucket := range buckets {
+		if len(bucket.Results) > 0 {
+			logrus.Debugf("Results %+v", bucket.Results)
+		}
+		for _, result := range bucket.Results {
+			allResults = append(allResults, result)
+
+			labels := prometheus.Labels{
+				"org_id":       e.orgID,
+				"org_name":     e.orgName,
+				"model":        deref(result.Model),
+				"operation":    endpoint.Name,
+				"project_id":   deref(result.ProjectID),
+				"project_name": e.ensureProjectName(deref(result.ProjectID)),
+				"user_id":      deref(result.UserID),
+				"user_email":   e.ensureUserEmail(deref(result.UserID)),
+				"api_key_id":   deref(result.APIKeyID),
+				"api_key_name": e.ensureAPIKeyName(deref(result.ProjectID), deref(result.APIKeyID)),
+				"batch":        string(result.Batch),
+			}
+			if byTier {
+				labels["service_tier"] = deref(result.ServiceTier)
+			}
 
-	for {
-		url := fmt.Sprintf("%s?start_time=%d&end_time=%d&bucket_width=1m&limit=1440&group_by=project_id,user_id,api_key_id,model,batch",
-			baseURL, startTime, endTime)
-		if nextPage != "" {
-			url += "&page=" + nextPage
+			added := make(map[string]float64)
+			for _, tc := range result.tokenCounts() {
+				added[tc.Type] = updateMetric(labels, tc.Type, bucket.StartTime, bucket.EndTime, float64(tc.Value))
+			}
+			addEstimatedCost(labels, added)
+			recordCacheUsage(labels, added)
+			recordAnomalyUsage(labels, added)
+			recordHistory(labels, bucket.StartTime, bucket.EndTime, added)
+
+			logrus.Debugf("Processed result - Model: %s, Operation: %s, ProjectID: %s, UserID: %s, APIKeyID: %s, Batch: %s, BucketStart: %d, BucketEnd: %d, InputTokens: %d, OutputTokens: %d, InputCached: %d, InputAudio: %d, OutputAudio: %d, Reasoning: %d, Requests: %d",
+				deref(result.Model), endpoint.Name, deref(result.ProjectID), deref(result.UserID), deref(result.APIKeyID),
+				string(result.Batch), bucket.StartTime, bucket.EndTime,
+				result.InputTokens, result.OutputTokens, result.InputCachedTokens, result.InputAudioTokens, result.OutputAudioTokens, result.OutputTokensDetails.ReasoningTokens, result.NumModelRequests)
 		}
+	}
 
-		logrus.Debugf("Fetching usage data: %s", url)
+	logrus.WithFields(windowFields(e.orgID, endpoint.Name, startTime, endTime)).WithField("records", len(allResults)).Info("Fetched usage records")
 
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			return fmt.Errorf("failed to create request: %w", err)
+	e.countAudio(endpoint, buckets)
+	e.countToolCalls(buckets)
+	return nil
+}
+
+// fetchUsageBuckets pages through the Usage API of endpoint and returns all buckets of the given width
+// (1m, 1h or 1d) between startTime and endTime, grouped by the comma-separated groupBy dimensions.
+func (e *Exporter) fetchUsageBuckets(endpoint UsageEndpoint, startTime, endTime int64, bucketWidth, groupBy string) ([]Bucket, error) {
+	basePath := fmt.Sprintf("/v1/organization/usage/%s", endpoint.Path)
+	start, limit := bucketWindow(startTime, endTime, bucketWidth)
+	nextPage := ""
+	guard := newPageGuard(e.orgID, endpoint.Name)
+
+	var buckets []Bucket
+
+	for {
+		path := fmt.Sprintf("%s?start_time=%d&end_time=%d&bucket_width=%s&limit=%d&group_by=%s",
+			basePath, start, endTime, bucketWidth, limit, groupBy)
+		if nextPage != "" {
+			path += "&page=" + nextPage
 		}
-		req.Header.Set("Authorization", "Bearer "+e.apiKey)
 
-		resp, err := e.client.Do(req)
+		logrus.WithFields(logrus.Fields{"org_id": e.orgID, "endpoint": endpoint.Name}).Debugf("Fetching usage data: %s", path)
+
+		resp, err := e.get(path)
 		if err != nil {
-			return fmt.Errorf("error fetching usage data: %w", err)
+			return nil, fmt.Errorf("error fetching usage data: %w", err)
 		}
 
 		var response APIResponse
@@ -304,55 +787,30 @@ func (e *Exporter) fetchUsageData(endpoint UsageEndpoint, startTime, endTime int
 		closeErr := resp.Body.Close()
 
 		if decodeErr != nil {
-			return fmt.Errorf("error decoding response: %w", decodeErr)
+			return nil, fmt.Errorf("error decoding response: %w", decodeErr)
 		}
 		if closeErr != nil {
 			logrus.WithError(closeErr).Warn("failed to close response body")
 		}
 		logrus.Debugf("Received response: %+v", response)
+		pagesFetchedTotal.WithLabelValues(e.orgID, endpoint.Name).Inc()
 
-		for _, bucket := range response.Data {
-			if len(bucket.Results) > 0 {
-				logrus.Debugf("Results %+v", bucket.Results)
-			}
-			for _, result := range bucket.Results {
-				allResults = append(allResults, result)
-
-				labels := prometheus.Labels{
-					"model":        deref(result.Model),
-					"operation":    endpoint.Name,
-					"project_id":   deref(result.ProjectID),
-					"project_name": e.ensureProjectName(deref(result.ProjectID)),
-					"user_id":      deref(result.UserID),
-					"api_key_id":   deref(result.APIKeyID),
-					"api_key_name": e.ensureAPIKeyName(deref(result.ProjectID), deref(result.APIKeyID)),
-					"batch":        string(result.Batch),
-				}
-
-				updateMetric(labels, "input", bucket.StartTime, bucket.EndTime, float64(result.InputTokens))
-				updateMetric(labels, "output", bucket.StartTime, bucket.EndTime, float64(result.OutputTokens))
-				updateMetric(labels, "input_cached", bucket.StartTime, bucket.EndTime, float64(result.InputCachedTokens))
-				updateMetric(labels, "input_audio", bucket.StartTime, bucket.EndTime, float64(result.InputAudioTokens))
-				updateMetric(labels, "output_audio", bucket.StartTime, bucket.EndTime, float64(result.OutputAudioTokens))
-
-				logrus.Debugf("Processed result - Model: %s, Operation: %s, ProjectID: %s, UserID: %s, APIKeyID: %s, Batch: %s, BucketStart: %d, BucketEnd: %d, InputTokens: %d, OutputTokens: %d, InputCached: %d, InputAudio: %d, OutputAudio: %d, Requests: %d",
-					deref(result.Model), endpoint.Name, deref(result.ProjectID), deref(result.UserID), deref(result.APIKeyID),
-					string(result.Batch), bucket.StartTime, bucket.EndTime,
-					result.InputTokens, result.OutputTokens, result.InputCachedTokens, result.InputAudioTokens, result.OutputAudioTokens, result.NumModelRequests)
-			}
+		for i := range response.Data {
+			response.Data[i].Results = slices.DeleteFunc(response.Data[i].Results, func(r UsageResult) bool { return !e.exportsResult(r.ProjectID, r.Model) })
 		}
+		buckets = append(buckets, response.Data...)
 
-		if !response.HasMore {
+		if !response.HasMore || !guard.next(response.NextPage) {
 			break
 		}
 		nextPage = response.NextPage
 	}
 
-	logrus.Infof("Total records fetched from %s: %d", endpoint.Path, len(allResults))
-	return nil
+	return buckets, nil
 }
 
-// ensureProjectName returns the name for already known projects and exports the name for new ones
+// ensureProjectName returns the name for already known projects and exports the name for new ones.
+// Projects whose lookup failed recently are reported as unknown without asking the API again.
 func (e *Exporter) ensureProjectName(projectId string) string {
 	if projectId == "" || projectId == "unknown" {
 		return "unknown"
@@ -365,22 +823,27 @@ func (e *Exporter) ensureProjectName(projectId string) string {
 	}
 	stateMu.RUnlock()
 
-	url := fmt.Sprintf("https://api.openai.com/v1/organization/projects/%s", projectId)
-	logrus.Debugf("Fetching project name: %s", url)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
+	now := time.Now()
+	if projectLookupFailedRecently(projectId, now) {
+		return "unknown"
+	}
+	if !apiBudget.allowOptional(now) {
+		logrus.Debugf("Skipping project name lookup for %s to stay within the API budget", projectId)
 		return "unknown"
 	}
-	req.Header.Set("Authorization", "Bearer "+e.apiKey)
 
-	resp, err := e.client.Do(req)
+	path := fmt.Sprintf("/v1/organization/projects/%s", projectId)
+	logrus.Debugf("Fetching project name: %s", path)
+	resp, err := e.get(path)
 	if err != nil {
+		recordProjectLookupFailure(projectId, now)
 		return "unknown"
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	var obj Project
 	if err := json.NewDecoder(resp.Body).Decode(&obj); err != nil || obj.Name == "" {
+		recordProjectLookupFailure(projectId, now)
 		return "unknown"
 	}
 	logrus.Debugf("Received response: %+v", resp)
@@ -391,7 +854,8 @@ func (e *Exporter) ensureProjectName(projectId string) string {
 	return obj.Name
 }
 
-// ensureProjectName returns the name for already known API keys and exports the name for new ones
+// ensureAPIKeyName returns the name for already known API keys and looks up the name of new ones,
+// first among the keys of the project and then among the organization's admin keys.
 func (e *Exporter) ensureAPIKeyName(projectID, apiKeyID string) string {
 	if apiKeyID == "" || apiKeyID == "unknown" {
 		return "unknown"
@@ -404,42 +868,37 @@ func (e *Exporter) ensureAPIKeyName(projectID, apiKeyID string) string {
 	}
 	stateMu.RUnlock()
 
-	var urls []string
+	if !apiBudget.allowOptional(time.Now()) {
+		logrus.Debugf("Skipping api key name lookup for %s to stay within the API budget", apiKeyID)
+		return "unknown"
+	}
+
+	var paths []string
 	if projectID != "" && projectID != "unknown" {
-		urls = append(urls,
-			fmt.Sprintf("https://api.openai.com/v1/organization/projects/%s/api_keys/%s", projectID, apiKeyID))
+		paths = append(paths,
+			fmt.Sprintf("/v1/organization/projects/%s/api_keys/%s", projectID, apiKeyID))
 	}
-	urls = append(urls,
-		fmt.Sprintf("https://api.openai.com/v1/organization/api_keys/%s", apiKeyID))
+	paths = append(paths,
+		fmt.Sprintf("/v1/organization/admin_api_keys/%s", apiKeyID))
 
-	for _, u := range urls {
-		logrus.Debugf("Fetching api key name: %s", u)
-		req, err := http.NewRequest("GET", u, nil)
-		if err != nil {
-			continue
-		}
-		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	for _, p := range paths {
+		logrus.Debugf("Fetching api key name: %s", p)
 
 		if name, ok := func() (string, bool) {
-			resp, err := e.client.Do(req)
+			resp, err := e.get(p)
 			if err != nil {
 				return "", false
 			}
 			defer func() { _ = resp.Body.Close() }()
 
-			if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-				_, _ = io.Copy(io.Discard, resp.Body) // дренируем
-				return "", false
-			}
-
 			var obj APIKey
 			if err := json.NewDecoder(resp.Body).Decode(&obj); err != nil {
 				return "", false
 			}
-			if obj.Name == "" {
+			if obj.displayName() == "" {
 				return "", false
 			}
-			return obj.Name, true
+			return obj.displayName(), true
 		}(); ok {
 			stateMu.Lock()
 			apiKeyNames[apiKeyID] = name
@@ -460,27 +919,66 @@ func deref(s *string) string {
 
 // fetchCostData downloads information about the cost of projects
 func (e *Exporter) fetchCostData(startTime, endTime int64) error {
-	baseURL := "https://api.openai.com/v1/organization/costs"
+	buckets, err := e.fetchCostBuckets(startTime, endTime, "project_id,line_item")
+	if err != nil {
+		return err
+	}
+
+	for _, bucket := range buckets {
+		if len(bucket.Results) > 0 {
+			logrus.Debugf("Results %+v", bucket.Results)
+		}
+		date := time.Unix(bucket.StartTime, 0).UTC().Format("2006-01-02")
+		for _, res := range bucket.Results {
+			projectId := deref(res.ProjectID)
+			lineName := "unknown"
+			if res.LineItem != nil && *res.LineItem != "" {
+				lineName = *res.LineItem
+			}
+			orgID := res.OrganizationID
+			if orgID == "" {
+				orgID = e.orgID
+			}
+			labels := prometheus.Labels{
+				"date":            date,
+				"project_id":      projectId,
+				"project_name":    e.ensureProjectName(projectId),
+				"line_item":       lineName,
+				"organization_id": orgID,
+				"org_name":        e.orgName,
+				"currency":        res.Amount.Currency,
+			}
+			dailyCostUSD.With(labels).Set(float64(res.Amount.Value))
+			updateCost(labels, float64(res.Amount.Value))
+			logrus.Debugf("Processed result - Date: %s, ProjectID: %s, ProjectName: %s, LineItem: %s, OrganizationID: %s, Cost: %f, Currency: %s",
+				date, projectId, e.ensureProjectName(projectId), lineName, orgID, res.Amount.Value, res.Amount.Currency)
+		}
+	}
+
+	return nil
+}
+
+// fetchCostBuckets pages through the Costs API and returns all daily buckets between startTime and endTime,
+// grouped by the comma-separated groupBy dimensions.
+func (e *Exporter) fetchCostBuckets(startTime, endTime int64, groupBy string) ([]CostBucket, error) {
+	basePath := "/v1/organization/costs"
 	nextPage := ""
+	guard := newPageGuard(e.orgID, "costs")
+
+	var buckets []CostBucket
 
 	for {
-		url := fmt.Sprintf("%s?start_time=%d&end_time=%d&group_by=project_id",
-			baseURL, startTime, endTime)
+		path := fmt.Sprintf("%s?start_time=%d&end_time=%d&group_by=%s",
+			basePath, startTime, endTime, groupBy)
 		if nextPage != "" {
-			url += "&page=" + nextPage
+			path += "&page=" + nextPage
 		}
 
-		logrus.Debugf("Fetching cost data: %s", url)
+		logrus.WithFields(logrus.Fields{"org_id": e.orgID, "endpoint": "costs"}).Debugf("Fetching cost data: %s", path)
 
-		req, err := http.NewRequest("GET", url, nil)
+		resp, err := e.get(path)
 		if err != nil {
-			return fmt.Errorf("failed to create request: %w", err)
-		}
-		req.Header.Set("Authorization", "Bearer "+e.apiKey)
-
-		resp, err := e.client.Do(req)
-		if err != nil {
-			return fmt.Errorf("error fetching cost data: %w", err)
+			return nil, fmt.Errorf("error fetching cost data: %w", err)
 		}
 
 		var out CostsList
@@ -488,104 +986,508 @@ func (e *Exporter) fetchCostData(startTime, endTime int64) error {
 		closeErr := resp.Body.Close()
 
 		if decodeErr != nil {
-			return fmt.Errorf("error decoding response: %w", decodeErr)
+			return nil, fmt.Errorf("error decoding response: %w", decodeErr)
 		}
 		if closeErr != nil {
 			logrus.WithError(closeErr).Warn("failed to close response body")
 		}
 		logrus.Debugf("Received response: %+v", resp)
+		pagesFetchedTotal.WithLabelValues(e.orgID, "costs").Inc()
 
-		for _, bucket := range out.Data {
-			if len(bucket.Results) > 0 {
-				logrus.Debugf("Results %+v", bucket.Results)
-			}
-			date := time.Unix(bucket.StartTime, 0).UTC().Format("2006-01-02")
-			for _, res := range bucket.Results {
-				projectId := deref(res.ProjectID)
-				lineName := "unknown"
-				if res.LineItem != nil && *res.LineItem != "" {
-					lineName = *res.LineItem
-				}
-				labels := prometheus.Labels{
-					"date":            date,
-					"project_id":      projectId,
-					"project_name":    e.ensureProjectName(projectId),
-					"line_item":       lineName,
-					"organization_id": res.OrganizationID,
-					"currency":        res.Amount.Currency,
-				}
-				dailyCostUSD.With(labels).Set(float64(res.Amount.Value))
-				logrus.Debugf("Processed result - Date: %s, ProjectID: %s, ProjectName: %s, LineItem: %s, OrganizationID: %s, Cost: %f, Currency: %s",
-					date, projectId, e.ensureProjectName(projectId), lineName, res.OrganizationID, res.Amount.Value, res.Amount.Currency)
-			}
+		for i := range out.Data {
+			out.Data[i].Results = slices.DeleteFunc(out.Data[i].Results, func(r CostResult) bool { return !e.exportsResult(r.ProjectID, nil) })
 		}
+		buckets = append(buckets, out.Data...)
 
-		if !out.HasMore {
+		if !out.HasMore || !guard.next(out.NextPage) {
 			break
 		}
 		nextPage = out.NextPage
 	}
 
+	return buckets, nil
+}
+
+// collectWindow fetches all enabled usage endpoints and the cost data for one time window concurrently.
+// Individual failures are logged as they happen; the returned error reports whether any fetch failed.
+// Usage endpoints and costs that failed earlier windows fetch them again, see fetchStart.
+func (e *Exporter) collectWindow(startTime, endTime int64) error {
+	var wg sync.WaitGroup
+	var failed atomic.Bool
+	for _, ep := range currentEndpoints() {
+		if !fetchAllowed(e.orgID, ep.Name, time.Now()) {
+			continue
+		}
+		collectionPool.submitAfter(&wg, jitter(*scrapeJitter), func() {
+			from := fetchStart(e.orgID, ep.Name, startTime, time.Now())
+			err := e.fetchUsageData(ep, from, endTime)
+			recordFetch(e.orgID, ep.Name, err)
+			recordWindow(e.orgID, ep.Name, from, err)
+			if err != nil {
+				logrus.WithError(err).WithFields(windowFields(e.orgID, ep.Name, from, endTime)).Error("Error fetching usage data")
+				failed.Store(true)
+			}
+		})
+	}
+	if fetchAllowed(e.orgID, "costs", time.Now()) {
+		collectionPool.submitAfter(&wg, jitter(*scrapeJitter), func() {
+			from := fetchStart(e.orgID, "costs", startTime, time.Now())
+			err := e.fetchCostData(from, endTime+60*60*24)
+			recordFetch(e.orgID, "costs", err)
+			recordWindow(e.orgID, "costs", from, err)
+			if err != nil {
+				logrus.WithError(err).WithFields(windowFields(e.orgID, "costs", from, endTime)).Warn("Error fetching cost data")
+				failed.Store(true)
+			}
+		})
+	}
+	if *auditEnabled && fetchAllowed(e.orgID, "audit_logs", time.Now()) {
+		collectionPool.submit(&wg, func() {
+			err := e.collectAuditLogs(startTime, endTime)
+			recordFetch(e.orgID, "audit_logs", err)
+			if err != nil {
+				logrus.WithError(err).WithFields(windowFields(e.orgID, "audit_logs", startTime, endTime)).Warn("Error collecting audit logs")
+				failed.Store(true)
+			}
+		})
+	}
+	if *projectLifecycleEnabled && apiBudget.allowOptional(time.Now()) && fetchAllowed(e.orgID, "projects", time.Now()) {
+		collectionPool.submit(&wg, func() {
+			err := e.trackProjectLifecycle()
+			recordFetch(e.orgID, "projects", err)
+			if err != nil {
+				logrus.WithError(err).WithFields(logrus.Fields{"org_id": e.orgID, "endpoint": "projects"}).Warn("Error tracking project lifecycle")
+				failed.Store(true)
+			}
+		})
+	} else if e.projectNamesDue(time.Now()) && apiBudget.allowOptional(time.Now()) && fetchAllowed(e.orgID, "projects", time.Now()) {
+		// A failed refresh keeps the previous names and does not make the window incomplete.
+		collectionPool.submit(&wg, func() {
+			err := e.refreshProjectNames()
+			recordFetch(e.orgID, "projects", err)
+			if err != nil {
+				logrus.WithError(err).WithFields(logrus.Fields{"org_id": e.orgID, "endpoint": "projects"}).Warn("Error refreshing project names")
+			}
+		})
+	}
+	for _, c := range optionalCollectors {
+		if *c.enabled && c.due(e.orgID, time.Now()) && apiBudget.allowOptional(time.Now()) && fetchAllowed(e.orgID, c.name, time.Now()) {
+			collectionPool.submit(&wg, func() { c.run(e) })
+		}
+	}
+	if e.budgetsDue(time.Now()) && fetchAllowed(e.orgID, "budgets", time.Now()) {
+		collectionPool.submit(&wg, func() {
+			err := e.collectBudgets(time.Now())
+			recordFetch(e.orgID, "budgets", err)
+			if err != nil {
+				logrus.WithError(err).WithFields(logrus.Fields{"org_id": e.orgID, "endpoint": "budgets"}).Warn("Error reading month-to-date spend")
+			}
+		})
+	}
+	wg.Wait()
+
+	if failed.Load() {
+		return fmt.Errorf("collection of window %d-%d was incomplete", startTime, endTime)
+	}
 	return nil
 }
 
+// windowCollector gathers the usage data of one time window into the exporter metrics.
+type windowCollector interface {
+	collectWindow(startTime, endTime int64) error
+}
+
 // collect performs a loop to gather data for the last time window (one minute).
-// For each cycle, a time window is determined: from (current time - scrape.interval) to current time.
-func (e *Exporter) collect() {
-	stepSec := int64((*scrapeInterval) / time.Second)
+// For each cycle, a time window is determined: from the end of the previous window to one scrape.interval later.
+// Each cycle starts once its window has ended, so cycles stay aligned to minute boundaries however long
+// the previous one took. Windows missed while the exporter was down are collected back-to-back until it has caught up.
+// The loop returns once ctx is done, after finishing the cycle in progress.
+func collect(ctx context.Context, c windowCollector, g prometheus.Gatherer) {
+	// The jitter is drawn once, so every cycle of this process starts at the same offset after its window ends.
+	phase := jitter(*scrapeJitter)
+	if phase > 0 {
+		logrus.Infof("Starting collection cycles %s after the end of each window", phase)
+	}
 	for {
-		startTime := lastScrape
-		endTime := lastScrape + stepSec
+		interval := currentScrapeInterval()
+		if !sleepContext(ctx, nextCycleDelay(interval, phase, time.Now())) {
+			return
+		}
 
-		logrus.Infof("Starting collection cycle: startTime=%d, endTime=%d", startTime, endTime)
+		stateMu.RLock()
+		startTime := lastScrape
+		stateMu.RUnlock()
+		_ = runCycle(c, startTime, startTime+int64(interval/time.Second))
 
-		var wg sync.WaitGroup
-		for _, endpoint := range usageEndpoints {
-			wg.Add(1)
-			go func(ep UsageEndpoint) {
-				defer wg.Done()
-				if err := e.fetchUsageData(ep, startTime, endTime); err != nil {
-					logrus.WithError(err).Errorf("Error fetching data from %s", ep.Path)
-				}
-			}(endpoint)
-		}
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			if err := e.fetchCostData(startTime, endTime+60*60*24); err != nil {
-				logrus.WithError(err).Warn("Error fetching cost data")
+		if *textfileDirectory != "" {
+			if err := writeTextfile(*textfileDirectory, g); err != nil {
+				logrus.WithError(err).Error("Error writing textfile")
 			}
-		}()
-		wg.Wait()
-		lastScrape += stepSec
-		time.Sleep(*scrapeInterval)
+		}
 	}
 }
 
+// nextCycleDelay returns how long to wait before the next cycle: until phase after the end of the next window,
+// and not at all while collection is catching up on windows that are already over.
+func nextCycleDelay(interval, phase time.Duration, now time.Time) time.Duration {
+	stateMu.RLock()
+	next := time.Unix(lastScrape, 0).Add(interval)
+	stateMu.RUnlock()
+
+	if wait := next.Add(phase).Sub(now); wait > 0 {
+		return wait
+	}
+	if behind := now.Sub(next); behind >= interval {
+		logrus.Infof("Collection is %s behind, catching up", behind.Truncate(time.Second))
+	}
+	return 0
+}
+
 // Main Function
 
 func main() {
-	flag.Parse()
+	name, args := subcommand(os.Args[1:])
+	switch name {
+	case "serve":
+		runServe(args)
+	case "validate":
+		os.Exit(runValidate(args))
+	case "query":
+		os.Exit(runQuery(args, os.Stdout))
+	case "rules":
+		os.Exit(runRules(args, os.Stdout))
+	case "health":
+		os.Exit(runHealth(args, os.Stdout))
+	case "version":
+		fmt.Println(version.Print("openai-exporter"))
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+}
+
+// startup holds what configure reads besides the flags.
+type startup struct {
+	explicit      map[string]bool
+	organizations []Organization
+	remote        configSource
+	remoteIndex   uint64
+	prices        *pricesFile
+	labels        prometheus.Labels
+}
+
+// configure parses args, loads the configuration file and the remote configuration, and validates
+// the settings. It starts no background work, so it is shared by serve and validate.
+func configure(args []string) (*startup, error) {
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return nil, err
+	}
+	if *printVersion {
+		fmt.Println(version.Print("openai-exporter"))
+		os.Exit(0)
+	}
+	cfg := &startup{explicit: explicitFlags()}
+	baseConfig = flagConfig()
+	userFilter, projectFilter, modelFilter = baseConfig.Users, baseConfig.Projects, baseConfig.Models
+	if err := errors.Join(userFilter.validate("user"), projectFilter.validate("project"), modelFilter.validate("model")); err != nil {
+		return nil, err
+	}
+	var groupBy map[string][]string
+	if *configFile != "" {
+		fileCfg, err := loadConfigFile(*configFile, cfg.explicit)
+		if err != nil {
+			return nil, err
+		}
+		cfg.organizations = fileCfg.Organizations
+		groupBy = fileCfg.GroupBy
+		runtimeCfg := runtimeConfig(fileCfg, cfg.explicit)
+		if err := runtimeCfg.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", *configFile, err)
+		}
+		applyFileConfig(runtimeCfg)
+		reloadSuccessful.Set(1)
+		reloadSuccessTimestamp.SetToCurrentTime()
+	}
 	setupLogging()
 
+	if *scrapeJitter >= *scrapeInterval {
+		return nil, fmt.Errorf("scrape.jitter (%s) must be shorter than scrape.interval (%s)", *scrapeJitter, *scrapeInterval)
+	}
+	if *snapshotKeep < 1 {
+		return nil, fmt.Errorf("state.snapshot-keep must be at least 1, got %d", *snapshotKeep)
+	}
+
+	if err := validateRetention(); err != nil {
+		return nil, err
+	}
+	if err := validateWebConfig(); err != nil {
+		return nil, fmt.Errorf("invalid web configuration file: %w", err)
+	}
+
+	if err := validateWorkers(*scrapeWorkers); err != nil {
+		return nil, err
+	}
+	if err := validateTransport(); err != nil {
+		return nil, err
+	}
+	if err := configureHeaders(); err != nil {
+		return nil, err
+	}
+	if err := validateShard(); err != nil {
+		return nil, err
+	}
+	if err := validateBucketWidth(); err != nil {
+		return nil, err
+	}
+	if err := validateAnomaly(); err != nil {
+		return nil, err
+	}
+	if err := configureNotifications(); err != nil {
+		return nil, err
+	}
+	if err := configureTape(); err != nil {
+		return nil, err
+	}
+	if err := configureHistory(); err != nil {
+		return nil, err
+	}
+	if err := validateEMF(); err != nil {
+		return nil, err
+	}
+	if err := validateInflux(); err != nil {
+		return nil, err
+	}
+	labels, err := parseStaticLabels(*staticLabelsFlag)
+	if err != nil {
+		return nil, err
+	}
+	cfg.labels = labels
+
+	if *scrapeMode != "pull" && *scrapeMode != "loop" {
+		return nil, fmt.Errorf("unknown scrape.mode %q, use pull or loop", *scrapeMode)
+	}
+
+	if *remoteBackend != "" {
+		src, err := newConfigSource(*remoteBackend, *remoteAddress, *remoteKey)
+		if err != nil {
+			return nil, err
+		}
+		data, index, err := src.Fetch(0)
+		if err != nil {
+			logrus.WithError(err).Warn("Error fetching remote configuration, starting with flag values")
+		} else if remoteCfg, err := parseConfig(data); err != nil {
+			return nil, err
+		} else {
+			applyRemoteConfig(remoteCfg)
+		}
+		cfg.remote, cfg.remoteIndex = src, index
+	}
+
+	if *pricingFile != "" {
+		f, err := loadPricesFile(*pricingFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.prices = f
+	}
+
+	store, err := newDedupStore(*stateBackend)
+	if err != nil {
+		return nil, err
+	}
+	dedupBackend = store
+
+	apiBudget.configure(*apiHourlyBudget, *apiOptionalRatio)
+	if apiLimiter, err = newAPILimiter(*apiRateLimit, *apiBurst); err != nil {
+		return nil, err
+	}
+
+	if err := configurePrivacy(); err != nil {
+		return nil, err
+	}
+	if err := configureGrouping(privacyGroupBy(*usageGroupByFlag, groupBy)); err != nil {
+		return nil, err
+	}
+
+	updateConfigInfo()
+	return cfg, nil
+}
+
+// newCollector creates the collector of the configured provider.
+func newCollector(organizations []Organization) (windowCollector, error) {
+	switch *providerName {
+	case "openai":
+		if *mockMode {
+			return newMockExporter()
+		}
+		if len(organizations) > 0 {
+			own, err := shardOrganizations(organizations)
+			if err != nil {
+				return nil, err
+			}
+			return newOrgExporters(own)
+		}
+		if *shardTotal > 1 && *shardBy == "organization" {
+			return nil, fmt.Errorf("sharding by organization needs the organizations list of the configuration file, use -shard.by=project to split a single organization")
+		}
+		return NewExporter()
+	case "litellm":
+		return NewLiteLLMExporter()
+	case "azure":
+		return NewAzureExporter()
+	default:
+		return nil, fmt.Errorf("unknown provider %q", *providerName)
+	}
+}
+
+// runServe runs the exporter until it receives SIGTERM or SIGINT.
+func runServe(args []string) {
+	cfg, err := configure(args)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	logrus.Infof("Starting openai-exporter %s, build context %s", version.Info(), version.BuildContext())
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *configFile != "" {
+		go watchReloadSignals(*configFile, cfg.explicit)
+	}
+	if cfg.remote != nil {
+		go watchRemoteConfig(cfg.remote, cfg.remoteIndex)
+	}
+	if cfg.prices != nil {
+		go cfg.prices.watch(ctx, *pricingPollInterval)
+	}
+
 	lastScrape = time.Now().Round(time.Minute).Add(-*scrapeInterval).Unix()
-	exporter, err := NewExporter()
+	if *stateRestore != "" {
+		if err := restoreSnapshot(*stateRestore); err != nil {
+			logrus.Fatal(err)
+		}
+	} else if *stateFile != "" {
+		if err := loadStateFile(*stateFile); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+
+	collector, err := newCollector(cfg.organizations)
 	if err != nil {
 		logrus.Fatal(err)
 	}
+	if err := checkSystemdStartup(collector); err != nil {
+		logrus.Fatal(err)
+	}
+
+	if *backfillDuration > 0 || *backfillToday {
+		runBackfill(collector, *backfillDuration)
+	}
+
+	registry := prometheus.NewRegistry()
+	// Every series, including the runtime metrics, carries the static labels.
+	registerer := prometheus.WrapRegistererWith(cfg.labels, registry)
+	registerer.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	if err := RegisterMetrics(registerer); err != nil {
+		logrus.Fatal(err)
+	}
 
-	go exporter.collect()
+	if *reconcileEnabled {
+		switch c := collector.(type) {
+		case *Exporter:
+			go c.reconcileLoop(ctx)
+		case orgExporters:
+			for _, e := range c {
+				go e.reconcileLoop(ctx)
+			}
+		}
+	}
 
-	http.Handle(*metricsPath, promhttp.Handler())
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	watchKeyFiles(ctx, collector)
+
+	var grpcServer *grpc.Server
+	if *grpcListenAddress != "" {
+		if grpcServer, err = serveGRPC(*grpcListenAddress); err != nil {
+			logrus.Fatal(err)
+		}
+		logrus.Infof("Serving gRPC usage queries on %s", *grpcListenAddress)
+	}
+
+	if replayTape != nil {
+		if err := runReplay(collector, registry, os.Stdout); err != nil {
+			logrus.Fatal(err)
+		}
+		return
+	}
+
+	if *runOnceFlag || *pushgatewayURL != "" {
+		if err := runOnce(collector, registry, os.Stdout); err != nil {
+			logrus.Fatal(err)
+		}
+		return
+	}
+
+	if *textfileDirectory != "" {
+		logrus.Infof("Writing metrics to %s every %s", *textfileDirectory, currentScrapeInterval())
+		stopOTLP := runOTLP(registry)
+		stopEMF := runEMF(registry)
+		stopInflux := runInflux(registry)
+		collect(ctx, collector, registry)
+		flushState()
+		stopOTLP()
+		stopEMF()
+		stopInflux()
+		return
+	}
+
+	var gatherer prometheus.Gatherer = registry
+	var collecting chan struct{}
+	refresh := func(time.Time) {}
+	if *scrapeMode == "pull" {
+		pull := &pullGatherer{source: collector, gatherer: registry}
+		gatherer, refresh = pull, pull.refresh
+	} else {
+		collecting = make(chan struct{})
+		go func() {
+			defer close(collecting)
+			collect(ctx, collector, registry)
+		}()
+	}
+	stopOTLP := runOTLP(gatherer)
+	stopEMF := runEMF(gatherer)
+	stopInflux := runInflux(gatherer)
+
+	// A mux of its own, so handlers that packages register on the default mux are not exposed.
+	mux := http.NewServeMux()
+	mux.Handle(*metricsPath, promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{Registry: registry}))
+	mux.HandleFunc("/-/healthy", healthyHandler)
+	mux.HandleFunc("/-/ready", readyHandler)
+	mux.HandleFunc("/-/snapshot", snapshotHandler(os.Getenv("OPENAI_EXPORTER_RELOAD_TOKEN")))
+	mux.HandleFunc("/-/reload", reloadHandler(*configFile, os.Getenv("OPENAI_EXPORTER_RELOAD_TOKEN"), cfg.explicit))
+	mux.HandleFunc("/api/v1/snapshot", usageSnapshotHandler(refresh))
+	registerDebugHandlers(mux)
+	if history != nil {
+		mux.HandleFunc("/api/v1/usage", usageHistoryHandler(history))
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		_, err := w.Write([]byte("<html><head><title>OpenAI Exporter</title></head><body><h1>OpenAI Exporter</h1><p><a href='" + *metricsPath + "'>Metrics</a></p></body></html>"))
 		if err != nil {
 			logrus.WithError(err).Error("Failed to write response")
 		}
 	})
 
-	logrus.Infof("Starting server on %s", *listenAddress)
-	if err := http.ListenAndServe(*listenAddress, nil); err != nil {
-		logrus.Fatal(err)
+	server := &http.Server{Addr: *listenAddress, Handler: mux}
+	go serve(server)
+	if *scrapeMode == "pull" {
+		// Cycles only run when Prometheus scrapes, which may be long after startup.
+		setReady()
 	}
+	<-ctx.Done()
+	notifySystemdStopping()
+	shutdown(server, grpcServer, collecting)
+	stopOTLP()
+	stopEMF()
+	stopInflux()
 }
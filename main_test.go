@@ -1,14 +1,20 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -259,11 +265,14 @@ func TestUpdateMetric(t *testing.T) {
 	lastScrape = 0
 
 	labels := prometheus.Labels{
+		"org_id":       "org-1",
+		"org_name":     "prod",
 		"model":        "gpt-4",
 		"operation":    "completions",
 		"project_id":   "proj-123",
 		"project_name": "test-project",
 		"user_id":      "user-456",
+		"user_email":   "",
 		"api_key_id":   "key-789",
 		"api_key_name": "key-name",
 		"batch":        "false",
@@ -293,6 +302,47 @@ func TestUpdateMetric(t *testing.T) {
 		updateMetric(labels, "input", bucketStart, bucketEnd, 200.0)
 		assert.Len(t, usageState, initialLen)
 	})
+
+	t.Run("counts the growth of a processed bucket", func(t *testing.T) {
+		usageState = make(map[string]float64)
+		tokensTotal.Reset()
+		series := tokensTotal.With(mergeLabels(mergeLabels(labels, "user_email", ""), "token_type", "input"))
+
+		updateMetric(labels, "input", bucketStart, bucketEnd, 100.0)
+		updateMetric(labels, "input", bucketStart, bucketEnd, 130.0)
+		assert.Equal(t, 130.0, testutil.ToFloat64(series))
+
+		updateMetric(labels, "input", bucketStart, bucketEnd, 120.0)
+		assert.Equal(t, 130.0, testutil.ToFloat64(series), "revisions downwards are ignored")
+		assert.Equal(t, 130.0, usageState["completions|"+strconv.FormatInt(bucketStart, 10)+"|proj-123|user-456|key-789|gpt-4|false|input|org-1"])
+	})
+
+	t.Run("same bucket of another organization is counted", func(t *testing.T) {
+		usageState = make(map[string]float64)
+		updateMetric(labels, "input", bucketStart, bucketEnd, 100.0)
+		updateMetric(mergeLabels(mergeLabels(labels, "org_id", "org-2"), "org_name", "staging"), "input", bucketStart, bucketEnd, 100.0)
+		assert.Len(t, usageState, 2)
+	})
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	require.NoError(t, RegisterMetrics(reg))
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	var buildInfo []string
+	for _, mf := range families {
+		if mf.GetName() == "openai_exporter_build_info" {
+			for _, l := range mf.GetMetric()[0].GetLabel() {
+				buildInfo = append(buildInfo, l.GetName())
+			}
+		}
+	}
+	assert.Subset(t, buildInfo, []string{"version", "revision", "goversion"})
+
+	assert.Error(t, RegisterMetrics(reg), "registering twice must fail")
+	assert.NoError(t, RegisterMetrics(prometheus.NewRegistry()), "each registry is independent")
 }
 
 func TestNewExporter(t *testing.T) {
@@ -437,6 +487,33 @@ func TestEnsureAPIKeyName(t *testing.T) {
 		assert.Equal(t, "fetched-key", k.Name)
 	})
 
+	t.Run("falls back to admin keys", func(t *testing.T) {
+		apiKeyNames = make(map[string]string)
+
+		var paths []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			paths = append(paths, r.URL.Path)
+			switch r.URL.Path {
+			case "/v1/organization/admin_api_keys/key_admin":
+				_ = json.NewEncoder(w).Encode(APIKey{Name: "terraform"})
+			case "/v1/organization/projects/proj-1/api_keys/key_unnamed":
+				_ = json.NewEncoder(w).Encode(APIKey{RedactedValue: "sk-proj-abc...xyz"})
+			default:
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"error": {"message": "not found"}}`))
+			}
+		}))
+		defer server.Close()
+
+		e := &Exporter{client: server.Client(), apiKey: "test", targets: newAPITargets("", server.URL, 3)}
+		assert.Equal(t, "terraform", e.ensureAPIKeyName("proj-1", "key_admin"))
+		assert.Equal(t, []string{"/v1/organization/projects/proj-1/api_keys/key_admin", "/v1/organization/admin_api_keys/key_admin"}, paths)
+		assert.Equal(t, "terraform", apiKeyNames["key_admin"])
+
+		assert.Equal(t, "sk-proj-abc...xyz", e.ensureAPIKeyName("proj-1", "key_unnamed"), "unnamed keys are shown by their redacted value")
+		assert.Equal(t, "unknown", e.ensureAPIKeyName("proj-1", "key_deleted"))
+	})
+
 	t.Run("API error returns unknown", func(t *testing.T) {
 		apiKeyNames = make(map[string]string)
 
@@ -450,6 +527,18 @@ func TestEnsureAPIKeyName(t *testing.T) {
 	})
 }
 
+func TestUsageResult_TokenCounts(t *testing.T) {
+	var result UsageResult
+	require.NoError(t, json.Unmarshal([]byte(`{"input_tokens": 120, "output_tokens": 900, "input_cached_tokens": 20,
+		"output_tokens_details": {"reasoning_tokens": 640}, "model": "o3-2025-04-16"}`), &result))
+
+	counts := make(map[string]int64)
+	for _, tc := range result.tokenCounts() {
+		counts[tc.Type] = tc.Value
+	}
+	assert.Equal(t, map[string]int64{"input": 120, "output": 900, "input_cached": 20, "input_audio": 0, "output_audio": 0, "reasoning": 640}, counts)
+}
+
 func TestFetchUsageData_ErrorCases(t *testing.T) {
 	t.Run("HTTP request error", func(t *testing.T) {
 		e := &Exporter{
@@ -522,6 +611,117 @@ func TestFetchCostData_ErrorCases(t *testing.T) {
 	})
 }
 
+func TestFetchCostData(t *testing.T) {
+	costState = make(map[string]float64)
+	projectNames = map[string]string{"proj-1": "Project One"}
+	dailyCostUSD.Reset()
+	costsTotal.Reset()
+
+	amount := "1.25"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/organization/costs", r.URL.Path)
+		assert.Equal(t, "project_id,line_item", r.URL.Query().Get("group_by"))
+		_, _ = w.Write([]byte(`{"object":"page","has_more":false,"data":[{"object":"bucket","start_time":86400,"end_time":172800,"results":[
+			{"object":"organization.costs.result","amount":{"value":` + amount + `,"currency":"usd"},"line_item":"gpt-4o, input","project_id":"proj-1","organization_id":"org-1"}
+		]}]}`))
+	}))
+	defer server.Close()
+
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("", server.URL, 3)}
+	counter := costsTotal.WithLabelValues("org-1", "prod", "proj-1", "Project One", "gpt-4o, input")
+
+	require.NoError(t, e.fetchCostData(86400, 172800))
+	assert.Equal(t, 1.25, testutil.ToFloat64(dailyCostUSD.WithLabelValues("1970-01-02", "proj-1", "Project One", "gpt-4o, input", "org-1", "prod", "usd")))
+	assert.Equal(t, 1.25, testutil.ToFloat64(counter))
+
+	t.Run("same amount is not counted twice", func(t *testing.T) {
+		require.NoError(t, e.fetchCostData(86400, 172800))
+		assert.Equal(t, 1.25, testutil.ToFloat64(counter))
+	})
+
+	t.Run("growing bucket adds the difference", func(t *testing.T) {
+		amount = "2"
+		require.NoError(t, e.fetchCostData(86400, 172800))
+		assert.Equal(t, 2.0, testutil.ToFloat64(counter))
+	})
+}
+
+func TestUpdateCost(t *testing.T) {
+	labels := func(date, currency string) prometheus.Labels {
+		return prometheus.Labels{
+			"date": date, "project_id": "proj-1", "project_name": "one",
+			"line_item": "gpt-4o", "organization_id": "org-1", "org_name": "prod", "currency": currency,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		updates []prometheus.Labels
+		amounts []float64
+		want    float64
+	}{
+		{
+			name:    "first amount is counted",
+			updates: []prometheus.Labels{labels("2024-01-01", "usd")},
+			amounts: []float64{3},
+			want:    3,
+		},
+		{
+			name:    "revisions downwards are ignored",
+			updates: []prometheus.Labels{labels("2024-01-01", "usd"), labels("2024-01-01", "usd"), labels("2024-01-01", "usd")},
+			amounts: []float64{3, 2, 4},
+			want:    4,
+		},
+		{
+			name:    "days accumulate",
+			updates: []prometheus.Labels{labels("2024-01-01", "usd"), labels("2024-01-02", "usd")},
+			amounts: []float64{3, 1.5},
+			want:    4.5,
+		},
+		{
+			name:    "other currencies are skipped",
+			updates: []prometheus.Labels{labels("2024-01-01", "eur")},
+			amounts: []float64{3},
+			want:    0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			costState = make(map[string]float64)
+			costsTotal.Reset()
+			for i, l := range tt.updates {
+				updateCost(l, tt.amounts[i])
+			}
+			assert.Equal(t, tt.want, testutil.ToFloat64(costsTotal.WithLabelValues("org-1", "prod", "proj-1", "one", "gpt-4o")))
+		})
+	}
+}
+
 func strPtr(s string) *string {
 	return &s
 }
+
+func TestSetupLogging_JSON(t *testing.T) {
+	origFormat, origLevel, origSlog := *logFormat, *logLevel, slog.Default()
+	defer func() {
+		*logFormat, *logLevel = origFormat, origLevel
+		slog.SetDefault(origSlog)
+		logrus.SetOutput(os.Stderr)
+		setupLogging()
+	}()
+	*logFormat, *logLevel = "json", "info"
+	setupLogging()
+
+	var buf bytes.Buffer
+	logrus.SetOutput(&buf)
+	logrus.WithFields(windowFields("org-1", "completions", 1000, 1060)).Info("Fetched usage records")
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "Fetched usage records", entry["msg"])
+	assert.Equal(t, "org-1", entry["org_id"])
+	assert.Equal(t, "completions", entry["endpoint"])
+	assert.Equal(t, 1000.0, entry["window_start"])
+	assert.Equal(t, 1060.0, entry["window_end"])
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Organization Members
+
+var (
+	membersEnabled  = flag.Bool("collector.members", false, "Export the number of organization users by role and of invites by role and status")
+	membersInterval = flag.Duration("collector.members.interval", 15*time.Minute, "Interval at which the users and invites are re-read")
+
+	organizationUsers = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_organization_users",
+			Help: "Number of users of the organization by role.",
+		},
+		[]string{"org_id", "org_name", "role"},
+	)
+	organizationInvites = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_organization_invites",
+			Help: "Number of invites to the organization by role and status (pending, accepted or expired).",
+		},
+		[]string{"org_id", "org_name", "role", "status"},
+	)
+	oldestPendingInvite = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_organization_oldest_pending_invite_timestamp_seconds",
+			Help: "Unix time at which the oldest pending invite of the organization was sent; missing without pending invites.",
+		},
+		[]string{"org_id", "org_name"},
+	)
+)
+
+type OrganizationUserList struct {
+	Object  string             `json:"object"`
+	Data    []OrganizationUser `json:"data"`
+	HasMore bool               `json:"has_more"`
+	LastID  string             `json:"last_id"`
+}
+
+type InviteList struct {
+	Object  string   `json:"object"`
+	Data    []Invite `json:"data"`
+	HasMore bool     `json:"has_more"`
+	LastID  string   `json:"last_id"`
+}
+
+type Invite struct {
+	ID        string `json:"id"`
+	Role      string `json:"role"`
+	Status    string `json:"status"`
+	InvitedAt int64  `json:"invited_at"`
+}
+
+// listPages pages through the organization list endpoint at path (e.g. "users"), decoding every page with decode,
+// which returns the ID of the last object on it and whether more pages follow. Pages are counted for endpoint.
+func (e *Exporter) listPages(path, endpoint string, decode func(*json.Decoder) (string, bool, error)) error {
+	after := ""
+	guard := newPageGuard(e.orgID, endpoint)
+	for {
+		u := fmt.Sprintf("/v1/organization/%s?limit=100", path)
+		if after != "" {
+			u += "&after=" + url.QueryEscape(after)
+		}
+
+		resp, err := e.get(u)
+		if err != nil {
+			return fmt.Errorf("error fetching %s: %w", path, err)
+		}
+
+		lastID, hasMore, decodeErr := decode(json.NewDecoder(resp.Body))
+		closeErr := resp.Body.Close()
+
+		if decodeErr != nil {
+			return fmt.Errorf("error decoding response: %w", decodeErr)
+		}
+		if closeErr != nil {
+			logrus.WithError(closeErr).Warn("failed to close response body")
+		}
+
+		pagesFetchedTotal.WithLabelValues(e.orgID, endpoint).Inc()
+
+		if !hasMore || lastID == "" || !guard.next(lastID) {
+			return nil
+		}
+		after = lastID
+	}
+}
+
+// collectMembers counts the users and invites of the organization. The emails of the listed users
+// refresh the user email cache.
+func (e *Exporter) collectMembers() error {
+	var users []OrganizationUser
+	err := e.listPages("users", "users", func(d *json.Decoder) (string, bool, error) {
+		var out OrganizationUserList
+		err := d.Decode(&out)
+		users = append(users, out.Data...)
+		return out.LastID, out.HasMore, err
+	})
+	if err != nil {
+		return err
+	}
+	var invites []Invite
+	err = e.listPages("invites", "invites", func(d *json.Decoder) (string, bool, error) {
+		var out InviteList
+		err := d.Decode(&out)
+		invites = append(invites, out.Data...)
+		return out.LastID, out.HasMore, err
+	})
+	if err != nil {
+		return err
+	}
+
+	roles := make(map[string]int)
+	for _, u := range users {
+		roles[u.Role]++
+	}
+	type inviteKey struct{ role, status string }
+	statuses := make(map[inviteKey]int)
+	var oldest int64
+	for _, inv := range invites {
+		statuses[inviteKey{inv.Role, inv.Status}]++
+		if inv.Status == "pending" && (oldest == 0 || inv.InvitedAt < oldest) {
+			oldest = inv.InvitedAt
+		}
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	if *resolveUsers {
+		for _, u := range users {
+			if u.Email != "" {
+				userEmails[u.ID] = u.Email
+			} else if u.Name != "" {
+				userEmails[u.ID] = u.Name
+			}
+			delete(userLookupFailures, u.ID)
+		}
+	}
+	organizationUsers.DeletePartialMatch(prometheus.Labels{"org_id": e.orgID})
+	for role, count := range roles {
+		organizationUsers.WithLabelValues(e.orgID, e.orgName, role).Set(float64(count))
+	}
+	organizationInvites.DeletePartialMatch(prometheus.Labels{"org_id": e.orgID})
+	for key, count := range statuses {
+		organizationInvites.WithLabelValues(e.orgID, e.orgName, key.role, key.status).Set(float64(count))
+	}
+	if oldest > 0 {
+		oldestPendingInvite.WithLabelValues(e.orgID, e.orgName).Set(float64(oldest))
+	} else {
+		oldestPendingInvite.DeleteLabelValues(e.orgID, e.orgName)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectMembers(t *testing.T) {
+	setResolveUsers(t)
+	userEmails = make(map[string]string)
+	organizationUsers.Reset()
+	organizationInvites.Reset()
+	oldestPendingInvite.Reset()
+
+	invites := `[
+		{"id": "invite-1", "role": "reader", "status": "pending", "invited_at": 1700000000},
+		{"id": "invite-2", "role": "reader", "status": "pending", "invited_at": 1690000000},
+		{"id": "invite-3", "role": "owner", "status": "accepted", "invited_at": 1680000000}
+	]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path + "?" + r.URL.Query().Get("after") {
+		case "/v1/organization/users?":
+			_, _ = w.Write([]byte(`{"object": "list", "data": [
+				{"id": "user-1", "email": "a@example.com", "role": "owner"},
+				{"id": "user-2", "email": "b@example.com", "role": "reader"}
+			], "has_more": true, "last_id": "user-2"}`))
+		case "/v1/organization/users?user-2":
+			_, _ = w.Write([]byte(`{"object": "list", "data": [{"id": "user-3", "name": "CI bot", "role": "reader"}], "has_more": false, "last_id": "user-3"}`))
+		case "/v1/organization/invites?":
+			_, _ = w.Write([]byte(`{"object": "list", "data": ` + invites + `, "has_more": false}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+
+	require.NoError(t, e.collectMembers())
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(organizationUsers.WithLabelValues("org-1", "prod", "owner")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(organizationUsers.WithLabelValues("org-1", "prod", "reader")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(organizationInvites.WithLabelValues("org-1", "prod", "reader", "pending")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(organizationInvites.WithLabelValues("org-1", "prod", "owner", "accepted")))
+	assert.Equal(t, 1690000000.0, testutil.ToFloat64(oldestPendingInvite.WithLabelValues("org-1", "prod")))
+	assert.Equal(t, map[string]string{"user-1": "a@example.com", "user-2": "b@example.com", "user-3": "CI bot"}, userEmails)
+
+	invites = `[{"id": "invite-3", "role": "owner", "status": "accepted", "invited_at": 1680000000}]`
+	require.NoError(t, e.collectMembers())
+	assert.Equal(t, 0, testutil.CollectAndCount(oldestPendingInvite), "without pending invites there is no oldest one")
+	assert.Equal(t, 1, testutil.CollectAndCount(organizationInvites))
+}
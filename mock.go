@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Demo Mode
+
+var mockMode = flag.Bool("mock", false, "Collect synthetic usage and costs of a demo organization from a built-in fake API instead of OpenAI, so no API key is needed")
+
+const (
+	mockOrgID   = "org-demo"
+	mockOrgName = "demo"
+)
+
+// mockProjects maps the IDs of the demo projects to their names.
+var mockProjects = map[string]string{
+	"proj_demo_search":   "search",
+	"proj_demo_support":  "support-bot",
+	"proj_demo_research": "research",
+}
+
+// mockUsers maps the IDs of the demo users to their emails.
+var mockUsers = map[string]string{
+	"user_demo_alice": "alice@example.com",
+	"user_demo_bob":   "bob@example.com",
+	"user_demo_ci":    "ci@example.com",
+}
+
+// mockSeries is a stream of synthetic usage of one endpoint by a project, user, API key and model.
+type mockSeries struct {
+	endpoint string
+	project  string
+	user     string
+	apiKey   string
+	model    string
+	// rate is the average number of input tokens per minute, output is the ratio of output to input tokens
+	// and cached the share of input tokens served from the prompt cache.
+	rate   float64
+	output float64
+	cached float64
+}
+
+var mockData = []mockSeries{
+	{endpoint: "completions", project: "proj_demo_search", user: "user_demo_alice", apiKey: "key_demo_search", model: "gpt-4o-mini-2024-07-18", rate: 12000, output: 0.25, cached: 0.4},
+	{endpoint: "completions", project: "proj_demo_search", user: "user_demo_ci", apiKey: "key_demo_search_ci", model: "gpt-4.1-nano", rate: 3000, output: 0.1, cached: 0.1},
+	{endpoint: "completions", project: "proj_demo_support", user: "user_demo_bob", apiKey: "key_demo_support", model: "gpt-4o-2024-08-06", rate: 6000, output: 0.35, cached: 0.55},
+	{endpoint: "completions", project: "proj_demo_support", user: "user_demo_alice", apiKey: "key_demo_support", model: "gpt-4o-mini-2024-07-18", rate: 8000, output: 0.3, cached: 0.2},
+	{endpoint: "completions", project: "proj_demo_research", user: "user_demo_bob", apiKey: "key_demo_research", model: "o3-mini", rate: 1500, output: 1.2, cached: 0.05},
+	{endpoint: "embeddings", project: "proj_demo_search", user: "user_demo_ci", apiKey: "key_demo_search_ci", model: "text-embedding-3-small", rate: 40000},
+	{endpoint: "embeddings", project: "proj_demo_research", user: "user_demo_bob", apiKey: "key_demo_research", model: "text-embedding-3-large", rate: 5000},
+}
+
+// mockAPI serves the parts of the OpenAI Administration API the exporter reads, with usage generated
+// deterministically from the minute and series, so buckets read again report the same values.
+type mockAPI struct {
+	mux *http.ServeMux
+	now func() time.Time
+}
+
+func newMockAPI(now func() time.Time) *mockAPI {
+	m := &mockAPI{mux: http.NewServeMux(), now: now}
+	m.mux.HandleFunc("GET /v1/organization/usage/{endpoint}", m.usage)
+	m.mux.HandleFunc("GET /v1/organization/costs", m.costs)
+	m.mux.HandleFunc("GET /v1/organization/projects", m.projects)
+	m.mux.HandleFunc("GET /v1/organization/projects/{id}", m.project)
+	m.mux.HandleFunc("GET /v1/organization/projects/{project}/api_keys/{id}", m.apiKey)
+	m.mux.HandleFunc("GET /v1/organization/users/{id}", m.user)
+	return m
+}
+
+func (m *mockAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mux.ServeHTTP(w, r)
+}
+
+// startMockAPI serves the mock API on a random loopback port and returns its base URL.
+func startMockAPI() (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("error starting mock API: %w", err)
+	}
+	go func() {
+		if err := http.Serve(ln, newMockAPI(time.Now)); err != nil {
+			logrus.WithError(err).Error("Mock API stopped")
+		}
+	}()
+	return "http://" + ln.Addr().String(), nil
+}
+
+// newMockExporter returns an exporter of the demo organization that reads from the mock API.
+func newMockExporter() (*Exporter, error) {
+	baseURL, err := startMockAPI()
+	if err != nil {
+		return nil, err
+	}
+	logrus.Warnf("Running in mock mode: serving synthetic usage of organization %s from %s", mockOrgID, baseURL)
+	return newExporter(Organization{ID: mockOrgID, Name: mockOrgName, BaseURL: baseURL}, "mock", "mock", nil)
+}
+
+// mockTokens returns the input, cached input, output tokens and requests of s in the minute starting at t.
+// Usage follows a daily cycle peaking in the afternoon (UTC), with per-minute noise.
+func (s mockSeries) mockTokens(t int64) (input, cached, output, requests int64) {
+	hour := float64(t%86400) / 3600
+	daily := 1 + 0.6*math.Sin((hour-9)/24*2*math.Pi)
+
+	h := fnv.New64a()
+	_, _ = fmt.Fprintf(h, "%s|%s|%s|%s|%d", s.endpoint, s.project, s.apiKey, s.model, t)
+	noise := 0.5 + float64(h.Sum64()%1000)/1000
+
+	input = int64(s.rate * daily * noise)
+	return input, int64(float64(input) * s.cached), int64(float64(input) * s.output), max(1, input/800)
+}
+
+// mockWindow parses the start_time, end_time (default: now) and bucket_width query parameters.
+func mockWindow(r *http.Request, now int64) (start, end, width int64, err error) {
+	q := r.URL.Query()
+	if start, err = strconv.ParseInt(q.Get("start_time"), 10, 64); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid start_time: %w", err)
+	}
+	end = now
+	if q.Get("end_time") != "" {
+		if end, err = strconv.ParseInt(q.Get("end_time"), 10, 64); err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid end_time: %w", err)
+		}
+	}
+	switch q.Get("bucket_width") {
+	case "1m":
+		width = 60
+	case "1h":
+		width = 3600
+	case "", "1d":
+		width = 86400
+	default:
+		return 0, 0, 0, fmt.Errorf("invalid bucket_width %q", q.Get("bucket_width"))
+	}
+	return start - start%width, end, width, nil
+}
+
+// usage returns the token usage of an endpoint, summed per bucket and grouped by the requested dimensions.
+func (m *mockAPI) usage(w http.ResponseWriter, r *http.Request) {
+	now := m.now().Unix()
+	start, end, width, err := mockWindow(r, now)
+	if err != nil {
+		mockError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	groupBy := strings.Split(r.URL.Query().Get("group_by"), ",")
+	grouped := func(dim, value string) *string {
+		if slices.Contains(groupBy, dim) {
+			return &value
+		}
+		return nil
+	}
+
+	out := APIResponse{Object: "page", Data: []Bucket{}}
+	for bucketStart := start; bucketStart < end; bucketStart += width {
+		bucket := Bucket{Object: "bucket", StartTime: bucketStart, EndTime: bucketStart + width, Results: []UsageResult{}}
+		results := make(map[string]*UsageResult)
+		var keys []string
+		for _, s := range mockData {
+			if s.endpoint != r.PathValue("endpoint") {
+				continue
+			}
+			res := UsageResult{
+				Object:      "organization.usage." + s.endpoint + ".result",
+				ProjectID:   grouped("project_id", s.project),
+				UserID:      grouped("user_id", s.user),
+				APIKeyID:    grouped("api_key_id", s.apiKey),
+				Model:       grouped("model", s.model),
+				Batch:       "false",
+				ServiceTier: grouped("service_tier", "default"),
+			}
+			key := fmt.Sprintf("%s|%s|%s|%s", deref(res.ProjectID), deref(res.UserID), deref(res.APIKeyID), deref(res.Model))
+			if existing, ok := results[key]; ok {
+				res = *existing
+			} else {
+				keys = append(keys, key)
+			}
+			for t := bucketStart; t < bucket.EndTime && t < now; t += 60 {
+				input, cached, output, requests := s.mockTokens(t)
+				res.InputTokens += input
+				res.InputCachedTokens += cached
+				res.OutputTokens += output
+				res.NumModelRequests += requests
+			}
+			results[key] = &res
+		}
+		for _, key := range keys {
+			bucket.Results = append(bucket.Results, *results[key])
+		}
+		out.Data = append(out.Data, bucket)
+	}
+	mockJSON(w, out)
+}
+
+// costs returns the daily cost of the mock usage at the built-in list prices, per project and line item.
+func (m *mockAPI) costs(w http.ResponseWriter, r *http.Request) {
+	now := m.now().Unix()
+	start, end, _, err := mockWindow(r, now)
+	if err != nil {
+		mockError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	start -= start % 86400
+	groupBy := strings.Split(r.URL.Query().Get("group_by"), ",")
+
+	out := CostsList{Object: "page", Data: []CostBucket{}}
+	for day := start; day < end; day += 86400 {
+		bucket := CostBucket{Object: "bucket", StartTime: day, EndTime: day + 86400, Results: []CostResult{}}
+		amounts := make(map[[2]string]float64)
+		var keys [][2]string
+		for _, s := range mockData {
+			price, _ := priceOf(defaultPrices, s.model)
+			tokens := make(map[string]float64)
+			for t := day; t < bucket.EndTime && t < now; t += 60 {
+				input, cached, output, _ := s.mockTokens(t)
+				tokens["input"] += float64(input)
+				tokens["input_cached"] += float64(cached)
+				tokens["output"] += float64(output)
+			}
+			var key [2]string
+			if slices.Contains(groupBy, "project_id") {
+				key[0] = s.project
+			}
+			for _, item := range []struct {
+				name string
+				cost float64
+			}{
+				{name: s.model + ", input", cost: price.cost(map[string]float64{"input": tokens["input"], "input_cached": tokens["input_cached"]})},
+				{name: s.model + ", output", cost: price.cost(map[string]float64{"output": tokens["output"]})},
+			} {
+				if slices.Contains(groupBy, "line_item") {
+					key[1] = item.name
+				}
+				if _, ok := amounts[key]; !ok {
+					keys = append(keys, key)
+				}
+				amounts[key] += item.cost
+			}
+		}
+		for _, key := range keys {
+			res := CostResult{Object: "organization.costs.result", OrganizationID: mockOrgID, Amount: Money{Value: FloatOrString(amounts[key]), Currency: "usd"}}
+			if key[0] != "" {
+				res.ProjectID = &key[0]
+			}
+			if key[1] != "" {
+				res.LineItem = &key[1]
+			}
+			bucket.Results = append(bucket.Results, res)
+		}
+		out.Data = append(out.Data, bucket)
+	}
+	mockJSON(w, out)
+}
+
+func (m *mockAPI) projects(w http.ResponseWriter, r *http.Request) {
+	out := ProjectList{Object: "list", Data: []ProjectInfo{}}
+	for _, id := range sortedKeys(mockProjects) {
+		out.Data = append(out.Data, ProjectInfo{ID: id, Name: mockProjects[id], Status: "active"})
+	}
+	mockJSON(w, out)
+}
+
+func (m *mockAPI) project(w http.ResponseWriter, r *http.Request) {
+	name, ok := mockProjects[r.PathValue("id")]
+	if !ok {
+		mockError(w, http.StatusNotFound, "No such project: "+r.PathValue("id"))
+		return
+	}
+	mockJSON(w, ProjectInfo{ID: r.PathValue("id"), Name: name, Status: "active"})
+}
+
+func (m *mockAPI) apiKey(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := mockProjects[r.PathValue("project")]; !ok || !strings.HasPrefix(id, "key_demo_") {
+		mockError(w, http.StatusNotFound, "No such API key: "+id)
+		return
+	}
+	mockJSON(w, APIKey{ID: id, Name: strings.ReplaceAll(strings.TrimPrefix(id, "key_demo_"), "_", "-") + "-key"})
+}
+
+func (m *mockAPI) user(w http.ResponseWriter, r *http.Request) {
+	email, ok := mockUsers[r.PathValue("id")]
+	if !ok {
+		mockError(w, http.StatusNotFound, "No such user: "+r.PathValue("id"))
+		return
+	}
+	mockJSON(w, OrganizationUser{ID: r.PathValue("id"), Name: strings.Split(email, "@")[0], Email: email, Role: "reader"})
+}
+
+func mockJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// mockError writes an error in the format of the OpenAI API.
+func mockError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"message": message, "type": "invalid_request_error"}})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockAPI_Usage(t *testing.T) {
+	setResolveUsers(t)
+	usageState = make(map[string]float64)
+	projectNames = make(map[string]string)
+	apiKeyNames = make(map[string]string)
+	userEmails = make(map[string]string)
+	tokensTotal.Reset()
+
+	now := time.Date(2024, 6, 12, 15, 30, 0, 0, time.UTC)
+	server := httptest.NewServer(newMockAPI(func() time.Time { return now }))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "mock", orgID: mockOrgID, orgName: mockOrgName, targets: newAPITargets(mockOrgID, server.URL, 3)}
+
+	end := now.Unix()
+	endpoint := UsageEndpoint{Path: "completions", Name: "completions"}
+	require.NoError(t, e.fetchUsageData(endpoint, end-300, end))
+	input := testutil.ToFloat64(tokensTotal.WithLabelValues(mockOrgID, mockOrgName, "gpt-4o-2024-08-06", "completions",
+		"proj_demo_support", "support-bot", "user_demo_bob", "bob@example.com", "key_demo_support", "support-key", "false", "input"))
+	assert.Positive(t, input, "names of projects, users and keys are resolved by the mock API")
+
+	require.NoError(t, e.fetchUsageData(endpoint, end-300, end))
+	assert.Equal(t, input, testutil.ToFloat64(tokensTotal.WithLabelValues(mockOrgID, mockOrgName, "gpt-4o-2024-08-06", "completions",
+		"proj_demo_support", "support-bot", "user_demo_bob", "bob@example.com", "key_demo_support", "support-key", "false", "input")),
+		"buckets read again report the same usage")
+
+	minutes, err := e.fetchUsageBuckets(endpoint, end-3600, end, "1m", "model")
+	require.NoError(t, err)
+	hours, err := e.fetchUsageBuckets(endpoint, end-3600, end, "1h", "model")
+	require.NoError(t, err)
+	sum := func(buckets []Bucket, start, end int64) (total int64) {
+		for _, b := range buckets {
+			for _, r := range b.Results {
+				if b.StartTime >= start && b.EndTime <= end {
+					assert.Nil(t, r.ProjectID, "dimensions not grouped by are left out")
+					total += r.InputTokens
+				}
+			}
+		}
+		return total
+	}
+	hour := now.Truncate(time.Hour).Unix()
+	assert.Equal(t, sum(minutes, hour, end), sum(hours, hour, hour+3600), "wider buckets sum the minutes up to now")
+}
+
+func TestMockAPI_Costs(t *testing.T) {
+	now := time.Date(2024, 6, 12, 15, 30, 0, 0, time.UTC)
+	server := httptest.NewServer(newMockAPI(func() time.Time { return now }))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "mock", orgID: mockOrgID, orgName: mockOrgName, targets: newAPITargets(mockOrgID, server.URL, 3)}
+
+	day := now.Truncate(24 * time.Hour).Unix()
+	byItem, err := e.fetchCostBuckets(day-86400, now.Unix(), "project_id,line_item")
+	require.NoError(t, err)
+	require.Len(t, byItem, 2)
+	byProject, err := e.fetchCostBuckets(day-86400, now.Unix(), "project_id")
+	require.NoError(t, err)
+
+	total := func(buckets []CostBucket) (sum float64) {
+		for _, r := range buckets[1].Results {
+			assert.Equal(t, "usd", r.Amount.Currency)
+			sum += float64(r.Amount.Value)
+		}
+		return sum
+	}
+	assert.Len(t, byProject[1].Results, 3)
+	assert.Len(t, byItem[1].Results, 14, "input and output of every model and project")
+	assert.InDelta(t, total(byProject), total(byItem), 1e-6)
+	assert.Greater(t, float64(byProject[0].Results[0].Amount.Value), float64(byProject[1].Results[0].Amount.Value), "the current day is still growing")
+}
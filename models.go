@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Available Models
+
+var (
+	modelsEnabled  = flag.Bool("collector.models", false, "Export the models available to the organization and when they were created")
+	modelsInterval = flag.Duration("collector.models.interval", time.Hour, "Interval at which the model list is re-read")
+
+	modelAvailable = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_model_available",
+			Help: "Whether a model is available (1) or was available earlier and has disappeared from the model list (0).",
+		},
+		[]string{"org_id", "org_name", "model"},
+	)
+	modelCreated = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_model_created_timestamp_seconds",
+			Help: "Unix time at which an available model was created.",
+		},
+		[]string{"org_id", "org_name", "model", "owned_by"},
+	)
+)
+
+var (
+	// knownModels maps org_id -> model -> whether it is currently available, for all models listed since startup.
+	knownModels = make(map[string]map[string]bool)
+)
+
+type ModelList struct {
+	Object string  `json:"object"`
+	Data   []Model `json:"data"`
+}
+
+type Model struct {
+	ID      string `json:"id"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// fetchModels lists the models available to the organization.
+func (e *Exporter) fetchModels() ([]Model, error) {
+	resp, err := e.get("/v1/models")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching models: %w", err)
+	}
+
+	var out ModelList
+	decodeErr := json.NewDecoder(resp.Body).Decode(&out)
+	closeErr := resp.Body.Close()
+
+	if decodeErr != nil {
+		return nil, fmt.Errorf("error decoding response: %w", decodeErr)
+	}
+	if closeErr != nil {
+		logrus.WithError(closeErr).Warn("failed to close response body")
+	}
+	pagesFetchedTotal.WithLabelValues(e.orgID, "models").Inc()
+	return out.Data, nil
+}
+
+// collectModels marks the listed models as available and models listed before but missing now as unavailable.
+func (e *Exporter) collectModels() error {
+	models, err := e.fetchModels()
+	if err != nil {
+		return err
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	known, listedBefore := knownModels[e.orgID]
+	if !listedBefore {
+		known = make(map[string]bool)
+		knownModels[e.orgID] = known
+	}
+	listed := make(map[string]struct{}, len(models))
+	for _, m := range models {
+		listed[m.ID] = struct{}{}
+		if available, seen := known[m.ID]; listedBefore && !available {
+			// Models listed at startup are not new; models that reappear are.
+			logrus.WithFields(logrus.Fields{"org_id": e.orgID, "model": m.ID, "reappeared": seen}).Info("New model available")
+		}
+		known[m.ID] = true
+		modelAvailable.WithLabelValues(e.orgID, e.orgName, m.ID).Set(1)
+		modelCreated.WithLabelValues(e.orgID, e.orgName, m.ID, m.OwnedBy).Set(float64(m.Created))
+	}
+	for id, available := range known {
+		if _, ok := listed[id]; ok || !available {
+			continue
+		}
+		logrus.WithFields(logrus.Fields{"org_id": e.orgID, "model": id}).Warn("Model is no longer available")
+		known[id] = false
+		modelAvailable.WithLabelValues(e.orgID, e.orgName, id).Set(0)
+		modelCreated.DeletePartialMatch(prometheus.Labels{"org_id": e.orgID, "model": id})
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectModels(t *testing.T) {
+	knownModels = make(map[string]map[string]bool)
+	modelAvailable.Reset()
+	modelCreated.Reset()
+
+	models := `[
+		{"id": "gpt-4o", "object": "model", "created": 1715367049, "owned_by": "system"},
+		{"id": "gpt-4-0613", "object": "model", "created": 1686588896, "owned_by": "openai"}
+	]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/models", r.URL.Path)
+		assert.Equal(t, "Bearer sk-proj", r.Header.Get("Authorization"), "project resources are read with the project key")
+		_, _ = w.Write([]byte(`{"object": "list", "data": ` + models + `}`))
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", projectKey: "sk-proj", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+	available := func(model string) float64 {
+		return testutil.ToFloat64(modelAvailable.WithLabelValues("org-1", "prod", model))
+	}
+
+	require.NoError(t, e.collectModels())
+	assert.Equal(t, 1.0, available("gpt-4o"))
+	assert.Equal(t, 1.0, available("gpt-4-0613"))
+	assert.Equal(t, 1715367049.0, testutil.ToFloat64(modelCreated.WithLabelValues("org-1", "prod", "gpt-4o", "system")))
+
+	models = `[
+		{"id": "gpt-4o", "object": "model", "created": 1715367049, "owned_by": "system"},
+		{"id": "gpt-4o-2024-11-20", "object": "model", "created": 1739331543, "owned_by": "system"}
+	]`
+	require.NoError(t, e.collectModels())
+	assert.Equal(t, 0.0, available("gpt-4-0613"), "models that disappear stay exported as unavailable")
+	assert.Equal(t, 1.0, available("gpt-4o-2024-11-20"))
+	assert.Equal(t, 3, testutil.CollectAndCount(modelAvailable))
+	assert.Equal(t, 2, testutil.CollectAndCount(modelCreated), "only available models have a creation time")
+}
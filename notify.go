@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Webhook Notifications
+
+var (
+	notifyWebhookURL       = flag.String("notify.webhook-url", "", "URL to POST a JSON notification to when a budget threshold is crossed or a usage anomaly starts")
+	notifyFormat           = flag.String("notify.format", "json", "Payload of webhook notifications: json (event, message, labels and values) or slack (Slack incoming webhook message)")
+	notifyBudgetThresholds = flag.String("notify.budget-thresholds", "0.8,1", "Comma-separated budget utilization ratios that trigger a notification when spend crosses them")
+
+	notificationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "openai_exporter_notifications_total",
+			Help: "Total number of webhook notifications sent, by event and result (success or error).",
+		},
+		[]string{"event", "result"},
+	)
+)
+
+var notifyClient = &http.Client{Timeout: 10 * time.Second}
+
+var (
+	// budgetThresholds are the parsed notify.budget-thresholds, in ascending order.
+	budgetThresholds []float64
+	// budgetUtilizationSeen maps org_id|budget -> utilization when the budget was last read, guarded by stateMu.
+	budgetUtilizationSeen = make(map[string]float64)
+)
+
+// notification is the JSON payload of a webhook notification.
+type notification struct {
+	Event     string            `json:"event"`
+	Message   string            `json:"message"`
+	Labels    map[string]string `json:"labels"`
+	Value     float64           `json:"value"`
+	Threshold float64           `json:"threshold"`
+	Time      time.Time         `json:"time"`
+}
+
+// configureNotifications validates the notification flags and parses the budget thresholds.
+func configureNotifications() error {
+	if *notifyFormat != "json" && *notifyFormat != "slack" {
+		return fmt.Errorf("unknown notify.format %q, use json or slack", *notifyFormat)
+	}
+	var thresholds []float64
+	for _, s := range splitList(*notifyBudgetThresholds) {
+		t, err := strconv.ParseFloat(s, 64)
+		if err != nil || t <= 0 {
+			return fmt.Errorf("invalid budget threshold %q: must be a positive ratio", s)
+		}
+		thresholds = append(thresholds, t)
+	}
+	slices.Sort(thresholds)
+	budgetThresholds = thresholds
+	return nil
+}
+
+// budgetCrossing returns the highest threshold the utilization of a budget crossed since it was last read,
+// and whether it crossed any. A budget read for the first time crosses every threshold it is at or above.
+// It must be called with stateMu held.
+func budgetCrossing(key string, utilization float64) (float64, bool) {
+	previous := budgetUtilizationSeen[key]
+	budgetUtilizationSeen[key] = utilization
+
+	var crossed float64
+	for _, t := range budgetThresholds {
+		if previous < t && utilization >= t {
+			crossed = t
+		}
+	}
+	return crossed, crossed > 0
+}
+
+// budgetNotification describes a budget whose spend crossed threshold.
+func budgetNotification(labels prometheus.Labels, spend, limit, threshold float64) notification {
+	return notification{
+		Event: "budget",
+		Message: fmt.Sprintf("Budget %s of %s crossed %.0f%% of its monthly limit: $%.2f of $%.2f spent",
+			labels["budget"], labels["org_name"], threshold*100, spend, limit),
+		Labels:    labels,
+		Value:     spend / limit,
+		Threshold: threshold,
+		Time:      time.Now().UTC(),
+	}
+}
+
+// anomalyNotification describes a project and model whose token rate became anomalous.
+func anomalyNotification(labels prometheus.Labels, score float64) notification {
+	return notification{
+		Event: "anomaly",
+		Message: fmt.Sprintf("Project %s of %s uses %s at %.1f times its usual token rate",
+			labels["project_name"], labels["org_name"], labels["model"], score),
+		Labels:    labels,
+		Value:     score,
+		Threshold: *anomalyFactor,
+		Time:      time.Now().UTC(),
+	}
+}
+
+// sendNotifications posts every notification to the webhook, if one is configured. Failures are logged
+// and counted, so they never fail a collection cycle.
+func sendNotifications(notifications []notification) {
+	if *notifyWebhookURL == "" {
+		return
+	}
+	for _, n := range notifications {
+		result := "success"
+		if err := postNotification(*notifyWebhookURL, *notifyFormat, n); err != nil {
+			logrus.WithError(err).WithField("event", n.Event).Warn("Error sending notification")
+			result = "error"
+		}
+		notificationsTotal.WithLabelValues(n.Event, result).Inc()
+	}
+}
+
+// postNotification posts one notification in format to url.
+func postNotification(url, format string, n notification) error {
+	var payload any = n
+	if format == "slack" {
+		payload = map[string]string{"text": ":warning: " + n.Message}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error encoding notification: %w", err)
+	}
+
+	logrus.Debugf("Sending %s notification: %s", n.Event, n.Message)
+	resp, err := notifyClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error sending notification: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigureNotifications(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		thresholds string
+		expected   []float64
+		wantErr    string
+	}{
+		{name: "defaults", format: "json", thresholds: "0.8,1", expected: []float64{0.8, 1}},
+		{name: "sorted", format: "slack", thresholds: "1, 0.5", expected: []float64{0.5, 1}},
+		{name: "unknown format", format: "xml", thresholds: "1", wantErr: `unknown notify.format "xml"`},
+		{name: "invalid threshold", format: "json", thresholds: "80%", wantErr: `invalid budget threshold "80%"`},
+		{name: "zero threshold", format: "json", thresholds: "0", wantErr: `invalid budget threshold "0"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origFormat, origThresholds := *notifyFormat, *notifyBudgetThresholds
+			defer func() { *notifyFormat, *notifyBudgetThresholds = origFormat, origThresholds }()
+			*notifyFormat, *notifyBudgetThresholds = tt.format, tt.thresholds
+
+			err := configureNotifications()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, budgetThresholds)
+		})
+	}
+}
+
+func TestBudgetCrossing(t *testing.T) {
+	budgetThresholds = []float64{0.8, 1}
+	budgetUtilizationSeen = make(map[string]float64)
+
+	steps := []struct {
+		utilization float64
+		threshold   float64
+		crossed     bool
+	}{
+		{utilization: 0.5},
+		{utilization: 0.85, threshold: 0.8, crossed: true},
+		{utilization: 0.9},
+		{utilization: 1.2, threshold: 1, crossed: true},
+		{utilization: 1.3},
+		{utilization: 0.1},
+		{utilization: 1.1, threshold: 1, crossed: true},
+	}
+	for i, step := range steps {
+		threshold, crossed := budgetCrossing("org-1|team", step.utilization)
+		assert.Equal(t, step.crossed, crossed, "step %d", i)
+		assert.Equal(t, step.threshold, threshold, "step %d", i)
+	}
+}
+
+func TestSendNotifications(t *testing.T) {
+	var payloads []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var payload map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		payloads = append(payloads, payload)
+	}))
+	defer server.Close()
+
+	origURL, origFormat := *notifyWebhookURL, *notifyFormat
+	defer func() { *notifyWebhookURL, *notifyFormat = origURL, origFormat }()
+	notificationsTotal.Reset()
+
+	labels := prometheus.Labels{"budget": "team", "org_id": "org-1", "org_name": "prod", "project_id": "proj-1"}
+	n := budgetNotification(labels, 85, 100, 0.8)
+	assert.Equal(t, "Budget team of prod crossed 80% of its monthly limit: $85.00 of $100.00 spent", n.Message)
+
+	*notifyWebhookURL = ""
+	sendNotifications([]notification{n})
+	assert.Empty(t, payloads, "nothing is sent without a webhook")
+
+	*notifyWebhookURL, *notifyFormat = server.URL, "json"
+	sendNotifications([]notification{n})
+	*notifyFormat = "slack"
+	sendNotifications([]notification{n})
+
+	require.Len(t, payloads, 2)
+	assert.Equal(t, "budget", payloads[0]["event"])
+	assert.Equal(t, 0.85, payloads[0]["value"])
+	assert.Equal(t, 0.8, payloads[0]["threshold"])
+	assert.Equal(t, "proj-1", payloads[0]["labels"].(map[string]any)["project_id"])
+	assert.Equal(t, map[string]any{"text": ":warning: " + n.Message}, payloads[1])
+	assert.Equal(t, 2.0, testutil.ToFloat64(notificationsTotal.WithLabelValues("budget", "success")))
+
+	*notifyWebhookURL = server.URL + "/\x7f"
+	sendNotifications([]notification{anomalyNotification(prometheus.Labels{"project_name": "one", "org_name": "prod", "model": "gpt-4o"}, 4)})
+	assert.Equal(t, 1.0, testutil.ToFloat64(notificationsTotal.WithLabelValues("anomaly", "error")))
+}
+
+func TestEvaluateAnomalies_NotifiesOnce(t *testing.T) {
+	var messages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n notification
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&n))
+		messages = append(messages, n.Message)
+	}))
+	defer server.Close()
+
+	origURL, origFormat := *notifyWebhookURL, *notifyFormat
+	defer func() { *notifyWebhookURL, *notifyFormat = origURL, origFormat }()
+	*notifyWebhookURL, *notifyFormat = server.URL, "json"
+	anomalySeries = make(map[string]*anomalyTracker)
+	*anomalyEnabled = true
+	defer func() { *anomalyEnabled = false }()
+
+	labels := prometheus.Labels{"org_id": "org-1", "org_name": "prod", "project_id": "proj-1", "project_name": "one", "model": "gpt-4o"}
+	for range anomalyWarmupCycles {
+		recordAnomalyUsage(labels, map[string]float64{"input": 10000})
+		evaluateAnomalies(time.Minute)
+	}
+	for range 2 {
+		recordAnomalyUsage(labels, map[string]float64{"input": 50000})
+		evaluateAnomalies(time.Minute)
+	}
+	assert.Equal(t, []string{"Project one of prod uses gpt-4o at 5.0 times its usual token rate"}, messages)
+}
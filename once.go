@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Run-Once Mode
+
+var (
+	runOnceFlag = flag.Bool("once", false, "Collect a single window, write the metrics to -textfile.directory, -pushgateway.url or stdout and exit; the exit code is non-zero when anything failed")
+	onceFrom    = flag.String("once.from", "", "Start of the window collected with -once, as RFC 3339 time or date (default: end of the previous run)")
+	onceTo      = flag.String("once.to", "", "End of the window collected with -once, as RFC 3339 time or date (default: the last full minute)")
+)
+
+// parseWindowTime parses an RFC 3339 time or a date, which stands for its start in UTC.
+func parseWindowTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, use RFC 3339 or YYYY-MM-DD", s)
+	}
+	return t, nil
+}
+
+// onceWindow returns the window collected with -once: from -once.from, or lastScrape, to -once.to,
+// or the last full minute before now.
+func onceWindow(now time.Time) (int64, int64, error) {
+	stateMu.RLock()
+	startTime := lastScrape
+	stateMu.RUnlock()
+	endTime := now.Truncate(time.Minute).Unix()
+
+	if *onceFrom != "" {
+		t, err := parseWindowTime(*onceFrom)
+		if err != nil {
+			return 0, 0, fmt.Errorf("-once.from: %w", err)
+		}
+		startTime = t.Unix()
+	}
+	if *onceTo != "" {
+		t, err := parseWindowTime(*onceTo)
+		if err != nil {
+			return 0, 0, fmt.Errorf("-once.to: %w", err)
+		}
+		endTime = t.Unix()
+	}
+	if endTime < startTime {
+		return 0, 0, fmt.Errorf("window ends (%s) before it starts (%s)", time.Unix(endTime, 0).UTC(), time.Unix(startTime, 0).UTC())
+	}
+	return startTime, endTime, nil
+}
+
+// runOnce collects a single window and writes the metrics to the textfile directory and the Pushgateway when
+// configured, and to out otherwise. The metrics are written even when the collection failed, so
+// openai_exporter_up reports the failure. The state file is written afterwards so the next run continues
+// where this one stopped.
+func runOnce(c windowCollector, g prometheus.Gatherer, out io.Writer) error {
+	startTime, endTime, err := onceWindow(time.Now())
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	if endTime > startTime {
+		errs = append(errs, runCycle(c, startTime, endTime))
+	}
+	if *textfileDirectory != "" {
+		errs = append(errs, writeTextfile(*textfileDirectory, g))
+	}
+	if *pushgatewayURL != "" {
+		errs = append(errs, pushMetrics(*pushgatewayURL, *pushgatewayJob, g))
+	}
+	if *textfileDirectory == "" && *pushgatewayURL == "" {
+		errs = append(errs, writeMetrics(out, g))
+	}
+	flushState()
+	return errors.Join(errs...)
+}
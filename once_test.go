@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnceWindow(t *testing.T) {
+	origFrom, origTo, origLast := *onceFrom, *onceTo, lastScrape
+	defer func() { *onceFrom, *onceTo, lastScrape = origFrom, origTo, origLast }()
+	now := time.Date(2024, 6, 8, 10, 30, 45, 0, time.UTC)
+	lastScrape = now.Add(-time.Hour).Unix()
+
+	tests := []struct {
+		name      string
+		from, to  string
+		wantStart time.Time
+		wantEnd   time.Time
+		wantErr   bool
+	}{
+		{name: "since the previous run", wantStart: now.Add(-time.Hour), wantEnd: time.Date(2024, 6, 8, 10, 30, 0, 0, time.UTC)},
+		{name: "dates", from: "2024-06-01", to: "2024-06-08", wantStart: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), wantEnd: time.Date(2024, 6, 8, 0, 0, 0, 0, time.UTC)},
+		{name: "RFC 3339", from: "2024-06-08T09:00:00+02:00", wantStart: time.Date(2024, 6, 8, 7, 0, 0, 0, time.UTC), wantEnd: time.Date(2024, 6, 8, 10, 30, 0, 0, time.UTC)},
+		{name: "invalid time", from: "last week", wantErr: true},
+		{name: "reversed", from: "2024-06-08", to: "2024-06-01", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*onceFrom, *onceTo = tt.from, tt.to
+			start, end, err := onceWindow(now)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStart.Unix(), start)
+			assert.Equal(t, tt.wantEnd.Unix(), end)
+		})
+	}
+}
+
+func TestRunOnce(t *testing.T) {
+	origFrom, origTo, origDir, origLast := *onceFrom, *onceTo, *textfileDirectory, lastScrape
+	defer func() { *onceFrom, *onceTo, *textfileDirectory, lastScrape = origFrom, origTo, origDir, origLast }()
+	*onceFrom, *onceTo = "2024-06-01", "2024-06-02"
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(exporterUp)
+	defer registry.Unregister(exporterUp)
+
+	t.Run("stdout", func(t *testing.T) {
+		*textfileDirectory = ""
+		c := &fakeCollector{}
+		var out bytes.Buffer
+
+		require.NoError(t, runOnce(c, registry, &out))
+		assert.Equal(t, [][2]int64{{1717200000, 1717286400}}, c.windows)
+		assert.Contains(t, out.String(), "openai_exporter_up 1")
+	})
+
+	t.Run("textfile on failure", func(t *testing.T) {
+		*textfileDirectory = t.TempDir()
+		c := &fakeCollector{err: errors.New("boom")}
+		var out bytes.Buffer
+
+		assert.Error(t, runOnce(c, registry, &out))
+		assert.Empty(t, out.String(), "metrics go to the textfile only")
+		data, err := os.ReadFile(filepath.Join(*textfileDirectory, textfileName))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "openai_exporter_up 0")
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Optional Collectors
+
+// optionalCollector reads organization data that changes slowly, like the files or API keys of its projects.
+// It is read at most once per interval; a failed read keeps the previous values, is retried in the next
+// cycle and does not make the window incomplete.
+type optionalCollector struct {
+	name     string // endpoint label of the fetch metrics and circuit breaker
+	enabled  *bool
+	interval *time.Duration
+	collect  func(*Exporter) error
+	failure  string // logged when collect fails
+	// projectResource is set for collectors of project resources, which need a project key.
+	projectResource bool
+}
+
+var optionalCollectors = []optionalCollector{
+	{"rate_limits", rateLimitsEnabled, rateLimitsInterval, (*Exporter).collectRateLimits, "Error reading project rate limits", false},
+	{"fine_tuning_jobs", fineTuningEnabled, fineTuningInterval, (*Exporter).collectFineTuningJobs, "Error reading fine-tuning jobs", true},
+	{"files", filesEnabled, filesInterval, (*Exporter).collectFiles, "Error reading files", true},
+	{"inventory", inventoryEnabled, inventoryInterval, (*Exporter).collectInventory, "Error counting assistants and vector stores", true},
+	{"models", modelsEnabled, modelsInterval, (*Exporter).collectModels, "Error reading models", true},
+	{"members", membersEnabled, membersInterval, (*Exporter).collectMembers, "Error reading users and invites", false},
+	{"service_accounts", serviceAccountsEnabled, serviceAccountsInterval, (*Exporter).collectServiceAccounts, "Error reading service accounts", false},
+	{"api_keys", apiKeysEnabled, apiKeysInterval, (*Exporter).collectAPIKeys, "Error reading API keys", false},
+}
+
+// optionalRefreshed maps collector name -> org_id -> time of the last successful read, guarded by stateMu.
+var optionalRefreshed = make(map[string]map[string]time.Time)
+
+// due reports whether the collector should read the organization again.
+func (c optionalCollector) due(orgID string, now time.Time) bool {
+	stateMu.RLock()
+	defer stateMu.RUnlock()
+	return now.Sub(optionalRefreshed[c.name][orgID]) >= *c.interval
+}
+
+// run reads the organization and records the fetch.
+func (c optionalCollector) run(e *Exporter) {
+	err := c.collect(e)
+	recordFetch(e.orgID, c.name, err)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{"org_id": e.orgID, "endpoint": c.name}).Warn(c.failure)
+		return
+	}
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	if optionalRefreshed[c.name] == nil {
+		optionalRefreshed[c.name] = make(map[string]time.Time)
+	}
+	optionalRefreshed[c.name][e.orgID] = time.Now()
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionalCollector(t *testing.T) {
+	optionalRefreshed = make(map[string]map[string]time.Time)
+	interval := time.Hour
+	var err error
+	calls := 0
+	c := optionalCollector{name: "files", interval: &interval, failure: "Error reading files", collect: func(*Exporter) error {
+		calls++
+		return err
+	}}
+	e := &Exporter{orgID: "org-1"}
+
+	assert.True(t, c.due("org-1", time.Now()), "never read before")
+
+	err = errors.New("boom")
+	c.run(e)
+	assert.True(t, c.due("org-1", time.Now()), "a failed read is retried in the next cycle")
+
+	err = nil
+	c.run(e)
+	assert.False(t, c.due("org-1", time.Now()))
+	assert.True(t, c.due("org-1", time.Now().Add(interval)))
+	assert.True(t, c.due("org-2", time.Now()), "organizations are read independently")
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Organizations
+
+// Organization is an OpenAI organization collected by the exporter. Several organizations are configured
+// under organizations in the configuration file; each one's admin keys are read from the environment
+// variables listed in APIKeyEnv or from the file APIKeyFile, so no secret has to be written into the
+// configuration file. The project or service account key of ProjectKeyEnv is used for the project
+// resources that admin keys cannot read, like files and fine-tuning jobs.
+// BaseURL replaces -openai.base-url for the organization when set and, like it, may list several URLs.
+type Organization struct {
+	ID            string `yaml:"id"`
+	Name          string `yaml:"name"`
+	APIKeyEnv     string `yaml:"api_key_env"`
+	APIKeyFile    string `yaml:"api_key_file"`
+	ProjectKeyEnv string `yaml:"project_key_env"`
+	BaseURL       string `yaml:"base_url"`
+}
+
+// singleOrganization returns the organization configured through OPENAI_ORG_ID and OPENAI_ORG_NAME, with
+// its project key in OPENAI_PROJECT_KEY. Its name defaults to its ID.
+func singleOrganization() Organization {
+	org := Organization{ID: os.Getenv("OPENAI_ORG_ID"), Name: os.Getenv("OPENAI_ORG_NAME"), ProjectKeyEnv: "OPENAI_PROJECT_KEY"}
+	if org.Name == "" {
+		org.Name = org.ID
+	}
+	return org
+}
+
+// readOrganizations decodes and validates the organizations list of the configuration file.
+// Names default to the organization ID. Errors carry the file name and the line of the offending entry.
+func readOrganizations(path string, node *yaml.Node) ([]Organization, error) {
+	if node.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("%s:%d: organizations must be a list", path, node.Line)
+	}
+	orgs := make([]Organization, 0, len(node.Content))
+	seen := make(map[string]bool)
+	for _, item := range node.Content {
+		var org Organization
+		if err := item.Decode(&org); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, item.Line, err)
+		}
+		switch {
+		case org.ID == "":
+			return nil, fmt.Errorf("%s:%d: organization without id", path, item.Line)
+		case seen[org.ID]:
+			return nil, fmt.Errorf("%s:%d: duplicate organization %q", path, item.Line, org.ID)
+		case (org.APIKeyEnv == "") == (org.APIKeyFile == ""):
+			return nil, fmt.Errorf("%s:%d: organization %q needs exactly one of api_key_env and api_key_file", path, item.Line, org.ID)
+		}
+		for _, raw := range splitList(org.BaseURL) {
+			if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, fmt.Errorf("%s:%d: organization %q has an invalid base_url %q", path, item.Line, org.ID, raw)
+			}
+		}
+		seen[org.ID] = true
+		if org.Name == "" {
+			org.Name = org.ID
+		}
+		orgs = append(orgs, org)
+	}
+	return orgs, nil
+}
+
+// projectKey returns the project or service account key of org. It fails when a collector of project
+// resources is enabled without one, as those resources do not accept admin keys.
+func projectKey(org Organization) (string, error) {
+	var key string
+	if org.ProjectKeyEnv != "" {
+		key = os.Getenv(org.ProjectKeyEnv)
+	}
+	if key != "" {
+		return key, nil
+	}
+	for _, c := range optionalCollectors {
+		if *c.enabled && c.projectResource {
+			return "", fmt.Errorf("the %s collector reads project resources, which do not accept admin keys; set %s to a project or service account key",
+				c.name, cmp.Or(org.ProjectKeyEnv, "project_key_env"))
+		}
+	}
+	return "", nil
+}
+
+// newOrgExporter creates the exporter of one configured organization.
+func newOrgExporter(org Organization) (*Exporter, error) {
+	projectKey, err := projectKey(org)
+	if err != nil {
+		return nil, fmt.Errorf("organization %s: %w", org.ID, err)
+	}
+	if org.APIKeyFile != "" {
+		keyFile, err := newSecretFile(org.APIKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("organization %s: %w", org.ID, err)
+		}
+		return newExporter(org, "", projectKey, keyFile)
+	}
+	var keys []string
+	for _, env := range splitList(org.APIKeyEnv) {
+		apiKey := os.Getenv(env)
+		if apiKey == "" {
+			return nil, fmt.Errorf("organization %s: %s environment variable is not set", org.ID, env)
+		}
+		keys = append(keys, apiKey)
+	}
+	return newExporter(org, strings.Join(keys, ","), projectKey, nil)
+}
+
+// orgExporters collects several organizations.
+type orgExporters []*Exporter
+
+// newOrgExporters creates an exporter for each of orgs.
+func newOrgExporters(orgs []Organization) (orgExporters, error) {
+	exporters := make(orgExporters, 0, len(orgs))
+	for _, org := range orgs {
+		e, err := newOrgExporter(org)
+		if err != nil {
+			return nil, err
+		}
+		exporters = append(exporters, e)
+	}
+	return exporters, nil
+}
+
+// collectWindow collects one time window of all organizations concurrently.
+// A failing organization does not keep the others from being collected.
+func (o orgExporters) collectWindow(startTime, endTime int64) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	for _, e := range o {
+		wg.Add(1)
+		go func(e *Exporter) {
+			defer wg.Done()
+			if err := e.collectWindow(startTime, endTime); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("organization %s: %w", e.orgID, err))
+				mu.Unlock()
+			}
+		}(e)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
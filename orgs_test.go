@@ -0,0 +1,161 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadConfigFile_Organizations(t *testing.T) {
+	path := writeConfigFile(t, `
+organizations:
+  - id: org-prod
+    name: prod
+    api_key_env: OPENAI_PROD_KEY
+  - id: org-research
+    api_key_file: /run/secrets/research
+    base_url: https://gateway.example
+`)
+	cfg, err := readConfigFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []Organization{
+		{ID: "org-prod", Name: "prod", APIKeyEnv: "OPENAI_PROD_KEY"},
+		{ID: "org-research", Name: "org-research", APIKeyFile: "/run/secrets/research", BaseURL: "https://gateway.example"},
+	}, cfg.Organizations)
+	assert.Empty(t, cfg.Flags)
+}
+
+func TestReadConfigFile_OrganizationErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "not a list",
+			content: "organizations: org-prod\n",
+			wantErr: ":1: organizations must be a list",
+		},
+		{
+			name:    "missing id",
+			content: "organizations:\n  - name: prod\n    api_key_env: KEY\n",
+			wantErr: ":2: organization without id",
+		},
+		{
+			name:    "duplicate id",
+			content: "organizations:\n  - id: org-1\n    api_key_env: KEY\n  - id: org-1\n    api_key_env: OTHER_KEY\n",
+			wantErr: `:4: duplicate organization "org-1"`,
+		},
+		{
+			name:    "no key",
+			content: "organizations:\n  - id: org-1\n",
+			wantErr: `:2: organization "org-1" needs exactly one of api_key_env and api_key_file`,
+		},
+		{
+			name:    "both keys",
+			content: "organizations:\n  - id: org-1\n    api_key_env: KEY\n    api_key_file: /key\n",
+			wantErr: `:2: organization "org-1" needs exactly one of api_key_env and api_key_file`,
+		},
+		{
+			name:    "relative base URL",
+			content: "organizations:\n  - id: org-1\n    api_key_env: KEY\n    base_url: gateway.example\n",
+			wantErr: `:2: organization "org-1" has an invalid base_url "gateway.example"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readConfigFile(writeConfigFile(t, tt.content))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestNewOrgExporters(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key")
+	writeSecret(t, keyFile, "sk-research\n", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	t.Setenv("OPENAI_PROD_KEY", "sk-prod")
+
+	exporters, err := newOrgExporters([]Organization{
+		{ID: "org-prod", Name: "prod", APIKeyEnv: "OPENAI_PROD_KEY"},
+		{ID: "org-research", Name: "research", APIKeyFile: keyFile, BaseURL: "https://gateway.example/openai/"},
+	})
+	require.NoError(t, err)
+	require.Len(t, exporters, 2)
+	assert.Equal(t, "sk-prod", exporters[0].apiKey)
+	assert.Equal(t, "prod", exporters[0].orgName)
+	assert.Equal(t, defaultBaseURL, exporters[0].targets.current())
+	require.NotNil(t, exporters[1].keyFile)
+	assert.Equal(t, "org-research", exporters[1].orgID)
+	assert.Equal(t, "https://gateway.example/openai", exporters[1].targets.current(), "base_url overrides -openai.base-url")
+
+	t.Run("missing key", func(t *testing.T) {
+		_, err := newOrgExporters([]Organization{{ID: "org-staging", Name: "staging", APIKeyEnv: "OPENAI_STAGING_KEY"}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "organization org-staging: OPENAI_STAGING_KEY")
+	})
+
+	t.Run("project key", func(t *testing.T) {
+		defer func(enabled bool) { *modelsEnabled = enabled }(*modelsEnabled)
+		*modelsEnabled = true
+		org := Organization{ID: "org-prod", Name: "prod", APIKeyEnv: "OPENAI_PROD_KEY", ProjectKeyEnv: "OPENAI_PROD_PROJECT_KEY"}
+
+		_, err := newOrgExporters([]Organization{org})
+		require.Error(t, err, "project resources do not accept the admin key")
+		assert.Contains(t, err.Error(), "organization org-prod: the models collector reads project resources")
+		assert.Contains(t, err.Error(), "OPENAI_PROD_PROJECT_KEY")
+
+		t.Setenv("OPENAI_PROD_PROJECT_KEY", "sk-proj")
+		exporters, err := newOrgExporters([]Organization{org})
+		require.NoError(t, err)
+		assert.Equal(t, "sk-proj", exporters[0].projectKey)
+	})
+}
+
+func TestOrgExporters_CollectWindow(t *testing.T) {
+	setResolveUsers(t)
+	usageState = make(map[string]float64)
+	tokensTotal.Reset()
+	origEndpoints := activeEndpoints
+	activeEndpoints = []UsageEndpoint{{Path: "completions", Name: "completions"}}
+	defer func() { activeEndpoints = origEndpoints }()
+
+	end := time.Now().Add(-time.Minute).Truncate(time.Minute).Unix()
+	prod := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/costs") {
+			_, _ = w.Write([]byte(`{"object": "page", "data": [], "has_more": false}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"object": "page", "data": [{"start_time": ` + strconv.FormatInt(end-60, 10) + `, "end_time": ` + strconv.FormatInt(end, 10) + `, "results": [
+			{"input_tokens": 12, "project_id": "proj-1", "user_id": "user-1", "api_key_id": "key-1", "model": "gpt-4", "batch": false}
+		]}], "has_more": false}`))
+	}))
+	defer prod.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer broken.Close()
+
+	projectNames = map[string]string{"proj-1": "one"}
+	apiKeyNames = map[string]string{"key-1": "ci"}
+	userEmails = map[string]string{"user-1": "ana@example.com"}
+	exporters := orgExporters{
+		{client: prod.Client(), apiKey: "test", orgID: "org-prod", orgName: "prod", targets: newAPITargets("org-prod", prod.URL, 3)},
+		{client: broken.Client(), apiKey: "test", orgID: "org-staging", orgName: "staging", targets: newAPITargets("org-staging", broken.URL, 3)},
+	}
+
+	err := exporters.collectWindow(end-60, end)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "organization org-staging")
+	assert.NotContains(t, err.Error(), "organization org-prod")
+	assert.Equal(t, 12.0, testutil.ToFloat64(tokensTotal.WithLabelValues("org-prod", "prod", "gpt-4", "completions", "proj-1", "one", "user-1", "ana@example.com", "key-1", "ci", "false", "input")))
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+	otelprom "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// OTLP Export
+
+var (
+	otlpEndpoint = flag.String("otlp.endpoint", "", "Base URL of an OTLP/HTTP receiver the OpenAI metrics are pushed to, e.g. https://otlp-gateway-prod-eu-west-2.grafana.net/otlp; headers are taken from OTEL_EXPORTER_OTLP_HEADERS")
+	otlpInterval = flag.Duration("otlp.interval", time.Minute, "Interval at which metrics are pushed to -otlp.endpoint")
+)
+
+// openaiGatherer gathers only the OpenAI metrics of g, leaving out Go runtime and process metrics.
+type openaiGatherer struct {
+	gatherer prometheus.Gatherer
+}
+
+func (o openaiGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := o.gatherer.Gather()
+	filtered := families[:0]
+	for _, mf := range families {
+		if strings.HasPrefix(mf.GetName(), "openai_") {
+			filtered = append(filtered, mf)
+		}
+	}
+	return filtered, err
+}
+
+// otlpMetricsURL returns the metrics URL of an OTLP/HTTP receiver, appending /v1/metrics to a base URL.
+func otlpMetricsURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid -otlp.endpoint: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid -otlp.endpoint %q: must be an http or https URL", endpoint)
+	}
+	if !strings.HasSuffix(u.Path, "/v1/metrics") {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/v1/metrics"
+	}
+	return u.String(), nil
+}
+
+// startOTLP pushes the OpenAI metrics of g to endpoint every interval. The returned function
+// pushes the metrics one last time and stops.
+func startOTLP(endpoint string, interval time.Duration, g prometheus.Gatherer) (func(context.Context) error, error) {
+	metricsURL, err := otlpMetricsURL(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	exporter, err := otlpmetrichttp.New(context.Background(), otlpmetrichttp.WithEndpointURL(metricsURL))
+	if err != nil {
+		return nil, fmt.Errorf("error creating OTLP exporter: %w", err)
+	}
+	res, err := resource.New(context.Background(),
+		resource.WithAttributes(attribute.String("service.name", "openai-exporter")),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating OTLP resource: %w", err)
+	}
+
+	reader := sdkmetric.NewPeriodicReader(exporter,
+		sdkmetric.WithInterval(interval),
+		sdkmetric.WithProducer(otelprom.NewMetricProducer(otelprom.WithGatherer(openaiGatherer{gatherer: g}))),
+	)
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithResource(res))
+	return provider.Shutdown, nil
+}
+
+// runOTLP starts pushing the metrics of g when -otlp.endpoint is set and returns the function
+// that pushes them a last time on shutdown.
+func runOTLP(g prometheus.Gatherer) func() {
+	if *otlpEndpoint == "" {
+		return func() {}
+	}
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logrus.WithError(err).Warn("Error pushing metrics via OTLP")
+	}))
+	stop, err := startOTLP(*otlpEndpoint, *otlpInterval, g)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	logrus.Infof("Pushing metrics to %s every %s", *otlpEndpoint, *otlpInterval)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+		if err := stop(ctx); err != nil {
+			logrus.WithError(err).Warn("Error pushing metrics via OTLP on shutdown")
+		}
+	}
+}
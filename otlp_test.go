@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestOTLPMetricsURL(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+		wantErr  bool
+	}{
+		{endpoint: "http://collector:4318", want: "http://collector:4318/v1/metrics"},
+		{endpoint: "https://otlp.example.com/otlp/", want: "https://otlp.example.com/otlp/v1/metrics"},
+		{endpoint: "https://otlp.example.com/otlp/v1/metrics", want: "https://otlp.example.com/otlp/v1/metrics"},
+		{endpoint: "collector:4318", wantErr: true},
+		{endpoint: "grpc://collector:4317", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			got, err := otlpMetricsURL(tt.endpoint)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestStartOTLP(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	received := make(map[string]float64)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var req collectorpb.ExportMetricsServiceRequest
+		require.NoError(t, proto.Unmarshal(body, &req))
+
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, r.URL.Path)
+		for _, rm := range req.GetResourceMetrics() {
+			for _, sm := range rm.GetScopeMetrics() {
+				for _, m := range sm.GetMetrics() {
+					received[m.GetName()] = 0
+					for _, dp := range m.GetSum().GetDataPoints() {
+						received[m.GetName()] += dp.GetAsDouble()
+					}
+				}
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	registry := prometheus.NewRegistry()
+	tokens := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "openai_api_tokens_total", Help: "Tokens."}, []string{"model"})
+	registry.MustRegister(tokens, collectors.NewGoCollector())
+	tokens.WithLabelValues("gpt-4").Add(42)
+
+	stop, err := startOTLP(receiver.URL, time.Hour, registry)
+	require.NoError(t, err)
+	require.NoError(t, stop(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"/v1/metrics"}, paths)
+	assert.Equal(t, map[string]float64{"openai_api_tokens_total": 42}, received, "only OpenAI metrics are pushed")
+}
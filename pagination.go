@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Pagination Guard
+
+var (
+	apiMaxPages = flag.Int("api.max-pages", 1000, "Maximum number of pages fetched per listing before paging stops and the result is truncated (0 for no limit)")
+
+	paginationTruncatedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "openai_exporter_pagination_truncated_total",
+			Help: "Total number of listings whose paging was stopped early, by reason (max_pages or repeated_cursor).",
+		},
+		[]string{"org_id", "endpoint", "reason"},
+	)
+)
+
+// pageGuard stops paging through a listing once it reaches api.max-pages or the upstream hands out a cursor
+// it already returned, so a broken or malicious response cannot page forever.
+type pageGuard struct {
+	orgID, endpoint string
+	pages           int
+	seen            map[string]bool
+}
+
+// newPageGuard returns the guard of one listing; the first page is requested without a cursor.
+func newPageGuard(orgID, endpoint string) *pageGuard {
+	return &pageGuard{orgID: orgID, endpoint: endpoint, seen: map[string]bool{"": true}}
+}
+
+// next records a fetched page and reports whether the page at cursor may be fetched next. When it may not,
+// the truncation is logged and counted, and the caller keeps what it fetched so far.
+func (g *pageGuard) next(cursor string) bool {
+	g.pages++
+	var reason string
+	switch {
+	case *apiMaxPages > 0 && g.pages >= *apiMaxPages:
+		reason = "max_pages"
+	case g.seen[cursor]:
+		reason = "repeated_cursor"
+	default:
+		g.seen[cursor] = true
+		return true
+	}
+	logrus.WithFields(logrus.Fields{"org_id": g.orgID, "endpoint": g.endpoint, "pages": g.pages, "reason": reason}).
+		Warn("Stopped paging, the result is truncated")
+	paginationTruncatedTotal.WithLabelValues(g.orgID, g.endpoint, reason).Inc()
+	return false
+}
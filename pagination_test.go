@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageGuard(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxPages int
+		cursors  []string
+		want     []bool
+		reason   string
+	}{
+		{name: "distinct cursors", maxPages: 10, cursors: []string{"a", "b", "c"}, want: []bool{true, true, true}},
+		{name: "repeated cursor", maxPages: 10, cursors: []string{"a", "b", "a"}, want: []bool{true, true, false}, reason: "repeated_cursor"},
+		{name: "cursor of the first page", maxPages: 10, cursors: []string{""}, want: []bool{false}, reason: "repeated_cursor"},
+		{name: "page limit", maxPages: 3, cursors: []string{"a", "b", "c"}, want: []bool{true, true, false}, reason: "max_pages"},
+		{name: "no page limit", maxPages: 0, cursors: []string{"a", "b", "c"}, want: []bool{true, true, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orig := *apiMaxPages
+			defer func() { *apiMaxPages = orig }()
+			*apiMaxPages = tt.maxPages
+			paginationTruncatedTotal.Reset()
+
+			g := newPageGuard("org-1", "completions")
+			var got []bool
+			for _, c := range tt.cursors {
+				got = append(got, g.next(c))
+			}
+			assert.Equal(t, tt.want, got)
+			if tt.reason != "" {
+				assert.Equal(t, 1.0, testutil.ToFloat64(paginationTruncatedTotal.WithLabelValues("org-1", "completions", tt.reason)))
+			} else {
+				assert.Equal(t, 0, testutil.CollectAndCount(paginationTruncatedTotal))
+			}
+		})
+	}
+}
+
+func TestFetchUsageBucketsRepeatedCursor(t *testing.T) {
+	paginationTruncatedTotal.Reset()
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		// Every page claims there is more and points back at the same page.
+		_, _ = fmt.Fprintf(w, `{"object": "page", "data": [{"object": "bucket", "start_time": %d, "end_time": %d, "results": []}], "has_more": true, "next_page": "page_2"}`,
+			requests*60, requests*60+60)
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+
+	buckets, err := e.fetchUsageBuckets(UsageEndpoint{Name: "completions", Path: "completions"}, 0, 3600, "1m", "model")
+	require.NoError(t, err)
+	assert.Equal(t, 2, requests, "paging stops when the cursor repeats")
+	assert.Len(t, buckets, 2)
+	assert.Equal(t, 1.0, testutil.ToFloat64(paginationTruncatedTotal.WithLabelValues("org-1", "completions", "repeated_cursor")))
+}
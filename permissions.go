@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Endpoint Permissions
+
+var (
+	endpointDisableAfter  = flag.Int("endpoint.disable-after", 3, "Consecutive permission errors (401 or 403) after which an endpoint is no longer fetched until it is retried (0 never disables endpoints)")
+	endpointRetryInterval = flag.Duration("endpoint.retry-interval", time.Hour, "Interval at which endpoints disabled after permission errors are tried again")
+
+	endpointEnabled = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_exporter_endpoint_enabled",
+			Help: "Whether an endpoint is fetched (1) or disabled after repeated permission errors (0).",
+		},
+		[]string{"org_id", "endpoint"},
+	)
+)
+
+// endpointPermission counts the consecutive permission errors of an endpoint of an organization.
+type endpointPermission struct {
+	failures int
+	// retryAt is when a disabled endpoint is tried again; it is zero while the endpoint is enabled.
+	retryAt time.Time
+}
+
+var (
+	permissionsMu sync.Mutex
+	// endpointPermissions maps org_id|endpoint to its permission errors.
+	endpointPermissions = make(map[string]*endpointPermission)
+)
+
+// isPermissionError reports whether err is a response rejecting the API key for the endpoint,
+// as when the key is scoped without the permission it needs.
+func isPermissionError(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden)
+}
+
+// recordPermission updates the permission errors of endpoint with the outcome of a fetch. After
+// endpoint.disable-after consecutive permission errors the endpoint is disabled until the retry interval
+// has passed; any other outcome enables it again.
+func recordPermission(orgID, endpoint string, err error, now time.Time) {
+	permissionsMu.Lock()
+	defer permissionsMu.Unlock()
+	key := orgID + "|" + endpoint
+	p, ok := endpointPermissions[key]
+	if !ok {
+		p = &endpointPermission{}
+		endpointPermissions[key] = p
+	}
+
+	if !isPermissionError(err) {
+		if !p.retryAt.IsZero() {
+			logrus.WithFields(logrus.Fields{"org_id": orgID, "endpoint": endpoint}).Info("Endpoint is accessible again, enabling it")
+		}
+		p.failures, p.retryAt = 0, time.Time{}
+		endpointEnabled.WithLabelValues(orgID, endpoint).Set(1)
+		return
+	}
+
+	p.failures++
+	if *endpointDisableAfter <= 0 || p.failures < *endpointDisableAfter {
+		endpointEnabled.WithLabelValues(orgID, endpoint).Set(1)
+		return
+	}
+	if p.retryAt.IsZero() {
+		logrus.WithFields(logrus.Fields{"org_id": orgID, "endpoint": endpoint}).Warnf(
+			"Disabling endpoint after %d permission errors in a row, retrying every %s", p.failures, *endpointRetryInterval)
+	}
+	p.retryAt = now.Add(*endpointRetryInterval)
+	endpointEnabled.WithLabelValues(orgID, endpoint).Set(0)
+}
+
+// fetchAllowed reports whether endpoint of the organization should be fetched at now: it is enabled,
+// or disabled and due to be retried.
+func fetchAllowed(orgID, endpoint string, now time.Time) bool {
+	permissionsMu.Lock()
+	defer permissionsMu.Unlock()
+	p, ok := endpointPermissions[orgID+"|"+endpoint]
+	return !ok || p.retryAt.IsZero() || !now.Before(p.retryAt)
+}
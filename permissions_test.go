@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPermissionError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "no error"},
+		{name: "unauthorized", err: &APIError{StatusCode: 401}, want: true},
+		{name: "forbidden", err: fmt.Errorf("error fetching costs: %w", &APIError{StatusCode: 403}), want: true},
+		{name: "server error", err: &APIError{StatusCode: 500}},
+		{name: "transport error", err: errors.New("connection refused")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isPermissionError(tt.err))
+		})
+	}
+}
+
+func TestRecordPermission(t *testing.T) {
+	endpointPermissions = make(map[string]*endpointPermission)
+	endpointEnabled.Reset()
+	forbidden := &APIError{StatusCode: 403}
+	start := time.Unix(1700000000, 0)
+	enabled := func() float64 { return testutil.ToFloat64(endpointEnabled.WithLabelValues("org-1", "members")) }
+
+	recordPermission("org-1", "members", forbidden, start)
+	recordPermission("org-1", "members", forbidden, start)
+	assert.True(t, fetchAllowed("org-1", "members", start), "enabled below the threshold")
+	assert.Equal(t, 1.0, enabled())
+
+	recordPermission("org-1", "members", forbidden, start)
+	assert.False(t, fetchAllowed("org-1", "members", start.Add(time.Minute)), "disabled at the threshold")
+	assert.True(t, fetchAllowed("org-1", "costs", start), "other endpoints are unaffected")
+	assert.True(t, fetchAllowed("org-2", "members", start), "other organizations are unaffected")
+	assert.Equal(t, 0.0, enabled())
+
+	retry := start.Add(*endpointRetryInterval)
+	assert.True(t, fetchAllowed("org-1", "members", retry), "retried after the interval")
+	recordPermission("org-1", "members", forbidden, retry)
+	assert.False(t, fetchAllowed("org-1", "members", retry.Add(time.Minute)), "disabled again by a failed retry")
+
+	retry = retry.Add(*endpointRetryInterval)
+	recordPermission("org-1", "members", nil, retry)
+	assert.True(t, fetchAllowed("org-1", "members", retry.Add(time.Minute)), "enabled by a successful retry")
+	assert.Equal(t, 1.0, enabled())
+
+	recordPermission("org-1", "members", forbidden, retry)
+	assert.True(t, fetchAllowed("org-1", "members", retry), "failures start counting again")
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collection Worker Pool
+
+var (
+	scrapeWorkers = flag.Int("scrape.workers", 8, "Maximum number of usage, cost and collector fetches that run at the same time, across all organizations")
+
+	workerPoolSize = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "openai_exporter_worker_pool_size",
+			Help: "Number of workers that run collection fetches.",
+		},
+	)
+	workerPoolBusy = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "openai_exporter_worker_pool_busy",
+			Help: "Number of workers currently running a collection fetch.",
+		},
+	)
+)
+
+// collectionPool runs the fetches of all collection cycles.
+var collectionPool = &workerPool{}
+
+// workerPool runs tasks on a fixed number of goroutines, started with the first task.
+// Tasks must not submit further tasks, or they could wait for a worker forever.
+type workerPool struct {
+	once  sync.Once
+	tasks chan func()
+}
+
+func validateWorkers(n int) error {
+	if n < 1 {
+		return fmt.Errorf("scrape.workers must be at least 1, got %d", n)
+	}
+	return nil
+}
+
+// submit hands task to the next free worker, blocking until there is one, and adds it to wg.
+func (p *workerPool) submit(wg *sync.WaitGroup, task func()) {
+	p.once.Do(func() { p.start(max(*scrapeWorkers, 1)) })
+	wg.Add(1)
+	p.tasks <- func() {
+		defer wg.Done()
+		workerPoolBusy.Inc()
+		defer workerPoolBusy.Dec()
+		task()
+	}
+}
+
+// submitAfter submits task once delay has passed and adds it to wg right away. The delay is waited out
+// on a timer, so no worker is held while it runs.
+func (p *workerPool) submitAfter(wg *sync.WaitGroup, delay time.Duration, task func()) {
+	if delay <= 0 {
+		p.submit(wg, task)
+		return
+	}
+	wg.Add(1)
+	time.AfterFunc(delay, func() {
+		defer wg.Done()
+		p.submit(wg, task)
+	})
+}
+
+func (p *workerPool) start(workers int) {
+	p.tasks = make(chan func())
+	for range workers {
+		go func() {
+			for task := range p.tasks {
+				task()
+			}
+		}()
+	}
+	workerPoolSize.Set(float64(workers))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerPool(t *testing.T) {
+	p := &workerPool{}
+	p.once.Do(func() { p.start(2) })
+
+	var wg sync.WaitGroup
+	var running, peak, done atomic.Int32
+	for range 6 {
+		p.submit(&wg, func() {
+			n := running.Add(1)
+			for {
+				old := peak.Load()
+				if n <= old || peak.CompareAndSwap(old, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			running.Add(-1)
+			done.Add(1)
+		})
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(6), done.Load())
+	assert.Equal(t, int32(2), peak.Load(), "no more tasks run at once than there are workers")
+}
+
+func TestWorkerPool_SubmitAfter(t *testing.T) {
+	p := &workerPool{}
+	p.once.Do(func() { p.start(1) })
+
+	var delayed sync.WaitGroup
+	p.submitAfter(&delayed, time.Hour, func() {})
+
+	var wg sync.WaitGroup
+	var ran atomic.Bool
+	p.submitAfter(&wg, 10*time.Millisecond, func() { ran.Store(true) })
+	wg.Wait()
+	assert.True(t, ran.Load(), "a delayed task does not hold the only worker while it waits")
+}
+
+func TestValidateWorkers(t *testing.T) {
+	assert.NoError(t, validateWorkers(1))
+	assert.ErrorContains(t, validateWorkers(0), "scrape.workers must be at least 1")
+}
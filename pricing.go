@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Estimated Token Costs
+
+var (
+	pricingEnabled      = flag.Bool("pricing.enabled", true, "Estimate the cost of token usage from the built-in price table and export it as openai_api_estimated_cost_usd_total")
+	pricingFile         = flag.String("pricing.file", "", "YAML or JSON file of model prices per 1M tokens that override and extend the built-in table, re-read when it changes")
+	pricingPollInterval = flag.Duration("pricing.file.poll-interval", 30*time.Second, "Interval between checks of -pricing.file for changes")
+
+	estimatedCostTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "openai_api_estimated_cost_usd_total",
+			Help: "Estimated cost in USD of the counted tokens, from list prices per model; see openai_api_costs_usd_total for billed amounts",
+		},
+		costLabelNames,
+	)
+	pricingReloadSuccessful = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "openai_exporter_pricing_last_reload_successful",
+			Help: "Whether the last attempt to read the pricing file was successful.",
+		},
+	)
+
+	pricesMu sync.RWMutex
+	// prices is the price table in use: the built-in prices, overridden by the pricing file.
+	prices = defaultPrices
+)
+
+// modelPrice is the list price of a model in USD per 1M tokens.
+type modelPrice struct {
+	Input       float64 `yaml:"input"`
+	CachedInput float64 `yaml:"cached_input"`
+	Output      float64 `yaml:"output"`
+}
+
+// defaultPrices are the list prices of common models. Dated snapshots are priced like the model
+// whose name they start with; models without a price are not estimated.
+var defaultPrices = map[string]modelPrice{
+	"gpt-5":                  {Input: 1.25, CachedInput: 0.125, Output: 10},
+	"gpt-5-mini":             {Input: 0.25, CachedInput: 0.025, Output: 2},
+	"gpt-5-nano":             {Input: 0.05, CachedInput: 0.005, Output: 0.4},
+	"gpt-4.1":                {Input: 2, CachedInput: 0.5, Output: 8},
+	"gpt-4.1-mini":           {Input: 0.4, CachedInput: 0.1, Output: 1.6},
+	"gpt-4.1-nano":           {Input: 0.1, CachedInput: 0.025, Output: 0.4},
+	"gpt-4o":                 {Input: 2.5, CachedInput: 1.25, Output: 10},
+	"gpt-4o-mini":            {Input: 0.15, CachedInput: 0.075, Output: 0.6},
+	"gpt-4-turbo":            {Input: 10, Output: 30},
+	"gpt-4":                  {Input: 30, Output: 60},
+	"gpt-3.5-turbo":          {Input: 0.5, Output: 1.5},
+	"o1":                     {Input: 15, CachedInput: 7.5, Output: 60},
+	"o1-mini":                {Input: 1.1, CachedInput: 0.55, Output: 4.4},
+	"o3":                     {Input: 2, CachedInput: 0.5, Output: 8},
+	"o3-mini":                {Input: 1.1, CachedInput: 0.55, Output: 4.4},
+	"o4-mini":                {Input: 1.1, CachedInput: 0.275, Output: 4.4},
+	"text-embedding-3-small": {Input: 0.02},
+	"text-embedding-3-large": {Input: 0.13},
+	"text-embedding-ada-002": {Input: 0.1},
+}
+
+// priceOf returns the price of model, matching dated snapshots such as gpt-4o-2024-08-06
+// by the longest model name they start with.
+func priceOf(prices map[string]modelPrice, model string) (modelPrice, bool) {
+	if p, ok := prices[model]; ok {
+		return p, true
+	}
+	var best string
+	for name := range prices {
+		if strings.HasPrefix(model, name+"-") && len(name) > len(best) {
+			best = name
+		}
+	}
+	p, ok := prices[best]
+	return p, ok && best != ""
+}
+
+// cost returns the cost in USD of tokens by token_type. Input tokens include the cached ones,
+// which are charged at the cached price when the model has one.
+func (p modelPrice) cost(tokens map[string]float64) float64 {
+	cachedPrice := p.CachedInput
+	if cachedPrice == 0 {
+		cachedPrice = p.Input
+	}
+	uncached := max(tokens["input"]-tokens["input_cached"], 0)
+	return (uncached*p.Input + tokens["input_cached"]*cachedPrice + tokens["output"]*p.Output) / 1e6
+}
+
+// addEstimatedCost adds the estimated cost of the tokens just counted for a usage result. Batch requests
+// are charged half the list price.
+func addEstimatedCost(labels prometheus.Labels, added map[string]float64) {
+	if !*pricingEnabled {
+		return
+	}
+	price, ok := priceOf(currentPrices(), labels["model"])
+	if !ok {
+		return
+	}
+	cost := price.cost(added)
+	if labels["batch"] == "true" {
+		cost /= 2
+	}
+	if cost <= 0 {
+		return
+	}
+	estimatedCostTotal.With(costLabels(labels)).Add(cost)
+}
+
+func currentPrices() map[string]modelPrice {
+	pricesMu.RLock()
+	defer pricesMu.RUnlock()
+	return prices
+}
+
+// parsePrices parses a pricing file, a YAML or JSON map from model name to prices, and merges it
+// over the built-in table.
+func parsePrices(data []byte) (map[string]modelPrice, error) {
+	var overrides map[string]modelPrice
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&overrides); err != nil {
+		return nil, err
+	}
+	merged := maps.Clone(defaultPrices)
+	for model, price := range overrides {
+		if model == "" {
+			return nil, fmt.Errorf("price without a model name")
+		}
+		if price.Input < 0 || price.CachedInput < 0 || price.Output < 0 {
+			return nil, fmt.Errorf("model %s: prices must not be negative", model)
+		}
+		merged[model] = price
+	}
+	return merged, nil
+}
+
+// pricesFile is the pricing file. Like secretFile it is re-read whenever its modification time or size
+// changes; an invalid file keeps the previous prices.
+type pricesFile struct {
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+// loadPricesFile reads the pricing file at path and puts its prices in use.
+func loadPricesFile(path string) (*pricesFile, error) {
+	f := &pricesFile{path: path}
+	if err := f.reload(); err != nil {
+		pricingReloadSuccessful.Set(0)
+		return nil, err
+	}
+	pricingReloadSuccessful.Set(1)
+	return f, nil
+}
+
+// reload reads the file if it changed since it was last read.
+func (f *pricesFile) reload() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return fmt.Errorf("error reading pricing file: %w", err)
+	}
+	if info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return nil
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("error reading pricing file: %w", err)
+	}
+	table, err := parsePrices(data)
+	if err != nil {
+		return fmt.Errorf("invalid pricing file %s: %w", f.path, err)
+	}
+	f.modTime, f.size = info.ModTime(), info.Size()
+
+	pricesMu.Lock()
+	prices = table
+	pricesMu.Unlock()
+	logrus.WithField("models", len(table)).Infof("Loaded prices from %s", f.path)
+	return nil
+}
+
+// watch checks the pricing file for changes every interval until ctx is done.
+func (f *pricesFile) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := f.reload(); err != nil {
+			pricingReloadSuccessful.Set(0)
+			logrus.WithError(err).Error("Error reloading pricing file, keeping the previous prices")
+			continue
+		}
+		pricingReloadSuccessful.Set(1)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriceOf(t *testing.T) {
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{model: "gpt-4o", want: "gpt-4o"},
+		{model: "gpt-4o-2024-08-06", want: "gpt-4o"},
+		{model: "gpt-4o-mini-2024-07-18", want: "gpt-4o-mini"},
+		{model: "gpt-4-0613", want: "gpt-4"},
+		{model: "gpt-4ox", want: ""},
+		{model: "whisper-1", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			price, ok := priceOf(defaultPrices, tt.model)
+			assert.Equal(t, tt.want != "", ok)
+			assert.Equal(t, defaultPrices[tt.want], price)
+		})
+	}
+}
+
+func TestModelPriceCost(t *testing.T) {
+	price := modelPrice{Input: 2.5, CachedInput: 1.25, Output: 10}
+	assert.InDelta(t, 2.875, price.cost(map[string]float64{"input": 1e6, "input_cached": 5e5, "output": 1e5}), 1e-9)
+	assert.InDelta(t, 0.625, price.cost(map[string]float64{"input_cached": 5e5}), 1e-9, "cached tokens counted on their own")
+	assert.InDelta(t, 23.0, modelPrice{Input: 10, Output: 30}.cost(map[string]float64{"input": 2e6, "input_cached": 1e6, "output": 1e5}), 1e-9,
+		"models without a cached price charge cached tokens like input tokens")
+}
+
+func TestFetchUsageData_EstimatedCost(t *testing.T) {
+	usageState = make(map[string]float64)
+	projectNames = map[string]string{"proj-1": "one"}
+	estimatedCostTotal.Reset()
+
+	end := time.Now().Add(-time.Minute).Truncate(time.Minute).Unix()
+	input := "1000000"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"object": "page", "data": [{"start_time": ` + strconv.FormatInt(end-60, 10) + `, "end_time": ` + strconv.FormatInt(end, 10) + `, "results": [
+			{"input_tokens": ` + input + `, "input_cached_tokens": 500000, "output_tokens": 100000, "project_id": "proj-1", "model": "gpt-4o-2024-08-06", "batch": false},
+			{"input_tokens": 1000000, "project_id": "proj-1", "model": "gpt-4o-mini", "batch": true},
+			{"input_tokens": 1000000, "project_id": "proj-1", "model": "ft:custom", "batch": false}
+		]}], "has_more": false}`))
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+	completions := UsageEndpoint{Path: "completions", Name: "completions"}
+	cost := func(model string) float64 {
+		return testutil.ToFloat64(estimatedCostTotal.WithLabelValues("org-1", "prod", "proj-1", "one", model))
+	}
+
+	require.NoError(t, e.fetchUsageData(completions, end-60, end))
+	require.NoError(t, e.fetchUsageData(completions, end-60, end))
+	assert.InDelta(t, 2.875, cost("gpt-4o-2024-08-06"), 1e-9, "buckets seen again add no cost")
+	assert.InDelta(t, 0.075, cost("gpt-4o-mini"), 1e-9, "batch requests cost half")
+	assert.Equal(t, 2, testutil.CollectAndCount(estimatedCostTotal), "models without a price are not estimated")
+
+	input = "1400000"
+	require.NoError(t, e.fetchUsageData(completions, end-60, end))
+	assert.InDelta(t, 3.875, cost("gpt-4o-2024-08-06"), 1e-9, "growth is charged")
+
+	t.Run("disabled", func(t *testing.T) {
+		*pricingEnabled = false
+		defer func() { *pricingEnabled = true }()
+		estimatedCostTotal.Reset()
+		usageState = make(map[string]float64)
+		require.NoError(t, e.fetchUsageData(completions, end-60, end))
+		assert.Equal(t, 0, testutil.CollectAndCount(estimatedCostTotal))
+	})
+}
+
+func TestParsePrices(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    map[string]modelPrice
+		wantErr string
+	}{
+		{
+			name: "yaml",
+			data: "gpt-4o: {input: 2, cached_input: 1, output: 8}\nmy-model:\n  input: 1\n  output: 2\n",
+			want: map[string]modelPrice{"gpt-4o": {Input: 2, CachedInput: 1, Output: 8}, "my-model": {Input: 1, Output: 2}},
+		},
+		{
+			name: "json",
+			data: `{"my-model": {"input": 0.5, "output": 1.5}}`,
+			want: map[string]modelPrice{"gpt-4o": defaultPrices["gpt-4o"], "my-model": {Input: 0.5, Output: 1.5}},
+		},
+		{name: "unknown field", data: "gpt-4o: {input: 2, cache: 1}", wantErr: "field cache not found"},
+		{name: "negative price", data: "gpt-4o: {input: -2}", wantErr: "must not be negative"},
+		{name: "not a map", data: "- gpt-4o", wantErr: "cannot unmarshal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePrices([]byte(tt.data))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			for model, price := range tt.want {
+				assert.Equal(t, price, got[model], model)
+			}
+			assert.Equal(t, defaultPrices["o3"], got["o3"], "built-in prices are kept")
+		})
+	}
+}
+
+func TestPricesFileReload(t *testing.T) {
+	t.Cleanup(func() { prices = defaultPrices })
+	path := filepath.Join(t.TempDir(), "prices.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("gpt-4o: {input: 2}\n"), 0o600))
+
+	f, err := loadPricesFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, modelPrice{Input: 2}, currentPrices()["gpt-4o"])
+	assert.Equal(t, 1.0, testutil.ToFloat64(pricingReloadSuccessful))
+
+	require.NoError(t, os.WriteFile(path, []byte("gpt-4o: {input: 1.5, output: 6}\n"), 0o600))
+	require.NoError(t, f.reload())
+	assert.Equal(t, modelPrice{Input: 1.5, Output: 6}, currentPrices()["gpt-4o"])
+
+	require.NoError(t, os.WriteFile(path, []byte("gpt-4o: {input: -1}\n"), 0o600))
+	assert.ErrorContains(t, f.reload(), "must not be negative")
+	assert.Equal(t, modelPrice{Input: 1.5, Output: 6}, currentPrices()["gpt-4o"], "an invalid file keeps the previous prices")
+
+	_, err = loadPricesFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorContains(t, err, "error reading pricing file")
+	assert.Equal(t, 0.0, testutil.ToFloat64(pricingReloadSuccessful))
+}
+
+func TestPricesFileWatch(t *testing.T) {
+	t.Cleanup(func() { prices = defaultPrices })
+	path := filepath.Join(t.TempDir(), "prices.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("gpt-4o: {input: 2}\n"), 0o600))
+	f, err := loadPricesFile(path)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.watch(ctx, 10*time.Millisecond)
+	}()
+	require.NoError(t, os.WriteFile(path, []byte("gpt-4o: {input: 3}\n"), 0o600))
+	assert.Eventually(t, func() bool { return currentPrices()["gpt-4o"].Input == 3 }, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch did not return after its context was cancelled")
+	}
+}
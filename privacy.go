@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Privacy
+
+var (
+	hashUserIDs   = flag.Bool("privacy.hash-user-ids", false, "Replace user_id with a hash salted with OPENAI_EXPORTER_HASH_SALT and leave user_email empty")
+	hashAPIKeyIDs = flag.Bool("privacy.hash-api-key-ids", false, "Replace api_key_id with a hash salted with OPENAI_EXPORTER_HASH_SALT and leave api_key_name empty")
+	dropUserIDs   = flag.Bool("privacy.drop-user-ids", false, "Stop grouping usage by user, which removes the user_id and user_email labels")
+)
+
+// hashSalt keys the hashes of identifiers. Set at startup.
+var hashSalt []byte
+
+// configurePrivacy reads the salt when identifiers are hashed.
+func configurePrivacy() error {
+	if *dropUserIDs && *hashUserIDs {
+		return fmt.Errorf("-privacy.drop-user-ids and -privacy.hash-user-ids cannot be combined")
+	}
+	if !*hashUserIDs && !*hashAPIKeyIDs {
+		return nil
+	}
+	salt := os.Getenv("OPENAI_EXPORTER_HASH_SALT")
+	if salt == "" {
+		return fmt.Errorf("hashing identifiers needs a secret salt in OPENAI_EXPORTER_HASH_SALT")
+	}
+	hashSalt = []byte(salt)
+	return nil
+}
+
+// privacyGroupBy removes the user dimension from the usage grouping when user IDs are dropped.
+func privacyGroupBy(defaults string, perEndpoint map[string][]string) (string, map[string][]string) {
+	if !*dropUserIDs {
+		return defaults, perEndpoint
+	}
+	isUser := func(dim string) bool { return dim == "user_id" }
+	out := make(map[string][]string, len(perEndpoint))
+	for name, dims := range perEndpoint {
+		out[name] = slices.DeleteFunc(slices.Clone(dims), isUser)
+	}
+	return strings.Join(slices.DeleteFunc(splitList(defaults), isUser), ","), out
+}
+
+// pseudonym returns the salted hash of id. Placeholders for missing and filtered-out IDs are kept.
+func pseudonym(id string) string {
+	if id == "" || id == "unknown" || id == otherUser {
+		return id
+	}
+	mac := hmac.New(sha256.New, hashSalt)
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// privateUserID and privateAPIKeyID return the IDs as they are exported.
+func privateUserID(id string) string {
+	if *hashUserIDs {
+		return pseudonym(id)
+	}
+	return id
+}
+
+func privateAPIKeyID(id string) string {
+	if *hashAPIKeyIDs {
+		return pseudonym(id)
+	}
+	return id
+}
+
+// privateLabels returns labels with the identifiers hashed as configured. The input is never modified.
+func privateLabels(labels prometheus.Labels) prometheus.Labels {
+	if *hashUserIDs {
+		labels = mergeLabels(mergeLabels(labels, "user_id", pseudonym(labels["user_id"])), "user_email", "")
+	}
+	if *hashAPIKeyIDs {
+		labels = mergeLabels(mergeLabels(labels, "api_key_id", pseudonym(labels["api_key_id"])), "api_key_name", "")
+	}
+	return labels
+}
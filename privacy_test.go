@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setPrivacy sets the privacy flags and salt, restoring them when the test ends.
+func setPrivacy(t *testing.T, hashUsers, hashKeys, dropUsers bool) {
+	t.Helper()
+	origUsers, origKeys, origDrop, origSalt := *hashUserIDs, *hashAPIKeyIDs, *dropUserIDs, hashSalt
+	t.Cleanup(func() { *hashUserIDs, *hashAPIKeyIDs, *dropUserIDs, hashSalt = origUsers, origKeys, origDrop, origSalt })
+	*hashUserIDs, *hashAPIKeyIDs, *dropUserIDs = hashUsers, hashKeys, dropUsers
+	t.Setenv("OPENAI_EXPORTER_HASH_SALT", "pepper")
+}
+
+func TestConfigurePrivacy(t *testing.T) {
+	setPrivacy(t, true, false, true)
+	assert.ErrorContains(t, configurePrivacy(), "cannot be combined")
+
+	setPrivacy(t, false, true, false)
+	t.Setenv("OPENAI_EXPORTER_HASH_SALT", "")
+	assert.ErrorContains(t, configurePrivacy(), "OPENAI_EXPORTER_HASH_SALT")
+
+	setPrivacy(t, true, true, false)
+	require.NoError(t, configurePrivacy())
+	assert.Equal(t, []byte("pepper"), hashSalt)
+}
+
+func TestPseudonym(t *testing.T) {
+	setPrivacy(t, true, false, false)
+	require.NoError(t, configurePrivacy())
+
+	hashed := pseudonym("user-123")
+	assert.Len(t, hashed, 16)
+	assert.NotContains(t, hashed, "user-123")
+	assert.Equal(t, hashed, pseudonym("user-123"), "the same ID always gets the same hash")
+	assert.NotEqual(t, hashed, pseudonym("user-124"))
+	for _, placeholder := range []string{"", "unknown", otherUser} {
+		assert.Equal(t, placeholder, pseudonym(placeholder))
+	}
+
+	hashSalt = []byte("salt")
+	assert.NotEqual(t, hashed, pseudonym("user-123"), "the hash depends on the salt")
+}
+
+func TestPrivacyGroupBy(t *testing.T) {
+	setPrivacy(t, false, false, false)
+	defaults, perEndpoint := privacyGroupBy("project_id,user_id,model", map[string][]string{"embeddings": {"user_id", "model"}})
+	assert.Equal(t, "project_id,user_id,model", defaults)
+	assert.Equal(t, []string{"user_id", "model"}, perEndpoint["embeddings"])
+
+	setPrivacy(t, false, false, true)
+	defaults, perEndpoint = privacyGroupBy("project_id,user_id,model", map[string][]string{"embeddings": {"user_id", "model"}})
+	assert.Equal(t, "project_id,model", defaults)
+	assert.Equal(t, map[string][]string{"embeddings": {"model"}}, perEndpoint)
+}
+
+func TestUpdateMetric_HashedIDs(t *testing.T) {
+	setPrivacy(t, true, true, false)
+	require.NoError(t, configurePrivacy())
+	usageState = make(map[string]float64)
+	tokensTotal.Reset()
+
+	end := time.Now().Add(-time.Minute).Unix()
+	updateMetric(prometheus.Labels{
+		"org_id": "org-1", "org_name": "prod", "model": "gpt-4o", "operation": "completions",
+		"project_id": "proj-1", "project_name": "one", "user_id": "user-1", "user_email": "ana@example.com",
+		"api_key_id": "key-1", "api_key_name": "ana's key", "batch": "false",
+	}, "input", end-60, end, 100)
+
+	assert.Equal(t, 100.0, testutil.ToFloat64(tokensTotal.WithLabelValues("org-1", "prod", "gpt-4o", "completions", "proj-1", "one",
+		pseudonym("user-1"), "", pseudonym("key-1"), "", "false", "input")))
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Window Progress
+
+// windowBacklog maps org_id|endpoint -> start of the oldest window the endpoint failed to fetch, guarded by
+// stateMu. The window advances for every endpoint after each cycle; an endpoint that failed fetches again
+// from its backlog in later cycles until a fetch succeeds, and the deduplication state keeps the buckets
+// it already counted from being counted twice.
+var windowBacklog = make(map[string]int64)
+
+// fetchStart returns where endpoint of the organization starts fetching a window beginning at startTime:
+// at its backlog, if it failed earlier windows, but no earlier than the buckets still remembered by the
+// deduplication state.
+func fetchStart(orgID, endpoint string, startTime int64, now time.Time) int64 {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	key := orgID + "|" + endpoint
+	backlog, ok := windowBacklog[key]
+	if !ok || backlog >= startTime {
+		return startTime
+	}
+	if *stateRetention > 0 {
+		// Buckets before the cutoff have been pruned, so fetching them again would count them twice.
+		if cutoff := now.Add(-*stateRetention).Truncate(time.Minute).Add(time.Minute).Unix(); backlog < cutoff {
+			logrus.WithFields(windowFields(orgID, endpoint, backlog, cutoff)).Warn("Giving up on usage older than the state retention")
+			backlog = min(cutoff, startTime)
+			windowBacklog[key] = backlog
+		}
+	}
+	return backlog
+}
+
+// recordWindow records the outcome of fetching the window of endpoint that started at startTime: a failed
+// window joins the backlog, and a successful fetch clears it.
+func recordWindow(orgID, endpoint string, startTime int64, err error) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	key := orgID + "|" + endpoint
+	if err == nil {
+		delete(windowBacklog, key)
+		return
+	}
+	if backlog, ok := windowBacklog[key]; !ok || startTime < backlog {
+		windowBacklog[key] = startTime
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchStart(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	minute := int64(60)
+	start := now.Truncate(time.Minute).Unix() - minute
+
+	tests := []struct {
+		name    string
+		backlog map[string]int64
+		want    int64
+	}{
+		{name: "no backlog", want: start},
+		{name: "failed earlier window", backlog: map[string]int64{"org-1|completions": start - 5*minute}, want: start - 5*minute},
+		{name: "other endpoint failed", backlog: map[string]int64{"org-1|embeddings": start - 5*minute}, want: start},
+		{name: "other organization failed", backlog: map[string]int64{"org-2|completions": start - 5*minute}, want: start},
+		{name: "older than the retention", backlog: map[string]int64{"org-1|completions": start - 72*60*minute}, want: now.Add(-48*time.Hour).Truncate(time.Minute).Unix() + minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			windowBacklog = make(map[string]int64)
+			defer func() { windowBacklog = make(map[string]int64) }()
+			for k, v := range tt.backlog {
+				windowBacklog[k] = v
+			}
+			assert.Equal(t, tt.want, fetchStart("org-1", "completions", start, now))
+		})
+	}
+}
+
+func TestRecordWindow(t *testing.T) {
+	windowBacklog = make(map[string]int64)
+	defer func() { windowBacklog = make(map[string]int64) }()
+	now := time.Unix(1700000000, 0)
+	start := now.Truncate(time.Minute).Unix() - 180
+	fetchErr := errors.New("server error")
+
+	recordWindow("org-1", "costs", start, fetchErr)
+	recordWindow("org-1", "costs", start+60, fetchErr)
+	assert.Equal(t, start, fetchStart("org-1", "costs", start+120, now), "the oldest failed window is kept")
+
+	recordWindow("org-1", "costs", start, nil)
+	assert.Equal(t, start+120, fetchStart("org-1", "costs", start+120, now), "a successful fetch clears the backlog")
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Project Name Cache
+
+var (
+	projectNameTTL         = flag.Duration("openai.project-name-ttl", time.Hour, "Interval at which the project list is re-read to pick up renamed and new projects (0 disables the refresh)")
+	projectNameNegativeTTL = flag.Duration("openai.project-name-negative-ttl", 10*time.Minute, "How long a failed project name lookup is remembered before the project is looked up again")
+)
+
+var (
+	// projectNamesRefreshed maps org_id -> time of the last successful refresh of its project names.
+	projectNamesRefreshed = make(map[string]time.Time)
+	// projectLookupFailures maps project_id -> time of the last failed name lookup.
+	projectLookupFailures = make(map[string]time.Time)
+)
+
+// projectNamesDue reports whether the project names of the organization should be refreshed.
+func (e *Exporter) projectNamesDue(now time.Time) bool {
+	if *projectNameTTL <= 0 {
+		return false
+	}
+	stateMu.RLock()
+	defer stateMu.RUnlock()
+	return now.Sub(projectNamesRefreshed[e.orgID]) >= *projectNameTTL
+}
+
+// refreshProjectNames re-reads the names of all projects of the organization.
+func (e *Exporter) refreshProjectNames() error {
+	projects, err := e.fetchProjects()
+	if err != nil {
+		return err
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	e.rememberProjectNames(projects, time.Now())
+	logrus.Debugf("Refreshed the names of %d projects of organization %s", len(projects), e.orgID)
+	return nil
+}
+
+// rememberProjectNames stores the names of a complete project list of the organization. Callers hold stateMu.
+func (e *Exporter) rememberProjectNames(projects []ProjectInfo, now time.Time) {
+	for _, p := range projects {
+		if p.Name != "" {
+			projectNames[p.ID] = p.Name
+			delete(projectLookupFailures, p.ID)
+		}
+	}
+	projectNamesRefreshed[e.orgID] = now
+}
+
+// projectLookupFailedRecently reports whether looking up the name of projectID failed within the negative TTL.
+func projectLookupFailedRecently(projectID string, now time.Time) bool {
+	stateMu.RLock()
+	defer stateMu.RUnlock()
+	failedAt, ok := projectLookupFailures[projectID]
+	return ok && now.Sub(failedAt) < *projectNameNegativeTTL
+}
+
+// recordProjectLookupFailure remembers that the name of projectID could not be resolved.
+func recordProjectLookupFailure(projectID string, now time.Time) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	projectLookupFailures[projectID] = now
+}
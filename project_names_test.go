@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshProjectNames(t *testing.T) {
+	projectNames = map[string]string{"proj-1": "old name"}
+	projectNamesRefreshed = make(map[string]time.Time)
+	projectLookupFailures = map[string]time.Time{"proj-2": time.Now()}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/organization/projects", r.URL.Path)
+		_, _ = w.Write([]byte(`{"object": "list", "data": [
+			{"id": "proj-1", "name": "new name", "status": "active"},
+			{"id": "proj-2", "name": "created later", "status": "active"}
+		], "has_more": false}`))
+	}))
+	defer server.Close()
+
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", targets: newAPITargets("org-1", server.URL, 3)}
+	now := time.Now()
+	assert.True(t, e.projectNamesDue(now))
+
+	require.NoError(t, e.refreshProjectNames())
+	assert.Equal(t, map[string]string{"proj-1": "new name", "proj-2": "created later"}, projectNames)
+	assert.Empty(t, projectLookupFailures, "refreshed projects are looked up again")
+	assert.False(t, e.projectNamesDue(now))
+	assert.True(t, e.projectNamesDue(time.Now().Add(*projectNameTTL)))
+
+	orig := *projectNameTTL
+	*projectNameTTL = 0
+	defer func() { *projectNameTTL = orig }()
+	assert.False(t, e.projectNamesDue(now.Add(24*time.Hour)), "a TTL of 0 disables the refresh")
+}
+
+func TestEnsureProjectName_NegativeCache(t *testing.T) {
+	projectNames = make(map[string]string)
+	projectLookupFailures = make(map[string]time.Time)
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if strings.HasSuffix(r.URL.Path, "/proj-gone") {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error": {"message": "not found"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id": "proj-1", "name": "one"}`))
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", targets: newAPITargets("", server.URL, 3)}
+
+	assert.Equal(t, "unknown", e.ensureProjectName("proj-gone"))
+	assert.Equal(t, "unknown", e.ensureProjectName("proj-gone"))
+	assert.Equal(t, 1, requests, "failed lookups are not retried within the negative TTL")
+
+	projectLookupFailures["proj-gone"] = time.Now().Add(-*projectNameNegativeTTL)
+	assert.Equal(t, "unknown", e.ensureProjectName("proj-gone"))
+	assert.Equal(t, 2, requests)
+
+	assert.Equal(t, "one", e.ensureProjectName("proj-1"))
+	assert.Equal(t, 3, requests)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Project Lifecycle Events
+
+var (
+	projectLifecycleEnabled = flag.Bool("collector.project-lifecycle", false, "Diff the organization's project list every cycle and count created, archived and deleted projects")
+
+	projectLifecycleEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "openai_project_lifecycle_events_total",
+			Help: "Number of project lifecycle events (created, archived, unarchived, deleted) observed in the organization's project list.",
+		},
+		[]string{"org_id", "org_name", "action"},
+	)
+)
+
+var (
+	// knownProjects maps org_id -> project_id -> status as of the last project list of each organization.
+	// An organization is missing until its first list succeeds.
+	knownProjects = make(map[string]map[string]string)
+)
+
+type ProjectList struct {
+	Object  string        `json:"object"`
+	Data    []ProjectInfo `json:"data"`
+	HasMore bool          `json:"has_more"`
+	LastID  string        `json:"last_id"`
+}
+
+type ProjectInfo struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// fetchProjects lists all projects of the organization, including archived ones.
+func (e *Exporter) fetchProjects() ([]ProjectInfo, error) {
+	var projects []ProjectInfo
+	after := ""
+	guard := newPageGuard(e.orgID, "projects")
+
+	for {
+		path := "/v1/organization/projects?limit=100&include_archived=true"
+		if after != "" {
+			path += "&after=" + url.QueryEscape(after)
+		}
+
+		logrus.Debugf("Fetching projects: %s", path)
+
+		resp, err := e.get(path)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching projects: %w", err)
+		}
+
+		var out ProjectList
+		decodeErr := json.NewDecoder(resp.Body).Decode(&out)
+		closeErr := resp.Body.Close()
+
+		if decodeErr != nil {
+			return nil, fmt.Errorf("error decoding response: %w", decodeErr)
+		}
+		if closeErr != nil {
+			logrus.WithError(closeErr).Warn("failed to close response body")
+		}
+
+		pagesFetchedTotal.WithLabelValues(e.orgID, "projects").Inc()
+
+		projects = append(projects, out.Data...)
+		if !out.HasMore || out.LastID == "" || !guard.next(out.LastID) {
+			return projects, nil
+		}
+		after = out.LastID
+	}
+}
+
+// trackProjectLifecycle compares the current project list with the previous one and counts the differences.
+// The first successful list only establishes the baseline. Project names are refreshed as a side effect.
+func (e *Exporter) trackProjectLifecycle() error {
+	projects, err := e.fetchProjects()
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string]string, len(projects))
+	for _, p := range projects {
+		current[p.ID] = p.Status
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	e.rememberProjectNames(projects, time.Now())
+
+	if known, ok := knownProjects[e.orgID]; ok {
+		for id, status := range current {
+			previous, existed := known[id]
+			switch {
+			case !existed:
+				e.recordProjectEvent("created", id)
+				if status == "archived" {
+					e.recordProjectEvent("archived", id)
+				}
+			case previous != "archived" && status == "archived":
+				e.recordProjectEvent("archived", id)
+			case previous == "archived" && status != "archived":
+				e.recordProjectEvent("unarchived", id)
+			}
+		}
+		for id := range known {
+			if _, ok := current[id]; !ok {
+				e.recordProjectEvent("deleted", id)
+			}
+		}
+	}
+	knownProjects[e.orgID] = current
+	return nil
+}
+
+func (e *Exporter) recordProjectEvent(action, projectID string) {
+	logrus.Infof("Project %s of organization %s %s", projectID, e.orgID, action)
+	projectLifecycleEvents.WithLabelValues(e.orgID, e.orgName, action).Inc()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackProjectLifecycle(t *testing.T) {
+	knownProjects = make(map[string]map[string]string)
+	projectNames = make(map[string]string)
+	projectLifecycleEvents.Reset()
+
+	var projects []ProjectInfo
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/organization/projects", r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("include_archived"))
+		// Serve one project per page to exercise pagination.
+		idx := 0
+		if after := r.URL.Query().Get("after"); after != "" {
+			for i, p := range projects {
+				if p.ID == after {
+					idx = i + 1
+				}
+			}
+		}
+		out := ProjectList{Object: "list"}
+		if idx < len(projects) {
+			out.Data = projects[idx : idx+1]
+			out.LastID = projects[idx].ID
+			out.HasMore = idx+1 < len(projects)
+		}
+		_ = json.NewEncoder(w).Encode(out)
+	}))
+	defer server.Close()
+
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("", server.URL, 3)}
+	count := func(action string) float64 {
+		return testutil.ToFloat64(projectLifecycleEvents.WithLabelValues("org-1", "prod", action))
+	}
+
+	projects = []ProjectInfo{
+		{ID: "proj-a", Name: "alpha", Status: "active"},
+		{ID: "proj-b", Name: "beta", Status: "active"},
+	}
+	require.NoError(t, e.trackProjectLifecycle())
+	assert.Equal(t, 0.0, count("created"), "first list is only a baseline")
+	assert.Equal(t, "alpha", projectNames["proj-a"])
+
+	projects = []ProjectInfo{
+		{ID: "proj-a", Name: "alpha", Status: "archived"},
+		{ID: "proj-c", Name: "gamma", Status: "active"},
+	}
+	require.NoError(t, e.trackProjectLifecycle())
+	assert.Equal(t, 1.0, count("created"))
+	assert.Equal(t, 1.0, count("archived"))
+	assert.Equal(t, 1.0, count("deleted"))
+
+	projects = []ProjectInfo{
+		{ID: "proj-a", Name: "alpha", Status: "active"},
+		{ID: "proj-c", Name: "gamma", Status: "active"},
+	}
+	require.NoError(t, e.trackProjectLifecycle())
+	assert.Equal(t, 1.0, count("unarchived"))
+	assert.Equal(t, 1.0, count("created"))
+
+	t.Run("organizations are tracked separately", func(t *testing.T) {
+		other := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-2", orgName: "staging", targets: newAPITargets("", server.URL, 3)}
+		require.NoError(t, other.trackProjectLifecycle())
+		assert.Equal(t, 0.0, testutil.ToFloat64(projectLifecycleEvents.WithLabelValues("org-2", "staging", "created")), "first list is only a baseline")
+
+		require.NoError(t, e.trackProjectLifecycle())
+		assert.Equal(t, 1.0, count("created"))
+		assert.Equal(t, 1.0, count("deleted"))
+	})
+}
+
+func TestTrackProjectLifecycle_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("invalid json"))
+	}))
+	defer server.Close()
+
+	e := &Exporter{client: server.Client(), apiKey: "test", targets: newAPITargets("", server.URL, 3)}
+	assert.Error(t, e.trackProjectLifecycle())
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Outbound Proxy
+
+var proxyURL = flag.String("openai.proxy-url", "", "Proxy for all API requests, e.g. http://proxy.internal:3128; NO_PROXY still applies. Without it, HTTPS_PROXY, HTTP_PROXY and NO_PROXY are honored")
+
+// apiProxy returns the proxy function of API clients: the one of -openai.proxy-url when set,
+// and the one of the environment otherwise.
+func apiProxy() (func(*http.Request) (*url.URL, error), error) {
+	if *proxyURL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	u, err := url.Parse(*proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -openai.proxy-url: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid -openai.proxy-url: unsupported scheme %q, use http, https or socks5", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid -openai.proxy-url: missing host")
+	}
+
+	cfg := httpproxy.Config{HTTPProxy: *proxyURL, HTTPSProxy: *proxyURL, NoProxy: os.Getenv("NO_PROXY")}
+	if cfg.NoProxy == "" {
+		cfg.NoProxy = os.Getenv("no_proxy")
+	}
+	proxy := cfg.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}, nil
+}
+
+// newAPIClient returns the HTTP client for requests to an API, routed through the configured proxy,
+// using the configured CAs and client certificate, and sending the configured headers.
+func newAPIClient(timeout time.Duration) (*http.Client, error) {
+	proxy, err := apiProxy()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := apiTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	tuneTransport(transport)
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &http.Client{Timeout: timeout, Transport: withHeaders(tapeTransport(transport))}, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAPIClient_Proxy(t *testing.T) {
+	orig := *proxyURL
+	defer func() { *proxyURL = orig }()
+
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxy.Close()
+
+	t.Run("requests go through the proxy", func(t *testing.T) {
+		*proxyURL = proxy.URL
+		client, err := newAPIClient(time.Second)
+		require.NoError(t, err)
+
+		resp, err := client.Get("http://api.openai.example/v1/organization/usage/completions")
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, []string{"http://api.openai.example/v1/organization/usage/completions"}, proxied)
+	})
+
+	t.Run("NO_PROXY applies", func(t *testing.T) {
+		t.Setenv("NO_PROXY", ".internal")
+		*proxyURL = proxy.URL
+		p, err := apiProxy()
+		require.NoError(t, err)
+
+		req, _ := http.NewRequest("GET", "https://gateway.internal/v1/models", nil)
+		u, err := p(req)
+		require.NoError(t, err)
+		assert.Nil(t, u)
+
+		req, _ = http.NewRequest("GET", "https://api.openai.com/v1/models", nil)
+		u, err = p(req)
+		require.NoError(t, err)
+		assert.Equal(t, proxy.URL, u.String())
+	})
+
+	for _, invalid := range []string{"ftp://proxy:21", "http://", "://proxy"} {
+		t.Run("invalid "+invalid, func(t *testing.T) {
+			*proxyURL = invalid
+			_, err := newAPIClient(time.Second)
+			assert.ErrorContains(t, err, "-openai.proxy-url")
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+)
+
+// Pull-Based Collection
+
+var (
+	scrapeLookback = flag.Duration("scrape.lookback", 0, "How far before the start of each window buckets are queried again, so usage that arrives late is still counted")
+	scrapeMode     = flag.String("scrape.mode", "pull", "When usage is fetched: pull (when /metrics is scraped, at most once per scrape.interval) or loop (in the background every scrape.interval)")
+
+	exporterUp = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "openai_exporter_up",
+			Help: "Whether the last collection cycle fetched all data successfully (1) or not (0).",
+		},
+	)
+	scrapeDuration = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "openai_exporter_scrape_duration_seconds",
+			Help: "Duration of the last collection cycle in seconds.",
+		},
+	)
+)
+
+// runCycle collects one window, extended backwards by the lookback, records its outcome,
+// advances lastScrape to endTime and prunes old buckets.
+func runCycle(c windowCollector, startTime, endTime int64) error {
+	logrus.WithFields(logrus.Fields{"window_start": startTime, "window_end": endTime}).Info("Starting collection cycle")
+
+	began := time.Now()
+	err := c.collectWindow(windowStart(startTime), endTime)
+	scrapeDuration.Set(time.Since(began).Seconds())
+	evaluateAnomalies(time.Duration(endTime-startTime) * time.Second)
+	if err != nil {
+		exporterUp.Set(0)
+	} else {
+		exporterUp.Set(1)
+	}
+
+	stateMu.Lock()
+	lastScrape = endTime
+	stateMu.Unlock()
+	pruneState(time.Now())
+	if err := flushHistory(time.Now()); err != nil {
+		logrus.WithError(err).Error("Error writing usage history")
+	}
+
+	if *stateFile != "" {
+		if err := checkpointState(*stateFile, *stateCheckpointInterval, time.Now()); err != nil {
+			logrus.WithError(err).Error("Error checkpointing state")
+		}
+	}
+
+	if *heartbeatURL != "" && err == nil {
+		if err := sendHeartbeat(*heartbeatURL); err != nil {
+			logrus.WithError(err).Warn("Error sending heartbeat")
+		}
+	}
+	if err == nil {
+		setReady()
+		notifySystemdWatchdog()
+	}
+	return err
+}
+
+// pullGatherer collects everything since the previous cycle before gathering, so usage is fetched when
+// Prometheus scrapes. Cycles run at most once per scrape interval; scrapes in between see the cached values.
+type pullGatherer struct {
+	mu       sync.Mutex
+	source   windowCollector
+	gatherer prometheus.Gatherer
+	lastRun  time.Time
+}
+
+func (p *pullGatherer) Gather() ([]*dto.MetricFamily, error) {
+	p.refresh(time.Now())
+	return p.gatherer.Gather()
+}
+
+// refresh runs a cycle up to the last full minute before now unless one ran within the scrape interval.
+// Concurrent scrapes wait for the running cycle and then share its result.
+func (p *pullGatherer) refresh(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if now.Sub(p.lastRun) < currentScrapeInterval() {
+		return
+	}
+
+	stateMu.RLock()
+	startTime := lastScrape
+	stateMu.RUnlock()
+
+	endTime := now.Truncate(time.Minute).Unix()
+	if endTime <= startTime {
+		return
+	}
+	p.lastRun = now
+	// Failures are reported through openai_exporter_up, so the scrape itself still succeeds.
+	_ = runCycle(p.source, startTime, endTime)
+}
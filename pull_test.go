@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCollector records the windows it is asked to collect.
+type fakeCollector struct {
+	windows [][2]int64
+	err     error
+}
+
+func (f *fakeCollector) collectWindow(startTime, endTime int64) error {
+	f.windows = append(f.windows, [2]int64{startTime, endTime})
+	return f.err
+}
+
+func TestRunCycle(t *testing.T) {
+	origLast := lastScrape
+	defer func() { lastScrape = origLast }()
+
+	tests := []struct {
+		name   string
+		err    error
+		wantUp float64
+	}{
+		{name: "successful cycle", wantUp: 1},
+		{name: "failed cycle", err: errors.New("boom"), wantUp: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lastScrape = 1000
+			c := &fakeCollector{err: tt.err}
+
+			err := runCycle(c, 1000, 1060)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, [][2]int64{{1000, 1060}}, c.windows)
+			assert.Equal(t, tt.wantUp, testutil.ToFloat64(exporterUp))
+			assert.Equal(t, int64(1060), lastScrape, "the window advances even when it fails")
+		})
+	}
+
+	t.Run("lookback", func(t *testing.T) {
+		orig := *scrapeLookback
+		*scrapeLookback = 10 * time.Minute
+		defer func() { *scrapeLookback = orig }()
+		c := &fakeCollector{}
+
+		require.NoError(t, runCycle(c, 1000, 1060))
+		assert.Equal(t, [][2]int64{{400, 1060}}, c.windows)
+		assert.Equal(t, int64(1060), lastScrape)
+	})
+}
+
+func TestPullGatherer(t *testing.T) {
+	origLast, origInterval := lastScrape, *scrapeInterval
+	defer func() { lastScrape, *scrapeInterval = origLast, origInterval }()
+
+	*scrapeInterval = time.Minute
+	now := time.Unix(10_000_000, 0).Truncate(time.Minute).Add(15 * time.Second)
+	lastScrape = now.Add(-3 * time.Minute).Truncate(time.Minute).Unix()
+	first := lastScrape
+
+	reg := prometheus.NewRegistry()
+	require.NoError(t, reg.Register(exporterUp))
+	c := &fakeCollector{}
+	p := &pullGatherer{source: c, gatherer: reg}
+
+	p.refresh(now)
+	require.Len(t, c.windows, 1)
+	assert.Equal(t, [2]int64{first, now.Truncate(time.Minute).Unix()}, c.windows[0], "collects up to the last full minute")
+
+	p.refresh(now.Add(30 * time.Second))
+	assert.Len(t, c.windows, 1, "scrapes within the interval are served from cache")
+
+	p.refresh(now.Add(90 * time.Second))
+	require.Len(t, c.windows, 2)
+	assert.Equal(t, [2]int64{c.windows[0][1], c.windows[0][1] + 60}, c.windows[1], "continues where the previous cycle ended")
+
+	families, err := p.Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 1)
+	assert.Equal(t, "openai_exporter_up", families[0].GetName())
+}
+
+func TestNextCycleDelay(t *testing.T) {
+	origLast := lastScrape
+	defer func() { lastScrape = origLast }()
+	// 20 seconds past a full minute.
+	now := time.Unix(1_700_000_000, 0)
+
+	tests := []struct {
+		name       string
+		lastScrape time.Time
+		phase      time.Duration
+		want       time.Duration
+	}{
+		{name: "caught up", lastScrape: now.Truncate(time.Minute), want: 40 * time.Second},
+		{name: "caught up with phase", lastScrape: now.Truncate(time.Minute), phase: 5 * time.Second, want: 45 * time.Second},
+		{name: "next window still open", lastScrape: now.Truncate(time.Minute).Add(-30 * time.Second), want: 10 * time.Second},
+		{name: "next window just over", lastScrape: now.Truncate(time.Minute).Add(-time.Minute), want: 0},
+		{name: "waits for the phase", lastScrape: now.Truncate(time.Minute).Add(-time.Minute), phase: 30 * time.Second, want: 10 * time.Second},
+		{name: "down for an hour", lastScrape: now.Add(-time.Hour), phase: 30 * time.Second, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lastScrape = tt.lastScrape.Unix()
+			assert.Equal(t, tt.want, nextCycleDelay(time.Minute, tt.phase, now))
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/sirupsen/logrus"
+)
+
+// Pushgateway Output
+
+var (
+	pushgatewayURL = flag.String("pushgateway.url", "", "Push the metrics to this Prometheus Pushgateway; implies -once")
+	pushgatewayJob = flag.String("pushgateway.job", "openai_exporter", "Job label the metrics are pushed under")
+)
+
+// pushMetrics replaces the metrics of job on the Pushgateway at rawURL with the OpenAI metrics of g.
+// Credentials in the URL are sent as basic auth.
+func pushMetrics(rawURL, job string, g prometheus.Gatherer) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid -pushgateway.url: %w", err)
+	}
+	user := u.User
+	u.User = nil
+
+	pusher := push.New(u.String(), job).Gatherer(openaiGatherer{gatherer: g})
+	if user != nil {
+		password, _ := user.Password()
+		pusher = pusher.BasicAuth(user.Username(), password)
+	}
+	if err := pusher.Push(); err != nil {
+		return fmt.Errorf("error pushing to Pushgateway: %w", err)
+	}
+	logrus.Infof("Pushed metrics to %s as job %s", u, job)
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunOnce_Pushgateway(t *testing.T) {
+	origURL, origLast := *pushgatewayURL, lastScrape
+	defer func() { *pushgatewayURL, lastScrape = origURL, origLast }()
+
+	var method, path, user, password string
+	var body []byte
+	status := http.StatusOK
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		user, password, _ = r.BasicAuth()
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer gateway.Close()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(exporterUp, collectors.NewGoCollector())
+	defer registry.Unregister(exporterUp)
+
+	tests := []struct {
+		name       string
+		url        string
+		collectErr error
+		status     int
+		wantUser   string
+		wantErr    bool
+	}{
+		{name: "pushed", url: gateway.URL},
+		{name: "basic auth from the URL", url: "http://cron:secret@" + gateway.Listener.Addr().String(), wantUser: "cron"},
+		{name: "failed collection is pushed and reported", url: gateway.URL, collectErr: errors.New("boom"), wantErr: true},
+		{name: "rejected push", url: gateway.URL, status: http.StatusBadRequest, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*pushgatewayURL = tt.url
+			status = http.StatusOK
+			if tt.status != 0 {
+				status = tt.status
+			}
+			start := time.Now().Add(-time.Hour).Truncate(time.Minute).Unix()
+			lastScrape = start
+			c := &fakeCollector{err: tt.collectErr}
+
+			err := runOnce(c, registry, io.Discard)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Len(t, c.windows, 1)
+			assert.Equal(t, start, c.windows[0][0], "the window starts at the previous run")
+			assert.Equal(t, http.MethodPut, method)
+			assert.Equal(t, "/metrics/job/openai_exporter", path)
+			assert.Equal(t, tt.wantUser, user)
+			if tt.wantUser != "" {
+				assert.Equal(t, "secret", password)
+			}
+			assert.Contains(t, string(body), "openai_exporter_up")
+			assert.NotContains(t, string(body), "go_goroutines")
+		})
+	}
+}
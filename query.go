@@ -0,0 +1,321 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Ad-Hoc Usage Queries
+
+// queryDimensions are the dimensions a query report can be grouped by, per report.
+var queryDimensions = map[string][]string{
+	"usage": {"org_id", "operation", "project_id", "user_id", "api_key_id", "model", "batch", "service_tier"},
+	"costs": {"org_id", "project_id", "line_item"},
+}
+
+// queryColumns are the summed value columns, per report.
+var queryColumns = map[string][]string{
+	"usage": {"input_tokens", "output_tokens", "input_cached_tokens", "input_audio_tokens", "output_audio_tokens", "requests"},
+	"costs": {"amount"},
+}
+
+// queryOptions are the flags of the query command.
+type queryOptions struct {
+	report  string
+	from    time.Time
+	to      time.Time
+	groupBy []string
+	format  string
+}
+
+// queryReport is the aggregated result of a query: one row per combination of the grouped dimensions.
+type queryReport struct {
+	// Labels are the dimension columns, including the names looked up for IDs.
+	Labels  []string
+	Columns []string
+	rows    map[string]*queryRow
+}
+
+type queryRow struct {
+	Labels map[string]string
+	Values []float64
+}
+
+func newQueryReport(opts queryOptions) *queryReport {
+	r := &queryReport{Columns: queryColumns[opts.report], rows: make(map[string]*queryRow)}
+	for _, dim := range opts.groupBy {
+		if names, ok := groupByLabels[dim]; ok {
+			r.Labels = append(r.Labels, names...)
+		} else {
+			r.Labels = append(r.Labels, dim)
+		}
+	}
+	if opts.report == "costs" {
+		r.Labels = append(r.Labels, "currency")
+	}
+	return r
+}
+
+// add sums values into the row of labels, leaving out labels the report is not grouped by.
+func (r *queryReport) add(labels map[string]string, values ...float64) {
+	parts := make([]string, len(r.Labels))
+	for i, name := range r.Labels {
+		parts[i] = labels[name]
+	}
+	key := strings.Join(parts, "\x00")
+	row, ok := r.rows[key]
+	if !ok {
+		row = &queryRow{Labels: make(map[string]string, len(r.Labels)), Values: make([]float64, len(r.Columns))}
+		for i, name := range r.Labels {
+			row.Labels[name] = parts[i]
+		}
+		r.rows[key] = row
+	}
+	for i, v := range values {
+		row.Values[i] += v
+	}
+}
+
+// sortedRows returns the rows ordered by their labels.
+func (r *queryReport) sortedRows() []*queryRow {
+	var rows []*queryRow
+	for _, key := range sortedKeys(r.rows) {
+		rows = append(rows, r.rows[key])
+	}
+	return rows
+}
+
+// parseQueryOptions parses the query flags and the exporter flags in args. Exporter flags are set on the
+// global flag set, so they take precedence over the configuration file like on the serve command line.
+func parseQueryOptions(args []string) (queryOptions, error) {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	report := fs.String("report", "usage", "Report to print: usage (tokens and requests) or costs")
+	from := fs.String("from", "", "Start of the queried period, as RFC 3339 time or date (default: 7 days before -to)")
+	to := fs.String("to", "", "End of the queried period, as RFC 3339 time or date, which includes that day (default: now)")
+	groupBy := fs.String("group-by", "project_id,model", "Comma-separated dimensions to group by; usage: "+strings.Join(queryDimensions["usage"], ", ")+"; costs: "+strings.Join(queryDimensions["costs"], ", "))
+	format := fs.String("format", "table", "Output format: table, json or csv")
+	flag.VisitAll(func(f *flag.Flag) { fs.Var(f.Value, f.Name, f.Usage) })
+	if err := fs.Parse(args); err != nil {
+		return queryOptions{}, err
+	}
+	var errs []error
+	fs.Visit(func(f *flag.Flag) {
+		if flag.Lookup(f.Name) != nil {
+			errs = append(errs, flag.Set(f.Name, f.Value.String()))
+		}
+	})
+	if err := errors.Join(errs...); err != nil {
+		return queryOptions{}, err
+	}
+
+	opts := queryOptions{report: *report, groupBy: splitList(*groupBy), format: *format, to: time.Now()}
+	allowed, ok := queryDimensions[opts.report]
+	if !ok {
+		return queryOptions{}, fmt.Errorf("unknown report %q, use usage or costs", opts.report)
+	}
+	for _, dim := range opts.groupBy {
+		if !slices.Contains(allowed, dim) {
+			return queryOptions{}, fmt.Errorf("cannot group the %s report by %q, use %s", opts.report, dim, strings.Join(allowed, ", "))
+		}
+	}
+	if opts.format != "table" && opts.format != "json" && opts.format != "csv" {
+		return queryOptions{}, fmt.Errorf("unknown format %q, use table, json or csv", opts.format)
+	}
+	if *to != "" {
+		t, err := parseWindowTime(*to)
+		if err != nil {
+			return queryOptions{}, fmt.Errorf("-to: %w", err)
+		}
+		// A date includes the whole day.
+		if _, err := time.Parse(time.DateOnly, *to); err == nil {
+			t = t.AddDate(0, 0, 1)
+		}
+		opts.to = t
+	}
+	opts.from = opts.to.AddDate(0, 0, -7)
+	if *from != "" {
+		t, err := parseWindowTime(*from)
+		if err != nil {
+			return queryOptions{}, fmt.Errorf("-from: %w", err)
+		}
+		opts.from = t
+	}
+	if !opts.from.Before(opts.to) {
+		return queryOptions{}, fmt.Errorf("period ends (%s) before it starts (%s)", opts.to.UTC(), opts.from.UTC())
+	}
+	return opts, nil
+}
+
+// runQuery prints a usage or cost report and returns the exit code.
+func runQuery(args []string, out io.Writer) int {
+	opts, err := parseQueryOptions(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		logrus.Error(err)
+		return 2
+	}
+	// Progress logs would only clutter the report; -log.level still enables them.
+	if !explicitFlags()["log.level"] {
+		_ = flag.Lookup("log.level").Value.Set("warn")
+	}
+	cfg, err := configure(nil)
+	if err != nil {
+		logrus.WithError(err).Error("Invalid configuration")
+		return 1
+	}
+	collector, err := newCollector(cfg.organizations)
+	if err != nil {
+		logrus.WithError(err).Error("Invalid configuration")
+		return 1
+	}
+	var exporters []*Exporter
+	switch c := collector.(type) {
+	case *Exporter:
+		exporters = []*Exporter{c}
+	case orgExporters:
+		exporters = c
+	default:
+		logrus.Errorf("query supports only the openai provider, not %s", *providerName)
+		return 1
+	}
+
+	report := newQueryReport(opts)
+	for _, e := range exporters {
+		if err := e.query(opts, report); err != nil {
+			logrus.WithError(err).Errorf("Error querying organization %s", e.orgID)
+			return 1
+		}
+	}
+	if err := report.write(out, opts.format); err != nil {
+		logrus.WithError(err).Error("Error writing report")
+		return 1
+	}
+	return 0
+}
+
+// query adds the usage or costs of the organization in the queried period to report.
+func (e *Exporter) query(opts queryOptions, report *queryReport) error {
+	var apiGroupBy []string
+	for _, dim := range opts.groupBy {
+		if dim != "org_id" && dim != "operation" {
+			apiGroupBy = append(apiGroupBy, dim)
+		}
+	}
+	start, end := opts.from.Unix(), opts.to.Unix()
+
+	if opts.report == "costs" {
+		buckets, err := e.fetchCostBuckets(start, end, strings.Join(apiGroupBy, ","))
+		if err != nil {
+			return err
+		}
+		for _, bucket := range buckets {
+			for _, res := range bucket.Results {
+				report.add(map[string]string{
+					"org_id":       e.orgID,
+					"project_id":   deref(res.ProjectID),
+					"project_name": e.ensureProjectName(deref(res.ProjectID)),
+					"line_item":    deref(res.LineItem),
+					"currency":     res.Amount.Currency,
+				}, float64(res.Amount.Value))
+			}
+		}
+		return nil
+	}
+
+	for _, endpoint := range currentEndpoints() {
+		// Sessions and stored bytes are not tokens and cannot be grouped like them.
+		if endpoint.Name == codeInterpreterEndpoint || endpoint.Name == vectorStoresEndpoint {
+			continue
+		}
+		buckets, err := e.fetchUsageBuckets(endpoint, start, end, "1d", strings.Join(apiGroupBy, ","))
+		if err != nil {
+			return fmt.Errorf("%s: %w", endpoint.Name, err)
+		}
+		for _, bucket := range buckets {
+			for _, res := range bucket.Results {
+				labels := map[string]string{
+					"org_id":       e.orgID,
+					"operation":    endpoint.Name,
+					"model":        deref(res.Model),
+					"batch":        string(res.Batch),
+					"service_tier": deref(res.ServiceTier),
+					"project_id":   deref(res.ProjectID),
+					"user_id":      deref(res.UserID),
+					"api_key_id":   deref(res.APIKeyID),
+				}
+				if slices.Contains(opts.groupBy, "project_id") {
+					labels["project_name"] = e.ensureProjectName(labels["project_id"])
+				}
+				if slices.Contains(opts.groupBy, "user_id") {
+					labels["user_email"] = e.ensureUserEmail(labels["user_id"])
+				}
+				if slices.Contains(opts.groupBy, "api_key_id") {
+					labels["api_key_name"] = e.ensureAPIKeyName(labels["project_id"], labels["api_key_id"])
+				}
+				report.add(labels, float64(res.InputTokens), float64(res.OutputTokens), float64(res.InputCachedTokens),
+					float64(res.InputAudioTokens), float64(res.OutputAudioTokens), float64(res.NumModelRequests))
+			}
+		}
+	}
+	return nil
+}
+
+// write prints the report as an aligned table, a JSON array of objects, or CSV with a header line.
+func (r *queryReport) write(w io.Writer, format string) error {
+	header := append(slices.Clone(r.Labels), r.Columns...)
+	records := [][]string{}
+	for _, row := range r.sortedRows() {
+		record := make([]string, 0, len(header))
+		for _, name := range r.Labels {
+			record = append(record, row.Labels[name])
+		}
+		for _, v := range row.Values {
+			record = append(record, strconv.FormatFloat(v, 'f', -1, 64))
+		}
+		records = append(records, record)
+	}
+
+	switch format {
+	case "json":
+		objects := []map[string]any{}
+		for _, row := range r.sortedRows() {
+			obj := make(map[string]any, len(header))
+			for name, value := range row.Labels {
+				obj[name] = value
+			}
+			for i, name := range r.Columns {
+				obj[name] = row.Values[i]
+			}
+			objects = append(objects, obj)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(objects)
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+		return cw.WriteAll(records)
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, strings.ToUpper(strings.Join(header, "\t")))
+		for _, record := range records {
+			_, _ = fmt.Fprintln(tw, strings.Join(record, "\t"))
+		}
+		return tw.Flush()
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQueryOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		wantFrom    time.Time
+		wantTo      time.Time
+		wantGroupBy []string
+		wantErr     bool
+	}{
+		{
+			name:        "dates include the last day",
+			args:        []string{"-from", "2024-06-01", "-to", "2024-06-07", "-group-by", "project_id,model"},
+			wantFrom:    time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+			wantTo:      time.Date(2024, 6, 8, 0, 0, 0, 0, time.UTC),
+			wantGroupBy: []string{"project_id", "model"},
+		},
+		{
+			name:        "a week by default",
+			args:        []string{"-to", "2024-06-07T12:00:00Z", "-report", "costs", "-group-by", "line_item"},
+			wantFrom:    time.Date(2024, 5, 31, 12, 0, 0, 0, time.UTC),
+			wantTo:      time.Date(2024, 6, 7, 12, 0, 0, 0, time.UTC),
+			wantGroupBy: []string{"line_item"},
+		},
+		{name: "dimension of another report", args: []string{"-report", "costs", "-group-by", "model"}, wantErr: true},
+		{name: "unknown report", args: []string{"-report", "tokens"}, wantErr: true},
+		{name: "unknown format", args: []string{"-format", "xml"}, wantErr: true},
+		{name: "reversed period", args: []string{"-from", "2024-06-08", "-to", "2024-06-01"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseQueryOptions(tt.args)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantFrom.Unix(), opts.from.Unix())
+			assert.Equal(t, tt.wantTo.Unix(), opts.to.Unix())
+			assert.Equal(t, tt.wantGroupBy, opts.groupBy)
+		})
+	}
+}
+
+func TestQueryReport(t *testing.T) {
+	origEndpoints := activeEndpoints
+	activeEndpoints = []UsageEndpoint{{Path: "completions", Name: "completions"}}
+	defer func() { activeEndpoints = origEndpoints }()
+	projectNames = map[string]string{"proj-1": "production", "proj-2": "staging"}
+
+	var groupBy string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		groupBy = r.URL.Query().Get("group_by")
+		if strings.HasSuffix(r.URL.Path, "/costs") {
+			_, _ = w.Write([]byte(`{"object": "page", "data": [
+				{"start_time": 1717200000, "results": [{"amount": {"value": 1.5, "currency": "usd"}, "project_id": "proj-1", "line_item": "gpt-4o, input"}]},
+				{"start_time": 1717286400, "results": [{"amount": {"value": 2.25, "currency": "usd"}, "project_id": "proj-1", "line_item": "gpt-4o, input"}]}
+			], "has_more": false}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"object": "page", "data": [
+			{"start_time": 1717200000, "results": [
+				{"input_tokens": 100, "output_tokens": 10, "num_model_requests": 2, "project_id": "proj-1", "model": "gpt-4o"},
+				{"input_tokens": 50, "output_tokens": 5, "num_model_requests": 1, "project_id": "proj-2", "model": "gpt-4o"}
+			]},
+			{"start_time": 1717286400, "results": [
+				{"input_tokens": 200, "output_tokens": 20, "num_model_requests": 3, "project_id": "proj-1", "model": "gpt-4o"}
+			]}
+		], "has_more": false}`))
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", targets: newAPITargets("org-1", server.URL, 3)}
+	period := queryOptions{from: time.Unix(1717200000, 0), to: time.Unix(1717372800, 0)}
+
+	t.Run("usage as table", func(t *testing.T) {
+		opts := period
+		opts.report, opts.groupBy = "usage", []string{"operation", "project_id"}
+		report := newQueryReport(opts)
+		require.NoError(t, e.query(opts, report))
+		assert.Equal(t, "project_id", groupBy, "operation is not an API dimension")
+
+		var out bytes.Buffer
+		require.NoError(t, report.write(&out, "table"))
+		assert.Equal(t, ""+
+			"OPERATION    PROJECT_ID  PROJECT_NAME  INPUT_TOKENS  OUTPUT_TOKENS  INPUT_CACHED_TOKENS  INPUT_AUDIO_TOKENS  OUTPUT_AUDIO_TOKENS  REQUESTS\n"+
+			"completions  proj-1      production    300           30             0                    0                   0                    5\n"+
+			"completions  proj-2      staging       50            5              0                    0                   0                    1\n",
+			out.String())
+	})
+
+	t.Run("costs as CSV and JSON", func(t *testing.T) {
+		opts := period
+		opts.report, opts.groupBy = "costs", []string{"line_item"}
+		report := newQueryReport(opts)
+		require.NoError(t, e.query(opts, report))
+		assert.Equal(t, "line_item", groupBy)
+
+		var out bytes.Buffer
+		require.NoError(t, report.write(&out, "csv"))
+		assert.Equal(t, "line_item,currency,amount\n\"gpt-4o, input\",usd,3.75\n", out.String())
+
+		out.Reset()
+		require.NoError(t, report.write(&out, "json"))
+		assert.JSONEq(t, `[{"line_item": "gpt-4o, input", "currency": "usd", "amount": 3.75}]`, out.String())
+	})
+}
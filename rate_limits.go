@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Project Rate Limits
+
+var (
+	rateLimitsEnabled  = flag.Bool("collector.rate-limits", false, "Export the rate limits configured for each model of each project")
+	rateLimitsInterval = flag.Duration("collector.rate-limits.interval", time.Hour, "Interval at which the rate limits of all projects are re-read")
+
+	projectRateLimit = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_project_rate_limit",
+			Help: "Rate limit configured for a model in a project, by limit type (e.g. requests_per_minute, tokens_per_minute).",
+		},
+		[]string{"org_id", "org_name", "project_id", "project_name", "model", "limit_type"},
+	)
+)
+
+type RateLimitList struct {
+	Object  string      `json:"object"`
+	Data    []RateLimit `json:"data"`
+	HasMore bool        `json:"has_more"`
+	LastID  string      `json:"last_id"`
+}
+
+// RateLimit holds the limits of one model; limits that do not apply to the model are missing.
+type RateLimit struct {
+	ID                          string `json:"id"`
+	Model                       string `json:"model"`
+	MaxRequestsPer1Minute       *int64 `json:"max_requests_per_1_minute"`
+	MaxTokensPer1Minute         *int64 `json:"max_tokens_per_1_minute"`
+	MaxImagesPer1Minute         *int64 `json:"max_images_per_1_minute"`
+	MaxAudioMegabytesPer1Minute *int64 `json:"max_audio_megabytes_per_1_minute"`
+	MaxRequestsPer1Day          *int64 `json:"max_requests_per_1_day"`
+	Batch1DayMaxInputTokens     *int64 `json:"batch_1_day_max_input_tokens"`
+}
+
+// limits returns the limits of the model by limit_type.
+func (r RateLimit) limits() map[string]*int64 {
+	return map[string]*int64{
+		"requests_per_minute":        r.MaxRequestsPer1Minute,
+		"tokens_per_minute":          r.MaxTokensPer1Minute,
+		"images_per_minute":          r.MaxImagesPer1Minute,
+		"audio_megabytes_per_minute": r.MaxAudioMegabytesPer1Minute,
+		"requests_per_day":           r.MaxRequestsPer1Day,
+		"batch_input_tokens_per_day": r.Batch1DayMaxInputTokens,
+	}
+}
+
+// fetchRateLimits lists the rate limits of all models of a project.
+func (e *Exporter) fetchRateLimits(projectID string) ([]RateLimit, error) {
+	var limits []RateLimit
+	after := ""
+	guard := newPageGuard(e.orgID, "rate_limits")
+
+	for {
+		path := fmt.Sprintf("/v1/organization/projects/%s/rate_limits?limit=100", url.PathEscape(projectID))
+		if after != "" {
+			path += "&after=" + url.QueryEscape(after)
+		}
+
+		resp, err := e.get(path)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching rate limits of project %s: %w", projectID, err)
+		}
+
+		var out RateLimitList
+		decodeErr := json.NewDecoder(resp.Body).Decode(&out)
+		closeErr := resp.Body.Close()
+
+		if decodeErr != nil {
+			return nil, fmt.Errorf("error decoding response: %w", decodeErr)
+		}
+		if closeErr != nil {
+			logrus.WithError(closeErr).Warn("failed to close response body")
+		}
+
+		pagesFetchedTotal.WithLabelValues(e.orgID, "rate_limits").Inc()
+
+		limits = append(limits, out.Data...)
+		if !out.HasMore || out.LastID == "" || !guard.next(out.LastID) {
+			return limits, nil
+		}
+		after = out.LastID
+	}
+}
+
+// collectRateLimits reads the rate limits of all active projects and replaces the exported ones of the organization.
+// The previous limits stay exported if any project cannot be read.
+func (e *Exporter) collectRateLimits() error {
+	projects, err := e.fetchProjects()
+	if err != nil {
+		return err
+	}
+
+	type limit struct {
+		labels prometheus.Labels
+		value  int64
+	}
+	var current []limit
+	for _, p := range projects {
+		if p.Status == "archived" || !exportsProject(p.ID, func() string { return p.Name }) {
+			continue
+		}
+		rateLimits, err := e.fetchRateLimits(p.ID)
+		if err != nil {
+			return err
+		}
+		for _, rl := range rateLimits {
+			for limitType, value := range rl.limits() {
+				if value == nil {
+					continue
+				}
+				current = append(current, limit{prometheus.Labels{
+					"org_id":       e.orgID,
+					"org_name":     e.orgName,
+					"project_id":   p.ID,
+					"project_name": p.Name,
+					"model":        rl.Model,
+					"limit_type":   limitType,
+				}, *value})
+			}
+		}
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	e.rememberProjectNames(projects, time.Now())
+	projectRateLimit.DeletePartialMatch(prometheus.Labels{"org_id": e.orgID})
+	for _, l := range current {
+		projectRateLimit.With(l.labels).Set(float64(l.value))
+	}
+	logrus.WithField("org_id", e.orgID).Debugf("Read %d rate limits of %d projects", len(current), len(projects))
+	return nil
+}
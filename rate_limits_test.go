@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectRateLimits(t *testing.T) {
+	projectNames = make(map[string]string)
+	projectRateLimit.Reset()
+
+	limits := `[
+		{"id": "rl-gpt-4o", "model": "gpt-4o", "max_requests_per_1_minute": 500, "max_tokens_per_1_minute": 30000},
+		{"id": "rl-dall-e-3", "model": "dall-e-3", "max_images_per_1_minute": 5}
+	]`
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		switch r.URL.Path {
+		case "/v1/organization/projects":
+			_, _ = w.Write([]byte(`{"object": "list", "data": [
+				{"id": "proj-1", "name": "one", "status": "active"},
+				{"id": "proj-2", "name": "two", "status": "archived"}
+			], "has_more": false}`))
+		case "/v1/organization/projects/proj-1/rate_limits":
+			_, _ = w.Write([]byte(`{"object": "list", "data": ` + limits + `, "has_more": false}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+	limit := func(model, limitType string) float64 {
+		return testutil.ToFloat64(projectRateLimit.WithLabelValues("org-1", "prod", "proj-1", "one", model, limitType))
+	}
+
+	require.NoError(t, e.collectRateLimits())
+	assert.Equal(t, []string{"/v1/organization/projects", "/v1/organization/projects/proj-1/rate_limits"}, requested,
+		"archived projects are skipped")
+	assert.Equal(t, 500.0, limit("gpt-4o", "requests_per_minute"))
+	assert.Equal(t, 30000.0, limit("gpt-4o", "tokens_per_minute"))
+	assert.Equal(t, 5.0, limit("dall-e-3", "images_per_minute"))
+	assert.Equal(t, 3, testutil.CollectAndCount(projectRateLimit), "limits that do not apply are not exported")
+
+	limits = `[{"id": "rl-gpt-4o", "model": "gpt-4o", "max_requests_per_1_minute": 1000}]`
+	require.NoError(t, e.collectRateLimits())
+	assert.Equal(t, 1000.0, limit("gpt-4o", "requests_per_minute"))
+	assert.Equal(t, 1, testutil.CollectAndCount(projectRateLimit), "removed limits are no longer exported")
+}
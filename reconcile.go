@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Daily Reconciliation
+
+// reconcileGroupBy lists the dimensions daily totals are compared by.
+const reconcileGroupBy = "project_id,model"
+
+var (
+	reconcileEnabled = flag.Bool("reconcile.enabled", false, "Once a day, re-fetch the previous UTC day with daily buckets and export corrected totals and their drift from the minute buckets")
+	reconcileDelay   = flag.Duration("reconcile.delay", 2*time.Hour, "Time after midnight UTC at which the previous day is reconciled, giving late revisions time to land")
+
+	dailyTokens = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_api_daily_tokens",
+			Help: "Corrected token totals of the last reconciled UTC day, from daily Usage API buckets.",
+		},
+		[]string{"org_id", "org_name", "date", "operation", "project_id", "model", "token_type"},
+	)
+	dailyTokensDrift = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_api_daily_tokens_drift",
+			Help: "Difference between the daily Usage API total and the sum of the minute buckets counted for the last reconciled UTC day.",
+		},
+		[]string{"org_id", "org_name", "date", "operation", "project_id", "model", "token_type"},
+	)
+)
+
+type reconcileKey struct {
+	operation string
+	projectID string
+	model     string
+	tokenType string
+}
+
+// minuteTotals sums the processed minute buckets of the organization orgID that start within [dayStart, dayEnd).
+func minuteTotals(orgID string, dayStart, dayEnd int64) map[reconcileKey]float64 {
+	totals := make(map[reconcileKey]float64)
+
+	stateMu.RLock()
+	defer stateMu.RUnlock()
+
+	for key, value := range usageState {
+		// Keys are built by updateMetric: operation|bucketStart|project_id|user_id|api_key_id|model|batch|token_type|org_id[|service_tier].
+		parts := strings.Split(key, "|")
+		if len(parts) < 9 || parts[8] != orgID {
+			continue
+		}
+		start, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || start < dayStart || start >= dayEnd {
+			continue
+		}
+		totals[reconcileKey{operation: parts[0], projectID: parts[2], model: parts[5], tokenType: parts[7]}] += value
+	}
+	return totals
+}
+
+// reconcileDay compares the daily totals of the UTC day starting at day with the minute buckets counted for it
+// and replaces the exported daily totals and drift with the result.
+func (e *Exporter) reconcileDay(day time.Time) error {
+	dayStart := day.UTC().Truncate(24 * time.Hour).Unix()
+	dayEnd := dayStart + 24*60*60
+	date := time.Unix(dayStart, 0).UTC().Format("2006-01-02")
+
+	apiTotals := make(map[reconcileKey]float64)
+	for _, endpoint := range currentEndpoints() {
+		// Sessions and stored bytes are not tokens and cannot be grouped by model.
+		if endpoint.Name == codeInterpreterEndpoint || endpoint.Name == vectorStoresEndpoint {
+			continue
+		}
+		buckets, err := e.fetchUsageBuckets(endpoint, dayStart, dayEnd, "1d", reconcileGroupBy)
+		if err != nil {
+			return fmt.Errorf("error reconciling %s for %s: %w", endpoint.Name, date, err)
+		}
+		for _, bucket := range buckets {
+			for _, result := range bucket.Results {
+				for _, tc := range result.tokenCounts() {
+					key := reconcileKey{operation: endpoint.Name, projectID: deref(result.ProjectID), model: deref(result.Model), tokenType: tc.Type}
+					apiTotals[key] += float64(tc.Value)
+				}
+			}
+		}
+	}
+	counted := minuteTotals(e.orgID, dayStart, dayEnd)
+
+	dailyTokens.DeletePartialMatch(prometheus.Labels{"org_id": e.orgID})
+	dailyTokensDrift.DeletePartialMatch(prometheus.Labels{"org_id": e.orgID})
+	for key, total := range apiTotals {
+		dailyTokens.WithLabelValues(e.orgID, e.orgName, date, key.operation, key.projectID, key.model, key.tokenType).Set(total)
+	}
+	var drifted int
+	for _, key := range unionKeys(apiTotals, counted) {
+		drift := apiTotals[key] - counted[key]
+		dailyTokensDrift.WithLabelValues(e.orgID, e.orgName, date, key.operation, key.projectID, key.model, key.tokenType).Set(drift)
+		if drift != 0 {
+			drifted++
+		}
+	}
+	logrus.Infof("Reconciled %s of organization %s: %d series, %d with drift", date, e.orgID, len(apiTotals), drifted)
+	return nil
+}
+
+func unionKeys(a, b map[reconcileKey]float64) []reconcileKey {
+	keys := make([]reconcileKey, 0, len(a))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// reconcileLoop reconciles the previous UTC day once a day, reconcile.delay after midnight, until ctx is done.
+func (e *Exporter) reconcileLoop(ctx context.Context) {
+	for {
+		now := time.Now().UTC()
+		next := now.Truncate(24 * time.Hour).Add(*reconcileDelay)
+		if !next.After(now) {
+			next = next.Add(24 * time.Hour)
+		}
+		if !sleepContext(ctx, time.Until(next)) {
+			return
+		}
+
+		day := next.Add(-*reconcileDelay).Add(-24 * time.Hour)
+		if err := e.reconcileDay(day); err != nil {
+			logrus.WithError(err).WithField("org_id", e.orgID).Warn("Error reconciling daily totals")
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinuteTotals(t *testing.T) {
+	usageState = map[string]float64{
+		"completions|1000|proj-1|user-1|key-1|gpt-4|false|input|org-1": 10,
+		"completions|1060|proj-1|user-2|key-2|gpt-4|true|input|org-1":  5,
+		"completions|5000|proj-1|user-1|key-1|gpt-4|false|input|org-1": 100,
+		"completions|1000|proj-2|user-3|key-3|gpt-4|false|input|org-2": 7,
+		"malformed": 1,
+	}
+
+	totals := minuteTotals("org-1", 0, 2000)
+	assert.Equal(t, map[reconcileKey]float64{
+		{operation: "completions", projectID: "proj-1", model: "gpt-4", tokenType: "input"}: 15,
+	}, totals)
+}
+
+func TestReconcileDay(t *testing.T) {
+	day := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	usageState = map[string]float64{
+		"completions|1717200000|proj-1|user-1|key-1|gpt-4|false|input|org-1":  90,
+		"completions|1717200060|proj-1|user-1|key-1|gpt-4|false|output|org-1": 20,
+	}
+	dailyTokens.Reset()
+	dailyTokensDrift.Reset()
+	dailyTokens.WithLabelValues("org-2", "research", "2024-05-31", "completions", "proj-2", "gpt-4", "input").Set(5)
+
+	origEndpoints := activeEndpoints
+	activeEndpoints = []UsageEndpoint{{Path: "completions", Name: "completions"}, {Path: "code_interpreter_sessions", Name: codeInterpreterEndpoint},
+		{Path: "vector_stores", Name: vectorStoresEndpoint}}
+	defer func() { activeEndpoints = origEndpoints }()
+
+	var gotQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/code_interpreter_sessions") || strings.HasSuffix(r.URL.Path, "/vector_stores") {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error": {"message": "Invalid group_by: model", "type": "invalid_request_error"}}`))
+			return
+		}
+		gotQuery = r.URL.RawQuery
+		_, _ = w.Write([]byte(`{"object": "page", "data": [{"start_time": 1717200000, "end_time": 1717286400, "results": [
+			{"input_tokens": 100, "output_tokens": 20, "project_id": "proj-1", "model": "gpt-4"}
+		]}], "has_more": false}`))
+	}))
+	defer server.Close()
+
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("", server.URL, 3)}
+	require.NoError(t, e.reconcileDay(day.Add(13*time.Hour)))
+
+	assert.Contains(t, gotQuery, "start_time=1717200000&end_time=1717286400&bucket_width=1d")
+	assert.Contains(t, gotQuery, "group_by=project_id,model")
+	assert.Equal(t, 100.0, testutil.ToFloat64(dailyTokens.WithLabelValues("org-1", "prod", "2024-06-01", "completions", "proj-1", "gpt-4", "input")))
+	assert.Equal(t, 10.0, testutil.ToFloat64(dailyTokensDrift.WithLabelValues("org-1", "prod", "2024-06-01", "completions", "proj-1", "gpt-4", "input")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(dailyTokensDrift.WithLabelValues("org-1", "prod", "2024-06-01", "completions", "proj-1", "gpt-4", "output")))
+	assert.Equal(t, 5.0, testutil.ToFloat64(dailyTokens.WithLabelValues("org-2", "research", "2024-05-31", "completions", "proj-2", "gpt-4", "input")),
+		"other organizations keep their totals")
+}
+
+func TestReconcileDay_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("invalid json"))
+	}))
+	defer server.Close()
+
+	e := &Exporter{client: server.Client(), apiKey: "test", targets: newAPITargets("", server.URL, 3)}
+	assert.Error(t, e.reconcileDay(time.Now()))
+}
+
+func TestReconcileLoop_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		(&Exporter{orgID: "org-1"}).reconcileLoop(ctx)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reconcileLoop did not return after its context was cancelled")
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Shared Deduplication State
+
+// redisTimeout bounds connecting to Redis and every command round trip.
+const redisTimeout = 5 * time.Second
+
+var (
+	stateBackend   = flag.String("state.backend", "memory", "Where processed buckets are recorded: memory (per replica) or redis (shared between replicas)")
+	redisAddress   = flag.String("state.redis.address", "127.0.0.1:6379", "Address of the Redis server used by -state.backend=redis")
+	redisDB        = flag.Int("state.redis.db", 0, "Redis database number used by -state.backend=redis")
+	redisKeyPrefix = flag.String("state.redis.key-prefix", "openai-exporter:", "Prefix of the bucket keys stored in Redis")
+	redisKeyTTL    = flag.Duration("state.redis.ttl", 72*time.Hour, "Time after which bucket keys expire in Redis; must exceed the oldest window that can be fetched again")
+
+	// dedupBackend, when set, decides which replica counts a bucket. Nil means every replica deduplicates on its own.
+	dedupBackend dedupStore
+)
+
+// dedupStore records processed buckets outside the process.
+type dedupStore interface {
+	// claim marks the bucket as counted and reports whether no one had claimed it before.
+	claim(key string, value float64) (bool, error)
+}
+
+func newDedupStore(backend string) (dedupStore, error) {
+	switch backend {
+	case "memory":
+		return nil, nil
+	case "redis":
+		if *redisKeyTTL < time.Second {
+			return nil, fmt.Errorf("state.redis.ttl must be at least 1s")
+		}
+		return &redisStore{
+			address:  *redisAddress,
+			password: os.Getenv("REDIS_PASSWORD"),
+			db:       *redisDB,
+			prefix:   *redisKeyPrefix,
+			ttl:      *redisKeyTTL,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported state backend %q", backend)
+	}
+}
+
+// redisStore claims buckets with SET NX, speaking just enough RESP for that over a single connection.
+type redisStore struct {
+	mu       sync.Mutex
+	address  string
+	password string
+	db       int
+	prefix   string
+	ttl      time.Duration
+
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+func (r *redisStore) claim(key string, value float64) (bool, error) {
+	reply, err := r.do("SET", r.prefix+key, strconv.FormatFloat(value, 'g', -1, 64),
+		"NX", "EX", strconv.FormatInt(int64(r.ttl/time.Second), 10))
+	if err != nil {
+		return false, err
+	}
+	// SET NX replies OK when the key was set and a null bulk string when it already existed.
+	return reply != nil, nil
+}
+
+// do sends one command and returns its reply, connecting (or reconnecting after a failure) as needed.
+func (r *redisStore) do(args ...string) (any, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil {
+		if err := r.connect(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := r.roundTrip(args...)
+	if err != nil {
+		if _, ok := err.(redisError); !ok {
+			_ = r.conn.Close()
+			r.conn = nil
+		}
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (r *redisStore) connect() error {
+	conn, err := net.DialTimeout("tcp", r.address, redisTimeout)
+	if err != nil {
+		return fmt.Errorf("error connecting to Redis at %s: %w", r.address, err)
+	}
+	r.conn = conn
+	r.rd = bufio.NewReader(conn)
+
+	var setup [][]string
+	if r.password != "" {
+		setup = append(setup, []string{"AUTH", r.password})
+	}
+	if r.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(r.db)})
+	}
+	for _, cmd := range setup {
+		if _, err := r.roundTrip(cmd...); err != nil {
+			_ = conn.Close()
+			r.conn = nil
+			return fmt.Errorf("error setting up Redis connection (%s): %w", cmd[0], err)
+		}
+	}
+	return nil
+}
+
+func (r *redisStore) roundTrip(args ...string) (any, error) {
+	_ = r.conn.SetDeadline(time.Now().Add(redisTimeout))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := io.WriteString(r.conn, b.String()); err != nil {
+		return nil, fmt.Errorf("error writing to Redis: %w", err)
+	}
+	return readRESP(r.rd)
+}
+
+// redisError is an error reply from the server; the connection stays usable after it.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// readRESP reads one reply. Null replies are returned as nil.
+func readRESP(rd *bufio.Reader) (any, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("error reading from Redis: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("empty reply from Redis")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid bulk length from Redis: %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, fmt.Errorf("error reading from Redis: %w", err)
+		}
+		return string(buf[:n]), nil
+	default:
+		return nil, fmt.Errorf("unsupported reply from Redis: %q", line)
+	}
+}
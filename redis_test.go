@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis understands AUTH, SELECT and SET key value NX EX ttl.
+type fakeRedis struct {
+	mu       sync.Mutex
+	password string
+	keys     map[string]string
+	ttls     map[string]string
+	commands [][]string
+}
+
+func startFakeRedis(t *testing.T, password string) (*fakeRedis, string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = lis.Close() })
+
+	f := &fakeRedis{password: password, keys: make(map[string]string), ttls: make(map[string]string)}
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f, lis.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	rd := bufio.NewReader(conn)
+	authed := f.password == ""
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := make([]string, n)
+		for i := range args {
+			header, _ := rd.ReadString('\n')
+			size, _ := strconv.Atoi(strings.TrimSpace(header[1:]))
+			buf := make([]byte, size+2)
+			_, _ = io.ReadFull(rd, buf)
+			args[i] = string(buf[:size])
+		}
+
+		f.mu.Lock()
+		f.commands = append(f.commands, args)
+		var reply string
+		switch {
+		case args[0] == "AUTH" && args[1] == f.password:
+			authed = true
+			reply = "+OK\r\n"
+		case args[0] == "AUTH":
+			reply = "-WRONGPASS invalid password\r\n"
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case args[0] == "SELECT":
+			reply = "+OK\r\n"
+		case args[0] == "SET":
+			if _, ok := f.keys[args[1]]; ok {
+				reply = "$-1\r\n"
+			} else {
+				f.keys[args[1]] = args[2]
+				f.ttls[args[1]] = args[5]
+				reply = "+OK\r\n"
+			}
+		default:
+			reply = fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+		}
+		f.mu.Unlock()
+		_, _ = io.WriteString(conn, reply)
+	}
+}
+
+func TestRedisStore_Claim(t *testing.T) {
+	f, addr := startFakeRedis(t, "secret")
+	store := &redisStore{address: addr, password: "secret", db: 2, prefix: "test:", ttl: time.Hour}
+
+	claimed, err := store.claim("completions|1000|proj-1|user-1|key-1|gpt-4|false|input", 42)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	claimed, err = store.claim("completions|1000|proj-1|user-1|key-1|gpt-4|false|input", 42)
+	require.NoError(t, err)
+	assert.False(t, claimed, "a second claim of the same bucket must fail")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	assert.Equal(t, "42", f.keys["test:completions|1000|proj-1|user-1|key-1|gpt-4|false|input"])
+	assert.Equal(t, "3600", f.ttls["test:completions|1000|proj-1|user-1|key-1|gpt-4|false|input"])
+	assert.Equal(t, []string{"AUTH", "secret"}, f.commands[0])
+	assert.Equal(t, []string{"SELECT", "2"}, f.commands[1])
+	assert.Len(t, f.commands, 4, "setup runs once per connection")
+}
+
+func TestRedisStore_Errors(t *testing.T) {
+	_, addr := startFakeRedis(t, "secret")
+
+	t.Run("wrong password", func(t *testing.T) {
+		store := &redisStore{address: addr, password: "wrong", ttl: time.Hour}
+		_, err := store.claim("key", 1)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "WRONGPASS")
+		assert.Nil(t, store.conn)
+	})
+
+	t.Run("unreachable server", func(t *testing.T) {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		unused := lis.Addr().String()
+		_ = lis.Close()
+
+		store := &redisStore{address: unused, ttl: time.Hour}
+		_, err = store.claim("key", 1)
+		assert.Error(t, err)
+	})
+}
+
+func TestNewDedupStore(t *testing.T) {
+	store, err := newDedupStore("memory")
+	require.NoError(t, err)
+	assert.Nil(t, store)
+
+	store, err = newDedupStore("redis")
+	require.NoError(t, err)
+	assert.IsType(t, &redisStore{}, store)
+
+	_, err = newDedupStore("memcached")
+	assert.Error(t, err)
+}
+
+func TestUpdateMetric_SharedBackend(t *testing.T) {
+	_, addr := startFakeRedis(t, "")
+	origBackend := dedupBackend
+	defer func() { dedupBackend = origBackend }()
+
+	labels := prometheus.Labels{
+		"org_id": "org-1", "org_name": "prod", "model": "gpt-4", "operation": "completions", "project_id": "proj-1", "project_name": "one",
+		"user_id": "user-1", "user_email": "", "api_key_id": "key-1", "api_key_name": "ci", "batch": "false",
+	}
+
+	tests := []struct {
+		name      string
+		backend   dedupStore
+		wantValue float64
+	}{
+		{name: "first replica counts the bucket", backend: &redisStore{address: addr, ttl: time.Hour}, wantValue: 10},
+		{name: "second replica skips it", backend: &redisStore{address: addr, ttl: time.Hour}, wantValue: 0},
+		{name: "unreachable backend falls back to local deduplication", backend: &redisStore{address: "127.0.0.1:1", ttl: time.Hour}, wantValue: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usageState = make(map[string]float64)
+			tokensTotal.Reset()
+			dedupBackend = tt.backend
+
+			updateMetric(labels, "input", 1000, 1060, 10)
+			assert.Equal(t, tt.wantValue, testutil.ToFloat64(tokensTotal.With(mergeLabels(labels, "token_type", "input"))))
+		})
+	}
+}
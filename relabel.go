@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Relabeling
+
+// RelabelRule rewrites the labels of token series before they are counted, like a Prometheus metric_relabel_config.
+type RelabelRule struct {
+	SourceLabels []string `yaml:"source_labels"`
+	Separator    string   `yaml:"separator"`
+	Regex        string   `yaml:"regex"`
+	TargetLabel  string   `yaml:"target_label"`
+	Replacement  *string  `yaml:"replacement"`
+	Action       string   `yaml:"action"`
+
+	re *regexp.Regexp
+}
+
+// relabelRules are the rules currently in effect, guarded by configMu.
+var relabelRules []RelabelRule
+
+// compile validates the rule, fills in the defaults and compiles its regex.
+func (r *RelabelRule) compile() error {
+	if r.Action == "" {
+		r.Action = "replace"
+	}
+	if r.Separator == "" {
+		r.Separator = ";"
+	}
+	regex := r.Regex
+	if regex == "" {
+		regex = "(.*)"
+	}
+	re, err := regexp.Compile("^(?:" + regex + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid regex %q: %w", r.Regex, err)
+	}
+	r.re = re
+
+	switch r.Action {
+	case "replace":
+		if !slices.Contains(allTokenLabels, r.TargetLabel) || r.TargetLabel == "token_type" {
+			return fmt.Errorf("replace needs a target_label of openai_api_tokens_total other than token_type, got %q", r.TargetLabel)
+		}
+	case "keep", "drop":
+		if len(r.SourceLabels) == 0 {
+			return fmt.Errorf("%s needs source_labels", r.Action)
+		}
+	case "labeldrop":
+		if r.Regex == "" {
+			return fmt.Errorf("labeldrop needs a regex")
+		}
+	default:
+		return fmt.Errorf("unknown relabel action %q", r.Action)
+	}
+	return nil
+}
+
+// relabel applies rules to labels in order. It returns false if the series is dropped.
+// Dropped labels are set to the empty string, which Prometheus treats as absent. The input is never modified.
+func relabel(rules []RelabelRule, labels prometheus.Labels) (prometheus.Labels, bool) {
+	if len(rules) == 0 {
+		return labels, true
+	}
+	out := make(prometheus.Labels, len(labels))
+	for k, v := range labels {
+		out[k] = v
+	}
+
+	for _, r := range rules {
+		values := make([]string, len(r.SourceLabels))
+		for i, name := range r.SourceLabels {
+			values[i] = out[name]
+		}
+		value := strings.Join(values, r.Separator)
+
+		switch r.Action {
+		case "replace":
+			match := r.re.FindStringSubmatchIndex(value)
+			if match == nil {
+				continue
+			}
+			replacement := "$1"
+			if r.Replacement != nil {
+				replacement = *r.Replacement
+			}
+			out[r.TargetLabel] = string(r.re.ExpandString(nil, replacement, value, match))
+		case "keep":
+			if !r.re.MatchString(value) {
+				return nil, false
+			}
+		case "drop":
+			if r.re.MatchString(value) {
+				return nil, false
+			}
+		case "labeldrop":
+			for name := range out {
+				if name != "token_type" && r.re.MatchString(name) {
+					out[name] = ""
+				}
+			}
+		}
+	}
+	return out, true
+}
+
+// currentRelabelRules returns the rules in effect.
+func currentRelabelRules() []RelabelRule {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return relabelRules
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelabel(t *testing.T) {
+	base := prometheus.Labels{"project_id": "proj-ci", "user_id": "user-1", "api_key_id": "key-1", "api_key_name": "ci", "batch": "true", "token_type": "input"}
+
+	tests := []struct {
+		name  string
+		rules []RelabelRule
+		want  prometheus.Labels
+		keep  bool
+	}{
+		{
+			name:  "no rules",
+			rules: nil,
+			want:  base,
+			keep:  true,
+		},
+		{
+			name:  "drop api key labels",
+			rules: []RelabelRule{{Action: "labeldrop", Regex: "api_key_.*"}},
+			want:  mergeLabels(mergeLabels(base, "api_key_id", ""), "api_key_name", ""),
+			keep:  true,
+		},
+		{
+			name:  "map batch values",
+			rules: []RelabelRule{{SourceLabels: []string{"batch"}, Regex: "true", TargetLabel: "batch", Replacement: strPtr("shared")}},
+			want:  mergeLabels(base, "batch", "shared"),
+			keep:  true,
+		},
+		{
+			name:  "constant user for a project",
+			rules: []RelabelRule{{SourceLabels: []string{"project_id"}, Regex: "proj-ci", TargetLabel: "user_id", Replacement: strPtr("shared")}},
+			want:  mergeLabels(base, "user_id", "shared"),
+			keep:  true,
+		},
+		{
+			name:  "no match leaves the series alone",
+			rules: []RelabelRule{{SourceLabels: []string{"project_id"}, Regex: "proj-prod", TargetLabel: "user_id", Replacement: strPtr("")}},
+			want:  base,
+			keep:  true,
+		},
+		{
+			name:  "default replacement with several source labels",
+			rules: []RelabelRule{{SourceLabels: []string{"project_id", "user_id"}, Regex: "proj-(.*);user-(.*)", TargetLabel: "user_id", Replacement: strPtr("$1-$2")}},
+			want:  mergeLabels(base, "user_id", "ci-1"),
+			keep:  true,
+		},
+		{
+			name:  "drop",
+			rules: []RelabelRule{{Action: "drop", SourceLabels: []string{"project_id"}, Regex: "proj-ci"}},
+			keep:  false,
+		},
+		{
+			name:  "keep",
+			rules: []RelabelRule{{Action: "keep", SourceLabels: []string{"project_id"}, Regex: "proj-prod"}},
+			keep:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := range tt.rules {
+				require.NoError(t, tt.rules[i].compile())
+			}
+			got, keep := relabel(tt.rules, base)
+			assert.Equal(t, tt.keep, keep)
+			if tt.keep {
+				assert.Equal(t, tt.want, got)
+			}
+			assert.Equal(t, "user-1", base["user_id"], "the input is not modified")
+		})
+	}
+}
+
+func TestRelabelRule_Compile(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    RelabelRule
+		wantErr string
+	}{
+		{name: "invalid regex", rule: RelabelRule{TargetLabel: "user_id", Regex: "("}, wantErr: "invalid regex"},
+		{name: "unknown target", rule: RelabelRule{TargetLabel: "team"}, wantErr: "target_label"},
+		{name: "token type cannot be replaced", rule: RelabelRule{TargetLabel: "token_type"}, wantErr: "target_label"},
+		{name: "drop without source labels", rule: RelabelRule{Action: "drop"}, wantErr: "needs source_labels"},
+		{name: "labeldrop without regex", rule: RelabelRule{Action: "labeldrop"}, wantErr: "needs a regex"},
+		{name: "unknown action", rule: RelabelRule{Action: "hashmod"}, wantErr: "unknown relabel action"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, tt.rule.compile(), tt.wantErr)
+		})
+	}
+}
+
+func TestUpdateMetric_Relabel(t *testing.T) {
+	usageState = make(map[string]float64)
+	tokensTotal.Reset()
+	relabelRules = []RelabelRule{{Action: "drop", SourceLabels: []string{"model"}, Regex: "gpt-3.5.*"}, {Action: "labeldrop", Regex: "user_.*"}}
+	for i := range relabelRules {
+		require.NoError(t, relabelRules[i].compile())
+	}
+	defer func() { relabelRules = nil }()
+
+	now := time.Now().Unix()
+	labels := prometheus.Labels{
+		"org_id": "org-1", "org_name": "prod", "model": "gpt-4", "operation": "completions", "project_id": "proj-1", "project_name": "p",
+		"user_id": "user-1", "user_email": "ana@example.com", "api_key_id": "key-1", "api_key_name": "k", "batch": "false",
+	}
+	updateMetric(labels, "input", now-120, now-60, 10)
+	updateMetric(mergeLabels(labels, "model", "gpt-3.5-turbo"), "input", now-120, now-60, 5)
+
+	assert.Len(t, usageState, 2, "dropped series are still marked as processed")
+	assert.Equal(t, 1, testutil.CollectAndCount(tokensTotal))
+	assert.Equal(t, 10.0, testutil.ToFloat64(tokensTotal.WithLabelValues("org-1", "prod", "gpt-4", "completions", "proj-1", "p", "", "", "key-1", "k", "false", "input")))
+}
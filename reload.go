@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/subtle"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Configuration Reload
+
+var (
+	reloadSuccessful = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "openai_exporter_config_last_reload_successful",
+			Help: "Whether the last configuration reload attempt was successful.",
+		},
+	)
+	reloadSuccessTimestamp = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "openai_exporter_config_last_reload_success_timestamp_seconds",
+			Help: "Timestamp of the last successful configuration reload.",
+		},
+	)
+
+	// reloadMu serializes reloads triggered by signals and HTTP requests.
+	reloadMu sync.Mutex
+)
+
+// reloadableSettings are the flags applied at runtime by a reload; all others take effect on restart.
+var reloadableSettings = map[string]bool{
+	"scrape.interval":       true,
+	"log.level":             true,
+	"filter.users.allow":    true,
+	"filter.users.deny":     true,
+	"filter.projects.allow": true,
+	"filter.projects.deny":  true,
+	"filter.models.allow":   true,
+	"filter.models.deny":    true,
+}
+
+// reloadConfig re-reads the configuration file and applies the settings that can change at runtime,
+// keeping the deduplication state. Settings the file no longer holds are reset to their command-line or
+// default values, unless the remote configuration sets them. Changes to settings that need a restart are logged and otherwise ignored.
+func reloadConfig(path string, explicit map[string]bool) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	err := func() error {
+		fileCfg, err := readConfigFile(path)
+		if err != nil {
+			return err
+		}
+		cfg := runtimeConfig(fileCfg, explicit)
+		if err := cfg.validate(); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		applyFileConfig(cfg)
+		return nil
+	}()
+
+	if err != nil {
+		reloadSuccessful.Set(0)
+		return err
+	}
+	reloadSuccessful.Set(1)
+	reloadSuccessTimestamp.SetToCurrentTime()
+	return nil
+}
+
+// runtimeConfig returns the settings of fileCfg that can change at runtime. Flags in explicit keep their
+// command-line values, so the file's settings for them are left out. Settings that need a restart and
+// differ from the running ones are logged.
+func runtimeConfig(fileCfg *FileConfig, explicit map[string]bool) *Config {
+	cfg := &Config{Endpoints: fileCfg.Endpoints, Relabel: fileCfg.Relabel, Budgets: fileCfg.Budgets}
+	for _, name := range sortedKeys(fileCfg.Flags) {
+		setting := fileCfg.Flags[name]
+		if explicit[name] {
+			continue
+		}
+		if !reloadableSettings[name] {
+			if current := flag.Lookup(name).Value.String(); current != setting.Value {
+				logrus.Warnf("Setting %s changed from %q to %q; restart the exporter to apply it", name, current, setting.Value)
+			}
+			continue
+		}
+		switch name {
+		case "scrape.interval":
+			cfg.ScrapeInterval = setting.Value
+		case "log.level":
+			cfg.LogLevel = setting.Value
+		case "filter.users.allow":
+			cfg.Users.Allow = splitList(setting.Value)
+		case "filter.users.deny":
+			cfg.Users.Deny = splitList(setting.Value)
+		case "filter.projects.allow":
+			cfg.Projects.Allow = splitList(setting.Value)
+		case "filter.projects.deny":
+			cfg.Projects.Deny = splitList(setting.Value)
+		case "filter.models.allow":
+			cfg.Models.Allow = splitList(setting.Value)
+		case "filter.models.deny":
+			cfg.Models.Deny = splitList(setting.Value)
+		}
+	}
+	return cfg
+}
+
+// watchReloadSignals reloads the configuration file whenever the process receives SIGHUP.
+func watchReloadSignals(path string, explicit map[string]bool) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	for range ch {
+		logrus.Info("Received SIGHUP, reloading configuration")
+		if err := reloadConfig(path, explicit); err != nil {
+			logrus.WithError(err).Error("Error reloading configuration")
+		}
+	}
+}
+
+// reloadHandler serves POST /-/reload. Requests must carry the token as a bearer token;
+// without a token the endpoint is disabled.
+func reloadHandler(path, token string, explicit map[string]bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if token == "" {
+			http.Error(w, "reloading over HTTP is disabled, set OPENAI_EXPORTER_RELOAD_TOKEN to enable it", http.StatusForbidden)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if path == "" {
+			http.Error(w, "no configuration file to reload, start the exporter with -config.file", http.StatusBadRequest)
+			return
+		}
+		if err := reloadConfig(path, explicit); err != nil {
+			logrus.WithError(err).Error("Error reloading configuration")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
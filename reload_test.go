@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadConfig(t *testing.T) {
+	origInterval, origEndpoints, origFilter, origLevel, origAttempts := *scrapeInterval, activeEndpoints, userFilter, logrus.GetLevel(), *retryMaxAttempts
+	defer func(base Config, rules []RelabelRule) {
+		*scrapeInterval, activeEndpoints, userFilter, *retryMaxAttempts = origInterval, origEndpoints, origFilter, origAttempts
+		logrus.SetLevel(origLevel)
+		baseConfig, relabelRules = base, rules
+	}(baseConfig, relabelRules)
+	// The command line gave no reloadable settings.
+	baseConfig = Config{ScrapeInterval: "1m0s", LogLevel: "info"}
+	usageState = map[string]float64{"completions|1000|proj-1|user-1|key-1|gpt-4|false|input": 1}
+
+	path := writeConfigFile(t, `
+scrape.interval: 3m
+log.level: warn
+endpoints: [embeddings]
+filter.users.deny: [bot-*]
+api.retry.max-attempts: 9
+`)
+
+	require.NoError(t, reloadConfig(path, nil))
+	assert.Equal(t, 3*time.Minute, currentScrapeInterval())
+	assert.Equal(t, logrus.WarnLevel, logrus.GetLevel())
+	assert.Equal(t, []string{"embeddings"}, endpointNames(currentEndpoints()))
+	assert.False(t, userFilter.allowed("bot-1"))
+	assert.Equal(t, origAttempts, *retryMaxAttempts, "settings that need a restart are not applied")
+	assert.Len(t, usageState, 1, "deduplication state survives reloads")
+	assert.Equal(t, 1.0, testutil.ToFloat64(reloadSuccessful))
+
+	t.Run("command-line flags keep their values", func(t *testing.T) {
+		*scrapeInterval = time.Minute
+		require.NoError(t, reloadConfig(path, map[string]bool{"scrape.interval": true}))
+		assert.Equal(t, time.Minute, currentScrapeInterval())
+	})
+
+	t.Run("settings removed from the file are reset", func(t *testing.T) {
+		withRelabel := writeConfigFile(t, `
+scrape.interval: 3m
+filter.users.deny: [bot-*]
+relabel_configs:
+  - source_labels: [model]
+    regex: gpt-4o-.*
+    action: drop
+`)
+		require.NoError(t, reloadConfig(withRelabel, nil))
+		require.False(t, userFilter.allowed("bot-1"))
+		require.Len(t, currentRelabelRules(), 1)
+
+		require.NoError(t, reloadConfig(writeConfigFile(t, "log.level: warn\n"), nil))
+		assert.True(t, userFilter.allowed("bot-1"), "a removed filter no longer applies")
+		assert.Empty(t, currentRelabelRules(), "removed relabel rules no longer apply")
+		assert.Equal(t, time.Minute, currentScrapeInterval(), "a removed setting takes its default")
+		assert.Equal(t, endpointNames(usageEndpoints), endpointNames(currentEndpoints()))
+	})
+
+	t.Run("bad configuration keeps the current one", func(t *testing.T) {
+		bad := writeConfigFile(t, "scrape.interval: 30s\n")
+		err := reloadConfig(bad, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "at least 1m")
+		assert.Equal(t, time.Minute, currentScrapeInterval())
+		assert.Equal(t, 0.0, testutil.ToFloat64(reloadSuccessful))
+	})
+}
+
+func TestReloadHandler(t *testing.T) {
+	origInterval := *scrapeInterval
+	defer func() { *scrapeInterval = origInterval }()
+	path := writeConfigFile(t, "scrape.interval: 2m\n")
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		auth       string
+		wantStatus int
+	}{
+		{name: "GET is not allowed", method: http.MethodGet, path: path, token: "secret", auth: "Bearer secret", wantStatus: http.StatusMethodNotAllowed},
+		{name: "disabled without a token", method: http.MethodPost, path: path, wantStatus: http.StatusForbidden},
+		{name: "wrong token", method: http.MethodPost, path: path, token: "secret", auth: "Bearer guess", wantStatus: http.StatusUnauthorized},
+		{name: "no configuration file", method: http.MethodPost, token: "secret", auth: "Bearer secret", wantStatus: http.StatusBadRequest},
+		{name: "successful reload", method: http.MethodPost, path: path, token: "secret", auth: "Bearer secret", wantStatus: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/-/reload", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			reloadHandler(tt.path, tt.token, nil)(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+	assert.Equal(t, 2*time.Minute, currentScrapeInterval())
+
+	t.Run("invalid file is reported", func(t *testing.T) {
+		bad := writeConfigFile(t, "scrape.interval: [\n")
+		req := httptest.NewRequest(http.MethodPost, "/-/reload", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		reloadHandler(bad, "secret", nil)(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Remote Configuration
+
+var (
+	remoteBackend      = flag.String("config.remote.backend", "", "Remote configuration backend to load and watch settings from (consul or etcd)")
+	remoteAddress      = flag.String("config.remote.address", "", "Address of the remote configuration backend (e.g. http://127.0.0.1:8500)")
+	remoteKey          = flag.String("config.remote.key", "openai-exporter/config", "Key holding the YAML configuration document in the remote backend")
+	remotePollInterval = flag.Duration("config.remote.poll-interval", 30*time.Second, "Interval between checks for configuration changes in etcd, and retry delay after backend errors")
+)
+
+// Config holds the settings that can be supplied, and changed at runtime, by a remote configuration backend.
+type Config struct {
+	ScrapeInterval string        `yaml:"scrape_interval"`
+	LogLevel       string        `yaml:"log_level"`
+	Endpoints      []string      `yaml:"endpoints"`
+	Users          Filter        `yaml:"users"`
+	Projects       Filter        `yaml:"projects"`
+	Models         Filter        `yaml:"models"`
+	Relabel        []RelabelRule `yaml:"relabel_configs"`
+	Budgets        []Budget      `yaml:"budgets"`
+}
+
+var (
+	configMu sync.RWMutex
+	// activeEndpoints is the subset of usageEndpoints that is currently collected.
+	activeEndpoints = usageEndpoints
+)
+
+// parseConfig decodes and validates a YAML configuration document.
+func parseConfig(data []byte) (*Config, error) {
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("error parsing configuration: %w", err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// validate checks every non-empty setting of cfg.
+func (cfg *Config) validate() error {
+	if cfg.ScrapeInterval != "" {
+		d, err := time.ParseDuration(cfg.ScrapeInterval)
+		if err != nil {
+			return fmt.Errorf("invalid scrape_interval %q: %w", cfg.ScrapeInterval, err)
+		}
+		if d < time.Minute {
+			return fmt.Errorf("scrape_interval must be at least 1m, got %s", d)
+		}
+	}
+	if cfg.LogLevel != "" {
+		if _, err := logrus.ParseLevel(cfg.LogLevel); err != nil {
+			return fmt.Errorf("invalid log_level %q: %w", cfg.LogLevel, err)
+		}
+	}
+	for _, name := range cfg.Endpoints {
+		if _, ok := findEndpoint(name); !ok {
+			return fmt.Errorf("unknown endpoint %q", name)
+		}
+	}
+	for i := range cfg.Relabel {
+		if err := cfg.Relabel[i].compile(); err != nil {
+			return fmt.Errorf("relabel_configs[%d]: %w", i, err)
+		}
+	}
+	if err := validateBudgets(cfg.Budgets); err != nil {
+		return err
+	}
+	return errors.Join(cfg.Users.validate("user"), cfg.Projects.validate("project"), cfg.Models.validate("model"))
+}
+
+func findEndpoint(name string) (UsageEndpoint, bool) {
+	for _, ep := range usageEndpoints {
+		if ep.Name == name {
+			return ep, true
+		}
+	}
+	return UsageEndpoint{}, false
+}
+
+// baseConfig holds the reloadable settings as given on the command line, or their defaults, before any
+// configuration file or document is applied. Settings a configuration leaves out fall back to it.
+var baseConfig Config
+
+// flagConfig returns the reloadable settings as the flags currently hold them.
+func flagConfig() Config {
+	return Config{
+		ScrapeInterval: scrapeInterval.String(),
+		LogLevel:       *logLevel,
+		Users:          Filter{Allow: splitList(*userAllowFlag), Deny: splitList(*userDenyFlag)},
+		Projects:       Filter{Allow: splitList(*projectAllowFlag), Deny: splitList(*projectDenyFlag)},
+		Models:         Filter{Allow: splitList(*modelAllowFlag), Deny: splitList(*modelDenyFlag)},
+	}
+}
+
+// applyConfig replaces the live settings with the ones from cfg. Settings cfg leaves out are reset to
+// baseConfig; without endpoints all are collected, and without relabel rules or budgets there are none.
+func applyConfig(cfg *Config) {
+	defer updateConfigInfo()
+	defer forgetBudgetSpend()
+
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	if interval := cmp.Or(cfg.ScrapeInterval, baseConfig.ScrapeInterval); interval != "" {
+		d, _ := time.ParseDuration(interval)
+		*scrapeInterval = d
+	}
+	if name := cmp.Or(cfg.LogLevel, baseConfig.LogLevel); name != "" {
+		level, _ := logrus.ParseLevel(name)
+		logrus.SetLevel(level)
+	}
+	activeEndpoints = usageEndpoints
+	if len(cfg.Endpoints) > 0 {
+		endpoints := make([]UsageEndpoint, 0, len(cfg.Endpoints))
+		for _, name := range cfg.Endpoints {
+			ep, _ := findEndpoint(name)
+			endpoints = append(endpoints, ep)
+		}
+		activeEndpoints = endpoints
+	}
+	userFilter = orFilter(cfg.Users, baseConfig.Users)
+	projectFilter = orFilter(cfg.Projects, baseConfig.Projects)
+	modelFilter = orFilter(cfg.Models, baseConfig.Models)
+	relabelRules = cfg.Relabel
+	budgets = cfg.Budgets
+	logrus.Infof("Applied configuration: scrape_interval=%s, log_level=%s, endpoints=%v",
+		*scrapeInterval, logrus.GetLevel(), endpointNames(activeEndpoints))
+}
+
+// configSources holds the last configuration applied from the configuration file and from the remote
+// backend. The remote document is merged over the file, so a file reload keeps the remote settings and a
+// remote change keeps the file's relabel rules and budgets unless it replaces them.
+var configSources struct {
+	mu           sync.Mutex
+	file, remote Config
+}
+
+// applyFileConfig applies cfg as the settings of the configuration file.
+func applyFileConfig(cfg *Config) {
+	configSources.mu.Lock()
+	defer configSources.mu.Unlock()
+	configSources.file = *cfg
+	applyConfig(mergeConfig(&configSources.remote, &configSources.file))
+}
+
+// applyRemoteConfig applies cfg as the settings of the remote configuration document.
+func applyRemoteConfig(cfg *Config) {
+	configSources.mu.Lock()
+	defer configSources.mu.Unlock()
+	configSources.remote = *cfg
+	applyConfig(mergeConfig(&configSources.remote, &configSources.file))
+}
+
+// mergeConfig returns the settings of over, falling back to those of under for settings over leaves out.
+func mergeConfig(over, under *Config) *Config {
+	cfg := *over
+	cfg.ScrapeInterval = cmp.Or(over.ScrapeInterval, under.ScrapeInterval)
+	cfg.LogLevel = cmp.Or(over.LogLevel, under.LogLevel)
+	if len(over.Endpoints) == 0 {
+		cfg.Endpoints = under.Endpoints
+	}
+	cfg.Users = orFilter(over.Users, under.Users)
+	cfg.Projects = orFilter(over.Projects, under.Projects)
+	cfg.Models = orFilter(over.Models, under.Models)
+	if over.Relabel == nil {
+		cfg.Relabel = under.Relabel
+	}
+	if over.Budgets == nil {
+		cfg.Budgets = under.Budgets
+	}
+	return &cfg
+}
+
+// orFilter returns f, or fallback if f is empty.
+func orFilter(f, fallback Filter) Filter {
+	if f.Allow == nil && f.Deny == nil {
+		return fallback
+	}
+	return f
+}
+
+func currentScrapeInterval() time.Duration {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return *scrapeInterval
+}
+
+func currentEndpoints() []UsageEndpoint {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return activeEndpoints
+}
+
+func endpointNames(endpoints []UsageEndpoint) []string {
+	names := make([]string, 0, len(endpoints))
+	for _, ep := range endpoints {
+		names = append(names, ep.Name)
+	}
+	return names
+}
+
+// configSource is a remote key/value store holding the configuration document.
+type configSource interface {
+	// Fetch returns the document once its version differs from index, along with the new version.
+	Fetch(index uint64) ([]byte, uint64, error)
+}
+
+func newConfigSource(backend, address, key string) (configSource, error) {
+	client := &http.Client{Timeout: 10 * time.Minute}
+	address = strings.TrimSuffix(address, "/")
+	switch backend {
+	case "consul":
+		if address == "" {
+			address = "http://127.0.0.1:8500"
+		}
+		return &consulSource{client: client, address: address, key: key, token: os.Getenv("CONSUL_HTTP_TOKEN")}, nil
+	case "etcd":
+		if address == "" {
+			address = "http://127.0.0.1:2379"
+		}
+		return &etcdSource{client: client, address: address, key: key, pollInterval: *remotePollInterval}, nil
+	default:
+		return nil, fmt.Errorf("unsupported remote configuration backend %q", backend)
+	}
+}
+
+// consulSource reads the configuration from Consul KV using blocking queries.
+type consulSource struct {
+	client  *http.Client
+	address string
+	key     string
+	token   string
+}
+
+func (c *consulSource) Fetch(index uint64) ([]byte, uint64, error) {
+	url := fmt.Sprintf("%s/v1/kv/%s?raw", c.address, strings.TrimPrefix(c.key, "/"))
+	if index > 0 {
+		url += fmt.Sprintf("&index=%d&wait=5m", index)
+	}
+	logrus.Debugf("Fetching configuration from consul: %s", url)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, index, fmt.Errorf("failed to create request: %w", err)
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, index, fmt.Errorf("error fetching configuration from consul: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, index, fmt.Errorf("consul returned status %d for key %s", resp.StatusCode, c.key)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, index, fmt.Errorf("error reading consul response: %w", err)
+	}
+	newIndex, err := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if err != nil {
+		return nil, index, fmt.Errorf("invalid X-Consul-Index header: %w", err)
+	}
+	// Consul requires the index to be reset if it goes backwards, e.g. after a snapshot restore.
+	if newIndex < index {
+		newIndex = 0
+	}
+	return body, newIndex, nil
+}
+
+// etcdSource reads the configuration from etcd through its v3 JSON gateway, polling for new revisions.
+type etcdSource struct {
+	client       *http.Client
+	address      string
+	key          string
+	pollInterval time.Duration
+}
+
+type etcdRangeResponse struct {
+	Kvs []struct {
+		Value       string `json:"value"`
+		ModRevision string `json:"mod_revision"`
+	} `json:"kvs"`
+}
+
+func (e *etcdSource) Fetch(index uint64) ([]byte, uint64, error) {
+	for {
+		value, revision, err := e.get()
+		if err != nil {
+			return nil, index, err
+		}
+		if revision != index {
+			return value, revision, nil
+		}
+		time.Sleep(e.pollInterval)
+	}
+}
+
+func (e *etcdSource) get() ([]byte, uint64, error) {
+	reqBody, err := json.Marshal(map[string]string{"key": base64.StdEncoding.EncodeToString([]byte(e.key))})
+	if err != nil {
+		return nil, 0, err
+	}
+	url := e.address + "/v3/kv/range"
+	logrus.Debugf("Fetching configuration from etcd: %s", url)
+
+	resp, err := e.client.Post(url, "application/json", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, 0, fmt.Errorf("error fetching configuration from etcd: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, 0, fmt.Errorf("etcd returned status %d for key %s", resp.StatusCode, e.key)
+	}
+
+	var out etcdRangeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, 0, fmt.Errorf("error decoding etcd response: %w", err)
+	}
+	if len(out.Kvs) == 0 {
+		return nil, 0, fmt.Errorf("key %s not found in etcd", e.key)
+	}
+	value, err := base64.StdEncoding.DecodeString(out.Kvs[0].Value)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error decoding etcd value: %w", err)
+	}
+	revision, err := strconv.ParseUint(out.Kvs[0].ModRevision, 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid etcd mod_revision: %w", err)
+	}
+	return value, revision, nil
+}
+
+// watchRemoteConfig applies every version of the remote configuration document newer than index until the
+// process exits. Invalid documents are logged and ignored, leaving the previous settings in place.
+func watchRemoteConfig(src configSource, index uint64) {
+	for {
+		data, newIndex, err := src.Fetch(index)
+		if err != nil {
+			logrus.WithError(err).Warn("Error fetching remote configuration")
+			time.Sleep(*remotePollInterval)
+			continue
+		}
+		if newIndex == index {
+			continue
+		}
+		index = newIndex
+
+		cfg, err := parseConfig(data)
+		if err != nil {
+			logrus.WithError(err).Error("Ignoring invalid remote configuration")
+			continue
+		}
+		applyRemoteConfig(cfg)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{
+			name:  "valid config",
+			input: "scrape_interval: 2m\nlog_level: debug\nendpoints: [completions, embeddings]\n",
+		},
+		{
+			name:  "empty config",
+			input: "",
+		},
+		{
+			name:    "invalid yaml",
+			input:   "scrape_interval: [",
+			wantErr: "error parsing configuration",
+		},
+		{
+			name:    "invalid interval",
+			input:   "scrape_interval: soon",
+			wantErr: "invalid scrape_interval",
+		},
+		{
+			name:    "interval below one minute",
+			input:   "scrape_interval: 30s",
+			wantErr: "at least 1m",
+		},
+		{
+			name:    "invalid log level",
+			input:   "log_level: loud",
+			wantErr: "invalid log_level",
+		},
+		{
+			name:    "invalid user pattern",
+			input:   "users:\n  deny: [\"bot-[\"]",
+			wantErr: "invalid user pattern",
+		},
+		{
+			name:    "unknown endpoint",
+			input:   "endpoints: [completions, chat]",
+			wantErr: `unknown endpoint "chat"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseConfig([]byte(tt.input))
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	origInterval, origEndpoints, origLevel := *scrapeInterval, activeEndpoints, logrus.GetLevel()
+	defer func() {
+		*scrapeInterval, activeEndpoints = origInterval, origEndpoints
+		logrus.SetLevel(origLevel)
+	}()
+
+	cfg, err := parseConfig([]byte("scrape_interval: 5m\nlog_level: warn\nendpoints: [embeddings]\n"))
+	require.NoError(t, err)
+	applyConfig(cfg)
+
+	assert.Equal(t, 5*time.Minute, currentScrapeInterval())
+	assert.Equal(t, logrus.WarnLevel, logrus.GetLevel())
+	assert.Equal(t, []string{"embeddings"}, endpointNames(currentEndpoints()))
+
+	t.Run("empty fields are reset", func(t *testing.T) {
+		defer func(base Config) { baseConfig = base }(baseConfig)
+		baseConfig = Config{ScrapeInterval: "1h"}
+		applyConfig(&Config{})
+		assert.Equal(t, time.Hour, currentScrapeInterval())
+		assert.Equal(t, endpointNames(usageEndpoints), endpointNames(currentEndpoints()))
+	})
+}
+
+func TestConfigSources(t *testing.T) {
+	origInterval, origEndpoints, origRules, origBudgets := *scrapeInterval, activeEndpoints, relabelRules, budgets
+	defer func(base Config) {
+		*scrapeInterval, activeEndpoints, relabelRules, budgets, baseConfig = origInterval, origEndpoints, origRules, origBudgets, base
+		configSources.file, configSources.remote = Config{}, Config{}
+	}(baseConfig)
+	baseConfig = Config{ScrapeInterval: "1m0s"}
+	configSources.file, configSources.remote = Config{}, Config{}
+
+	withRelabel := writeConfigFile(t, `
+scrape.interval: 3m
+relabel_configs:
+  - source_labels: [model]
+    regex: gpt-4o-.*
+    action: drop
+`)
+	require.NoError(t, reloadConfig(withRelabel, nil))
+	remote, err := parseConfig([]byte("scrape_interval: 5m\nbudgets:\n  - name: company\n    monthly_usd: 500\n"))
+	require.NoError(t, err)
+	applyRemoteConfig(remote)
+
+	assert.Equal(t, 5*time.Minute, currentScrapeInterval(), "the remote configuration is merged over the file")
+	assert.Len(t, currentRelabelRules(), 1, "a remote change keeps the file's relabel rules")
+	assert.Len(t, currentBudgets(), 1)
+
+	require.NoError(t, reloadConfig(writeConfigFile(t, "log.level: info\n"), nil))
+	assert.Empty(t, currentRelabelRules(), "relabel rules removed from the file no longer apply")
+	assert.Len(t, currentBudgets(), 1, "a file reload keeps the remote budgets")
+	assert.Equal(t, 5*time.Minute, currentScrapeInterval())
+
+	applyRemoteConfig(&Config{})
+	assert.Empty(t, currentBudgets(), "budgets removed from the remote document no longer apply")
+	assert.Equal(t, time.Minute, currentScrapeInterval())
+}
+
+func TestNewConfigSource(t *testing.T) {
+	_, err := newConfigSource("zookeeper", "", "key")
+	assert.Error(t, err)
+
+	src, err := newConfigSource("consul", "", "key")
+	require.NoError(t, err)
+	assert.Equal(t, "http://127.0.0.1:8500", src.(*consulSource).address)
+
+	src, err = newConfigSource("etcd", "http://etcd:2379/", "key")
+	require.NoError(t, err)
+	assert.Equal(t, "http://etcd:2379", src.(*etcdSource).address)
+}
+
+func TestConsulSource_Fetch(t *testing.T) {
+	var gotQuery, gotToken string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.RawQuery
+		gotToken = r.Header.Get("X-Consul-Token")
+		if r.URL.Path != "/v1/kv/openai-exporter/config" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("X-Consul-Index", "42")
+		_, _ = w.Write([]byte("log_level: debug\n"))
+	}))
+	defer server.Close()
+
+	src := &consulSource{client: server.Client(), address: server.URL, key: "openai-exporter/config", token: "secret"}
+
+	t.Run("initial fetch", func(t *testing.T) {
+		data, index, err := src.Fetch(0)
+		require.NoError(t, err)
+		assert.Equal(t, "log_level: debug\n", string(data))
+		assert.Equal(t, uint64(42), index)
+		assert.Equal(t, "raw", gotQuery)
+		assert.Equal(t, "secret", gotToken)
+	})
+
+	t.Run("blocking query", func(t *testing.T) {
+		_, _, err := src.Fetch(41)
+		require.NoError(t, err)
+		assert.Equal(t, "raw&index=41&wait=5m", gotQuery)
+	})
+
+	t.Run("index going backwards is reset", func(t *testing.T) {
+		_, index, err := src.Fetch(50)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(0), index)
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		missing := &consulSource{client: server.Client(), address: server.URL, key: "other"}
+		_, _, err := missing.Fetch(0)
+		assert.Error(t, err)
+	})
+}
+
+func TestEtcdSource_Fetch(t *testing.T) {
+	revision := "7"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		key, _ := base64.StdEncoding.DecodeString(req["key"])
+		if r.URL.Path != "/v3/kv/range" || string(key) != "openai-exporter/config" {
+			_, _ = w.Write([]byte(`{}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"kvs": []map[string]string{{
+				"value":        base64.StdEncoding.EncodeToString([]byte("scrape_interval: 2m\n")),
+				"mod_revision": revision,
+			}},
+		})
+		revision = "8"
+	}))
+	defer server.Close()
+
+	src := &etcdSource{client: server.Client(), address: server.URL, key: "openai-exporter/config", pollInterval: time.Millisecond}
+
+	data, index, err := src.Fetch(0)
+	require.NoError(t, err)
+	assert.Equal(t, "scrape_interval: 2m\n", string(data))
+	assert.Equal(t, uint64(7), index)
+
+	_, index, err = src.Fetch(index)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(8), index)
+
+	missing := &etcdSource{client: server.Client(), address: server.URL, key: "other"}
+	_, _, err = missing.Fetch(0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Retries and Backoff
+
+var (
+	retryMaxAttempts = flag.Int("api.retry.max-attempts", 3, "Maximum number of attempts per API request, including the first one")
+	retryBackoff     = flag.Duration("api.retry.backoff", time.Second, "Delay before the first retry; doubles with every further retry")
+	retryMaxBackoff  = flag.Duration("api.retry.max-backoff", 30*time.Second, "Maximum delay between two attempts; a longer Retry-After ends the retries")
+	retryJitter      = flag.Float64("api.retry.jitter", 0.2, "Random extra delay added to every backoff, as a fraction of it")
+
+	// sleep waits before a retry and reports whether it did so before the context was done; replaced in tests.
+	sleep = sleepContext
+)
+
+// retryPolicy decides whether and when a failed request is attempted again.
+// The zero value makes a single attempt.
+type retryPolicy struct {
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	jitter      float64
+}
+
+func retryPolicyFromFlags() retryPolicy {
+	return retryPolicy{
+		maxAttempts: *retryMaxAttempts,
+		backoff:     *retryBackoff,
+		maxBackoff:  *retryMaxBackoff,
+		jitter:      *retryJitter,
+	}
+}
+
+// retryable reports whether the outcome of an attempt is worth retrying: transport errors,
+// rate limiting and server errors.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// next returns the delay before the attempt following attempt (1-based), or false if there is none.
+// A Retry-After header takes precedence over the exponential backoff.
+func (p retryPolicy) next(attempt int, resp *http.Response, now time.Time) (time.Duration, bool) {
+	if attempt >= p.maxAttempts {
+		return 0, false
+	}
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+			if d > p.maxBackoff {
+				return 0, false
+			}
+			return d, true
+		}
+	}
+
+	d := p.backoff
+	for i := 1; i < attempt && d < p.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.maxBackoff {
+		d = p.maxBackoff
+	}
+	return d + jitter(time.Duration(float64(d)*p.jitter)), true
+}
+
+// parseRetryAfter parses a Retry-After value given in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy_Next(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	policy := retryPolicy{maxAttempts: 4, backoff: time.Second, maxBackoff: 5 * time.Second}
+
+	withHeader := func(value string) *http.Response {
+		return &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{value}}}
+	}
+
+	tests := []struct {
+		name      string
+		attempt   int
+		resp      *http.Response
+		wantDelay time.Duration
+		wantRetry bool
+	}{
+		{name: "first retry uses the base backoff", attempt: 1, wantDelay: time.Second, wantRetry: true},
+		{name: "backoff doubles", attempt: 2, wantDelay: 2 * time.Second, wantRetry: true},
+		{name: "backoff is capped", attempt: 3, wantDelay: 4 * time.Second, wantRetry: true},
+		{name: "attempts are exhausted", attempt: 4, wantRetry: false},
+		{name: "Retry-After in seconds", attempt: 1, resp: withHeader("3"), wantDelay: 3 * time.Second, wantRetry: true},
+		{name: "Retry-After as HTTP date", attempt: 1, resp: withHeader(now.Add(2 * time.Second).Format(http.TimeFormat)), wantDelay: 2 * time.Second, wantRetry: true},
+		{name: "Retry-After beyond the maximum ends retries", attempt: 1, resp: withHeader("60"), wantRetry: false},
+		{name: "invalid Retry-After falls back to backoff", attempt: 1, resp: withHeader("soon"), wantDelay: time.Second, wantRetry: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, retry := policy.next(tt.attempt, tt.resp, now)
+			assert.Equal(t, tt.wantRetry, retry)
+			assert.Equal(t, tt.wantDelay, delay)
+		})
+	}
+
+	t.Run("jitter adds at most the configured fraction", func(t *testing.T) {
+		p := retryPolicy{maxAttempts: 2, backoff: time.Second, maxBackoff: time.Minute, jitter: 0.5}
+		for i := 0; i < 20; i++ {
+			delay, _ := p.next(1, nil, now)
+			assert.GreaterOrEqual(t, delay, time.Second)
+			assert.Less(t, delay, 1500*time.Millisecond)
+		}
+	})
+
+	t.Run("zero policy makes a single attempt", func(t *testing.T) {
+		_, retry := retryPolicy{}.next(1, nil, now)
+		assert.False(t, retry)
+	})
+}
+
+func TestRetryable(t *testing.T) {
+	assert.True(t, retryable(nil, errors.New("connection reset")))
+	assert.True(t, retryable(&http.Response{StatusCode: http.StatusTooManyRequests}, nil))
+	assert.True(t, retryable(&http.Response{StatusCode: http.StatusServiceUnavailable}, nil))
+	assert.False(t, retryable(&http.Response{StatusCode: http.StatusOK}, nil))
+	assert.False(t, retryable(&http.Response{StatusCode: http.StatusUnauthorized}, nil))
+}
+
+func TestExporterGet_Retries(t *testing.T) {
+	var delays []time.Duration
+	origSleep := sleep
+	sleep = func(_ context.Context, d time.Duration) bool {
+		delays = append(delays, d)
+		return true
+	}
+	defer func() { sleep = origSleep }()
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	e := &Exporter{
+		client:  server.Client(),
+		apiKey:  "test",
+		targets: newAPITargets("", server.URL, 10),
+		retry:   retryPolicy{maxAttempts: 3, backoff: time.Second, maxBackoff: 10 * time.Second},
+	}
+
+	resp, err := e.get("/v1/organization/usage/completions")
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(3), calls.Load())
+	assert.Equal(t, []time.Duration{2 * time.Second, 2 * time.Second}, delays)
+
+	t.Run("last status is reported when attempts are exhausted", func(t *testing.T) {
+		calls.Store(0)
+		delays = nil
+		e.retry.maxAttempts = 2
+
+		_, err := e.get("/v1/organization/usage/completions")
+		var apiErr *APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+		assert.Len(t, delays, 1)
+	})
+
+	t.Run("cancelled requests stop waiting", func(t *testing.T) {
+		calls.Store(1)
+		sleep = func(context.Context, time.Duration) bool { return false }
+		e.retry.maxAttempts = 3
+
+		_, err := e.get("/v1/organization/usage/completions")
+		assert.ErrorContains(t, err, "cancelled while waiting to retry")
+		assert.Equal(t, int32(2), calls.Load(), "no further attempt is made")
+	})
+}
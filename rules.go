@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Recommended Prometheus Rules
+
+// ruleFile is a Prometheus rule file.
+type ruleFile struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// promDuration formats d like a Prometheus duration, e.g. 1h30m.
+func promDuration(d time.Duration) string {
+	return model.Duration(d).String()
+}
+
+// recommendedRules returns the recording and alerting rules for the current configuration: the health of
+// the exporter always, and budgets, estimated costs and anomalies when they are configured. Windows and
+// delays scale with the scrape interval, since usage only moves once per interval.
+func recommendedRules(interval time.Duration, budgetList []Budget, thresholds []float64) ruleFile {
+	rateWindow := promDuration(max(time.Hour, 4*interval))
+	pending := promDuration(max(15*time.Minute, 2*interval))
+	stale := max(30*time.Minute, 3*interval)
+
+	health := ruleGroup{Name: "openai-exporter", Rules: []rule{
+		{
+			Alert:       "OpenAIExporterCollectionFailing",
+			Expr:        "openai_exporter_up == 0",
+			For:         pending,
+			Labels:      map[string]string{"severity": "warning"},
+			Annotations: map[string]string{"summary": "The OpenAI exporter on {{ $labels.instance }} fails to fetch usage or costs"},
+		},
+		{
+			Alert:       "OpenAIExporterFetchErrors",
+			Expr:        fmt.Sprintf("sum by (org_id, endpoint) (increase(openai_exporter_scrape_errors_total[%s])) > 0", rateWindow),
+			For:         pending,
+			Labels:      map[string]string{"severity": "info"},
+			Annotations: map[string]string{"summary": "Fetching {{ $labels.endpoint }} of {{ $labels.org_id }} failed"},
+		},
+		{
+			Alert:       "OpenAIUsageStale",
+			Expr:        fmt.Sprintf("time() - openai_exporter_last_success_timestamp_seconds > %d", int64(stale/time.Second)),
+			Labels:      map[string]string{"severity": "warning"},
+			Annotations: map[string]string{"summary": "{{ $labels.endpoint }} of {{ $labels.org_id }} was last fetched {{ $value | humanizeDuration }} ago"},
+		},
+	}}
+
+	usage := ruleGroup{Name: "openai-usage", Rules: []rule{
+		{
+			Record: "org_project_model:openai_api_tokens:rate" + rateWindow,
+			Expr:   fmt.Sprintf("sum by (org_id, org_name, project_id, project_name, model, token_type) (rate(openai_api_tokens_total[%s]))", rateWindow),
+		},
+	}}
+	if *pricingEnabled {
+		usage.Rules = append(usage.Rules, rule{
+			Record: "org_project:openai_api_estimated_cost_usd:rate" + rateWindow,
+			Expr:   fmt.Sprintf("sum by (org_id, org_name, project_id, project_name) (rate(openai_api_estimated_cost_usd_total[%s]))", rateWindow),
+		})
+	}
+	if *anomalyEnabled {
+		usage.Rules = append(usage.Rules, rule{
+			Alert:       "OpenAIUsageAnomaly",
+			Expr:        "openai_usage_anomaly_score and openai_usage_anomaly == 1",
+			Labels:      map[string]string{"severity": "warning"},
+			Annotations: map[string]string{"summary": "{{ $labels.project_name }} uses {{ $labels.model }} at {{ $value | printf \"%.1f\" }} times its usual token rate"},
+		})
+	}
+
+	groups := []ruleGroup{health, usage}
+	if len(budgetList) > 0 {
+		budget := ruleGroup{Name: "openai-budgets"}
+		for _, t := range thresholds {
+			name, severity := "OpenAIBudgetThresholdCrossed", "warning"
+			if t >= 1 {
+				name, severity = "OpenAIBudgetExceeded", "critical"
+			}
+			budget.Rules = append(budget.Rules, rule{
+				Alert:       name,
+				Expr:        fmt.Sprintf("openai_budget_utilization_ratio >= %g", t),
+				Labels:      map[string]string{"severity": severity},
+				Annotations: map[string]string{"summary": fmt.Sprintf("Budget {{ $labels.budget }} of {{ $labels.org_name }} spent %.0f%% of its monthly limit", t*100)},
+			})
+		}
+		// Spending faster than the month elapses, by half again, exhausts the budget before the month ends.
+		budget.Rules = append(budget.Rules, rule{
+			Alert:       "OpenAIBudgetBurnRateHigh",
+			Expr:        "openai_budget_utilization_ratio > 0.1 and openai_budget_utilization_ratio > 1.5 * (scalar(day_of_month()) - 1 + scalar(hour()) / 24) / scalar(days_in_month())",
+			For:         "1h",
+			Labels:      map[string]string{"severity": "warning"},
+			Annotations: map[string]string{"summary": "Budget {{ $labels.budget }} of {{ $labels.org_name }} is on track to run out before the end of the month"},
+		})
+		groups = append(groups, budget)
+	}
+	return ruleFile{Groups: groups}
+}
+
+// runRules prints the recommended rules for the configuration in args and returns the exit code.
+func runRules(args []string, out io.Writer) int {
+	// Progress logs would only clutter the rules; -log.level still enables them.
+	_ = flag.Lookup("log.level").Value.Set("warn")
+	if _, err := configure(args); err != nil {
+		logrus.WithError(err).Error("Invalid configuration")
+		return 1
+	}
+	enc := yaml.NewEncoder(out)
+	enc.SetIndent(2)
+	if err := enc.Encode(recommendedRules(currentScrapeInterval(), currentBudgets(), budgetThresholds)); err != nil {
+		logrus.WithError(err).Error("Error writing rules")
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecommendedRules(t *testing.T) {
+	names := func(f ruleFile) map[string][]string {
+		out := make(map[string][]string)
+		for _, g := range f.Groups {
+			for _, r := range g.Rules {
+				out[g.Name] = append(out[g.Name], r.Alert+r.Record)
+			}
+		}
+		return out
+	}
+
+	origPricing, origAnomaly := *pricingEnabled, *anomalyEnabled
+	defer func() { *pricingEnabled, *anomalyEnabled = origPricing, origAnomaly }()
+
+	*pricingEnabled, *anomalyEnabled = false, false
+	rules := recommendedRules(5*time.Minute, nil, []float64{0.8, 1})
+	assert.Equal(t, map[string][]string{
+		"openai-exporter": {"OpenAIExporterCollectionFailing", "OpenAIExporterFetchErrors", "OpenAIUsageStale"},
+		"openai-usage":    {"org_project_model:openai_api_tokens:rate1h"},
+	}, names(rules))
+	assert.Equal(t, "15m", rules.Groups[0].Rules[0].For)
+	assert.Equal(t, "time() - openai_exporter_last_success_timestamp_seconds > 1800", rules.Groups[0].Rules[2].Expr)
+
+	*pricingEnabled, *anomalyEnabled = true, true
+	rules = recommendedRules(time.Hour, []Budget{{ProjectID: "proj-1", MonthlyUSD: 100}}, []float64{0.5, 0.8, 1})
+	assert.Equal(t, map[string][]string{
+		"openai-exporter": {"OpenAIExporterCollectionFailing", "OpenAIExporterFetchErrors", "OpenAIUsageStale"},
+		"openai-usage":    {"org_project_model:openai_api_tokens:rate4h", "org_project:openai_api_estimated_cost_usd:rate4h", "OpenAIUsageAnomaly"},
+		"openai-budgets":  {"OpenAIBudgetThresholdCrossed", "OpenAIBudgetThresholdCrossed", "OpenAIBudgetExceeded", "OpenAIBudgetBurnRateHigh"},
+	}, names(rules), "windows grow with the scrape interval")
+	assert.Equal(t, "2h", rules.Groups[0].Rules[0].For)
+	assert.Equal(t, "time() - openai_exporter_last_success_timestamp_seconds > 10800", rules.Groups[0].Rules[2].Expr)
+	assert.Equal(t, "openai_budget_utilization_ratio >= 0.8", rules.Groups[2].Rules[1].Expr)
+	assert.Equal(t, "critical", rules.Groups[2].Rules[2].Labels["severity"])
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// API Key File
+
+var (
+	apiKeyFile = flag.String("openai.api-key-file", "", "File holding the OpenAI admin API key, or several one per line, re-read when it changes (overrides OPENAI_SECRET_KEY_FILE and OPENAI_SECRET_KEY)")
+
+	keyReloadsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "openai_exporter_key_reloads_total",
+			Help: "Total number of times a changed admin key file was reloaded.",
+		},
+	)
+)
+
+// secretFile is a secret mounted as a file, e.g. from a Kubernetes or Docker secret.
+// The file is re-read whenever its modification time or size changes, so rotated secrets
+// are picked up without a restart.
+type secretFile struct {
+	mu      sync.Mutex
+	path    string
+	modTime time.Time
+	size    int64
+	secret  string
+}
+
+// newSecretFile reads the secret at path, failing if it cannot be read or is empty.
+func newSecretFile(path string) (*secretFile, error) {
+	f := &secretFile{path: path}
+	if _, err := f.value(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// value returns the current secret. If the file changed but cannot be read, the previous secret is kept.
+func (f *secretFile) value() (string, error) {
+	return f.read(false)
+}
+
+// read returns the current secret, re-reading the file if it changed or force is set.
+func (f *secretFile) read(force bool) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return f.fallback(fmt.Errorf("error reading secret file: %w", err))
+	}
+	if !force && f.secret != "" && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.secret, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return f.fallback(fmt.Errorf("error reading secret file: %w", err))
+	}
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return f.fallback(fmt.Errorf("secret file %s is empty", f.path))
+	}
+	if f.secret != "" && secret != f.secret {
+		logrus.Infof("Secret in %s changed, using the new value", f.path)
+		keyReloadsTotal.Inc()
+	}
+	f.secret, f.modTime, f.size = secret, info.ModTime(), info.Size()
+	return f.secret, nil
+}
+
+func (f *secretFile) fallback(err error) (string, error) {
+	if f.secret == "" {
+		return "", err
+	}
+	logrus.WithError(err).Warn("Keeping the previous secret")
+	return f.secret, nil
+}
+
+// watch re-reads the secret as soon as its file changes, rather than on the next request, until ctx is done.
+// It watches the directory rather than the file, since Kubernetes rotates a mounted Secret by swapping a
+// symlink in the directory, which replaces the file instead of writing to it. Without a watch, changes are
+// still picked up on the next request.
+func (f *secretFile) watch(ctx context.Context) {
+	w, err := fsnotify.NewWatcher()
+	if err == nil {
+		err = w.Add(filepath.Dir(f.path))
+		if err != nil {
+			_ = w.Close()
+		}
+	}
+	if err != nil {
+		logrus.WithError(err).Warnf("Error watching %s, re-reading it before requests only", f.path)
+		return
+	}
+	defer func() { _ = w.Close() }()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			// A failed read keeps the previous secret and logs why.
+			_, _ = f.read(true)
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			logrus.WithError(err).Warnf("Error watching %s", f.path)
+		}
+	}
+}
+
+// watchKeyFiles watches the key files of the organizations collected by c until ctx is done.
+func watchKeyFiles(ctx context.Context, c windowCollector) {
+	var exporters []*Exporter
+	switch c := c.(type) {
+	case *Exporter:
+		exporters = []*Exporter{c}
+	case orgExporters:
+		exporters = c
+	}
+	for _, e := range exporters {
+		if e.keyFile != nil {
+			go e.keyFile.watch(ctx)
+		}
+	}
+}
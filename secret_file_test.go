@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSecret(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestNewSecretFile(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		content string
+		missing bool
+		want    string
+		wantErr string
+	}{
+		{name: "trims surrounding whitespace", content: "  sk-admin\n", want: "sk-admin"},
+		{name: "empty file", content: "\n", wantErr: "is empty"},
+		{name: "missing file", missing: true, wantErr: "error reading secret file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if !tt.missing {
+				writeSecret(t, path, tt.content, modTime)
+			}
+
+			f, err := newSecretFile(path)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			got, err := f.value()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSecretFile_Rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-key")
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	writeSecret(t, path, "sk-old", modTime)
+
+	f, err := newSecretFile(path)
+	require.NoError(t, err)
+
+	// Same size and modification time: the cached value is returned.
+	require.NoError(t, os.WriteFile(path, []byte("sk-new"), 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+	got, err := f.value()
+	require.NoError(t, err)
+	assert.Equal(t, "sk-old", got)
+
+	writeSecret(t, path, "sk-new", modTime.Add(time.Minute))
+	got, err = f.value()
+	require.NoError(t, err)
+	assert.Equal(t, "sk-new", got)
+
+	writeSecret(t, path, "", modTime.Add(2*time.Minute))
+	got, err = f.value()
+	require.NoError(t, err)
+	assert.Equal(t, "sk-new", got, "an emptied file keeps the previous secret")
+
+	require.NoError(t, os.Remove(path))
+	got, err = f.value()
+	require.NoError(t, err)
+	assert.Equal(t, "sk-new", got, "a removed file keeps the previous secret")
+
+	writeSecret(t, path, "sk-rotated", modTime.Add(3*time.Minute))
+	got, err = f.value()
+	require.NoError(t, err)
+	assert.Equal(t, "sk-rotated", got)
+}
+
+func TestSecretFile_Watch(t *testing.T) {
+	// The layout of a mounted Kubernetes Secret: the key is a symlink through ..data to a timestamped
+	// directory, and rotation swaps ..data to a new one.
+	dir := t.TempDir()
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for name, key := range map[string]string{"..2024_01_01": "sk-old", "..2024_01_02": "sk-new"} {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, name), 0o700))
+		// The same size and modification time, so only the watch notices the new key.
+		writeSecret(t, filepath.Join(dir, name, "api-key"), key, modTime)
+	}
+	require.NoError(t, os.Symlink("..2024_01_01", filepath.Join(dir, "..data")))
+	require.NoError(t, os.Symlink("..data/api-key", filepath.Join(dir, "api-key")))
+
+	f, err := newSecretFile(filepath.Join(dir, "api-key"))
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go f.watch(ctx)
+	reloads := testutil.ToFloat64(keyReloadsTotal)
+
+	// Wait until the watch is set up, which the swap would otherwise race with.
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, os.Symlink("..2024_01_02", filepath.Join(dir, "..data_tmp")))
+	require.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+
+	assert.Eventually(t, func() bool {
+		got, err := f.value()
+		return err == nil && got == "sk-new"
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, reloads+1, testutil.ToFloat64(keyReloadsTotal))
+}
+
+func TestNewExporter_APIKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-key")
+	writeSecret(t, path, "sk-from-file\n", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	t.Setenv("OPENAI_SECRET_KEY", "")
+	t.Setenv("OPENAI_SECRET_KEY_FILE", path)
+	t.Setenv("OPENAI_ORG_ID", "org-123")
+
+	e, err := NewExporter()
+	require.NoError(t, err)
+	require.NotNil(t, e.keyFile)
+
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	e.client = server.Client()
+	e.targets = newAPITargets("", server.URL, 10)
+
+	resp, err := e.get("/v1/organization/projects")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, "Bearer sk-from-file", auth)
+
+	writeSecret(t, path, "sk-rotated", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))
+	resp, err = e.get("/v1/organization/projects")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, "Bearer sk-rotated", auth)
+
+	t.Run("missing file", func(t *testing.T) {
+		t.Setenv("OPENAI_SECRET_KEY_FILE", filepath.Join(t.TempDir(), "missing"))
+		_, err := NewExporter()
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Exporter Self-Metrics
+
+var (
+	scrapeErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "openai_exporter_scrape_errors_total",
+			Help: "Total number of failed fetches per organization and endpoint.",
+		},
+		[]string{"org_id", "endpoint"},
+	)
+	pagesFetchedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "openai_exporter_pages_fetched_total",
+			Help: "Total number of response pages fetched per organization and endpoint.",
+		},
+		[]string{"org_id", "endpoint"},
+	)
+	lastSuccessTimestamp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_exporter_last_success_timestamp_seconds",
+			Help: "Unix time of the last successful fetch per organization and endpoint.",
+		},
+		[]string{"org_id", "endpoint"},
+	)
+	lastErrorInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_exporter_last_error_info",
+			Help: "Last failed fetch per organization and endpoint, with the status code and the x-request-id of the API response to quote to OpenAI support; always 1.",
+		},
+		[]string{"org_id", "endpoint", "status", "request_id"},
+	)
+
+	lastErrorMu sync.Mutex
+	// lastErrorLabels holds the labels of the lastErrorInfo series of every organization and endpoint, keyed
+	// by org_id|endpoint, so the series of the previous error is removed when a new one is recorded.
+	lastErrorLabels = make(map[string]prometheus.Labels)
+)
+
+// recordFetch counts a failed fetch of endpoint for the organization orgID or records the time of a successful one.
+func recordFetch(orgID, endpoint string, err error) {
+	recordEndpointStatus(orgID, endpoint, err, time.Now())
+	recordPermission(orgID, endpoint, err, time.Now())
+	if err != nil {
+		scrapeErrorsTotal.WithLabelValues(orgID, endpoint).Inc()
+		recordLastError(orgID, endpoint, err)
+		return
+	}
+	lastSuccessTimestamp.WithLabelValues(orgID, endpoint).Set(float64(time.Now().Unix()))
+}
+
+// recordLastError replaces the lastErrorInfo series of endpoint with one for err. Errors other than API
+// responses, e.g. timeouts, have the status "error" and no request ID.
+func recordLastError(orgID, endpoint string, err error) {
+	labels := prometheus.Labels{"org_id": orgID, "endpoint": endpoint, "status": "error", "request_id": ""}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		labels["status"] = strconv.Itoa(apiErr.StatusCode)
+		labels["request_id"] = apiErr.RequestID
+	}
+
+	lastErrorMu.Lock()
+	defer lastErrorMu.Unlock()
+	key := orgID + "|" + endpoint
+	if previous, ok := lastErrorLabels[key]; ok {
+		lastErrorInfo.Delete(previous)
+	}
+	lastErrorLabels[key] = labels
+	lastErrorInfo.With(labels).Set(1)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordFetch(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantErrors  float64
+		wantSuccess bool
+	}{
+		{name: "success records the time", wantSuccess: true},
+		{name: "failure is counted", err: errors.New("boom"), wantErrors: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scrapeErrorsTotal.Reset()
+			lastSuccessTimestamp.Reset()
+
+			before := time.Now().Unix()
+			recordFetch("org-1", "completions", tt.err)
+
+			assert.Equal(t, tt.wantErrors, testutil.ToFloat64(scrapeErrorsTotal.WithLabelValues("org-1", "completions")))
+			if tt.wantSuccess {
+				assert.GreaterOrEqual(t, testutil.ToFloat64(lastSuccessTimestamp.WithLabelValues("org-1", "completions")), float64(before))
+			} else {
+				assert.Equal(t, 0, testutil.CollectAndCount(lastSuccessTimestamp))
+			}
+		})
+	}
+}
+
+func TestRecordLastError(t *testing.T) {
+	lastErrorInfo.Reset()
+	lastErrorLabels = make(map[string]prometheus.Labels)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req_7c3f9a")
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"error": {"message": "The server had an error", "type": "server_error"}}`))
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "sk-admin", orgID: "org-1", targets: newAPITargets("org-1", server.URL, 3)}
+	_, err := e.get("/v1/organization/costs")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "(request ID req_7c3f9a)")
+
+	recordFetch("org-1", "costs", fmt.Errorf("failed to fetch costs: %w", err))
+	assert.Equal(t, 1.0, testutil.ToFloat64(lastErrorInfo.WithLabelValues("org-1", "costs", "500", "req_7c3f9a")))
+
+	recordFetch("org-1", "costs", errors.New("context deadline exceeded"))
+	recordFetch("org-1", "costs", nil)
+	assert.Equal(t, 1, testutil.CollectAndCount(lastErrorInfo), "only the last error is kept")
+	assert.Equal(t, 1.0, testutil.ToFloat64(lastErrorInfo.WithLabelValues("org-1", "costs", "error", "")))
+}
+
+func TestFetchUsageBuckets_CountsPages(t *testing.T) {
+	pagesFetchedTotal.Reset()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "" {
+			_, _ = w.Write([]byte(`{"object":"page","data":[],"has_more":true,"next_page":"p2"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"object":"page","data":[],"has_more":false}`))
+	}))
+	defer server.Close()
+
+	e := &Exporter{client: server.Client(), apiKey: "test", targets: newAPITargets("", server.URL, 3)}
+	_, err := e.fetchUsageBuckets(UsageEndpoint{Path: "embeddings", Name: "embeddings"}, 1000, 2000, "1m", usageGroupBy)
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, testutil.ToFloat64(pagesFetchedTotal.WithLabelValues("", "embeddings")))
+}
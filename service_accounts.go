@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Service Accounts
+
+var (
+	serviceAccountsEnabled  = flag.Bool("collector.service-accounts", false, "Export an info metric for every service account of every active project")
+	serviceAccountsInterval = flag.Duration("collector.service-accounts.interval", time.Hour, "Interval at which the service accounts are re-read")
+
+	serviceAccountInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_service_account_info",
+			Help: "Service accounts of the organization's projects; always 1.",
+		},
+		[]string{"org_id", "org_name", "project_id", "project_name", "service_account_id", "name", "role"},
+	)
+)
+
+type ServiceAccountList struct {
+	Object  string           `json:"object"`
+	Data    []ServiceAccount `json:"data"`
+	HasMore bool             `json:"has_more"`
+	LastID  string           `json:"last_id"`
+}
+
+type ServiceAccount struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// collectServiceAccounts lists the service accounts of all active projects and replaces the exported ones
+// of the organization. The previous ones stay exported if any project cannot be read.
+func (e *Exporter) collectServiceAccounts() error {
+	projects, err := e.fetchProjects()
+	if err != nil {
+		return err
+	}
+
+	var current []prometheus.Labels
+	for _, p := range projects {
+		if p.Status == "archived" || !exportsProject(p.ID, func() string { return p.Name }) {
+			continue
+		}
+		path := fmt.Sprintf("projects/%s/service_accounts", url.PathEscape(p.ID))
+		err := e.listPages(path, "service_accounts", func(d *json.Decoder) (string, bool, error) {
+			var out ServiceAccountList
+			err := d.Decode(&out)
+			for _, sa := range out.Data {
+				current = append(current, prometheus.Labels{
+					"org_id":             e.orgID,
+					"org_name":           e.orgName,
+					"project_id":         p.ID,
+					"project_name":       p.Name,
+					"service_account_id": sa.ID,
+					"name":               sa.Name,
+					"role":               sa.Role,
+				})
+			}
+			return out.LastID, out.HasMore, err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	e.rememberProjectNames(projects, time.Now())
+	serviceAccountInfo.DeletePartialMatch(prometheus.Labels{"org_id": e.orgID})
+	for _, labels := range current {
+		serviceAccountInfo.With(labels).Set(1)
+	}
+	return nil
+}
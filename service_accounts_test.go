@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectServiceAccounts(t *testing.T) {
+	projectNames = make(map[string]string)
+	serviceAccountInfo.Reset()
+
+	accounts := `[{"id": "svc_acct_1", "name": "ci", "role": "member"}, {"id": "svc_acct_2", "name": "batch-jobs", "role": "owner"}]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/organization/projects":
+			_, _ = w.Write([]byte(`{"object": "list", "data": [
+				{"id": "proj-1", "name": "one", "status": "active"},
+				{"id": "proj-2", "name": "two", "status": "archived"}
+			], "has_more": false}`))
+		case "/v1/organization/projects/proj-1/service_accounts":
+			_, _ = w.Write([]byte(`{"object": "list", "data": ` + accounts + `, "has_more": false}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+
+	require.NoError(t, e.collectServiceAccounts())
+	assert.Equal(t, 1.0, testutil.ToFloat64(serviceAccountInfo.WithLabelValues("org-1", "prod", "proj-1", "one", "svc_acct_1", "ci", "member")))
+	assert.Equal(t, 2, testutil.CollectAndCount(serviceAccountInfo), "archived projects are skipped")
+
+	accounts = `[{"id": "svc_acct_1", "name": "ci-renamed", "role": "member"}]`
+	require.NoError(t, e.collectServiceAccounts())
+	assert.Equal(t, 1.0, testutil.ToFloat64(serviceAccountInfo.WithLabelValues("org-1", "prod", "proj-1", "one", "svc_acct_1", "ci-renamed", "member")))
+	assert.Equal(t, 1, testutil.CollectAndCount(serviceAccountInfo), "renamed and deleted accounts are replaced")
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strings"
+)
+
+// Sharding
+
+var (
+	shardIndex = flag.Int("shard.index", 0, "Index of this replica among shard.total replicas, from 0")
+	shardTotal = flag.Int("shard.total", 1, "Number of replicas the organizations or projects are split across (1 disables sharding)")
+	shardBy    = flag.String("shard.by", "organization", "What is split across replicas: organization (the configured organizations) or project (the projects of every organization)")
+)
+
+// validateShard checks the sharding flags.
+func validateShard() error {
+	if *shardTotal < 1 {
+		return fmt.Errorf("shard.total must be at least 1, got %d", *shardTotal)
+	}
+	if *shardIndex < 0 || *shardIndex >= *shardTotal {
+		return fmt.Errorf("shard.index must be between 0 and %d, got %d", *shardTotal-1, *shardIndex)
+	}
+	if *shardBy != "organization" && *shardBy != "project" {
+		return fmt.Errorf("unknown shard.by %q, use organization or project", *shardBy)
+	}
+	return nil
+}
+
+// shardOrganizations returns the organizations of this replica. Organizations are assigned round-robin
+// in the order of their IDs, so every replica with the same list agrees and no shard gets more than one extra.
+func shardOrganizations(orgs []Organization) ([]Organization, error) {
+	if *shardTotal == 1 || *shardBy != "organization" {
+		return orgs, nil
+	}
+	sorted := slices.Clone(orgs)
+	slices.SortFunc(sorted, func(a, b Organization) int { return strings.Compare(a.ID, b.ID) })
+	var own []Organization
+	for i, org := range sorted {
+		if i%*shardTotal == *shardIndex {
+			own = append(own, org)
+		}
+	}
+	if len(own) == 0 {
+		return nil, fmt.Errorf("shard %d of %d has no organizations; configure at least %d or use -shard.by=project", *shardIndex, *shardTotal, *shardTotal)
+	}
+	return own, nil
+}
+
+// ownsProject reports whether the usage and costs of a project are exported by this replica. When sharding
+// by project, projects are assigned by a hash of their ID, since the project lists change over time.
+func ownsProject(projectID string) bool {
+	if *shardTotal == 1 || *shardBy != "project" {
+		return true
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(projectID))
+	return int(h.Sum32()%uint32(*shardTotal)) == *shardIndex
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setShard configures this replica as shard index of total, restoring the flags when the test ends.
+func setShard(t *testing.T, index, total int, by string) {
+	t.Helper()
+	origIndex, origTotal, origBy := *shardIndex, *shardTotal, *shardBy
+	t.Cleanup(func() { *shardIndex, *shardTotal, *shardBy = origIndex, origTotal, origBy })
+	*shardIndex, *shardTotal, *shardBy = index, total, by
+}
+
+func TestValidateShard(t *testing.T) {
+	tests := []struct {
+		name    string
+		index   int
+		total   int
+		by      string
+		wantErr string
+	}{
+		{name: "disabled", index: 0, total: 1, by: "organization"},
+		{name: "last shard", index: 2, total: 3, by: "project"},
+		{name: "no shards", index: 0, total: 0, by: "organization", wantErr: "shard.total must be at least 1"},
+		{name: "index out of range", index: 3, total: 3, by: "organization", wantErr: "shard.index must be between 0 and 2"},
+		{name: "unknown dimension", index: 0, total: 2, by: "model", wantErr: `unknown shard.by "model"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setShard(t, tt.index, tt.total, tt.by)
+			err := validateShard()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestShardOrganizations(t *testing.T) {
+	orgs := []Organization{{ID: "org-e"}, {ID: "org-a"}, {ID: "org-d"}, {ID: "org-b"}, {ID: "org-c"}}
+
+	var got [][]string
+	for index := range 2 {
+		setShard(t, index, 2, "organization")
+		own, err := shardOrganizations(orgs)
+		require.NoError(t, err)
+		var ids []string
+		for _, org := range own {
+			ids = append(ids, org.ID)
+		}
+		got = append(got, ids)
+	}
+	assert.Equal(t, [][]string{{"org-a", "org-c", "org-e"}, {"org-b", "org-d"}}, got)
+
+	setShard(t, 2, 3, "organization")
+	_, err := shardOrganizations(orgs[:2])
+	assert.ErrorContains(t, err, "shard 2 of 3 has no organizations")
+
+	setShard(t, 1, 2, "project")
+	own, err := shardOrganizations(orgs)
+	require.NoError(t, err)
+	assert.Len(t, own, 5, "all organizations are collected when sharding by project")
+}
+
+func TestOwnsProject(t *testing.T) {
+	owners := make(map[string][]int)
+	for index := range 3 {
+		setShard(t, index, 3, "project")
+		for i := range 30 {
+			id := fmt.Sprintf("proj_%d", i)
+			if ownsProject(id) {
+				owners[id] = append(owners[id], index)
+			}
+		}
+	}
+	assert.Len(t, owners, 30)
+	for id, shards := range owners {
+		assert.Len(t, shards, 1, "%s belongs to exactly one shard", id)
+	}
+}
+
+func TestFetchUsageData_ShardByProject(t *testing.T) {
+	usageState = make(map[string]float64)
+	projectNames = map[string]string{}
+
+	end := time.Now().Add(-time.Minute).Truncate(time.Minute).Unix()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"object": "page", "data": [{"start_time": ` + strconv.FormatInt(end-60, 10) + `, "end_time": ` + strconv.FormatInt(end, 10) + `, "results": [
+			{"input_tokens": 10, "project_id": "proj_1", "model": "gpt-4o"},
+			{"input_tokens": 20, "project_id": "proj_2", "model": "gpt-4o"}
+		]}], "has_more": false}`))
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+
+	setShard(t, 0, 2, "project")
+	require.NotEqual(t, ownsProject("proj_1"), ownsProject("proj_2"), "test projects must fall into different shards")
+	require.NoError(t, e.fetchUsageData(UsageEndpoint{Path: "completions", Name: "completions"}, end-60, end))
+
+	projects := make(map[string]bool)
+	for key := range usageState {
+		projects[strings.Split(key, "|")[2]] = true
+	}
+	require.Len(t, projects, 1, "only the projects of this shard are counted")
+	for id := range projects {
+		assert.True(t, ownsProject(id))
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+// Graceful Shutdown
+
+var shutdownTimeout = flag.Duration("web.shutdown-timeout", 30*time.Second, "Time given to the in-flight collection cycle and scrapes to finish on SIGTERM before their requests are cancelled")
+
+// requestCtx is the context of all API requests. It is cancelled when the shutdown timeout expires,
+// so requests still running at that point fail instead of holding up the exit.
+var requestCtx, cancelRequests = context.WithCancel(context.Background())
+
+// sleepContext waits for d and reports whether it did so without ctx being done first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// shutdown closes the listeners, waits for in-flight scrapes, gRPC queries and the collection loop
+// (done, if not nil) up to the shutdown timeout, and then writes the state file.
+func shutdown(server *http.Server, grpcServer *grpc.Server, done <-chan struct{}) {
+	logrus.Infof("Shutting down, waiting up to %s for in-flight work", *shutdownTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	stop := context.AfterFunc(ctx, cancelRequests)
+	defer stop()
+
+	if err := server.Shutdown(ctx); err != nil {
+		logrus.WithError(err).Warn("Error shutting down the HTTP server")
+	}
+	stopGRPC(ctx, grpcServer)
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			logrus.Warn("Collection cycle did not finish in time, cancelled its requests")
+			<-done
+		}
+	}
+	flushState()
+}
+
+// flushState writes the state file regardless of the checkpoint interval.
+func flushState() {
+	if *stateFile == "" {
+		return
+	}
+	if err := checkpointState(*stateFile, 0, time.Now()); err != nil {
+		logrus.WithError(err).Error("Error writing state on shutdown")
+		return
+	}
+	logrus.Infof("Wrote state to %s", *stateFile)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSleepContext(t *testing.T) {
+	assert.True(t, sleepContext(context.Background(), time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, sleepContext(ctx, time.Hour))
+	assert.False(t, sleepContext(ctx, 0))
+}
+
+// cancellingCollector cancels the collection loop's context from within its first cycle.
+type cancellingCollector struct {
+	fakeCollector
+	cancel context.CancelFunc
+}
+
+func (c *cancellingCollector) collectWindow(startTime, endTime int64) error {
+	c.cancel()
+	return c.fakeCollector.collectWindow(startTime, endTime)
+}
+
+func TestCollect_StopsAfterCycleInProgress(t *testing.T) {
+	origLast := lastScrape
+	defer func() { lastScrape = origLast }()
+	lastScrape = time.Now().Add(-time.Hour).Unix()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &cancellingCollector{cancel: cancel}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		collect(ctx, c, nil)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("collect did not return after its context was cancelled")
+	}
+	assert.Len(t, c.windows, 1, "the cycle in progress finishes, further missed windows are not collected")
+}
+
+func TestShutdown(t *testing.T) {
+	orig := *stateFile
+	*stateFile = filepath.Join(t.TempDir(), "state.json")
+	defer func() { *stateFile = orig }()
+
+	done := make(chan struct{})
+	close(done)
+	shutdown(&http.Server{}, nil, done)
+
+	_, err := os.Stat(*stateFile)
+	require.NoError(t, err, "the state is written on shutdown")
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Spend Budgets
+
+var (
+	budgetInterval = flag.Duration("budget.interval", time.Hour, "Interval at which the month-to-date spend of the configured budgets is re-read")
+
+	budgetLabels = []string{"budget", "org_id", "org_name", "project_id"}
+
+	budgetLimitUSD = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_budget_limit_usd",
+			Help: "Monthly limit of a configured budget in USD.",
+		},
+		budgetLabels,
+	)
+	budgetSpendUSD = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_budget_spend_usd",
+			Help: "Month-to-date spend in USD counted against a configured budget.",
+		},
+		budgetLabels,
+	)
+	budgetUtilization = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openai_budget_utilization_ratio",
+			Help: "Month-to-date spend of a configured budget as a fraction of its limit.",
+		},
+		budgetLabels,
+	)
+)
+
+// Budget is a monthly spending limit of a project, or of a whole organization when ProjectID is empty.
+type Budget struct {
+	Name       string  `yaml:"name"`
+	OrgID      string  `yaml:"org_id"`
+	ProjectID  string  `yaml:"project_id"`
+	MonthlyUSD float64 `yaml:"monthly_usd"`
+}
+
+var (
+	// budgets are the configured budgets, guarded by configMu.
+	budgets []Budget
+	// budgetsRefreshed maps org_id -> time of the last successful read of its month-to-date spend.
+	budgetsRefreshed = make(map[string]time.Time)
+)
+
+func (b Budget) validate() error {
+	if b.MonthlyUSD <= 0 {
+		return fmt.Errorf("monthly_usd must be positive, got %g", b.MonthlyUSD)
+	}
+	return nil
+}
+
+// label returns the name of the budget, which defaults to its project or organization.
+func (b Budget) label(orgID string) string {
+	switch {
+	case b.Name != "":
+		return b.Name
+	case b.ProjectID != "":
+		return b.ProjectID
+	}
+	return orgID
+}
+
+// appliesTo reports whether the budget limits the spend of the organization.
+func (b Budget) appliesTo(orgID string) bool {
+	return b.OrgID == "" || b.OrgID == orgID
+}
+
+// validateBudgets checks every budget and that no two of them share a name.
+func validateBudgets(list []Budget) error {
+	seen := make(map[string]bool)
+	for i, b := range list {
+		if err := b.validate(); err != nil {
+			return fmt.Errorf("budgets[%d]: %w", i, err)
+		}
+		key := b.OrgID + "|" + b.label(b.OrgID)
+		if seen[key] {
+			return fmt.Errorf("budgets[%d]: duplicate budget %q", i, b.label(b.OrgID))
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+func currentBudgets() []Budget {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return budgets
+}
+
+// budgetsDue reports whether the organization has budgets whose spend should be read again.
+func (e *Exporter) budgetsDue(now time.Time) bool {
+	var applies bool
+	for _, b := range currentBudgets() {
+		applies = applies || b.appliesTo(e.orgID)
+	}
+	if !applies {
+		return false
+	}
+	stateMu.RLock()
+	defer stateMu.RUnlock()
+	return now.Sub(budgetsRefreshed[e.orgID]) >= *budgetInterval
+}
+
+// monthStart returns the start of the UTC month of now, which is when budgets reset.
+func monthStart(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// collectBudgets reads the month-to-date USD spend of the organization per project from the Costs API
+// and exports it against the budgets of the organization.
+func (e *Exporter) collectBudgets(now time.Time) error {
+	buckets, err := e.fetchCostBuckets(monthStart(now).Unix(), now.Unix(), "project_id")
+	if err != nil {
+		return err
+	}
+	var total float64
+	byProject := make(map[string]float64)
+	for _, bucket := range buckets {
+		for _, res := range bucket.Results {
+			if !strings.EqualFold(res.Amount.Currency, "usd") {
+				continue
+			}
+			byProject[deref(res.ProjectID)] += float64(res.Amount.Value)
+			total += float64(res.Amount.Value)
+		}
+	}
+
+	list := currentBudgets()
+	var notifications []notification
+	stateMu.Lock()
+
+	for _, g := range []*prometheus.GaugeVec{budgetLimitUSD, budgetSpendUSD, budgetUtilization} {
+		g.DeletePartialMatch(prometheus.Labels{"org_id": e.orgID})
+	}
+	for _, b := range list {
+		if !b.appliesTo(e.orgID) {
+			continue
+		}
+		spend := total
+		if b.ProjectID != "" {
+			spend = byProject[b.ProjectID]
+		}
+		labels := prometheus.Labels{"budget": b.label(e.orgID), "org_id": e.orgID, "org_name": e.orgName, "project_id": b.ProjectID}
+		budgetLimitUSD.With(labels).Set(b.MonthlyUSD)
+		budgetSpendUSD.With(labels).Set(spend)
+		budgetUtilization.With(labels).Set(spend / b.MonthlyUSD)
+		if threshold, crossed := budgetCrossing(e.orgID+"|"+labels["budget"], spend/b.MonthlyUSD); crossed {
+			notifications = append(notifications, budgetNotification(labels, spend, b.MonthlyUSD, threshold))
+		}
+	}
+	budgetsRefreshed[e.orgID] = now
+	stateMu.Unlock()
+
+	sendNotifications(notifications)
+	return nil
+}
+
+// forgetBudgetSpend makes every organization read its spend in the next cycle, so changed budgets apply quickly.
+func forgetBudgetSpend() {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	budgetsRefreshed = make(map[string]time.Time)
+}
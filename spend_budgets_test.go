@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectBudgets(t *testing.T) {
+	origBudgets := budgets
+	defer func() { budgets = origBudgets }()
+	budgetsRefreshed = make(map[string]time.Time)
+	budgetLimitUSD.Reset()
+	budgetSpendUSD.Reset()
+	budgetUtilization.Reset()
+
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/organization/costs", r.URL.Path)
+		assert.Equal(t, strconv.FormatInt(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC).Unix(), 10), r.URL.Query().Get("start_time"))
+		assert.Equal(t, "project_id", r.URL.Query().Get("group_by"))
+		_, _ = w.Write([]byte(`{"object": "page", "has_more": false, "data": [
+			{"start_time": 1709251200, "end_time": 1709337600, "results": [
+				{"amount": {"value": 40, "currency": "usd"}, "project_id": "proj-1"},
+				{"amount": {"value": 10, "currency": "usd"}, "project_id": "proj-2"}
+			]},
+			{"start_time": 1709337600, "end_time": 1709424000, "results": [
+				{"amount": {"value": 50, "currency": "usd"}, "project_id": "proj-1"}
+			]}
+		]}`))
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+
+	budgets = nil
+	assert.False(t, e.budgetsDue(now), "organizations without budgets read no spend")
+
+	budgets = []Budget{
+		{ProjectID: "proj-1", MonthlyUSD: 100},
+		{Name: "company", MonthlyUSD: 400},
+		{Name: "other-org", OrgID: "org-2", MonthlyUSD: 1},
+	}
+	assert.True(t, e.budgetsDue(now))
+	require.NoError(t, e.collectBudgets(now))
+
+	assert.Equal(t, 100.0, testutil.ToFloat64(budgetLimitUSD.WithLabelValues("proj-1", "org-1", "prod", "proj-1")))
+	assert.Equal(t, 90.0, testutil.ToFloat64(budgetSpendUSD.WithLabelValues("proj-1", "org-1", "prod", "proj-1")))
+	assert.Equal(t, 0.9, testutil.ToFloat64(budgetUtilization.WithLabelValues("proj-1", "org-1", "prod", "proj-1")))
+	assert.Equal(t, 100.0, testutil.ToFloat64(budgetSpendUSD.WithLabelValues("company", "org-1", "prod", "")))
+	assert.Equal(t, 0.25, testutil.ToFloat64(budgetUtilization.WithLabelValues("company", "org-1", "prod", "")))
+	assert.Equal(t, 2, testutil.CollectAndCount(budgetLimitUSD), "budgets of other organizations are skipped")
+	assert.False(t, e.budgetsDue(now))
+
+	applyConfig(&Config{Budgets: []Budget{{Name: "company", MonthlyUSD: 500}}})
+	assert.True(t, e.budgetsDue(now), "changed budgets are read in the next cycle")
+	require.NoError(t, e.collectBudgets(now))
+	assert.Equal(t, 1, testutil.CollectAndCount(budgetLimitUSD), "removed budgets are no longer exported")
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+)
+
+// State Snapshots
+
+var (
+	snapshotDir  = flag.String("state.snapshot-dir", os.TempDir(), "Directory in which POST /-/snapshot writes state snapshots")
+	snapshotKeep = flag.Int("state.snapshot-keep", 5, "Number of snapshots kept in -state.snapshot-dir, older ones are deleted")
+	stateRestore = flag.String("state.restore", "", "Restore deduplication state, name caches and token counters from this snapshot file at startup")
+
+	stateFile               = flag.String("state.file", "", "Checkpoint the state to this file after collection cycles and reload it at startup (disabled when empty)")
+	stateCheckpointInterval = flag.Duration("state.checkpoint-interval", time.Minute, "Minimum time between two checkpoints to -state.file")
+
+	// lastCheckpoint is when the state was last written to -state.file; only used by the collection goroutine.
+	lastCheckpoint time.Time
+)
+
+// stateSnapshot is everything needed to continue collection on another host without double counting.
+type stateSnapshot struct {
+	CreatedAt    int64              `json:"created_at"`
+	LastScrape   int64              `json:"last_scrape"`
+	UsageState   map[string]float64 `json:"usage_state"`
+	ProjectNames map[string]string  `json:"project_names"`
+	APIKeyNames  map[string]string  `json:"api_key_names"`
+	Tokens       []tokenSample      `json:"tokens"`
+	// Backlog is the start of the oldest failed window per org_id|endpoint, see windowBacklog.
+	Backlog map[string]int64 `json:"backlog,omitempty"`
+}
+
+// tokenSample is the current value of one openai_api_tokens_total series.
+type tokenSample struct {
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+}
+
+// takeSnapshot copies the current state.
+func takeSnapshot() (*stateSnapshot, error) {
+	tokens, err := tokenSamples()
+	if err != nil {
+		return nil, err
+	}
+
+	stateMu.RLock()
+	defer stateMu.RUnlock()
+
+	snap := &stateSnapshot{
+		CreatedAt:    time.Now().Unix(),
+		LastScrape:   lastScrape,
+		UsageState:   make(map[string]float64, len(usageState)),
+		ProjectNames: make(map[string]string, len(projectNames)),
+		APIKeyNames:  make(map[string]string, len(apiKeyNames)),
+		Tokens:       tokens,
+	}
+	for k, v := range usageState {
+		snap.UsageState[k] = v
+	}
+	for k, v := range projectNames {
+		snap.ProjectNames[k] = v
+	}
+	for k, v := range apiKeyNames {
+		snap.APIKeyNames[k] = v
+	}
+	if len(windowBacklog) > 0 {
+		snap.Backlog = make(map[string]int64, len(windowBacklog))
+		for k, v := range windowBacklog {
+			snap.Backlog[k] = v
+		}
+	}
+	return snap, nil
+}
+
+// tokenSamples reads the current values of all openai_api_tokens_total series.
+func tokenSamples() ([]tokenSample, error) {
+	samples, err := collectSamples(tokensTotal, func(m *dto.Metric) float64 { return m.GetCounter().GetValue() })
+	if err != nil {
+		return nil, fmt.Errorf("error reading token counters: %w", err)
+	}
+	return samples, nil
+}
+
+// collectSamples reads the current series of c, using value to extract each sample's value.
+func collectSamples(c prometheus.Collector, value func(*dto.Metric) float64) ([]tokenSample, error) {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	var samples []tokenSample
+	var err error
+	for m := range ch {
+		var pb dto.Metric
+		if writeErr := m.Write(&pb); writeErr != nil {
+			err = writeErr
+			continue
+		}
+		labels := make(map[string]string, len(pb.GetLabel()))
+		for _, lp := range pb.GetLabel() {
+			labels[lp.GetName()] = lp.GetValue()
+		}
+		samples = append(samples, tokenSample{Labels: labels, Value: value(&pb)})
+	}
+	return samples, err
+}
+
+// writeSnapshot writes the current state to a new file in dir and returns its path.
+func writeSnapshot(dir string) (string, error) {
+	snap, err := takeSnapshot()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("openai-exporter-snapshot-%d.json", snap.CreatedAt))
+	if err := saveSnapshot(snap, path); err != nil {
+		return "", err
+	}
+	pruneSnapshots(dir, *snapshotKeep)
+	return path, nil
+}
+
+// pruneSnapshots deletes all but the keep newest snapshots in dir. The names hold the creation time in
+// Unix seconds, which sort chronologically.
+func pruneSnapshots(dir string, keep int) {
+	paths, err := filepath.Glob(filepath.Join(dir, "openai-exporter-snapshot-*.json"))
+	if err != nil || len(paths) <= keep {
+		return
+	}
+	slices.Sort(paths)
+	for _, path := range paths[:len(paths)-keep] {
+		if err := os.Remove(path); err != nil {
+			logrus.WithError(err).Warnf("Failed to delete old snapshot %s", path)
+		}
+	}
+}
+
+// saveSnapshot writes snap to path through a temporary file, so a crash never leaves a truncated file behind.
+func saveSnapshot(snap *stateSnapshot, path string) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("error encoding snapshot: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("error writing snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("error writing snapshot: %w", err)
+	}
+	return nil
+}
+
+// checkpointState writes the state to path unless the previous checkpoint is more recent than interval.
+func checkpointState(path string, interval time.Duration, now time.Time) error {
+	if now.Sub(lastCheckpoint) < interval {
+		return nil
+	}
+	snap, err := takeSnapshot()
+	if err != nil {
+		return err
+	}
+	if err := saveSnapshot(snap, path); err != nil {
+		return err
+	}
+	lastCheckpoint = now
+	logrus.Debugf("Checkpointed %d buckets to %s", len(snap.UsageState), path)
+	return nil
+}
+
+// loadStateFile restores the state from path if it exists; a missing file just means a first start.
+func loadStateFile(path string) error {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		logrus.Infof("No state file at %s, starting with empty state", path)
+		return nil
+	}
+	return restoreSnapshot(path)
+}
+
+// restoreSnapshot loads a snapshot file into the (still empty) state and token counters.
+// Snapshots written before usage was labelled by organization are assigned to the organization of OPENAI_ORG_ID.
+// Token series are mapped onto the current group_by dimensions, see restoredTokenLabels.
+func restoreSnapshot(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading snapshot: %w", err)
+	}
+	var snap stateSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("error decoding snapshot %s: %w", path, err)
+	}
+
+	legacy := singleOrganization()
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	for k, v := range snap.UsageState {
+		if strings.Count(k, "|") == 7 {
+			k += "|" + legacy.ID
+		}
+		usageState[k] = v
+	}
+	for k, v := range snap.ProjectNames {
+		projectNames[k] = v
+	}
+	for k, v := range snap.APIKeyNames {
+		apiKeyNames[k] = v
+	}
+	for k, v := range snap.Backlog {
+		windowBacklog[k] = v
+	}
+	if snap.LastScrape > 0 {
+		lastScrape = snap.LastScrape
+	}
+	for _, s := range snap.Tokens {
+		if _, ok := s.Labels["org_id"]; !ok {
+			s.Labels["org_id"], s.Labels["org_name"] = legacy.ID, legacy.Name
+		}
+		labels := restoredTokenLabels(s.Labels)
+		c, err := tokensTotal.GetMetricWith(labels)
+		if err != nil {
+			return fmt.Errorf("error restoring token counter %v: %w", s.Labels, err)
+		}
+		c.Add(s.Value)
+		// Restored series are kept for the retention from now on, like series counted right now.
+		tokenSeries[seriesKey(labels)] = time.Now().Unix()
+	}
+	logrus.Infof("Restored state from %s: %d buckets, %d token series, last scrape %d",
+		path, len(snap.UsageState), len(snap.Tokens), snap.LastScrape)
+	return nil
+}
+
+// restoredTokenLabels maps the labels of a snapshotted token series onto the labels of openai_api_tokens_total:
+// labels of dimensions that are no longer grouped by are dropped and those of new dimensions are left empty.
+// Other missing labels are kept missing, so the series is rejected.
+func restoredTokenLabels(labels prometheus.Labels) prometheus.Labels {
+	out := make(prometheus.Labels, len(tokenLabels))
+	for _, name := range tokenLabels {
+		value, ok := labels[name]
+		if _, dimension := groupedLabel(name); !ok && !dimension {
+			continue
+		}
+		out[name] = value
+	}
+	return out
+}
+
+// snapshotHandler serves POST /-/snapshot. Requests must carry the token as a bearer token;
+// without a token the endpoint is disabled.
+func snapshotHandler(token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if token == "" {
+			http.Error(w, "snapshots over HTTP are disabled, set OPENAI_EXPORTER_RELOAD_TOKEN to enable them", http.StatusForbidden)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		path, err := writeSnapshot(*snapshotDir)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to write snapshot")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		logrus.Infof("Wrote state snapshot to %s", path)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"path": path})
+	}
+}
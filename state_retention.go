@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// State Retention
+
+var (
+	stateRetention = flag.Duration("state.retention", 48*time.Hour, "Time after which processed buckets are forgotten; must exceed the oldest window that can be fetched again (0 keeps them forever)")
+
+	stateEntries = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "openai_exporter_state_entries",
+			Help: "Number of processed usage buckets kept in memory for deduplication.",
+		},
+	)
+)
+
+// validateRetention checks that buckets are kept long enough for the features that read them back.
+func validateRetention() error {
+	if *stateRetention <= 0 {
+		return nil
+	}
+	if *stateRetention <= *scrapeLookback {
+		return fmt.Errorf("state.retention (%s) must exceed scrape.lookback (%s)", *stateRetention, *scrapeLookback)
+	}
+	if *backfillDuration >= *stateRetention {
+		return fmt.Errorf("backfill.duration (%s) must be shorter than state.retention (%s)", *backfillDuration, *stateRetention)
+	}
+	if *reconcileEnabled && *stateRetention < 24*time.Hour+*reconcileDelay {
+		return fmt.Errorf("state.retention (%s) must be at least 24h plus reconcile.delay (%s) to reconcile the previous day", *stateRetention, *reconcileDelay)
+	}
+	return nil
+}
+
+// pruneState forgets the processed buckets that started more than state.retention before now and the daily
+// cost buckets of days that ended before then. Token series that nothing was counted in for state.retention
+// stop counting towards metrics.max-series; they keep being exported with their current value. The cached
+// token ratios of such series are removed.
+func pruneState(now time.Time) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	if *stateRetention > 0 {
+		cutoff := now.Add(-*stateRetention).Unix()
+		var pruned int
+		for key := range usageState {
+			// Keys are built by updateMetric: operation|bucketStart|...
+			parts := strings.SplitN(key, "|", 3)
+			if len(parts) < 3 {
+				continue
+			}
+			if start, err := strconv.ParseInt(parts[1], 10, 64); err == nil && start < cutoff {
+				delete(usageState, key)
+				pruned++
+			}
+		}
+		for key := range costState {
+			// Keys are built by updateCost: date|organization_id|...
+			date, _, _ := strings.Cut(key, "|")
+			if day, err := time.Parse("2006-01-02", date); err == nil && day.AddDate(0, 0, 1).Unix() <= cutoff {
+				delete(costState, key)
+				pruned++
+			}
+		}
+		for key, last := range tokenSeries {
+			if last < cutoff {
+				delete(tokenSeries, key)
+				pruned++
+			}
+		}
+		pruned += pruneCacheTokens(cutoff)
+		if pruned > 0 {
+			logrus.Debugf("Pruned %d buckets and series older than %s from the state", pruned, *stateRetention)
+		}
+	}
+	stateEntries.Set(float64(len(usageState)))
+}
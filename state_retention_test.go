@@ -0,0 +1,119 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPruneState(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	fresh := "completions|1699999940|proj-1|user-1|key-1|gpt-4|false|input|org-1"
+	old := "completions|1699827140|proj-1|user-1|key-1|gpt-4|false|input|org-1"
+
+	tests := []struct {
+		name      string
+		retention time.Duration
+		want      []string
+	}{
+		{name: "old buckets are pruned", retention: 48 * time.Hour, want: []string{fresh}},
+		{name: "longer retention", retention: 72 * time.Hour, want: []string{fresh, old}},
+		{name: "disabled", retention: 0, want: []string{fresh, old}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orig := *stateRetention
+			*stateRetention = tt.retention
+			defer func() { *stateRetention = orig }()
+			usageState = map[string]float64{fresh: 1, old: 2}
+
+			pruneState(now)
+
+			assert.ElementsMatch(t, tt.want, sortedKeys(usageState))
+			assert.Equal(t, float64(len(tt.want)), testutil.ToFloat64(stateEntries))
+		})
+	}
+}
+
+func TestPruneState_CostsAndSeries(t *testing.T) {
+	orig := *stateRetention
+	*stateRetention = 48 * time.Hour
+	defer func() { *stateRetention = orig }()
+
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	usageState = make(map[string]float64)
+	costState = map[string]float64{
+		"2024-03-07|org-1|proj-1|GPT-4o": 1,
+		"2024-03-08|org-1|proj-1|GPT-4o": 2,
+		"2024-03-10|org-1|proj-1|GPT-4o": 3,
+	}
+	tokensTotal.Reset()
+	tokenSeries = make(map[string]int64)
+	labels := func(apiKey string) prometheus.Labels {
+		return prometheus.Labels{
+			"org_id": "org-1", "org_name": "prod", "model": "gpt-4", "operation": "completions", "project_id": "proj-1", "project_name": "p",
+			"user_id": "user-1", "user_email": "", "api_key_id": apiKey, "api_key_name": apiKey, "batch": "false", "token_type": "input",
+		}
+	}
+	stateMu.Lock()
+	tokensTotal.With(limitSeries(labels("key-old"), now.Add(-72*time.Hour).Unix())).Add(1)
+	tokensTotal.With(limitSeries(labels("key-new"), now.Add(-72*time.Hour).Unix())).Add(1)
+	tokensTotal.With(limitSeries(labels("key-new"), now.Add(-time.Hour).Unix())).Add(1)
+	stateMu.Unlock()
+
+	pruneState(now)
+
+	assert.ElementsMatch(t, []string{"2024-03-08|org-1|proj-1|GPT-4o", "2024-03-10|org-1|proj-1|GPT-4o"}, sortedKeys(costState),
+		"days that ended before the cutoff are forgotten")
+	assert.Len(t, tokenSeries, 1, "series not counted in since the cutoff no longer count towards the limit")
+	assert.Equal(t, 2, testutil.CollectAndCount(tokensTotal), "but are still exported")
+	assert.Equal(t, 1.0, testutil.ToFloat64(tokensTotal.With(labels("key-old"))))
+}
+
+func TestPruneState_CacheTokens(t *testing.T) {
+	orig := *stateRetention
+	*stateRetention = 48 * time.Hour
+	defer func() { *stateRetention = orig }()
+
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	series := func(model string) prometheus.Labels {
+		return prometheus.Labels{"org_id": "org-1", "org_name": "prod", "project_id": "proj-1", "project_name": "p", "model": model}
+	}
+	cacheTokens = map[string]*cacheCount{
+		"org-1|prod|proj-1|p|gpt-4":  {series: series("gpt-4"), input: 10, cached: 5, last: now.Add(-72 * time.Hour).Unix()},
+		"org-1|prod|proj-1|p|gpt-4o": {series: series("gpt-4o"), input: 10, cached: 2, last: now.Add(-time.Hour).Unix()},
+	}
+	cachedTokenRatio.Reset()
+	cachedTokenRatio.WithLabelValues("org-1", "prod", "proj-1", "p", "gpt-4").Set(0.5)
+	cachedTokenRatio.WithLabelValues("org-1", "prod", "proj-1", "p", "gpt-4o").Set(0.2)
+
+	pruneState(now)
+
+	assert.Equal(t, []string{"org-1|prod|proj-1|p|gpt-4o"}, sortedKeys(cacheTokens), "series not counted in since the cutoff are forgotten")
+	assert.Equal(t, 1, testutil.CollectAndCount(cachedTokenRatio), "with their ratio")
+	assert.Equal(t, 0.2, testutil.ToFloat64(cachedTokenRatio.WithLabelValues("org-1", "prod", "proj-1", "p", "gpt-4o")))
+}
+
+func TestValidateRetention(t *testing.T) {
+	origRetention, origReconcile := *stateRetention, *reconcileEnabled
+	defer func() { *stateRetention, *reconcileEnabled = origRetention, origReconcile }()
+
+	*reconcileEnabled = true
+	*stateRetention = 24 * time.Hour
+	assert.ErrorContains(t, validateRetention(), "reconcile.delay")
+
+	*stateRetention = 48 * time.Hour
+	assert.NoError(t, validateRetention())
+
+	origBackfill := *backfillDuration
+	*backfillDuration = 48 * time.Hour
+	assert.ErrorContains(t, validateRetention(), "backfill.duration")
+	*backfillDuration = origBackfill
+
+	*stateRetention = 0
+	assert.NoError(t, validateRetention())
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	usageState = map[string]float64{"completions|1000|proj-1|user-1|key-1|gpt-4|false|input|org-1": 42}
+	projectNames = map[string]string{"proj-1": "production"}
+	apiKeyNames = map[string]string{"key-1": "ci"}
+	lastScrape = 123456
+	tokensTotal.Reset()
+	tokensTotal.WithLabelValues("org-1", "prod", "gpt-4", "completions", "proj-1", "production", "user-1", "", "key-1", "ci", "false", "input").Add(42)
+
+	path, err := writeSnapshot(t.TempDir())
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(filepath.Base(path), "openai-exporter-snapshot-"))
+
+	usageState = make(map[string]float64)
+	projectNames = make(map[string]string)
+	apiKeyNames = make(map[string]string)
+	lastScrape = 0
+	tokensTotal.Reset()
+
+	require.NoError(t, restoreSnapshot(path))
+	assert.Equal(t, 42.0, usageState["completions|1000|proj-1|user-1|key-1|gpt-4|false|input|org-1"])
+	assert.Equal(t, "production", projectNames["proj-1"])
+	assert.Equal(t, "ci", apiKeyNames["key-1"])
+	assert.Equal(t, int64(123456), lastScrape)
+	assert.Equal(t, 42.0, testutil.ToFloat64(tokensTotal.WithLabelValues("org-1", "prod", "gpt-4", "completions", "proj-1", "production", "user-1", "", "key-1", "ci", "false", "input")))
+}
+
+func TestRestoreSnapshot_Legacy(t *testing.T) {
+	t.Setenv("OPENAI_ORG_ID", "org-1")
+	t.Setenv("OPENAI_ORG_NAME", "prod")
+	usageState = make(map[string]float64)
+	tokensTotal.Reset()
+
+	path := filepath.Join(t.TempDir(), "legacy.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"usage_state": {"completions|1000|proj-1|user-1|key-1|gpt-4|false|input": 42},
+		"tokens": [{"labels": {"model": "gpt-4", "operation": "completions", "project_id": "proj-1", "project_name": "production",
+			"user_id": "user-1", "api_key_id": "key-1", "api_key_name": "ci", "batch": "false", "token_type": "input"}, "value": 42}]
+	}`), 0600))
+
+	require.NoError(t, restoreSnapshot(path))
+	assert.Equal(t, map[string]float64{"completions|1000|proj-1|user-1|key-1|gpt-4|false|input|org-1": 42}, usageState)
+	assert.Equal(t, 42.0, testutil.ToFloat64(tokensTotal.WithLabelValues("org-1", "prod", "gpt-4", "completions", "proj-1", "production", "user-1", "", "key-1", "ci", "false", "input")))
+}
+
+func TestRestoreSnapshot_Errors(t *testing.T) {
+	assert.Error(t, restoreSnapshot(filepath.Join(t.TempDir(), "missing.json")))
+
+	path := filepath.Join(t.TempDir(), "bad.json")
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0600))
+	assert.Error(t, restoreSnapshot(path))
+
+	path = filepath.Join(t.TempDir(), "labels.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"tokens": [{"labels": {"model": "gpt-4"}, "value": 1}]}`), 0600))
+	assert.Error(t, restoreSnapshot(path), "series with a mismatched label set must be rejected")
+}
+
+func TestSnapshotHandler(t *testing.T) {
+	dir := t.TempDir()
+	origDir, origKeep := *snapshotDir, *snapshotKeep
+	*snapshotDir, *snapshotKeep = dir, 2
+	defer func() { *snapshotDir, *snapshotKeep = origDir, origKeep }()
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		auth   string
+		want   int
+	}{
+		{name: "GET is not allowed", token: "secret", method: http.MethodGet, auth: "Bearer secret", want: http.StatusMethodNotAllowed},
+		{name: "disabled without token", method: http.MethodPost, want: http.StatusForbidden},
+		{name: "missing token", token: "secret", method: http.MethodPost, want: http.StatusUnauthorized},
+		{name: "wrong token", token: "secret", method: http.MethodPost, auth: "Bearer wrong", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/-/snapshot", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			snapshotHandler(tt.token)(rec, req)
+			assert.Equal(t, tt.want, rec.Code)
+
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+
+	t.Run("POST writes a snapshot", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/-/snapshot", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		snapshotHandler("secret")(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), dir)
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+}
+
+func TestPruneSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"openai-exporter-snapshot-1000.json", "openai-exporter-snapshot-3000.json", "openai-exporter-snapshot-2000.json", "state.json"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0600))
+	}
+
+	pruneSnapshots(dir, 2)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"openai-exporter-snapshot-2000.json", "openai-exporter-snapshot-3000.json", "state.json"}, names,
+		"the oldest snapshot is deleted, other files are left alone")
+}
+
+func TestCheckpointState(t *testing.T) {
+	origCheckpoint := lastCheckpoint
+	defer func() { lastCheckpoint = origCheckpoint }()
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	usageState = map[string]float64{"completions|1000|proj-1|user-1|key-1|gpt-4|false|input|org-1": 7}
+	lastScrape = 1060
+	tokensTotal.Reset()
+	lastCheckpoint = time.Time{}
+	now := time.Unix(2000, 0)
+
+	require.NoError(t, checkpointState(path, time.Minute, now))
+	_, err := os.Stat(path + ".tmp")
+	assert.True(t, os.IsNotExist(err), "temporary file must be renamed")
+
+	t.Run("skipped within the interval", func(t *testing.T) {
+		usageState["completions|1060|proj-1|user-1|key-1|gpt-4|false|input|org-1"] = 3
+		require.NoError(t, checkpointState(path, time.Minute, now.Add(30*time.Second)))
+
+		usageState = make(map[string]float64)
+		require.NoError(t, loadStateFile(path))
+		assert.Len(t, usageState, 1)
+	})
+
+	t.Run("written after the interval", func(t *testing.T) {
+		usageState = map[string]float64{
+			"completions|1000|proj-1|user-1|key-1|gpt-4|false|input|org-1": 7,
+			"completions|1060|proj-1|user-1|key-1|gpt-4|false|input|org-1": 3,
+		}
+		lastScrape = 1120
+		require.NoError(t, checkpointState(path, time.Minute, now.Add(time.Minute)))
+
+		usageState = make(map[string]float64)
+		lastScrape = 0
+		require.NoError(t, loadStateFile(path))
+		assert.Len(t, usageState, 2)
+		assert.Equal(t, int64(1120), lastScrape)
+	})
+}
+
+func TestLoadStateFile_Missing(t *testing.T) {
+	usageState = make(map[string]float64)
+	assert.NoError(t, loadStateFile(filepath.Join(t.TempDir(), "missing.json")))
+	assert.Empty(t, usageState)
+}
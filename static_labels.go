@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Static Labels
+
+var staticLabelsFlag = flag.String("labels", "", "Comma-separated name=value labels attached to every exported series, e.g. env=prod,team=ml")
+
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// parseStaticLabels parses a comma-separated list of name=value pairs.
+func parseStaticLabels(s string) (prometheus.Labels, error) {
+	labels := prometheus.Labels{}
+	for _, pair := range splitList(s) {
+		name, value, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("invalid label %q in -labels, use name=value", pair)
+		case !labelNamePattern.MatchString(name) || strings.HasPrefix(name, "__"):
+			return nil, fmt.Errorf("invalid label name %q in -labels", name)
+		case labels[name] != "":
+			return nil, fmt.Errorf("duplicate label %q in -labels", name)
+		}
+		labels[name] = strings.TrimSpace(value)
+	}
+	return labels, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStaticLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    prometheus.Labels
+		wantErr string
+	}{
+		{name: "empty", input: "", want: prometheus.Labels{}},
+		{name: "pairs", input: "env=prod, team=ml", want: prometheus.Labels{"env": "prod", "team": "ml"}},
+		{name: "value with equals sign", input: "query=a=b", want: prometheus.Labels{"query": "a=b"}},
+		{name: "missing value", input: "env", wantErr: `invalid label "env"`},
+		{name: "invalid name", input: "1env=prod", wantErr: `invalid label name "1env"`},
+		{name: "reserved name", input: "__name__=x", wantErr: `invalid label name "__name__"`},
+		{name: "duplicate", input: "env=prod,env=dev", wantErr: `duplicate label "env"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStaticLabels(tt.input)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRegisterMetrics_StaticLabels(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	require.NoError(t, RegisterMetrics(prometheus.WrapRegistererWith(prometheus.Labels{"env": "prod"}, registry)))
+	exporterUp.Set(1)
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	var found bool
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			var env string
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "env" {
+					env = label.GetValue()
+				}
+			}
+			assert.Equal(t, "prod", env, family.GetName())
+			found = true
+		}
+	}
+	assert.True(t, found)
+}
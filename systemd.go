@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/coreos/go-systemd/v22/daemon"
+	"github.com/sirupsen/logrus"
+)
+
+// systemd Notifications
+
+// sdNotify sends state to systemd. Without a NOTIFY_SOCKET, e.g. outside a Type=notify unit, it does nothing.
+func sdNotify(state string) {
+	if _, err := daemon.SdNotify(false, state); err != nil {
+		logrus.WithError(err).Warn("Error notifying systemd")
+	}
+}
+
+// checkSystemdStartup validates the API credentials of c when systemd waits for the exporter to become
+// ready, so a unit with a rejected key fails to start instead of reporting ready. It warns when the watchdog
+// would fire before a cycle can ping it.
+func checkSystemdStartup(c windowCollector) error {
+	if os.Getenv("NOTIFY_SOCKET") == "" {
+		return nil
+	}
+	if timeout, err := daemon.SdWatchdogEnabled(false); err == nil && timeout > 0 && timeout <= currentScrapeInterval() {
+		logrus.Warnf("WatchdogSec=%s is not longer than scrape.interval %s, systemd will restart the exporter between cycles", timeout, currentScrapeInterval())
+	}
+	checker, ok := c.(credentialChecker)
+	if !ok {
+		return nil
+	}
+	sdNotify("STATUS=Checking API credentials")
+	if err := checker.checkCredentials(); err != nil {
+		return fmt.Errorf("API credentials were rejected: %w", err)
+	}
+	return nil
+}
+
+// notifySystemdReady tells systemd the exporter is up.
+func notifySystemdReady() {
+	sdNotify(daemon.SdNotifyReady + "\nSTATUS=Collecting")
+}
+
+// notifySystemdWatchdog pings the watchdog of systemd after a successful collection cycle, so a collector
+// that stops completing cycles gets the unit restarted.
+func notifySystemdWatchdog() {
+	sdNotify(daemon.SdNotifyWatchdog)
+}
+
+// notifySystemdStopping tells systemd the exporter is shutting down.
+func notifySystemdStopping() {
+	sdNotify(daemon.SdNotifyStopping)
+}
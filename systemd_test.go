@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listenNotify listens for systemd notifications like a Type=notify unit would.
+func listenNotify(t *testing.T) *net.UnixConn {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+	return conn
+}
+
+func readNotify(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	return string(buf[:n])
+}
+
+func TestNotifySystemd(t *testing.T) {
+	conn := listenNotify(t)
+	exporterReady.Store(false)
+	defer exporterReady.Store(false)
+
+	setReady()
+	assert.Equal(t, "READY=1\nSTATUS=Collecting", readNotify(t, conn))
+	notifySystemdWatchdog()
+	assert.Equal(t, "WATCHDOG=1", readNotify(t, conn))
+
+	setReady()
+	notifySystemdWatchdog()
+	assert.Equal(t, "WATCHDOG=1", readNotify(t, conn), "READY=1 is only sent once")
+}
+
+func TestCheckSystemdStartup(t *testing.T) {
+	tests := []struct {
+		name    string
+		notify  bool
+		status  int
+		wantErr bool
+	}{
+		{name: "not under systemd", status: 401},
+		{name: "valid credentials", notify: true, status: 200},
+		{name: "rejected credentials", notify: true, status: 401, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NOTIFY_SOCKET", "")
+			if tt.notify {
+				conn := listenNotify(t)
+				defer func() { assert.Equal(t, "STATUS=Checking API credentials", readNotify(t, conn)) }()
+			}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(`{"object": "list", "data": []}`))
+			}))
+			defer server.Close()
+			e := &Exporter{client: server.Client(), apiKey: "sk-admin", orgID: "org-1", targets: newAPITargets("org-1", server.URL, 3)}
+
+			err := checkSystemdStartup(e)
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "API credentials were rejected")
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
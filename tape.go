@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Record and Replay
+
+var (
+	recordFile = flag.String("record.file", "", "Append every API response to this file as JSON lines, for bug reports and offline development")
+	replayFile = flag.String("replay.file", "", "Answer API requests from a file written with -record.file, collect the recorded windows, print the metrics and exit")
+)
+
+// tapeEntry is one recorded API exchange. Request headers, and with them the API key, are not recorded.
+type tapeEntry struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	// URL is the path and query of the request, without the base URL.
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+	Body   string `json:"body"`
+}
+
+var (
+	// replayTape is the tape loaded from -replay.file, if set.
+	replayTape *tape
+	// recording is the file opened for -record.file, if set; it is shared by the clients of all organizations.
+	recording *tapeRecorder
+)
+
+// configureTape checks the record and replay flags and loads the tape to replay.
+func configureTape() error {
+	if *recordFile != "" && *replayFile != "" {
+		return fmt.Errorf("record.file and replay.file cannot be combined")
+	}
+	replayTape, recording = nil, nil
+	if *recordFile != "" {
+		f, err := os.OpenFile(*recordFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return fmt.Errorf("error opening record file: %w", err)
+		}
+		recording = &tapeRecorder{enc: json.NewEncoder(f)}
+	}
+	if *replayFile != "" {
+		t, err := loadTape(*replayFile)
+		if err != nil {
+			return err
+		}
+		replayTape = t
+	}
+	return nil
+}
+
+// tapeTransport returns the transport of the API clients: next, recording to -record.file, or the
+// replay tape instead of next.
+func tapeTransport(next http.RoundTripper) http.RoundTripper {
+	switch {
+	case replayTape != nil:
+		return replayTape
+	case recording != nil:
+		return &recordingTransport{next: next, recorder: recording}
+	}
+	return next
+}
+
+// tapeRecorder appends entries to a tape.
+type tapeRecorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (r *tapeRecorder) record(entry tapeEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.enc.Encode(entry); err != nil {
+		logrus.WithError(err).Warn("Error recording API response")
+	}
+}
+
+// recordingTransport records every response it receives from next.
+type recordingTransport struct {
+	next     http.RoundTripper
+	recorder *tapeRecorder
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	t.recorder.record(tapeEntry{Time: time.Now().UTC(), Method: req.Method, URL: req.URL.RequestURI(), Status: resp.StatusCode, Body: string(body)})
+	return resp, nil
+}
+
+// tape replays recorded responses. A request gets the next unplayed response to the same URL, or else to
+// the same path, so the responses of an endpoint are replayed in the recorded order even when flags change
+// the queries. Once all responses of a path have been played, its last one is repeated.
+type tape struct {
+	mu      sync.Mutex
+	entries []tapeEntry
+	played  []bool
+}
+
+func loadTape(path string) (*tape, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening replay file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	t := &tape{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry tapeEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		t.entries = append(t.entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading replay file: %w", err)
+	}
+	t.played = make([]bool, len(t.entries))
+	return t, nil
+}
+
+func (t *tape) RoundTrip(req *http.Request) (*http.Response, error) {
+	entry, ok := t.next(req.Method, req.URL.RequestURI())
+	if !ok {
+		entry = tapeEntry{Status: http.StatusNotFound, Body: `{"error": {"message": "not recorded", "type": "invalid_request_error"}}`}
+		logrus.Warnf("No recorded response for %s %s", req.Method, req.URL.RequestURI())
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.Status, http.StatusText(entry.Status)),
+		StatusCode:    entry.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(strings.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+		Request:       req,
+	}, nil
+}
+
+// next returns the response to play for a request and marks it as played.
+func (t *tape) next(method, uri string) (tapeEntry, bool) {
+	path, _, _ := strings.Cut(uri, "?")
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	samePath, last := -1, -1
+	for i, e := range t.entries {
+		if e.Method != method {
+			continue
+		}
+		ePath, _, _ := strings.Cut(e.URL, "?")
+		if ePath != path {
+			continue
+		}
+		last = i
+		if t.played[i] {
+			continue
+		}
+		if e.URL == uri {
+			t.played[i] = true
+			return e, true
+		}
+		if samePath < 0 {
+			samePath = i
+		}
+	}
+	switch {
+	case samePath >= 0:
+		t.played[samePath] = true
+		return t.entries[samePath], true
+	case last >= 0:
+		return t.entries[last], true
+	}
+	return tapeEntry{}, false
+}
+
+// windows returns the collection windows of the recording, in order: the distinct time ranges of the
+// usage requests for buckets of width.
+func (t *tape) windows(width string) [][2]int64 {
+	var windows [][2]int64
+	seen := make(map[[2]int64]bool)
+	for _, e := range t.entries {
+		u, err := url.Parse(e.URL)
+		if err != nil || !strings.HasPrefix(u.Path, "/v1/organization/usage/") || u.Query().Get("bucket_width") != width {
+			continue
+		}
+		start, err1 := strconv.ParseInt(u.Query().Get("start_time"), 10, 64)
+		end, err2 := strconv.ParseInt(u.Query().Get("end_time"), 10, 64)
+		w := [2]int64{start, end}
+		if err1 != nil || err2 != nil || seen[w] {
+			continue
+		}
+		seen[w] = true
+		windows = append(windows, w)
+	}
+	return windows
+}
+
+// runReplay collects every window of the replay tape, as the recording exporter did, and writes the
+// resulting metrics to out.
+func runReplay(c windowCollector, g prometheus.Gatherer, out io.Writer) error {
+	windows := replayTape.windows(*usageBucketWidth)
+	if len(windows) == 0 {
+		return fmt.Errorf("%s holds no usage requests for %s buckets to replay", *replayFile, *usageBucketWidth)
+	}
+	var errs []error
+	for _, w := range windows {
+		logrus.WithFields(logrus.Fields{"window_start": w[0], "window_end": w[1]}).Info("Replaying collection cycle")
+		// The recorded windows already include the lookback.
+		if err := c.collectWindow(w[0], w[1]); err != nil {
+			errs = append(errs, err)
+		}
+		stateMu.Lock()
+		lastScrape = w[1]
+		stateMu.Unlock()
+	}
+	errs = append(errs, writeMetrics(out, g))
+	return errors.Join(errs...)
+}
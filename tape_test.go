@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTape_Next(t *testing.T) {
+	tp := &tape{entries: []tapeEntry{
+		{Method: "GET", URL: "/v1/organization/usage/completions?start_time=60", Status: 200, Body: "a"},
+		{Method: "GET", URL: "/v1/organization/usage/completions?start_time=120", Status: 200, Body: "b"},
+		{Method: "GET", URL: "/v1/organization/projects/proj-1", Status: 200, Body: "c"},
+	}}
+	tp.played = make([]bool, len(tp.entries))
+
+	tests := []struct {
+		uri  string
+		body string
+		ok   bool
+	}{
+		{uri: "/v1/organization/usage/completions?start_time=120", body: "b", ok: true},
+		{uri: "/v1/organization/usage/completions?start_time=999", body: "a", ok: true},
+		{uri: "/v1/organization/usage/completions?start_time=60", body: "b", ok: true},
+		{uri: "/v1/organization/projects/proj-1", body: "c", ok: true},
+		{uri: "/v1/organization/projects/proj-1", body: "c", ok: true},
+		{uri: "/v1/organization/costs", ok: false},
+	}
+	for _, tt := range tests {
+		entry, ok := tp.next("GET", tt.uri)
+		assert.Equal(t, tt.ok, ok, tt.uri)
+		assert.Equal(t, tt.body, entry.Body, tt.uri)
+	}
+
+	resp, err := tp.RoundTrip(httptest.NewRequest("GET", "http://api.invalid/v1/organization/costs", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	setResolveUsers(t)
+	path := filepath.Join(t.TempDir(), "tape.jsonl")
+	origRecord, origReplay := *recordFile, *replayFile
+	defer func() {
+		*recordFile, *replayFile = origRecord, origReplay
+		require.NoError(t, configureTape())
+	}()
+
+	now := time.Date(2024, 6, 12, 15, 30, 0, 0, time.UTC)
+	server := httptest.NewServer(newMockAPI(func() time.Time { return now }))
+	defer server.Close()
+
+	end := now.Unix()
+	endpoint := UsageEndpoint{Path: "completions", Name: "completions"}
+	collect := func(baseURL string) float64 {
+		usageState = make(map[string]float64)
+		projectNames = make(map[string]string)
+		apiKeyNames = make(map[string]string)
+		userEmails = make(map[string]string)
+		tokensTotal.Reset()
+
+		client, err := newAPIClient(5 * time.Second)
+		require.NoError(t, err)
+		e := &Exporter{client: client, apiKey: "test", orgID: mockOrgID, orgName: mockOrgName, targets: newAPITargets(mockOrgID, baseURL, 3)}
+		require.NoError(t, e.fetchUsageData(endpoint, end-300, end-120))
+		require.NoError(t, e.fetchUsageData(endpoint, end-180, end))
+		return testutil.ToFloat64(tokensTotal.WithLabelValues(mockOrgID, mockOrgName, "o3-mini", "completions",
+			"proj_demo_research", "research", "user_demo_bob", "bob@example.com", "key_demo_research", "research-key", "false", "output"))
+	}
+
+	*recordFile, *replayFile = path, ""
+	require.NoError(t, configureTape())
+	recorded := collect(server.URL)
+	require.Positive(t, recorded)
+
+	tapeData, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(tapeData), "Bearer", "the API key is not recorded")
+
+	*recordFile, *replayFile = "", path
+	require.NoError(t, configureTape())
+	assert.Equal(t, [][2]int64{{end - 300, end - 120}, {end - 180, end}}, replayTape.windows("1m"))
+	assert.Equal(t, recorded, collect("http://api.invalid"), "the replay counts the same tokens without the API")
+
+	*recordFile = path
+	assert.ErrorContains(t, configureTape(), "cannot be combined")
+}
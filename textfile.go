@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+// node_exporter Textfile Output
+
+const textfileName = "openai_exporter.prom"
+
+var textfileDirectory = flag.String("textfile.directory", "", "Write metrics to a .prom file in this directory after each cycle for node_exporter's textfile collector, instead of serving HTTP")
+
+func init() {
+	flag.StringVar(textfileDirectory, "output.textfile-dir", "", "Alias of -textfile.directory")
+}
+
+// writeTextfile atomically replaces dir/openai_exporter.prom with the current OpenAI metrics from g.
+// Go runtime and process metrics are left out, since node_exporter already exposes its own.
+func writeTextfile(dir string, g prometheus.Gatherer) error {
+	tmp, err := os.CreateTemp(dir, textfileName+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if err := writeMetrics(tmp, g); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing temporary file: %w", err)
+	}
+	// node_exporter ignores files it cannot read; CreateTemp uses 0600.
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("error setting file permissions: %w", err)
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, textfileName))
+}
+
+// writeMetrics writes the OpenAI metrics from g to w in the text exposition format.
+func writeMetrics(w io.Writer, g prometheus.Gatherer) error {
+	families, err := openaiGatherer{gatherer: g}.Gather()
+	if err != nil {
+		return fmt.Errorf("error gathering metrics: %w", err)
+	}
+	for _, mf := range families {
+		if _, err := expfmt.MetricFamilyToText(w, mf); err != nil {
+			return fmt.Errorf("error writing metrics: %w", err)
+		}
+	}
+	return nil
+}
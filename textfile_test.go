@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTextfile(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "openai_api_tokens_total", Help: "test"}, []string{"model"})
+	reg.MustRegister(counter, collectors.NewGoCollector())
+	counter.WithLabelValues("gpt-4").Add(42)
+
+	dir := t.TempDir()
+	require.NoError(t, writeTextfile(dir, reg))
+
+	data, err := os.ReadFile(filepath.Join(dir, textfileName))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `openai_api_tokens_total{model="gpt-4"} 42`)
+	assert.NotContains(t, string(data), "go_goroutines")
+
+	info, err := os.Stat(filepath.Join(dir, textfileName))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files must be cleaned up")
+}
+
+func TestWriteTextfile_MissingDirectory(t *testing.T) {
+	err := writeTextfile(filepath.Join(t.TempDir(), "missing"), prometheus.NewRegistry())
+	assert.Error(t, err)
+}
+
+func TestTextfileDirectoryAlias(t *testing.T) {
+	orig := *textfileDirectory
+	defer func() { *textfileDirectory = orig }()
+
+	require.NoError(t, flag.Set("output.textfile-dir", "/var/lib/node_exporter/textfile"))
+	assert.Equal(t, "/var/lib/node_exporter/textfile", *textfileDirectory)
+}
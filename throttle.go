@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+// Client-Side Rate Limiting
+
+var (
+	apiRateLimit = flag.Float64("api.rate-limit", 0, "Maximum number of OpenAI API requests per second, shared by all organizations, endpoints, lookups and backfills (0 disables)")
+	apiBurst     = flag.Int("api.burst", 5, "Number of OpenAI API requests that may be sent at once before api.rate-limit applies")
+
+	rateLimitWaitSeconds = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "openai_exporter_rate_limit_wait_seconds_total",
+			Help: "Total time OpenAI API requests waited for the client-side rate limiter.",
+		},
+	)
+)
+
+// apiLimiter paces the OpenAI API requests of the whole process; by default it lets every request through.
+var apiLimiter = rate.NewLimiter(rate.Inf, 0)
+
+// newAPILimiter returns a token-bucket limiter of perSecond requests with the given burst,
+// or an unlimited one if perSecond is 0.
+func newAPILimiter(perSecond float64, burst int) (*rate.Limiter, error) {
+	if perSecond < 0 {
+		return nil, fmt.Errorf("api.rate-limit must not be negative, got %g", perSecond)
+	}
+	if perSecond == 0 {
+		return rate.NewLimiter(rate.Inf, 0), nil
+	}
+	if burst < 1 {
+		return nil, fmt.Errorf("api.burst must be at least 1, got %d", burst)
+	}
+	return rate.NewLimiter(rate.Limit(perSecond), burst), nil
+}
+
+// waitForAPI blocks until the limiter allows another request, or the requests are cancelled on shutdown.
+func waitForAPI() error {
+	began := time.Now()
+	err := apiLimiter.Wait(requestCtx)
+	rateLimitWaitSeconds.Add(time.Since(began).Seconds())
+	return err
+}
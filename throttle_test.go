@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestNewAPILimiter(t *testing.T) {
+	tests := []struct {
+		name      string
+		perSecond float64
+		burst     int
+		wantLimit rate.Limit
+		wantErr   string
+	}{
+		{name: "disabled", perSecond: 0, burst: 0, wantLimit: rate.Inf},
+		{name: "limited", perSecond: 2.5, burst: 5, wantLimit: 2.5},
+		{name: "negative rate", perSecond: -1, burst: 5, wantErr: "must not be negative"},
+		{name: "no burst", perSecond: 1, burst: 0, wantErr: "api.burst must be at least 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter, err := newAPILimiter(tt.perSecond, tt.burst)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantLimit, limiter.Limit())
+		})
+	}
+}
+
+func TestWaitForAPI(t *testing.T) {
+	defer func(l *rate.Limiter) { apiLimiter = l }(apiLimiter)
+	var err error
+	apiLimiter, err = newAPILimiter(20, 1)
+	require.NoError(t, err)
+	waited := testutil.ToFloat64(rateLimitWaitSeconds)
+
+	began := time.Now()
+	for range 3 {
+		require.NoError(t, waitForAPI())
+	}
+	assert.GreaterOrEqual(t, time.Since(began), 90*time.Millisecond, "requests after the burst are paced")
+	assert.Greater(t, testutil.ToFloat64(rateLimitWaitSeconds), waited)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// Outbound TLS
+
+var (
+	tlsCAFile   = flag.String("openai.tls.ca-file", "", "PEM file of root CAs trusted for API requests in addition to the system ones, e.g. of a TLS-intercepting gateway")
+	tlsCertFile = flag.String("openai.tls.cert-file", "", "PEM client certificate presented to the API; requires -openai.tls.key-file")
+	tlsKeyFile  = flag.String("openai.tls.key-file", "", "PEM private key of -openai.tls.cert-file")
+)
+
+// apiTLSConfig returns the TLS configuration of API clients, or nil when no TLS flag is set.
+func apiTLSConfig() (*tls.Config, error) {
+	if *tlsCAFile == "" && *tlsCertFile == "" && *tlsKeyFile == "" {
+		return nil, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if *tlsCAFile != "" {
+		pem, err := os.ReadFile(*tlsCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read -openai.tls.ca-file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in -openai.tls.ca-file %s", *tlsCAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if (*tlsCertFile == "") != (*tlsKeyFile == "") {
+		return nil, fmt.Errorf("-openai.tls.cert-file and -openai.tls.key-file must be set together")
+	}
+	if *tlsCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(*tlsCertFile, *tlsKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeClientCert writes a self-signed client certificate and its key to dir.
+func writeClientCert(t *testing.T, dir string) (certFile, keyFile string, cert *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err = x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, "client.crt")
+	keyFile = filepath.Join(dir, "client.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile, cert
+}
+
+func TestNewAPIClient_TLS(t *testing.T) {
+	origCA, origCert, origKey := *tlsCAFile, *tlsCertFile, *tlsKeyFile
+	defer func() { *tlsCAFile, *tlsCertFile, *tlsKeyFile = origCA, origCert, origKey }()
+	dir := t.TempDir()
+
+	certFile, keyFile, clientCert := writeClientCert(t, dir)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+	emptyFile := filepath.Join(dir, "empty.pem")
+	require.NoError(t, os.WriteFile(emptyFile, nil, 0600))
+
+	tests := []struct {
+		name             string
+		ca, cert, key    string
+		wantConfigErr    bool
+		wantRequestError bool
+	}{
+		{name: "CA and client certificate", ca: caFile, cert: certFile, key: keyFile},
+		{name: "without client certificate", ca: caFile, wantRequestError: true},
+		{name: "without CA", cert: certFile, key: keyFile, wantRequestError: true},
+		{name: "CA file without certificates", ca: emptyFile, wantConfigErr: true},
+		{name: "missing CA file", ca: filepath.Join(dir, "missing.pem"), wantConfigErr: true},
+		{name: "certificate without key", ca: caFile, cert: certFile, wantConfigErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*tlsCAFile, *tlsCertFile, *tlsKeyFile = tt.ca, tt.cert, tt.key
+			client, err := newAPIClient(5 * time.Second)
+			if tt.wantConfigErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			resp, err := client.Get(server.URL)
+			if tt.wantRequestError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+			assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Built-in Tool Calls
+
+var toolCallsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "openai_api_tool_calls_total",
+		Help: "Total number of built-in tool calls, which are charged per call, per organization, project, model and tool",
+	},
+	[]string{"org_id", "org_name", "project_id", "project_name", "model", "tool"},
+)
+
+// toolCalls returns the built-in tool calls of the result by tool.
+func (r UsageResult) toolCalls() map[string]int64 {
+	return map[string]int64{
+		"web_search":  r.NumWebSearchCalls,
+		"file_search": r.NumFileSearchCalls,
+	}
+}
+
+// countToolCalls counts the built-in tool calls in the buckets of a usage endpoint. Like audio units,
+// results split by dimensions the counter does not have are summed per bucket first.
+func (e *Exporter) countToolCalls(buckets []Bucket) {
+	for _, bucket := range buckets {
+		totals := make(map[string]int64)
+		series := make(map[string]prometheus.Labels)
+		for _, result := range bucket.Results {
+			for tool, calls := range result.toolCalls() {
+				if calls == 0 {
+					continue
+				}
+				key := strings.Join([]string{
+					"tool_calls_total",
+					fmt.Sprintf("%d", bucket.StartTime),
+					deref(result.ProjectID),
+					deref(result.Model),
+					tool,
+					e.orgID,
+				}, "|")
+				if _, ok := series[key]; !ok {
+					series[key] = prometheus.Labels{
+						"org_id":       e.orgID,
+						"org_name":     e.orgName,
+						"project_id":   deref(result.ProjectID),
+						"project_name": e.ensureProjectName(deref(result.ProjectID)),
+						"model":        deref(result.Model),
+						"tool":         tool,
+					}
+				}
+				totals[key] += calls
+			}
+		}
+		for _, key := range sortedKeys(totals) {
+			updateCounter(toolCallsTotal, key, series[key], bucket.EndTime, float64(totals[key]))
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchUsageData_ToolCalls(t *testing.T) {
+	usageState = make(map[string]float64)
+	projectNames = map[string]string{"proj-1": "one"}
+	toolCallsTotal.Reset()
+
+	end := time.Now().Add(-time.Minute).Truncate(time.Minute).Unix()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"object": "page", "data": [{"start_time": ` + strconv.FormatInt(end-60, 10) + `, "end_time": ` + strconv.FormatInt(end, 10) + `, "results": [
+			{"input_tokens": 10, "num_web_search_calls": 3, "project_id": "proj-1", "user_id": "user-1", "model": "gpt-4o"},
+			{"input_tokens": 10, "num_web_search_calls": 2, "num_file_search_calls": 4, "project_id": "proj-1", "user_id": "user-2", "model": "gpt-4o"},
+			{"input_tokens": 10, "project_id": "proj-1", "model": "gpt-4o-mini"}
+		]}], "has_more": false}`))
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+
+	for range 2 {
+		require.NoError(t, e.fetchUsageData(UsageEndpoint{Path: "completions", Name: "completions"}, end-60, end))
+	}
+
+	assert.Equal(t, 5.0, testutil.ToFloat64(toolCallsTotal.WithLabelValues("org-1", "prod", "proj-1", "one", "gpt-4o", "web_search")),
+		"results of different users are summed and counted once")
+	assert.Equal(t, 4.0, testutil.ToFloat64(toolCallsTotal.WithLabelValues("org-1", "prod", "proj-1", "one", "gpt-4o", "file_search")))
+	assert.Equal(t, 2, testutil.CollectAndCount(toolCallsTotal), "tools that were not called have no series")
+}
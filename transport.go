@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// HTTP Transport Tuning
+
+var (
+	openaiTimeout       = flag.Duration("openai.timeout", 10*time.Second, "Timeout of a single OpenAI API request, including reading the response; raise it for cost and backfill queries over long ranges")
+	maxIdleConnsPerHost = flag.Int("openai.max-idle-conns-per-host", 8, "Maximum number of idle connections kept open per API host")
+	idleConnTimeout     = flag.Duration("openai.idle-conn-timeout", 90*time.Second, "How long an idle connection is kept open before it is closed (0 keeps it open)")
+	keepAlive           = flag.Duration("openai.keep-alive", 30*time.Second, "Interval of TCP keep-alive probes on API connections (0 disables them)")
+)
+
+// validateTransport checks the transport flags.
+func validateTransport() error {
+	if *openaiTimeout <= 0 {
+		return fmt.Errorf("openai.timeout must be positive, got %s", *openaiTimeout)
+	}
+	if *maxIdleConnsPerHost < 0 {
+		return fmt.Errorf("openai.max-idle-conns-per-host must not be negative, got %d", *maxIdleConnsPerHost)
+	}
+	return nil
+}
+
+// tuneTransport applies the connection pool and keep-alive settings to transport.
+func tuneTransport(transport *http.Transport) {
+	transport.MaxIdleConnsPerHost = *maxIdleConnsPerHost
+	transport.MaxIdleConns = max(transport.MaxIdleConns, *maxIdleConnsPerHost)
+	transport.IdleConnTimeout = *idleConnTimeout
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: *keepAlive}
+	if *keepAlive == 0 {
+		dialer.KeepAlive = -1
+	}
+	transport.DialContext = dialer.DialContext
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTransport(t *testing.T) {
+	defer func(timeout time.Duration, idle int) { *openaiTimeout, *maxIdleConnsPerHost = timeout, idle }(*openaiTimeout, *maxIdleConnsPerHost)
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		idle    int
+		wantErr string
+	}{
+		{name: "defaults", timeout: 10 * time.Second, idle: 8},
+		{name: "no timeout", timeout: 0, idle: 8, wantErr: "openai.timeout must be positive"},
+		{name: "negative idle connections", timeout: time.Minute, idle: -1, wantErr: "must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*openaiTimeout, *maxIdleConnsPerHost = tt.timeout, tt.idle
+			err := validateTransport()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestNewAPIClient_Transport(t *testing.T) {
+	defer func(idle int, idleTimeout time.Duration) {
+		*maxIdleConnsPerHost, *idleConnTimeout = idle, idleTimeout
+	}(*maxIdleConnsPerHost, *idleConnTimeout)
+	*maxIdleConnsPerHost, *idleConnTimeout = 200, time.Minute
+	defer func(h http.Header) { apiHeaders = h }(apiHeaders)
+	apiHeaders = nil
+
+	client, err := newAPIClient(45 * time.Second)
+	require.NoError(t, err)
+
+	assert.Equal(t, 45*time.Second, client.Timeout)
+	transport := client.Transport.(*http.Transport)
+	assert.Equal(t, 200, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 200, transport.MaxIdleConns, "the total is raised to fit the per-host limit")
+	assert.Equal(t, time.Minute, transport.IdleConnTimeout)
+	assert.NotNil(t, transport.DialContext)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+)
+
+// Usage Snapshot API
+
+// usageSnapshot is the /api/v1/snapshot response: the counters of the exporter as structured JSON.
+type usageSnapshot struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	// LastScrape is the end of the last collected window.
+	LastScrape time.Time      `json:"last_scrape"`
+	Usage      []modelUsage   `json:"usage"`
+	Costs      []projectCosts `json:"costs"`
+	DailyCosts []dailyCost    `json:"daily_costs"`
+}
+
+// modelUsage is the usage of one model by one project since the counters started.
+type modelUsage struct {
+	OrgID            string             `json:"org_id"`
+	OrgName          string             `json:"org_name"`
+	ProjectID        string             `json:"project_id"`
+	ProjectName      string             `json:"project_name"`
+	Model            string             `json:"model"`
+	Tokens           map[string]float64 `json:"tokens"`
+	EstimatedCostUSD *float64           `json:"estimated_cost_usd,omitempty"`
+}
+
+// projectCosts is the billed amount of one project since the counters started, in total and per line item.
+type projectCosts struct {
+	OrgID       string             `json:"org_id"`
+	OrgName     string             `json:"org_name"`
+	ProjectID   string             `json:"project_id"`
+	ProjectName string             `json:"project_name"`
+	TotalUSD    float64            `json:"total_usd"`
+	LineItems   map[string]float64 `json:"line_items"`
+}
+
+// dailyCost is the spend of one line item of a project on one day, as last reported by the Costs API.
+type dailyCost struct {
+	Date        string  `json:"date"`
+	OrgID       string  `json:"org_id"`
+	OrgName     string  `json:"org_name"`
+	ProjectID   string  `json:"project_id"`
+	ProjectName string  `json:"project_name"`
+	LineItem    string  `json:"line_item"`
+	Currency    string  `json:"currency"`
+	Amount      float64 `json:"amount"`
+}
+
+func counterValue(m *dto.Metric) float64 { return m.GetCounter().GetValue() }
+
+// takeUsageSnapshot sums the token counters per organization, project and model, and the cost counters per
+// project, over all other labels.
+func takeUsageSnapshot(now time.Time) (usageSnapshot, error) {
+	stateMu.RLock()
+	snap := usageSnapshot{GeneratedAt: now.UTC(), LastScrape: time.Unix(lastScrape, 0).UTC(),
+		Usage: []modelUsage{}, Costs: []projectCosts{}, DailyCosts: []dailyCost{}}
+	stateMu.RUnlock()
+
+	tokens, err := collectSamples(tokensTotal, counterValue)
+	if err != nil {
+		return snap, fmt.Errorf("error reading token counters: %w", err)
+	}
+	usage := make(map[string]*modelUsage)
+	key := func(l map[string]string) string {
+		return strings.Join([]string{l["org_id"], l["project_id"], l["model"]}, "|")
+	}
+	for _, s := range tokens {
+		u, ok := usage[key(s.Labels)]
+		if !ok {
+			u = &modelUsage{OrgID: s.Labels["org_id"], OrgName: s.Labels["org_name"], ProjectID: s.Labels["project_id"],
+				ProjectName: s.Labels["project_name"], Model: s.Labels["model"], Tokens: map[string]float64{}}
+			usage[key(s.Labels)] = u
+		}
+		u.Tokens[s.Labels["token_type"]] += s.Value
+	}
+	if *pricingEnabled {
+		costs, err := collectSamples(estimatedCostTotal, counterValue)
+		if err != nil {
+			return snap, fmt.Errorf("error reading estimated costs: %w", err)
+		}
+		for _, s := range costs {
+			if u, ok := usage[key(s.Labels)]; ok {
+				cost := s.Value
+				if u.EstimatedCostUSD != nil {
+					cost += *u.EstimatedCostUSD
+				}
+				u.EstimatedCostUSD = &cost
+			}
+		}
+	}
+	for _, k := range sortedKeys(usage) {
+		snap.Usage = append(snap.Usage, *usage[k])
+	}
+
+	costs, err := collectSamples(costsTotal, counterValue)
+	if err != nil {
+		return snap, fmt.Errorf("error reading cost counters: %w", err)
+	}
+	projects := make(map[string]*projectCosts)
+	for _, s := range costs {
+		k := s.Labels["org_id"] + "|" + s.Labels["project_id"]
+		p, ok := projects[k]
+		if !ok {
+			p = &projectCosts{OrgID: s.Labels["org_id"], OrgName: s.Labels["org_name"], ProjectID: s.Labels["project_id"],
+				ProjectName: s.Labels["project_name"], LineItems: map[string]float64{}}
+			projects[k] = p
+		}
+		p.TotalUSD += s.Value
+		p.LineItems[s.Labels["line_item"]] += s.Value
+	}
+	for _, k := range sortedKeys(projects) {
+		snap.Costs = append(snap.Costs, *projects[k])
+	}
+
+	daily, err := collectSamples(dailyCostUSD, func(m *dto.Metric) float64 { return m.GetGauge().GetValue() })
+	if err != nil {
+		return snap, fmt.Errorf("error reading daily costs: %w", err)
+	}
+	for _, s := range daily {
+		snap.DailyCosts = append(snap.DailyCosts, dailyCost{Date: s.Labels["date"], OrgID: s.Labels["organization_id"],
+			OrgName: s.Labels["org_name"], ProjectID: s.Labels["project_id"], ProjectName: s.Labels["project_name"],
+			LineItem: s.Labels["line_item"], Currency: s.Labels["currency"], Amount: s.Value})
+	}
+	slices.SortFunc(snap.DailyCosts, func(a, b dailyCost) int {
+		return strings.Compare(a.Date+a.OrgID+a.ProjectID+a.LineItem, b.Date+b.OrgID+b.ProjectID+b.LineItem)
+	})
+	return snap, nil
+}
+
+// usageSnapshotHandler serves GET /api/v1/snapshot. refresh runs before every snapshot, so in pull mode
+// requests collect like scrapes do.
+func usageSnapshotHandler(refresh func(time.Time)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "only GET is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		refresh(time.Now())
+		snap, err := takeUsageSnapshot(time.Now())
+		if err != nil {
+			logrus.WithError(err).Error("Failed to take usage snapshot")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(snap); err != nil {
+			logrus.WithError(err).Error("Failed to write response")
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageSnapshotHandler(t *testing.T) {
+	tokensTotal.Reset()
+	costsTotal.Reset()
+	dailyCostUSD.Reset()
+	estimatedCostTotal.Reset()
+	defer func() {
+		tokensTotal.Reset()
+		costsTotal.Reset()
+		dailyCostUSD.Reset()
+		estimatedCostTotal.Reset()
+	}()
+
+	tokens := func(user, tokenType string) prometheus.Labels {
+		return prometheus.Labels{"org_id": "org-1", "org_name": "prod", "model": "gpt-4o", "operation": "completions",
+			"project_id": "proj-1", "project_name": "one", "user_id": user, "user_email": "", "api_key_id": "key-1",
+			"api_key_name": "ci", "batch": "false", "token_type": tokenType}
+	}
+	tokensTotal.With(tokens("user-1", "input")).Add(100)
+	tokensTotal.With(tokens("user-2", "input")).Add(50)
+	tokensTotal.With(tokens("user-1", "output")).Add(20)
+	estimatedCostTotal.WithLabelValues("org-1", "prod", "proj-1", "one", "gpt-4o").Add(0.75)
+	costsTotal.WithLabelValues("org-1", "prod", "proj-1", "one", "completions").Add(1.5)
+	costsTotal.WithLabelValues("org-1", "prod", "proj-1", "one", "embeddings").Add(0.25)
+	dailyCostUSD.WithLabelValues("2024-06-08", "proj-1", "one", "completions", "org-1", "prod", "usd").Set(1.5)
+
+	var refreshed bool
+	rec := httptest.NewRecorder()
+	usageSnapshotHandler(func(time.Time) { refreshed = true })(rec, httptest.NewRequest(http.MethodGet, "/api/v1/snapshot", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, refreshed)
+
+	var snap usageSnapshot
+	estimated := 0.75
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &snap))
+	assert.Equal(t, []modelUsage{{OrgID: "org-1", OrgName: "prod", ProjectID: "proj-1", ProjectName: "one", Model: "gpt-4o",
+		Tokens: map[string]float64{"input": 150, "output": 20}, EstimatedCostUSD: &estimated}}, snap.Usage)
+	assert.Equal(t, []projectCosts{{OrgID: "org-1", OrgName: "prod", ProjectID: "proj-1", ProjectName: "one", TotalUSD: 1.75,
+		LineItems: map[string]float64{"completions": 1.5, "embeddings": 0.25}}}, snap.Costs)
+	assert.Equal(t, []dailyCost{{Date: "2024-06-08", OrgID: "org-1", OrgName: "prod", ProjectID: "proj-1", ProjectName: "one",
+		LineItem: "completions", Currency: "usd", Amount: 1.5}}, snap.DailyCosts)
+
+	rec = httptest.NewRecorder()
+	usageSnapshotHandler(func(time.Time) {})(rec, httptest.NewRequest(http.MethodPost, "/api/v1/snapshot", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
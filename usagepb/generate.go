@@ -0,0 +1,4 @@
+// Package usagepb contains the protobuf definitions and generated gRPC code of the usage query service.
+package usagepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative usage.proto
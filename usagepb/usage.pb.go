@@ -0,0 +1,589 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        v5.28.3
+// source: usage.proto
+
+package usagepb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type QueryUsageRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Start of the time range as a Unix timestamp in seconds, inclusive. Buckets are matched by their start.
+	StartTime int64 `protobuf:"varint,1,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	// End of the time range as a Unix timestamp in seconds, exclusive. Zero means no upper bound.
+	EndTime int64 `protobuf:"varint,2,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
+	// Dimensions to group by: operation, project_id, user_id, api_key_id, model, batch, token_type.
+	// An empty list returns a single grand total.
+	GroupBy []string `protobuf:"bytes,3,rep,name=group_by,json=groupBy,proto3" json:"group_by,omitempty"`
+	// Only buckets matching every non-empty list are included.
+	Filter        *UsageFilter `protobuf:"bytes,4,opt,name=filter,proto3" json:"filter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryUsageRequest) Reset() {
+	*x = QueryUsageRequest{}
+	mi := &file_usage_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryUsageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryUsageRequest) ProtoMessage() {}
+
+func (x *QueryUsageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_usage_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryUsageRequest.ProtoReflect.Descriptor instead.
+func (*QueryUsageRequest) Descriptor() ([]byte, []int) {
+	return file_usage_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *QueryUsageRequest) GetStartTime() int64 {
+	if x != nil {
+		return x.StartTime
+	}
+	return 0
+}
+
+func (x *QueryUsageRequest) GetEndTime() int64 {
+	if x != nil {
+		return x.EndTime
+	}
+	return 0
+}
+
+func (x *QueryUsageRequest) GetGroupBy() []string {
+	if x != nil {
+		return x.GroupBy
+	}
+	return nil
+}
+
+func (x *QueryUsageRequest) GetFilter() *UsageFilter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+type UsageFilter struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Operations    []string               `protobuf:"bytes,1,rep,name=operations,proto3" json:"operations,omitempty"`
+	ProjectIds    []string               `protobuf:"bytes,2,rep,name=project_ids,json=projectIds,proto3" json:"project_ids,omitempty"`
+	UserIds       []string               `protobuf:"bytes,3,rep,name=user_ids,json=userIds,proto3" json:"user_ids,omitempty"`
+	ApiKeyIds     []string               `protobuf:"bytes,4,rep,name=api_key_ids,json=apiKeyIds,proto3" json:"api_key_ids,omitempty"`
+	Models        []string               `protobuf:"bytes,5,rep,name=models,proto3" json:"models,omitempty"`
+	TokenTypes    []string               `protobuf:"bytes,6,rep,name=token_types,json=tokenTypes,proto3" json:"token_types,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UsageFilter) Reset() {
+	*x = UsageFilter{}
+	mi := &file_usage_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UsageFilter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UsageFilter) ProtoMessage() {}
+
+func (x *UsageFilter) ProtoReflect() protoreflect.Message {
+	mi := &file_usage_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UsageFilter.ProtoReflect.Descriptor instead.
+func (*UsageFilter) Descriptor() ([]byte, []int) {
+	return file_usage_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *UsageFilter) GetOperations() []string {
+	if x != nil {
+		return x.Operations
+	}
+	return nil
+}
+
+func (x *UsageFilter) GetProjectIds() []string {
+	if x != nil {
+		return x.ProjectIds
+	}
+	return nil
+}
+
+func (x *UsageFilter) GetUserIds() []string {
+	if x != nil {
+		return x.UserIds
+	}
+	return nil
+}
+
+func (x *UsageFilter) GetApiKeyIds() []string {
+	if x != nil {
+		return x.ApiKeyIds
+	}
+	return nil
+}
+
+func (x *UsageFilter) GetModels() []string {
+	if x != nil {
+		return x.Models
+	}
+	return nil
+}
+
+func (x *UsageFilter) GetTokenTypes() []string {
+	if x != nil {
+		return x.TokenTypes
+	}
+	return nil
+}
+
+type UsageAggregate struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Values of the requested group_by dimensions.
+	Dimensions map[string]string `protobuf:"bytes,1,rep,name=dimensions,proto3" json:"dimensions,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Tokens     float64           `protobuf:"fixed64,2,opt,name=tokens,proto3" json:"tokens,omitempty"`
+	// Number of buckets summed into this aggregate.
+	Buckets       int64 `protobuf:"varint,3,opt,name=buckets,proto3" json:"buckets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UsageAggregate) Reset() {
+	*x = UsageAggregate{}
+	mi := &file_usage_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UsageAggregate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UsageAggregate) ProtoMessage() {}
+
+func (x *UsageAggregate) ProtoReflect() protoreflect.Message {
+	mi := &file_usage_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UsageAggregate.ProtoReflect.Descriptor instead.
+func (*UsageAggregate) Descriptor() ([]byte, []int) {
+	return file_usage_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *UsageAggregate) GetDimensions() map[string]string {
+	if x != nil {
+		return x.Dimensions
+	}
+	return nil
+}
+
+func (x *UsageAggregate) GetTokens() float64 {
+	if x != nil {
+		return x.Tokens
+	}
+	return 0
+}
+
+func (x *UsageAggregate) GetBuckets() int64 {
+	if x != nil {
+		return x.Buckets
+	}
+	return 0
+}
+
+type QueryUsageResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Aggregates    []*UsageAggregate      `protobuf:"bytes,1,rep,name=aggregates,proto3" json:"aggregates,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryUsageResponse) Reset() {
+	*x = QueryUsageResponse{}
+	mi := &file_usage_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryUsageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryUsageResponse) ProtoMessage() {}
+
+func (x *QueryUsageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_usage_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryUsageResponse.ProtoReflect.Descriptor instead.
+func (*QueryUsageResponse) Descriptor() ([]byte, []int) {
+	return file_usage_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *QueryUsageResponse) GetAggregates() []*UsageAggregate {
+	if x != nil {
+		return x.Aggregates
+	}
+	return nil
+}
+
+type QueryCostsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// First day of the range as YYYY-MM-DD (UTC), inclusive. Empty means no lower bound.
+	StartDate string `protobuf:"bytes,1,opt,name=start_date,json=startDate,proto3" json:"start_date,omitempty"`
+	// Last day of the range as YYYY-MM-DD (UTC), inclusive. Empty means no upper bound.
+	EndDate string `protobuf:"bytes,2,opt,name=end_date,json=endDate,proto3" json:"end_date,omitempty"`
+	// Dimensions to group by: date, project_id, project_name, line_item, organization_id.
+	// Costs are always grouped by currency as well.
+	GroupBy []string `protobuf:"bytes,3,rep,name=group_by,json=groupBy,proto3" json:"group_by,omitempty"`
+	// Only costs of these projects are included. Empty means all projects.
+	ProjectIds    []string `protobuf:"bytes,4,rep,name=project_ids,json=projectIds,proto3" json:"project_ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryCostsRequest) Reset() {
+	*x = QueryCostsRequest{}
+	mi := &file_usage_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryCostsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryCostsRequest) ProtoMessage() {}
+
+func (x *QueryCostsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_usage_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryCostsRequest.ProtoReflect.Descriptor instead.
+func (*QueryCostsRequest) Descriptor() ([]byte, []int) {
+	return file_usage_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *QueryCostsRequest) GetStartDate() string {
+	if x != nil {
+		return x.StartDate
+	}
+	return ""
+}
+
+func (x *QueryCostsRequest) GetEndDate() string {
+	if x != nil {
+		return x.EndDate
+	}
+	return ""
+}
+
+func (x *QueryCostsRequest) GetGroupBy() []string {
+	if x != nil {
+		return x.GroupBy
+	}
+	return nil
+}
+
+func (x *QueryCostsRequest) GetProjectIds() []string {
+	if x != nil {
+		return x.ProjectIds
+	}
+	return nil
+}
+
+type CostAggregate struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Values of the requested group_by dimensions.
+	Dimensions    map[string]string `protobuf:"bytes,1,rep,name=dimensions,proto3" json:"dimensions,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Currency      string            `protobuf:"bytes,2,opt,name=currency,proto3" json:"currency,omitempty"`
+	Amount        float64           `protobuf:"fixed64,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CostAggregate) Reset() {
+	*x = CostAggregate{}
+	mi := &file_usage_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CostAggregate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CostAggregate) ProtoMessage() {}
+
+func (x *CostAggregate) ProtoReflect() protoreflect.Message {
+	mi := &file_usage_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CostAggregate.ProtoReflect.Descriptor instead.
+func (*CostAggregate) Descriptor() ([]byte, []int) {
+	return file_usage_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *CostAggregate) GetDimensions() map[string]string {
+	if x != nil {
+		return x.Dimensions
+	}
+	return nil
+}
+
+func (x *CostAggregate) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *CostAggregate) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+type QueryCostsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Aggregates    []*CostAggregate       `protobuf:"bytes,1,rep,name=aggregates,proto3" json:"aggregates,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryCostsResponse) Reset() {
+	*x = QueryCostsResponse{}
+	mi := &file_usage_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryCostsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryCostsResponse) ProtoMessage() {}
+
+func (x *QueryCostsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_usage_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryCostsResponse.ProtoReflect.Descriptor instead.
+func (*QueryCostsResponse) Descriptor() ([]byte, []int) {
+	return file_usage_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *QueryCostsResponse) GetAggregates() []*CostAggregate {
+	if x != nil {
+		return x.Aggregates
+	}
+	return nil
+}
+
+var File_usage_proto protoreflect.FileDescriptor
+
+const file_usage_proto_rawDesc = "" +
+	"\n" +
+	"\vusage.proto\x12\x18openai_exporter.usage.v1\"\xa7\x01\n" +
+	"\x11QueryUsageRequest\x12\x1d\n" +
+	"\n" +
+	"start_time\x18\x01 \x01(\x03R\tstartTime\x12\x19\n" +
+	"\bend_time\x18\x02 \x01(\x03R\aendTime\x12\x19\n" +
+	"\bgroup_by\x18\x03 \x03(\tR\agroupBy\x12=\n" +
+	"\x06filter\x18\x04 \x01(\v2%.openai_exporter.usage.v1.UsageFilterR\x06filter\"\xc2\x01\n" +
+	"\vUsageFilter\x12\x1e\n" +
+	"\n" +
+	"operations\x18\x01 \x03(\tR\n" +
+	"operations\x12\x1f\n" +
+	"\vproject_ids\x18\x02 \x03(\tR\n" +
+	"projectIds\x12\x19\n" +
+	"\buser_ids\x18\x03 \x03(\tR\auserIds\x12\x1e\n" +
+	"\vapi_key_ids\x18\x04 \x03(\tR\tapiKeyIds\x12\x16\n" +
+	"\x06models\x18\x05 \x03(\tR\x06models\x12\x1f\n" +
+	"\vtoken_types\x18\x06 \x03(\tR\n" +
+	"tokenTypes\"\xdb\x01\n" +
+	"\x0eUsageAggregate\x12X\n" +
+	"\n" +
+	"dimensions\x18\x01 \x03(\v28.openai_exporter.usage.v1.UsageAggregate.DimensionsEntryR\n" +
+	"dimensions\x12\x16\n" +
+	"\x06tokens\x18\x02 \x01(\x01R\x06tokens\x12\x18\n" +
+	"\abuckets\x18\x03 \x01(\x03R\abuckets\x1a=\n" +
+	"\x0fDimensionsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"^\n" +
+	"\x12QueryUsageResponse\x12H\n" +
+	"\n" +
+	"aggregates\x18\x01 \x03(\v2(.openai_exporter.usage.v1.UsageAggregateR\n" +
+	"aggregates\"\x89\x01\n" +
+	"\x11QueryCostsRequest\x12\x1d\n" +
+	"\n" +
+	"start_date\x18\x01 \x01(\tR\tstartDate\x12\x19\n" +
+	"\bend_date\x18\x02 \x01(\tR\aendDate\x12\x19\n" +
+	"\bgroup_by\x18\x03 \x03(\tR\agroupBy\x12\x1f\n" +
+	"\vproject_ids\x18\x04 \x03(\tR\n" +
+	"projectIds\"\xdb\x01\n" +
+	"\rCostAggregate\x12W\n" +
+	"\n" +
+	"dimensions\x18\x01 \x03(\v27.openai_exporter.usage.v1.CostAggregate.DimensionsEntryR\n" +
+	"dimensions\x12\x1a\n" +
+	"\bcurrency\x18\x02 \x01(\tR\bcurrency\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\x01R\x06amount\x1a=\n" +
+	"\x0fDimensionsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"]\n" +
+	"\x12QueryCostsResponse\x12G\n" +
+	"\n" +
+	"aggregates\x18\x01 \x03(\v2'.openai_exporter.usage.v1.CostAggregateR\n" +
+	"aggregates2\xe0\x01\n" +
+	"\fUsageService\x12g\n" +
+	"\n" +
+	"QueryUsage\x12+.openai_exporter.usage.v1.QueryUsageRequest\x1a,.openai_exporter.usage.v1.QueryUsageResponse\x12g\n" +
+	"\n" +
+	"QueryCosts\x12+.openai_exporter.usage.v1.QueryCostsRequest\x1a,.openai_exporter.usage.v1.QueryCostsResponseB-Z+github.com/foxdalas/openai-exporter/usagepbb\x06proto3"
+
+var (
+	file_usage_proto_rawDescOnce sync.Once
+	file_usage_proto_rawDescData []byte
+)
+
+func file_usage_proto_rawDescGZIP() []byte {
+	file_usage_proto_rawDescOnce.Do(func() {
+		file_usage_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_usage_proto_rawDesc), len(file_usage_proto_rawDesc)))
+	})
+	return file_usage_proto_rawDescData
+}
+
+var file_usage_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_usage_proto_goTypes = []any{
+	(*QueryUsageRequest)(nil),  // 0: openai_exporter.usage.v1.QueryUsageRequest
+	(*UsageFilter)(nil),        // 1: openai_exporter.usage.v1.UsageFilter
+	(*UsageAggregate)(nil),     // 2: openai_exporter.usage.v1.UsageAggregate
+	(*QueryUsageResponse)(nil), // 3: openai_exporter.usage.v1.QueryUsageResponse
+	(*QueryCostsRequest)(nil),  // 4: openai_exporter.usage.v1.QueryCostsRequest
+	(*CostAggregate)(nil),      // 5: openai_exporter.usage.v1.CostAggregate
+	(*QueryCostsResponse)(nil), // 6: openai_exporter.usage.v1.QueryCostsResponse
+	nil,                        // 7: openai_exporter.usage.v1.UsageAggregate.DimensionsEntry
+	nil,                        // 8: openai_exporter.usage.v1.CostAggregate.DimensionsEntry
+}
+var file_usage_proto_depIdxs = []int32{
+	1, // 0: openai_exporter.usage.v1.QueryUsageRequest.filter:type_name -> openai_exporter.usage.v1.UsageFilter
+	7, // 1: openai_exporter.usage.v1.UsageAggregate.dimensions:type_name -> openai_exporter.usage.v1.UsageAggregate.DimensionsEntry
+	2, // 2: openai_exporter.usage.v1.QueryUsageResponse.aggregates:type_name -> openai_exporter.usage.v1.UsageAggregate
+	8, // 3: openai_exporter.usage.v1.CostAggregate.dimensions:type_name -> openai_exporter.usage.v1.CostAggregate.DimensionsEntry
+	5, // 4: openai_exporter.usage.v1.QueryCostsResponse.aggregates:type_name -> openai_exporter.usage.v1.CostAggregate
+	0, // 5: openai_exporter.usage.v1.UsageService.QueryUsage:input_type -> openai_exporter.usage.v1.QueryUsageRequest
+	4, // 6: openai_exporter.usage.v1.UsageService.QueryCosts:input_type -> openai_exporter.usage.v1.QueryCostsRequest
+	3, // 7: openai_exporter.usage.v1.UsageService.QueryUsage:output_type -> openai_exporter.usage.v1.QueryUsageResponse
+	6, // 8: openai_exporter.usage.v1.UsageService.QueryCosts:output_type -> openai_exporter.usage.v1.QueryCostsResponse
+	7, // [7:9] is the sub-list for method output_type
+	5, // [5:7] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_usage_proto_init() }
+func file_usage_proto_init() {
+	if File_usage_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_usage_proto_rawDesc), len(file_usage_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_usage_proto_goTypes,
+		DependencyIndexes: file_usage_proto_depIdxs,
+		MessageInfos:      file_usage_proto_msgTypes,
+	}.Build()
+	File_usage_proto = out.File
+	file_usage_proto_goTypes = nil
+	file_usage_proto_depIdxs = nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// User Enrichment
+
+var (
+	resolveUsers         = flag.Bool("openai.resolve-users", false, "Look up the email of each user_id in /v1/organization/users and export it as the user_email label; emails are personal data and left out of the metrics unless enabled")
+	userEmailNegativeTTL = flag.Duration("openai.user-email-negative-ttl", 10*time.Minute, "How long a failed user email lookup is remembered before the user is looked up again")
+)
+
+var (
+	// userEmails maps user_id -> email (or name for users without an email), guarded by stateMu.
+	userEmails = make(map[string]string)
+	// userLookupFailures maps user_id -> time of the last failed email lookup, guarded by stateMu.
+	userLookupFailures = make(map[string]time.Time)
+)
+
+type OrganizationUser struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// ensureUserEmail returns the email for already known users and looks up the email of new ones.
+// It returns an empty string when user lookups are disabled.
+func (e *Exporter) ensureUserEmail(userID string) string {
+	if !*resolveUsers {
+		return ""
+	}
+	if userID == "" || userID == "unknown" {
+		return "unknown"
+	}
+
+	stateMu.RLock()
+	if n, ok := userEmails[userID]; ok && n != "" {
+		stateMu.RUnlock()
+		return n
+	}
+	stateMu.RUnlock()
+
+	now := time.Now()
+	if userLookupFailedRecently(userID, now) {
+		return "unknown"
+	}
+	if !apiBudget.allowOptional(now) {
+		logrus.Debugf("Skipping user lookup for %s to stay within the API budget", userID)
+		return "unknown"
+	}
+
+	path := fmt.Sprintf("/v1/organization/users/%s", userID)
+	logrus.Debugf("Fetching user: %s", path)
+	resp, err := e.get(path)
+	if err != nil {
+		recordUserLookupFailure(userID, now)
+		return "unknown"
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var obj OrganizationUser
+	if err := json.NewDecoder(resp.Body).Decode(&obj); err != nil {
+		recordUserLookupFailure(userID, now)
+		return "unknown"
+	}
+	email := obj.Email
+	if email == "" {
+		email = obj.Name
+	}
+	if email == "" {
+		recordUserLookupFailure(userID, now)
+		return "unknown"
+	}
+
+	stateMu.Lock()
+	userEmails[userID] = email
+	delete(userLookupFailures, userID)
+	stateMu.Unlock()
+	return email
+}
+
+// userLookupFailedRecently reports whether looking up the email of userID failed within the negative TTL.
+func userLookupFailedRecently(userID string, now time.Time) bool {
+	stateMu.RLock()
+	defer stateMu.RUnlock()
+	failedAt, ok := userLookupFailures[userID]
+	return ok && now.Sub(failedAt) < *userEmailNegativeTTL
+}
+
+// recordUserLookupFailure remembers that the email of userID could not be resolved.
+func recordUserLookupFailure(userID string, now time.Time) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	userLookupFailures[userID] = now
+}
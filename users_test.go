@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setResolveUsers enables -openai.resolve-users, restoring it when the test ends.
+func setResolveUsers(t *testing.T) {
+	t.Helper()
+	orig := *resolveUsers
+	t.Cleanup(func() { *resolveUsers = orig })
+	*resolveUsers = true
+}
+
+func TestEnsureUserEmail(t *testing.T) {
+	setResolveUsers(t)
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/v1/organization/users/user-ana":
+			_ = json.NewEncoder(w).Encode(OrganizationUser{ID: "user-ana", Name: "Ana", Email: "ana@example.com"})
+		case "/v1/organization/users/user-noemail":
+			_ = json.NewEncoder(w).Encode(OrganizationUser{ID: "user-noemail", Name: "Build Bot"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error": {"message": "not found"}}`))
+		}
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", targets: newAPITargets("", server.URL, 3)}
+
+	tests := []struct {
+		name    string
+		userID  string
+		enabled bool
+		want    string
+	}{
+		{name: "email", userID: "user-ana", enabled: true, want: "ana@example.com"},
+		{name: "name without email", userID: "user-noemail", enabled: true, want: "Build Bot"},
+		{name: "unknown user", userID: "user-gone", enabled: true, want: "unknown"},
+		{name: "missing user ID", userID: "unknown", enabled: true, want: "unknown"},
+		{name: "disabled", userID: "user-ana", enabled: false, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userEmails = make(map[string]string)
+			userLookupFailures = make(map[string]time.Time)
+			orig := *resolveUsers
+			*resolveUsers = tt.enabled
+			defer func() { *resolveUsers = orig }()
+
+			assert.Equal(t, tt.want, e.ensureUserEmail(tt.userID))
+		})
+	}
+
+	t.Run("cached", func(t *testing.T) {
+		userEmails = map[string]string{"user-ana": "ana@example.com"}
+		requests = 0
+		assert.Equal(t, "ana@example.com", e.ensureUserEmail("user-ana"))
+		assert.Zero(t, requests)
+	})
+
+	t.Run("failed lookups are remembered", func(t *testing.T) {
+		userEmails = make(map[string]string)
+		userLookupFailures = make(map[string]time.Time)
+		requests = 0
+		assert.Equal(t, "unknown", e.ensureUserEmail("user-gone"))
+		assert.Equal(t, "unknown", e.ensureUserEmail("user-gone"))
+		assert.Equal(t, 1, requests, "the failure is cached")
+
+		userLookupFailures["user-gone"] = time.Now().Add(-*userEmailNegativeTTL)
+		assert.Equal(t, "unknown", e.ensureUserEmail("user-gone"))
+		assert.Equal(t, 2, requests, "and looked up again once the negative TTL has passed")
+	})
+}
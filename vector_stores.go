@@ -0,0 +1,61 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Vector Store Storage
+
+// vectorStoresEndpoint reports stored bytes instead of tokens and can only be grouped by project.
+const vectorStoresEndpoint = "vector_stores"
+
+var vectorStoreUsageBytes = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "openai_vector_store_usage_bytes",
+		Help: "Bytes stored in vector stores per organization and project, as of the latest usage bucket",
+	},
+	[]string{"org_id", "org_name", "project_id", "project_name"},
+)
+
+// countVectorStoreUsage fetches the vector store buckets of the given width between startTime and endTime
+// and records their storage.
+func (e *Exporter) countVectorStoreUsage(endpoint UsageEndpoint, startTime, endTime int64, bucketWidth string) error {
+	buckets, err := e.fetchUsageBuckets(endpoint, startTime, endTime, bucketWidth, "project_id")
+	if err != nil {
+		return err
+	}
+	var records int
+	for _, bucket := range buckets {
+		records += len(bucket.Results)
+	}
+	logrus.WithFields(windowFields(e.orgID, endpoint.Name, startTime, endTime)).WithField("records", records).Info("Fetched usage records")
+
+	e.recordVectorStoreUsage(buckets)
+	return nil
+}
+
+// recordVectorStoreUsage sets the storage of each project to its value in the latest bucket that reports it.
+// Storage is a level rather than a count, so buckets are not deduplicated and only the newest value is kept.
+func (e *Exporter) recordVectorStoreUsage(buckets []Bucket) {
+	buckets = slices.Clone(buckets)
+	slices.SortFunc(buckets, func(a, b Bucket) int { return cmp.Compare(a.StartTime, b.StartTime) })
+
+	for _, bucket := range buckets {
+		usage := make(map[string]int64)
+		for _, result := range bucket.Results {
+			usage[deref(result.ProjectID)] += result.UsageBytes
+		}
+		for _, projectID := range sortedKeys(usage) {
+			vectorStoreUsageBytes.With(prometheus.Labels{
+				"org_id":       e.orgID,
+				"org_name":     e.orgName,
+				"project_id":   projectID,
+				"project_name": e.ensureProjectName(projectID),
+			}).Set(float64(usage[projectID]))
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchUsageData_VectorStores(t *testing.T) {
+	projectNames = map[string]string{"proj-1": "one", "proj-2": "two"}
+	vectorStoreUsageBytes.Reset()
+
+	// Buckets are deliberately out of order; the newest one wins.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "project_id", r.URL.Query().Get("group_by"), "vector stores can only be grouped by project")
+		_, _ = w.Write([]byte(`{"object": "page", "data": [
+			{"start_time": 1120, "end_time": 1180, "results": [{"usage_bytes": 4096, "project_id": "proj-1"}]},
+			{"start_time": 1000, "end_time": 1060, "results": [{"usage_bytes": 1024, "project_id": "proj-1"}, {"usage_bytes": 512, "project_id": "proj-2"}]},
+			{"start_time": 1060, "end_time": 1120, "results": []}
+		], "has_more": false}`))
+	}))
+	defer server.Close()
+	e := &Exporter{client: server.Client(), apiKey: "test", orgID: "org-1", orgName: "prod", targets: newAPITargets("org-1", server.URL, 3)}
+
+	require.NoError(t, e.fetchUsageData(UsageEndpoint{Path: "vector_stores", Name: vectorStoresEndpoint}, 1000, 1180))
+
+	assert.Equal(t, 4096.0, testutil.ToFloat64(vectorStoreUsageBytes.WithLabelValues("org-1", "prod", "proj-1", "one")))
+	assert.Equal(t, 512.0, testutil.ToFloat64(vectorStoreUsageBytes.WithLabelValues("org-1", "prod", "proj-2", "two")),
+		"projects keep their last reported storage")
+}
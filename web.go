@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/prometheus/exporter-toolkit/web"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Web Server
+
+var webConfigFile = flag.String("web.config.file", "", "Path to a web configuration file enabling TLS and/or authentication, see https://github.com/prometheus/exporter-toolkit/blob/master/docs/web-configuration.md")
+
+// validateWebConfig checks the web configuration file, if any, so mistakes stop the exporter at startup.
+func validateWebConfig() error {
+	if *webConfigFile == "" {
+		return nil
+	}
+	return web.Validate(*webConfigFile)
+}
+
+// loadWebConfig reads the web configuration file at path, for listeners that exporter-toolkit does not serve.
+// Relative certificate paths are resolved against the directory of the file.
+func loadWebConfig(path string) (*web.Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading web configuration file: %w", err)
+	}
+	cfg := &web.Config{TLSConfig: web.TLSConfig{MinVersion: tls.VersionTLS12, MaxVersion: tls.VersionTLS13}}
+	if err := yaml.Unmarshal(content, cfg); err != nil {
+		return nil, fmt.Errorf("error parsing web configuration file: %w", err)
+	}
+	cfg.TLSConfig.SetDirectory(filepath.Dir(path))
+	return cfg, nil
+}
+
+// tlsEnabled reports whether the web configuration sets up TLS, as opposed to only basic authentication.
+func tlsEnabled(c *web.TLSConfig) bool {
+	return c.TLSCertPath != "" || c.TLSCert != "" || c.TLSKeyPath != "" || c.TLSKey != "" ||
+		c.ClientCAs != "" || c.ClientCAsText != "" || c.ClientAuth != ""
+}
+
+// serve runs server until it is shut down, with TLS and authentication as set up by web.config.file.
+func serve(server *http.Server) {
+	logrus.Infof("Starting server on %s", server.Addr)
+	systemdSocket := false
+	flags := &web.FlagConfig{
+		WebListenAddresses: &[]string{server.Addr},
+		WebSystemdSocket:   &systemdSocket,
+		WebConfigFile:      webConfigFile,
+	}
+	var err error
+	if path, ok := strings.CutPrefix(server.Addr, "unix://"); ok {
+		var l net.Listener
+		if l, err = listenUnix(path); err == nil {
+			err = web.Serve(l, server, flags, slog.Default())
+		}
+	} else {
+		err = web.ListenAndServe(server, flags, slog.Default())
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logrus.Fatal(err)
+	}
+}
+
+// listenUnix listens on the Unix domain socket at path, for sidecars that scrape the exporter without a
+// TCP port. A socket left behind by an exporter that did not shut down cleanly is replaced; the socket is
+// removed again when the server shuts down.
+func listenUnix(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("error removing stale socket: %w", err)
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("error listening on unix socket: %w", err)
+	}
+	return l, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateWebConfig(t *testing.T) {
+	orig := *webConfigFile
+	defer func() { *webConfigFile = orig }()
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{name: "basic auth", content: "basic_auth_users:\n  prometheus: $2y$10$QOauhQNbBCuQDKes6eFzPeMqBSjb7Mr5DUmpZ/VcEd00UAV/LDeSi\n"},
+		{name: "missing certificate", content: "tls_server_config:\n  cert_file: " + filepath.Join(dir, "missing.crt") + "\n  key_file: " + filepath.Join(dir, "missing.key") + "\n", wantErr: true},
+		{name: "unknown key", content: "tls_config: {}\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "web.yml")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0600))
+			*webConfigFile = path
+
+			err := validateWebConfig()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	*webConfigFile = ""
+	assert.NoError(t, validateWebConfig(), "without a file the endpoint is served over plain HTTP")
+}
+
+func TestServeUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exporter.sock")
+	// A socket left behind by an earlier run.
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte("up 1\n")) })
+	server := &http.Server{Addr: "unix://" + path, Handler: mux}
+	go serve(server)
+
+	client := &http.Client{Transport: &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "unix", path)
+	}}}
+	var resp *http.Response
+	require.Eventually(t, func() bool {
+		resp, err = client.Get("http://localhost/metrics")
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "up 1\n", string(body))
+
+	require.NoError(t, server.Shutdown(context.Background()))
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "the socket is removed on shutdown")
+}